@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 
+	"github.com/MereWhiplash/engram-cogitator/internal/embedder"
 	"github.com/MereWhiplash/engram-cogitator/internal/storage"
 )
 
@@ -29,8 +30,22 @@ func buildStorageConfig(driver, dbPath, postgresDSN, mongoURI, mongoDatabase str
 		if mongoURI == "" {
 			return storage.Config{}, fmt.Errorf("mongodb driver requires --mongodb-uri")
 		}
+	case "memory":
+		// Nothing to connect to; --memory-snapshot is optional
 	default:
 		return storage.Config{}, fmt.Errorf("unknown storage driver: %s", driver)
 	}
 	return cfg, nil
 }
+
+// resolveEmbeddingDim returns the explicit --embedding-dim, or the known
+// dimension of the model when none is given
+func resolveEmbeddingDim(model string, dim int) (int, error) {
+	if dim > 0 {
+		return dim, nil
+	}
+	if known := embedder.KnownDimension(model); known > 0 {
+		return known, nil
+	}
+	return 0, fmt.Errorf("unknown dimension for embedding model %q; set --embedding-dim", model)
+}
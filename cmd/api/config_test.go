@@ -24,3 +24,22 @@ func TestBuildStorageConfig_Postgres(t *testing.T) {
 		t.Fatalf("postgres config broken: %v cfg=%+v", err, cfg)
 	}
 }
+
+func TestBuildStorageConfig_Memory(t *testing.T) {
+	cfg, err := buildStorageConfig("memory", "", "", "", "engram")
+	if err != nil || cfg.Driver != "memory" {
+		t.Fatalf("memory config broken: %v cfg=%+v", err, cfg)
+	}
+}
+
+func TestResolveEmbeddingDim(t *testing.T) {
+	if dim, err := resolveEmbeddingDim("mxbai-embed-large", 0); err != nil || dim != 1024 {
+		t.Fatalf("known model: got dim=%d err=%v", dim, err)
+	}
+	if dim, err := resolveEmbeddingDim("my-model", 512); err != nil || dim != 512 {
+		t.Fatalf("explicit dim: got dim=%d err=%v", dim, err)
+	}
+	if _, err := resolveEmbeddingDim("my-model", 0); err == nil {
+		t.Fatal("expected error for unknown model without --embedding-dim")
+	}
+}
@@ -3,6 +3,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"expvar"
 	"flag"
 	"fmt"
 	"log"
@@ -43,18 +45,40 @@ func main() {
 	addr := flag.String("addr", ":8080", "Server address")
 
 	// Storage flags
-	storageDriver := flag.String("storage-driver", "postgres", "Storage driver: sqlite, postgres, mongodb")
+	storageDriver := flag.String("storage-driver", "postgres", "Storage driver: sqlite, postgres, mongodb, memory")
 	dbPath := flag.String("db-path", "", "Path to SQLite database (sqlite driver)")
 	postgresDSN := flag.String("postgres-dsn", "", "PostgreSQL connection string")
 	mongoURI := flag.String("mongodb-uri", "", "MongoDB connection URI")
 	mongoDatabase := flag.String("mongodb-database", "engram", "MongoDB database name")
+	mongoFallbackCandidates := flag.Int("mongodb-fallback-candidates", storage.DefaultMongoFallbackCandidates, "Max memories ranked in-process when Atlas Vector Search is unavailable")
+	memorySnapshot := flag.String("memory-snapshot", "", "File to load memories from on start and save them to on shutdown (memory driver; empty keeps nothing)")
 
 	// Embedder flags
 	ollamaURL := flag.String("ollama-url", "http://localhost:11434", "Ollama API URL")
 	embeddingModel := flag.String("embedding-model", "nomic-embed-text", "Ollama embedding model")
+	embeddingDim := flag.Int("embedding-dim", 0, "Embedding dimension (0 to infer from well-known models)")
 
-	// Migrate flag
+	// Migrate flags
 	migrateOnly := flag.Bool("migrate", false, "Run migrations and exit")
+	migrateStatus := flag.Bool("migrate-status", false, "Print applied and pending migrations and exit")
+	autoMigrate := flag.Bool("auto-migrate", true, "Apply pending migrations on startup (if false, refuse to start until --migrate has run)")
+
+	// Re-embed flags
+	reembed := flag.Bool("reembed", false, "Re-embed every memory with --embedding-model and exit (resumes if interrupted)")
+	reembedDryRun := flag.Bool("reembed-dry-run", false, "Estimate the --reembed work without writing and exit")
+	reembedBatch := flag.Int("reembed-batch-size", 50, "Memories embedded between --reembed progress reports")
+
+	// Expiry flags
+	expirySweep := flag.Duration("expiry-sweep-interval", time.Minute, "How often to invalidate memories past their expires_at (0 to disable)")
+
+	// Usage flags
+	usageFlush := flag.Duration("usage-flush-interval", 30*time.Second, "How often to write buffered retrieval counts to storage (0 disables usage tracking)")
+
+	// Supersede flags
+	supersedeSameRepo := flag.Bool("supersede-same-repo", false, "Reject a superseded_by memory, or merge sources, from another repo than the memory replacing them")
+
+	// Ranking flags
+	rankingPolicy := service.RankingFlags(flag.CommandLine)
 
 	// Rate limiting flags
 	rateLimit := flag.Int("rate-limit", 100, "Requests per minute per IP (0 to disable)")
@@ -62,6 +86,9 @@ func main() {
 	// CORS flags
 	corsOrigins := flag.String("cors-origins", "", "Comma-separated list of allowed CORS origins (empty to disable)")
 
+	// Metrics flags
+	metricsAddr := flag.String("metrics-addr", "", "Address to serve expvar metrics on /debug/vars (empty to disable)")
+
 	// Version flag
 	versionFlag := flag.Bool("version", false, "Print version and exit")
 
@@ -74,35 +101,100 @@ func main() {
 
 	ctx := context.Background()
 
+	ranking, err := rankingPolicy()
+	if err != nil {
+		log.Fatalf("ranking config: %v", err)
+	}
+
 	// Build storage config (sqlite permitted: single shared local api process)
 	cfg, err := buildStorageConfig(*storageDriver, expandPath(*dbPath), *postgresDSN, *mongoURI, *mongoDatabase)
 	if err != nil {
 		log.Fatalf("storage config: %v", err)
 	}
+	cfg.MongoDBFallbackCandidates = *mongoFallbackCandidates
+	cfg.MemorySnapshotPath = expandPath(*memorySnapshot)
 	if cfg.Driver == "sqlite" {
 		if err := os.MkdirAll(filepath.Dir(cfg.SQLitePath), 0o755); err != nil {
 			log.Fatalf("failed to create db dir: %v", err)
 		}
 	}
 
+	// Status reports must not change the schema they describe
+	cfg.NoMigrate = *migrateStatus || (!*autoMigrate && !*migrateOnly)
+
+	cfg.EmbeddingDim, err = resolveEmbeddingDim(*embeddingModel, *embeddingDim)
+	if err != nil {
+		log.Fatalf("embedding config: %v", err)
+	}
+	// Re-embedding is how a mismatched database is fixed, so it skips the check
+	reembedMode := *reembed || *reembedDryRun
+	if !*migrateStatus && !reembedMode {
+		cfg.EmbeddingModel = *embeddingModel
+	}
+
 	// Initialize storage
 	store, err := storage.New(ctx, cfg)
+	if errors.Is(err, storage.ErrEmbeddingMismatch) {
+		log.Fatalf("%v\nStart with the --embedding-model the database was created with, or run with --reembed to migrate it", err)
+	}
 	if err != nil {
 		log.Fatalf("Failed to initialize storage: %v", err)
 	}
-	defer store.Close()
+	// The memory driver writes its snapshot on Close
+	defer func() {
+		if err := store.Close(); err != nil {
+			log.Printf("Failed to close storage: %v", err)
+		}
+	}()
 
-	// If migrate-only, exit now
-	if *migrateOnly {
-		log.Println("Migrations complete")
-		return
+	if *migrateOnly || *migrateStatus || !*autoMigrate {
+		status, err := migrationStatus(ctx, store)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+
+		switch {
+		case *migrateStatus:
+			printMigrationStatus(os.Stdout, cfg.Driver, status)
+			return
+		case *migrateOnly:
+			log.Printf("Migrations complete (schema version %d)", status.Current)
+			return
+		case !status.UpToDate():
+			log.Fatalf("Database has %d pending migration(s); run ec-api --migrate first", len(status.Pending))
+		}
 	}
 
 	// Initialize embedder
 	emb := embedder.NewOllama(*ollamaURL, *embeddingModel)
 
+	if reembedMode {
+		target := storage.EmbeddingSpec{Model: *embeddingModel, Dimension: cfg.EmbeddingDim}
+		if err := runReembed(ctx, store, emb, target, *reembedDryRun, *reembedBatch); err != nil {
+			log.Fatalf("Re-embed failed: %v", err)
+		}
+		return
+	}
+
 	// Create service
 	svc := service.New(store, emb)
+	svc.SetSupersedeSameRepo(*supersedeSameRepo)
+	if err := svc.SetRankingPolicy(ranking); err != nil {
+		log.Fatalf("ranking config: %v", err)
+	}
+
+	// Expire memories in the background until shutdown
+	sweepCtx, stopSweep := context.WithCancel(ctx)
+	defer stopSweep()
+	if *expirySweep > 0 {
+		go svc.RunExpirySweeper(sweepCtx, *expirySweep)
+	}
+
+	// Count retrievals, writing them in batches until shutdown
+	if *usageFlush > 0 {
+		svc.TrackUsage()
+		go svc.RunUsageFlusher(sweepCtx, *usageFlush)
+	}
 
 	// Create handlers
 	handlers := api.NewHandlers(svc)
@@ -147,11 +239,36 @@ func main() {
 	r.Get("/health", handlers.Health)
 	r.Route("/v1", func(r chi.Router) {
 		r.Post("/memories", handlers.Add)
+		r.Post("/memories:batch", handlers.AddBatch)
 		r.Get("/memories", handlers.List)
 		r.Post("/memories/search", handlers.Search)
+		r.Post("/memories/merge", handlers.Merge)
+		r.Post("/memories/invalidate", handlers.BulkInvalidate)
+		r.Get("/memories/review", handlers.Review)
+		r.Get("/memories/stale", handlers.Stale)
+		r.Get("/memories/{id}", handlers.Get)
+		r.Patch("/memories/{id}", handlers.Update)
+		r.Get("/memories/{id}/history", handlers.History)
+		r.Get("/memories/{id}/lineage", handlers.Lineage)
 		r.Put("/memories/{id}/invalidate", handlers.Invalidate)
+		r.Put("/memories/{id}/revalidate", handlers.Revalidate)
+		r.Get("/tags", handlers.Tags)
+		r.Get("/repos", handlers.Repos)
+		r.Get("/areas", handlers.Areas)
+		r.Get("/authors", handlers.Authors)
+		r.Get("/stats", handlers.Stats)
 	})
 
+	// Metrics listen separately so they are never exposed on the public address
+	if *metricsAddr != "" {
+		go func() {
+			log.Printf("Serving metrics on %s/debug/vars", *metricsAddr)
+			if err := http.ListenAndServe(*metricsAddr, expvar.Handler()); err != nil {
+				log.Printf("Metrics server error: %v", err)
+			}
+		}()
+	}
+
 	// Create server
 	srv := &http.Server{
 		Addr:         *addr,
@@ -174,6 +291,7 @@ func main() {
 		if limiter != nil {
 			limiter.Stop()
 		}
+		stopSweep()
 
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
@@ -192,5 +310,8 @@ func main() {
 	}
 
 	<-done
+	if err := svc.FlushUsage(context.Background()); err != nil {
+		log.Printf("Usage flush failed: %v", err)
+	}
 	fmt.Println("Server stopped")
 }
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+)
+
+// migrationStatus reads the schema status of a store opened by storage.New
+func migrationStatus(ctx context.Context, store storage.Storage) (*storage.MigrationStatus, error) {
+	m, ok := store.(storage.Migrator)
+	if !ok {
+		return nil, fmt.Errorf("storage driver does not support migrations")
+	}
+	return m.MigrationStatus(ctx)
+}
+
+// printMigrationStatus writes the --migrate-status report
+func printMigrationStatus(w io.Writer, driver string, status *storage.MigrationStatus) {
+	fmt.Fprintf(w, "Driver:         %s\n", driver)
+	fmt.Fprintf(w, "Schema version: %d (latest known %d)\n", status.Current, status.Latest)
+
+	for _, a := range status.Applied {
+		fmt.Fprintf(w, "  [applied] %3d  %-40s %s\n", a.Version, a.Description, a.AppliedAt.Format("2006-01-02 15:04:05"))
+	}
+	for _, p := range status.Pending {
+		fmt.Fprintf(w, "  [pending] %3d  %s\n", p.Version, p.Description)
+	}
+
+	if status.UpToDate() {
+		fmt.Fprintln(w, "Up to date")
+	} else {
+		fmt.Fprintf(w, "%d pending migration(s); run ec-api --migrate to apply\n", len(status.Pending))
+	}
+}
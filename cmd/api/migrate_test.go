@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+)
+
+func TestPrintMigrationStatus_Pending(t *testing.T) {
+	var buf bytes.Buffer
+	printMigrationStatus(&buf, "sqlite", &storage.MigrationStatus{
+		Current: 1,
+		Latest:  2,
+		Applied: []storage.AppliedMigration{{Version: 1, Description: "create memories", AppliedAt: time.Now()}},
+		Pending: []storage.Migration{{Version: 2, Description: "add revisions"}},
+	})
+
+	out := buf.String()
+	for _, want := range []string{"Schema version: 1 (latest known 2)", "[applied]   1  create memories", "[pending]   2  add revisions", "1 pending migration(s)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestPrintMigrationStatus_UpToDate(t *testing.T) {
+	var buf bytes.Buffer
+	printMigrationStatus(&buf, "postgres", &storage.MigrationStatus{Current: 2, Latest: 2})
+
+	if !strings.Contains(buf.String(), "Up to date") {
+		t.Errorf("expected up to date report, got:\n%s", buf.String())
+	}
+}
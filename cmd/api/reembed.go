@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/embedder"
+	"github.com/MereWhiplash/engram-cogitator/internal/service"
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+)
+
+// runReembed moves every memory to the target embedding model (--reembed)
+func runReembed(ctx context.Context, store storage.Storage, emb embedder.Embedder, target storage.EmbeddingSpec, dryRun bool, batchSize int) error {
+	result, err := service.Reembed(ctx, store, emb, target, service.ReembedOptions{
+		BatchSize: batchSize,
+		DryRun:    dryRun,
+		Progress: func(done, total int) {
+			log.Printf("Re-embedded %d/%d memories", done, total)
+		},
+	})
+	if err != nil {
+		if result != nil && result.Embedded > 0 {
+			log.Printf("Staged %d memories before failing; run --reembed again to resume", result.Embedded)
+		}
+		return err
+	}
+
+	log.Printf("Re-embed %s (%d dimensions) -> %s (%d dimensions)",
+		result.From.Model, result.From.Dimension, result.To.Model, result.To.Dimension)
+	if dryRun {
+		log.Printf("Dry run: %d memories, %d already staged, %d to embed, estimated %s",
+			result.Total, result.Resumed, result.Total-result.Resumed, result.Estimate.Round(time.Second))
+		return nil
+	}
+	log.Printf("Re-embed complete: %d memories embedded (%d resumed from an earlier run)", result.Embedded, result.Resumed)
+	return nil
+}
@@ -0,0 +1,109 @@
+// Command ec-copy copies every memory from one storage backend to another,
+// e.g. from a solo-mode SQLite file to the team Postgres.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+)
+
+// version is set by goreleaser via ldflags
+var version = "dev"
+
+func main() {
+	from := flag.String("from", defaultDBPath(), "Source database: SQLite path, postgres:// DSN or mongodb:// URI")
+	to := flag.String("to", "", "Destination database: SQLite path, postgres:// DSN or mongodb:// URI")
+	fromMongoDatabase := flag.String("from-mongodb-database", "engram", "Source MongoDB database name")
+	toMongoDatabase := flag.String("to-mongodb-database", "engram", "Destination MongoDB database name")
+	batchSize := flag.Int("batch-size", 100, "Memories read from the source at a time")
+	versionFlag := flag.Bool("version", false, "Print version and exit")
+
+	flag.Parse()
+
+	if *versionFlag {
+		fmt.Printf("ec-copy %s\n", version)
+		return
+	}
+	if *to == "" {
+		log.Fatal("--to is required")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, parseTarget(*from, *fromMongoDatabase), parseTarget(*to, *toMongoDatabase), *batchSize); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, srcCfg, dstCfg storage.Config, batchSize int) error {
+	if srcCfg.Driver == "sqlite" {
+		if _, err := os.Stat(srcCfg.SQLitePath); err != nil {
+			return fmt.Errorf("source database: %w", err)
+		}
+	}
+
+	src, err := storage.New(ctx, srcCfg)
+	if err != nil {
+		return fmt.Errorf("failed to open source: %w", err)
+	}
+	defer src.Close()
+
+	// The destination must hold vectors from the same model as the source
+	spec, err := storage.StoredEmbeddingSpec(ctx, src)
+	if err != nil {
+		return fmt.Errorf("failed to read source embedding model: %w", err)
+	}
+	if spec.Model == "" {
+		log.Printf("WARNING: source has no recorded embedding model; assuming %d dimensions", storage.DefaultEmbeddingDim)
+	}
+	dstCfg.EmbeddingModel = spec.Model
+	dstCfg.EmbeddingDim = spec.Dimension
+
+	if dstCfg.Driver == "sqlite" {
+		if err := os.MkdirAll(filepath.Dir(dstCfg.SQLitePath), 0o755); err != nil {
+			return fmt.Errorf("failed to create destination dir: %w", err)
+		}
+	}
+	dst, err := storage.New(ctx, dstCfg)
+	if errors.Is(err, storage.ErrEmbeddingMismatch) {
+		return fmt.Errorf("%w\nRe-embed one side with --reembed so both use the same model, then copy again", err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open destination: %w", err)
+	}
+	defer dst.Close()
+
+	log.Printf("Copying memories from %s to %s", srcCfg.Driver, dstCfg.Driver)
+	result, err := storage.Copy(ctx, src, dst, storage.CopyOptions{
+		BatchSize: batchSize,
+		Progress: func(read int) {
+			log.Printf("Read %d memories", read)
+		},
+	})
+	if err != nil {
+		// Setup and verification failures return no progress to resume
+		if result == nil || errors.Is(err, storage.ErrCopyMismatch) {
+			return err
+		}
+		// Everything imported so far is kept; running again resumes
+		return fmt.Errorf("copy stopped after reading %d memories (run again to resume): %w", result.Total, err)
+	}
+
+	log.Printf("Copied %d of %d memories (%d already copied by an earlier run), linked %d supersessions",
+		result.Copied, result.Total, result.Resumed, result.Linked)
+	if result.Unlinked > 0 {
+		log.Printf("WARNING: %d memories were superseded by a memory missing from the source; their links were dropped", result.Unlinked)
+	}
+	log.Printf("Verified: destination matches all %d source memories", result.Total)
+	return nil
+}
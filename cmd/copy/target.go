@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+)
+
+// defaultDBPath returns the solo-mode database path (~/.engram/memory.db)
+func defaultDBPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".engram/memory.db" // fallback to local
+	}
+	return filepath.Join(home, ".engram", "memory.db")
+}
+
+// expandPath expands ~ to home directory in paths
+func expandPath(path string) string {
+	if len(path) > 0 && path[0] == '~' {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// parseTarget builds a storage config from a connection string, detecting
+// the driver the same way install.sh does: postgres:// and mongodb:// URIs
+// select those drivers, anything else is a SQLite path
+func parseTarget(target, mongoDatabase string) storage.Config {
+	switch {
+	case strings.HasPrefix(target, "postgres://"), strings.HasPrefix(target, "postgresql://"):
+		return storage.Config{Driver: "postgres", PostgresDSN: target}
+	case strings.HasPrefix(target, "mongodb://"), strings.HasPrefix(target, "mongodb+srv://"):
+		return storage.Config{Driver: "mongodb", MongoDBURI: target, MongoDBDatabase: mongoDatabase}
+	default:
+		return storage.Config{Driver: "sqlite", SQLitePath: expandPath(target)}
+	}
+}
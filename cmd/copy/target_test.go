@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		driver string
+	}{
+		{"/tmp/memory.db", "sqlite"},
+		{"postgres://u:p@host:5432/engram", "postgres"},
+		{"postgresql://host/engram", "postgres"},
+		{"mongodb://host:27017", "mongodb"},
+		{"mongodb+srv://cluster.example.net", "mongodb"},
+	}
+
+	for _, tt := range tests {
+		cfg := parseTarget(tt.target, "engram")
+		if cfg.Driver != tt.driver {
+			t.Errorf("parseTarget(%q).Driver = %q, want %q", tt.target, cfg.Driver, tt.driver)
+		}
+	}
+
+	if cfg := parseTarget("mongodb://host", "team"); cfg.MongoDBDatabase != "team" || cfg.MongoDBURI != "mongodb://host" {
+		t.Errorf("expected mongodb URI and database to be set, got %+v", cfg)
+	}
+	if cfg := parseTarget("postgres://host/engram", ""); cfg.PostgresDSN != "postgres://host/engram" {
+		t.Errorf("expected postgres DSN to be set, got %+v", cfg)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -9,6 +10,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
@@ -45,11 +47,13 @@ func expandPath(path string) string {
 
 func main() {
 	// Storage flags
-	storageDriver := flag.String("storage-driver", "sqlite", "Storage driver: sqlite, postgres, mongodb")
+	storageDriver := flag.String("storage-driver", "sqlite", "Storage driver: sqlite, postgres, mongodb, memory")
 	dbPath := flag.String("db-path", defaultDBPath(), "Path to SQLite database (sqlite driver)")
 	postgresDSN := flag.String("postgres-dsn", "", "PostgreSQL connection string (postgres driver)")
 	mongoURI := flag.String("mongodb-uri", "", "MongoDB connection URI (mongodb driver)")
 	mongoDatabase := flag.String("mongodb-database", "engram", "MongoDB database name (mongodb driver)")
+	mongoFallbackCandidates := flag.Int("mongodb-fallback-candidates", storage.DefaultMongoFallbackCandidates, "Max memories ranked in-process when Atlas Vector Search is unavailable (mongodb driver)")
+	memorySnapshot := flag.String("memory-snapshot", "", "File to load memories from on start and save them to on exit (memory driver; empty keeps nothing)")
 
 	// Project context flags
 	repoFlag := flag.String("repo", "", "Project identity (auto-detected from git remote if not set)")
@@ -57,10 +61,25 @@ func main() {
 	// Embedder flags
 	ollamaURL := flag.String("ollama-url", "http://ollama:11434", "Ollama API URL")
 	embeddingModel := flag.String("embedding-model", "nomic-embed-text", "Ollama embedding model")
+	embeddingDim := flag.Int("embedding-dim", 0, "Embedding dimension (0 to infer from well-known models)")
+
+	// Ranking flags
+	rankingPolicy := service.RankingFlags(flag.CommandLine)
+
+	// Expiry flags
+	expirySweep := flag.Duration("expiry-sweep-interval", time.Minute, "How often to invalidate memories past their expires_at (0 to disable)")
+
+	// Usage flags
+	usageFlush := flag.Duration("usage-flush-interval", 30*time.Second, "How often to write buffered retrieval counts to storage (0 disables usage tracking)")
 
 	// CLI mode flags
 	listFlag := flag.Bool("list", false, "List recent memories (CLI mode)")
 	limitFlag := flag.Int("limit", 5, "Limit for list operation")
+	statsFlag := flag.Bool("stats", false, "Print memory counts by repo, area, type and author, and growth over time (CLI mode; --repo limits them to one repo)")
+	statsPeriod := flag.String("stats-period", "month", "Growth bucket for --stats: day, week, or month")
+	reembedFlag := flag.Bool("reembed", false, "Re-embed every memory with --embedding-model and exit (resumes if interrupted)")
+	reembedDryRun := flag.Bool("reembed-dry-run", false, "Estimate the --reembed work without writing and exit")
+	reembedBatch := flag.Int("reembed-batch-size", 50, "Memories embedded between --reembed progress reports")
 	versionFlag := flag.Bool("version", false, "Print version and exit")
 
 	flag.Parse()
@@ -72,9 +91,23 @@ func main() {
 
 	ctx := context.Background()
 
+	ranking, err := rankingPolicy()
+	if err != nil {
+		log.Fatalf("Invalid ranking config: %v", err)
+	}
+
 	// Expand paths
 	expandedDBPath := expandPath(*dbPath)
 
+	// Resolve embedding dimension
+	dim := *embeddingDim
+	if dim <= 0 {
+		dim = embedder.KnownDimension(*embeddingModel)
+	}
+	if dim == 0 {
+		log.Fatalf("Unknown dimension for embedding model %q; set --embedding-dim", *embeddingModel)
+	}
+
 	// Build storage config
 	cfg := storage.Config{
 		Driver:          *storageDriver,
@@ -82,6 +115,11 @@ func main() {
 		PostgresDSN:     *postgresDSN,
 		MongoDBURI:      *mongoURI,
 		MongoDBDatabase: *mongoDatabase,
+		EmbeddingModel:  *embeddingModel,
+		EmbeddingDim:    dim,
+
+		MongoDBFallbackCandidates: *mongoFallbackCandidates,
+		MemorySnapshotPath:        expandPath(*memorySnapshot),
 	}
 
 	// Ensure parent directory exists for SQLite
@@ -100,6 +138,25 @@ func main() {
 		return
 	}
 
+	// CLI mode - memory statistics
+	if *statsFlag {
+		if err := runStats(ctx, cfg, *repoFlag, *statsPeriod); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// CLI mode - re-embed memories with a new model
+	if *reembedFlag || *reembedDryRun {
+		emb := embedder.NewOllama(*ollamaURL, *embeddingModel)
+		if err := runReembed(ctx, cfg, emb, *reembedDryRun, *reembedBatch); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Auto-detect project identity if not provided
 	repo := *repoFlag
 	if repo == "" {
@@ -109,16 +166,27 @@ func main() {
 
 	// Initialize storage
 	store, err := storage.New(ctx, cfg)
+	if errors.Is(err, storage.ErrEmbeddingMismatch) {
+		log.Fatalf("%v\nStart with the --embedding-model the database was created with, or run with --reembed to migrate it", err)
+	}
 	if err != nil {
 		log.Fatalf("Failed to initialize storage: %v", err)
 	}
-	defer store.Close()
+	// The memory driver writes its snapshot on Close
+	defer func() {
+		if err := store.Close(); err != nil {
+			log.Printf("Failed to close storage: %v", err)
+		}
+	}()
 
 	// Initialize embedder
 	emb := embedder.NewOllama(*ollamaURL, *embeddingModel)
 
 	// Create service
 	svc := service.New(store, emb)
+	if err := svc.SetRankingPolicy(ranking); err != nil {
+		log.Fatalf("Invalid ranking config: %v", err)
+	}
 
 	// Create MCP server
 	server := mcp.NewServer(&mcp.Implementation{
@@ -142,9 +210,24 @@ func main() {
 		cancel()
 	}()
 
+	// Expire memories in the background until shutdown
+	if *expirySweep > 0 {
+		go svc.RunExpirySweeper(ctx, *expirySweep)
+	}
+
+	// Count retrievals, writing them in batches until shutdown
+	if *usageFlush > 0 {
+		svc.TrackUsage()
+		go svc.RunUsageFlusher(ctx, *usageFlush)
+	}
+
 	// Start server with stdio transport
 	log.Println("Starting Engram Cogitator MCP server...")
-	if err := server.Run(ctx, &mcp.StdioTransport{}); err != nil {
+	err = server.Run(ctx, &mcp.StdioTransport{})
+	if flushErr := svc.FlushUsage(context.Background()); flushErr != nil {
+		log.Printf("Usage flush failed: %v", flushErr)
+	}
+	if err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/embedder"
+	"github.com/MereWhiplash/engram-cogitator/internal/service"
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+)
+
+// runReembed moves every memory to the configured embedding model (--reembed)
+func runReembed(ctx context.Context, cfg storage.Config, emb embedder.Embedder, dryRun bool, batchSize int) error {
+	target := storage.EmbeddingSpec{Model: cfg.EmbeddingModel, Dimension: cfg.EmbeddingDim}
+
+	// Re-embedding is how a mismatched database is fixed, so skip the check
+	cfg.EmbeddingModel = ""
+	store, err := storage.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer store.Close()
+
+	result, err := service.Reembed(ctx, store, emb, target, service.ReembedOptions{
+		BatchSize: batchSize,
+		DryRun:    dryRun,
+		Progress: func(done, total int) {
+			log.Printf("Re-embedded %d/%d memories", done, total)
+		},
+	})
+	if err != nil {
+		if result != nil && result.Embedded > 0 {
+			log.Printf("Staged %d memories before failing; run --reembed again to resume", result.Embedded)
+		}
+		return err
+	}
+
+	log.Printf("Re-embed %s (%d dimensions) -> %s (%d dimensions)",
+		result.From.Model, result.From.Dimension, result.To.Model, result.To.Dimension)
+	if dryRun {
+		log.Printf("Dry run: %d memories, %d already staged, %d to embed, estimated %s",
+			result.Total, result.Resumed, result.Total-result.Resumed, result.Estimate.Round(time.Second))
+		return nil
+	}
+	log.Printf("Re-embed complete: %d memories embedded (%d resumed from an earlier run)", result.Embedded, result.Resumed)
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// runStats prints memory counts for repo, or every repo if it is empty
+func runStats(ctx context.Context, cfg storage.Config, repo, period string) error {
+	p := types.StatsPeriod(period)
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	store, err := storage.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer store.Close()
+
+	stats, err := store.Stats(ctx, types.StatsOpts{Repo: repo, Period: p})
+	if err != nil {
+		return fmt.Errorf("failed to count memories: %w", err)
+	}
+
+	printStats(os.Stdout, stats)
+	return nil
+}
+
+// printStats writes stats as aligned tables
+func printStats(out io.Writer, stats *types.Stats) {
+	fmt.Fprintf(out, "%d memories: %d valid, %d invalid\n", stats.Total, stats.Valid, stats.Invalid)
+	if stats.Total == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, g := range []struct {
+		title  string
+		counts []types.StatCount
+	}{
+		{"repo", stats.ByRepo},
+		{"area", stats.ByArea},
+		{"type", stats.ByType},
+		{"author", stats.ByAuthor},
+	} {
+		fmt.Fprintf(w, "\nBy %s:\tvalid\tinvalid\n", g.title)
+		for _, c := range g.counts {
+			value := c.Value
+			if value == "" {
+				value = "(none)"
+			}
+			fmt.Fprintf(w, "  %s\t%d\t%d\n", value, c.Valid, c.Invalid)
+		}
+	}
+
+	format := time.DateOnly
+	if stats.Period == types.PeriodMonth {
+		format = "2006-01"
+	}
+	fmt.Fprintf(w, "\nAdded per %s:\tadded\ttotal\n", stats.Period)
+	for _, b := range stats.Growth {
+		fmt.Fprintf(w, "  %s\t%d\t%d\n", b.Start.Format(format), b.Added, b.Total)
+	}
+	w.Flush()
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/apitypes"
+)
+
+// statusCodes maps each error status the API sends to its error code
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          apitypes.CodeValidation,
+	http.StatusNotFound:            apitypes.CodeNotFound,
+	http.StatusConflict:            apitypes.CodeConflict,
+	http.StatusUnprocessableEntity: apitypes.CodeInvalidSupersede,
+	http.StatusTooManyRequests:     apitypes.CodeRateLimited,
+	http.StatusInternalServerError: apitypes.CodeInternal,
+	http.StatusServiceUnavailable:  apitypes.CodeEmbedderUnavailable,
+}
+
+// codeStatuses maps each error code back to its status
+var codeStatuses = func() map[string]int {
+	m := make(map[string]int, len(statusCodes))
+	for status, code := range statusCodes {
+		m[code] = status
+	}
+	return m
+}()
+
+// respondServiceError responds to an error from the service. Errors from
+// types get their own status and code; anything else is logged and reported
+// as msg, a "failed to ..." message, with a 500.
+func (h *Handlers) respondServiceError(w http.ResponseWriter, r *http.Request, operation string, err error, msg string) {
+	code := apitypes.ErrorCode(err)
+	switch code {
+	case apitypes.CodeInternal:
+		h.logError(r, operation, err)
+		h.respondError(w, http.StatusInternalServerError, msg)
+	case apitypes.CodeNotFound:
+		h.respondError(w, http.StatusNotFound, "memory not found")
+	case apitypes.CodeEmbedderUnavailable:
+		// The embedder's own error may name internal hosts, so it is only logged
+		h.logError(r, operation, err)
+		h.respondError(w, http.StatusServiceUnavailable, msg+": embedder unavailable")
+	default:
+		h.respondError(w, codeStatuses[code], err.Error())
+	}
+}
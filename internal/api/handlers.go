@@ -2,12 +2,15 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
@@ -41,7 +44,7 @@ func (h *Handlers) respondJSON(w http.ResponseWriter, status int, data interface
 }
 
 func (h *Handlers) respondError(w http.ResponseWriter, status int, msg string) {
-	h.respondJSON(w, status, apitypes.ErrorResponse{Error: msg})
+	h.respondJSON(w, status, apitypes.ErrorResponse{Error: msg, Code: statusCodes[status]})
 }
 
 // logError logs an error with request context
@@ -75,6 +78,22 @@ func (h *Handlers) Add(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, err := types.NormalizeTags(req.Tags); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	expiresAt, err := parseDate(req.ExpiresAt)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	reviewBy, err := parseDate(req.ReviewBy)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	ctx := r.Context()
 
 	// Create memory with git context
@@ -86,16 +105,101 @@ func (h *Handlers) Add(w http.ResponseWriter, r *http.Request) {
 		AuthorName:  GetAuthorName(ctx),
 		AuthorEmail: GetAuthorEmail(ctx),
 		Repo:        GetRepo(ctx),
+		Tags:        req.Tags,
+		ExpiresAt:   expiresAt,
+		ReviewBy:    reviewBy,
 	})
 	if err != nil {
-		h.logError(r, "add", err)
-		h.respondError(w, http.StatusInternalServerError, "failed to create memory")
+		h.respondServiceError(w, r, "add", err, "failed to create memory")
 		return
 	}
 
 	h.respondJSON(w, http.StatusCreated, apitypes.AddResponse{Memory: mem})
 }
 
+// AddBatch handles POST /v1/memories:batch. Memories that fail validation
+// are reported in their result and the rest are added in one transaction;
+// the response is 200 with a result per memory unless the whole batch fails.
+func (h *Handlers) AddBatch(w http.ResponseWriter, r *http.Request) {
+	var req apitypes.BatchAddRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if len(req.Memories) == 0 {
+		h.respondError(w, http.StatusBadRequest, "memories are required")
+		return
+	}
+	if len(req.Memories) > service.MaxBatchSize {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("at most %d memories can be added at once", service.MaxBatchSize))
+		return
+	}
+
+	ctx := r.Context()
+
+	results := make([]apitypes.BatchAddResult, len(req.Memories))
+	var (
+		params []service.AddParams
+		index  []int // index[i] is the memory params[i] came from
+	)
+	for i, m := range req.Memories {
+		p, err := batchAddParams(ctx, m)
+		if err != nil {
+			results[i] = apitypes.BatchAddResult{Error: err.Error(), Code: apitypes.CodeValidation}
+			continue
+		}
+		params = append(params, p)
+		index = append(index, i)
+	}
+
+	added, err := h.svc.AddBatch(ctx, params)
+	if err != nil {
+		h.respondServiceError(w, r, "add batch", err, "failed to create memories")
+		return
+	}
+
+	resp := apitypes.BatchAddResponse{Results: results}
+	for i, res := range added {
+		if res.Err != nil {
+			resp.Results[index[i]] = apitypes.BatchAddResult{Error: res.Err.Error(), Code: apitypes.ErrorCode(res.Err)}
+			continue
+		}
+		resp.Results[index[i]].Memory = res.Memory
+		resp.Added++
+	}
+	h.respondJSON(w, http.StatusOK, resp)
+}
+
+// batchAddParams checks one memory of a batch as Add checks its request and
+// returns its service parameters
+func batchAddParams(ctx context.Context, req apitypes.AddRequest) (service.AddParams, error) {
+	if req.Type == "" || req.Area == "" || req.Content == "" {
+		return service.AddParams{}, errors.New("type, area, and content are required")
+	}
+	expiresAt, err := parseDate(req.ExpiresAt)
+	if err != nil {
+		return service.AddParams{}, err
+	}
+	reviewBy, err := parseDate(req.ReviewBy)
+	if err != nil {
+		return service.AddParams{}, err
+	}
+
+	return service.AddParams{
+		Type:        req.Type,
+		Area:        req.Area,
+		Content:     req.Content,
+		Rationale:   req.Rationale,
+		AuthorName:  GetAuthorName(ctx),
+		AuthorEmail: GetAuthorEmail(ctx),
+		Repo:        GetRepo(ctx),
+		Tags:        req.Tags,
+		ExpiresAt:   expiresAt,
+		ReviewBy:    reviewBy,
+	}, nil
+}
+
 // Search handles POST /v1/memories/search
 func (h *Handlers) Search(w http.ResponseWriter, r *http.Request) {
 	var req apitypes.SearchRequest
@@ -109,6 +213,17 @@ func (h *Handlers) Search(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := types.SearchMode(req.Mode).Validate(); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tags := types.TagFilter{Any: req.TagsAny, All: req.TagsAll}
+	if _, err := tags.Normalize(); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	limit := req.Limit
 	if limit <= 0 {
 		limit = 5
@@ -122,10 +237,14 @@ func (h *Handlers) Search(w http.ResponseWriter, r *http.Request) {
 		repo = GetRepo(ctx)
 	}
 
-	memories, err := h.svc.SearchWithRepo(ctx, req.Query, limit, req.Type, req.Area, repo)
+	rank := types.RankingOverride{
+		RecencyWeight:       req.RecencyWeight,
+		RecencyHalfLifeDays: req.RecencyHalfLifeDays,
+		UsageWeight:         req.UsageWeight,
+	}
+	memories, err := h.svc.SearchWithRepo(ctx, req.Query, limit, req.Type, req.Area, repo, req.Mode, tags, rank)
 	if err != nil {
-		h.logError(r, "search", err)
-		h.respondError(w, http.StatusInternalServerError, "failed to search memories")
+		h.respondServiceError(w, r, "search", err, "failed to search memories")
 		return
 	}
 
@@ -148,11 +267,30 @@ func (h *Handlers) List(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	var after *types.ListCursor
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		parsed, err := types.ParseListCursor(c)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		after = parsed
+	}
+
 	memType := r.URL.Query().Get("type")
 	area := r.URL.Query().Get("area")
 	repo := r.URL.Query().Get("repo")
 	includeInvalid := r.URL.Query().Get("include_invalid") == "true"
 
+	tags := types.TagFilter{
+		Any: splitTags(r.URL.Query().Get("tags_any")),
+		All: splitTags(r.URL.Query().Get("tags_all")),
+	}
+	if _, err := tags.Normalize(); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	ctx := r.Context()
 
 	// Fall back to the X-EC-Repo header so shim-mode stays repo-scoped; query wins.
@@ -161,32 +299,343 @@ func (h *Handlers) List(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Request one extra to determine if there are more results
-	memories, err := h.svc.ListWithRepo(ctx, limit+1, memType, area, repo, includeInvalid, offset)
+	memories, err := h.svc.ListWithRepo(ctx, limit+1, memType, area, repo, includeInvalid, offset, after, tags)
 	if err != nil {
-		h.logError(r, "list", err)
-		h.respondError(w, http.StatusInternalServerError, "failed to list memories")
+		h.respondServiceError(w, r, "list", err, "failed to list memories")
 		return
 	}
 
-	hasMore := len(memories) > limit
-	if hasMore {
+	pagination := &apitypes.PaginationInfo{Limit: limit, Offset: offset}
+	if len(memories) > limit {
 		memories = memories[:limit]
+		pagination.HasMore = true
+		pagination.NextCursor = types.CursorAfter(memories[limit-1]).Encode()
 	}
 
 	h.respondJSON(w, http.StatusOK, apitypes.ListResponse{
-		Memories: memories,
-		Pagination: &apitypes.PaginationInfo{
-			Limit:   limit,
-			Offset:  offset,
-			HasMore: hasMore,
-		},
+		Memories:   memories,
+		Pagination: pagination,
 	})
 }
 
+// splitTags splits a comma-separated tags query parameter
+func splitTags(param string) []string {
+	if param == "" {
+		return nil
+	}
+	return strings.Split(param, ",")
+}
+
+// parseDate parses an optional date from a request; empty means none
+func parseDate(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := types.ParseDate(s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// parseDateChange parses a date update, where "" clears the date (returned
+// as the zero time) and nil leaves it unchanged
+func parseDateChange(s *string) (*time.Time, error) {
+	if s == nil {
+		return nil, nil
+	}
+	if *s == "" {
+		return &time.Time{}, nil
+	}
+	return parseDate(*s)
+}
+
+// Review handles GET /v1/memories/review
+func (h *Handlers) Review(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	ctx := r.Context()
+
+	// Fall back to the X-EC-Repo header so shim-mode stays repo-scoped; query wins.
+	repo := r.URL.Query().Get("repo")
+	if repo == "" {
+		repo = GetRepo(ctx)
+	}
+
+	memories, err := h.svc.DueForReview(ctx, repo, limit)
+	if err != nil {
+		h.respondServiceError(w, r, "review", err, "failed to list memories due for review")
+		return
+	}
+	if memories == nil {
+		memories = []types.Memory{}
+	}
+
+	h.respondJSON(w, http.StatusOK, apitypes.ReviewResponse{Memories: memories})
+}
+
+// Stale handles GET /v1/memories/stale
+func (h *Handlers) Stale(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+	minAgeDays := 0
+	if d := r.URL.Query().Get("min_age_days"); d != "" {
+		parsed, err := strconv.Atoi(d)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "invalid min_age_days")
+			return
+		}
+		minAgeDays = parsed
+	}
+
+	ctx := r.Context()
+
+	// Fall back to the X-EC-Repo header so shim-mode stays repo-scoped; query wins.
+	repo := r.URL.Query().Get("repo")
+	if repo == "" {
+		repo = GetRepo(ctx)
+	}
+
+	memories, err := h.svc.StaleCandidates(ctx, repo, minAgeDays, limit)
+	if err != nil {
+		h.respondServiceError(w, r, "stale", err, "failed to list stale memories")
+		return
+	}
+	if memories == nil {
+		memories = []types.Memory{}
+	}
+
+	h.respondJSON(w, http.StatusOK, apitypes.StaleResponse{Memories: memories})
+}
+
+// Tags handles GET /v1/tags
+func (h *Handlers) Tags(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Fall back to the X-EC-Repo header so shim-mode stays repo-scoped; query wins.
+	repo := r.URL.Query().Get("repo")
+	if repo == "" {
+		repo = GetRepo(ctx)
+	}
+
+	tags, err := h.svc.Tags(ctx, repo)
+	if err != nil {
+		h.respondServiceError(w, r, "tags", err, "failed to list tags")
+		return
+	}
+	if tags == nil {
+		tags = []types.TagCount{}
+	}
+
+	h.respondJSON(w, http.StatusOK, apitypes.TagsResponse{Tags: tags})
+}
+
+// Repos handles GET /v1/repos, which covers every repo regardless of the
+// caller's
+func (h *Handlers) Repos(w http.ResponseWriter, r *http.Request) {
+	repos, ok := h.catalog(w, r, types.CatalogRepo, "")
+	if !ok {
+		return
+	}
+	h.respondJSON(w, http.StatusOK, apitypes.ReposResponse{Repos: repos})
+}
+
+// Areas handles GET /v1/areas
+func (h *Handlers) Areas(w http.ResponseWriter, r *http.Request) {
+	// Fall back to the X-EC-Repo header so shim-mode stays repo-scoped; query wins.
+	repo := r.URL.Query().Get("repo")
+	if repo == "" {
+		repo = GetRepo(r.Context())
+	}
+
+	areas, ok := h.catalog(w, r, types.CatalogArea, repo)
+	if !ok {
+		return
+	}
+	h.respondJSON(w, http.StatusOK, apitypes.AreasResponse{Areas: areas})
+}
+
+// Authors handles GET /v1/authors
+func (h *Handlers) Authors(w http.ResponseWriter, r *http.Request) {
+	// Fall back to the X-EC-Repo header so shim-mode stays repo-scoped; query wins.
+	repo := r.URL.Query().Get("repo")
+	if repo == "" {
+		repo = GetRepo(r.Context())
+	}
+
+	authors, ok := h.catalog(w, r, types.CatalogAuthor, repo)
+	if !ok {
+		return
+	}
+	h.respondJSON(w, http.StatusOK, apitypes.AuthorsResponse{Authors: authors})
+}
+
+// catalog lists the values of field, responding with the error and
+// returning false if that fails
+func (h *Handlers) catalog(w http.ResponseWriter, r *http.Request, field types.CatalogField, repo string) ([]types.CatalogEntry, bool) {
+	entries, err := h.svc.Catalog(r.Context(), field, repo)
+	if err != nil {
+		h.respondServiceError(w, r, "catalog", err, fmt.Sprintf("failed to list %ss", field))
+		return nil, false
+	}
+	if entries == nil {
+		entries = []types.CatalogEntry{}
+	}
+	return entries, true
+}
+
+// Stats handles GET /v1/stats
+func (h *Handlers) Stats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Fall back to the X-EC-Repo header so shim-mode stays repo-scoped; query wins.
+	repo := r.URL.Query().Get("repo")
+	if repo == "" {
+		repo = GetRepo(ctx)
+	}
+
+	stats, err := h.svc.Stats(ctx, repo, r.URL.Query().Get("period"))
+	if err != nil {
+		h.respondServiceError(w, r, "stats", err, "failed to count memories")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, apitypes.StatsResponse{Stats: stats})
+}
+
+// parseMemoryID extracts the {id} URL parameter
+func parseMemoryID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+}
+
+// Get handles GET /v1/memories/:id
+func (h *Handlers) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMemoryID(r)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid memory ID")
+		return
+	}
+
+	mem, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		h.respondServiceError(w, r, "get", err, "failed to get memory")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, apitypes.GetResponse{Memory: mem})
+}
+
+// Update handles PATCH /v1/memories/:id
+func (h *Handlers) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMemoryID(r)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid memory ID")
+		return
+	}
+
+	var req apitypes.UpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Type == nil && req.Area == nil && req.Content == nil && req.Rationale == nil &&
+		req.ExpiresAt == nil && req.ReviewBy == nil {
+		h.respondError(w, http.StatusBadRequest, "at least one of type, area, content, rationale, expires_at, or review_by is required")
+		return
+	}
+	if req.Type != nil {
+		if err := types.MemoryType(*req.Type).Validate(); err != nil {
+			h.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if (req.Area != nil && *req.Area == "") || (req.Content != nil && *req.Content == "") {
+		h.respondError(w, http.StatusBadRequest, "area and content cannot be empty")
+		return
+	}
+	expiresAt, err := parseDateChange(req.ExpiresAt)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	reviewBy, err := parseDateChange(req.ReviewBy)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+
+	mem, err := h.svc.Update(ctx, service.UpdateParams{
+		ID:              id,
+		Type:            req.Type,
+		Area:            req.Area,
+		Content:         req.Content,
+		Rationale:       req.Rationale,
+		ExpiresAt:       expiresAt,
+		ReviewBy:        reviewBy,
+		ExpectedVersion: req.ExpectedVersion,
+		EditorName:      GetAuthorName(ctx),
+		EditorEmail:     GetAuthorEmail(ctx),
+	})
+	if err != nil {
+		h.respondServiceError(w, r, "update", err, "failed to update memory")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, apitypes.UpdateResponse{Memory: mem})
+}
+
+// History handles GET /v1/memories/:id/history
+func (h *Handlers) History(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMemoryID(r)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid memory ID")
+		return
+	}
+
+	mem, revisions, err := h.svc.History(r.Context(), id)
+	if err != nil {
+		h.respondServiceError(w, r, "history", err, "failed to get memory history")
+		return
+	}
+	if revisions == nil {
+		revisions = []types.Revision{}
+	}
+
+	h.respondJSON(w, http.StatusOK, apitypes.HistoryResponse{Memory: mem, Revisions: revisions})
+}
+
+// Lineage handles GET /v1/memories/:id/lineage
+func (h *Handlers) Lineage(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMemoryID(r)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid memory ID")
+		return
+	}
+
+	lineage, err := h.svc.Lineage(r.Context(), id)
+	if err != nil {
+		h.respondServiceError(w, r, "lineage", err, "failed to get memory lineage")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, apitypes.LineageResponse{Lineage: *lineage})
+}
+
 // Invalidate handles PUT /v1/memories/:id/invalidate
 func (h *Handlers) Invalidate(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	id, err := parseMemoryID(r)
 	if err != nil {
 		h.respondError(w, http.StatusBadRequest, "invalid memory ID")
 		return
@@ -202,13 +651,15 @@ func (h *Handlers) Invalidate(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
-	if err := h.svc.Invalidate(ctx, id, req.SupersededBy); err != nil {
-		if errors.Is(err, types.ErrNotFound) {
-			h.respondError(w, http.StatusNotFound, "memory not found")
-			return
-		}
-		h.logError(r, "invalidate", err)
-		h.respondError(w, http.StatusInternalServerError, "failed to invalidate memory")
+	err = h.svc.Invalidate(ctx, types.Invalidation{
+		ID:           id,
+		SupersededBy: req.SupersededBy,
+		Reason:       req.Reason,
+		ByName:       GetAuthorName(ctx),
+		ByEmail:      GetAuthorEmail(ctx),
+	})
+	if err != nil {
+		h.respondServiceError(w, r, "invalidate", err, "failed to invalidate memory")
 		return
 	}
 
@@ -219,3 +670,129 @@ func (h *Handlers) Invalidate(w http.ResponseWriter, r *http.Request) {
 
 	h.respondJSON(w, http.StatusOK, apitypes.InvalidateResponse{Message: msg})
 }
+
+// BulkInvalidate handles POST /v1/memories/invalidate
+func (h *Handlers) BulkInvalidate(w http.ResponseWriter, r *http.Request) {
+	var req apitypes.BulkInvalidateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	createdBefore, err := parseDate(req.CreatedBefore)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter := types.MemoryFilter{
+		Repo:          req.Repo,
+		Area:          req.Area,
+		Type:          types.MemoryType(req.Type),
+		Author:        req.Author,
+		CreatedBefore: createdBefore,
+	}
+	// Checked before the header fills in the repo, so a request without
+	// filters can't retire the caller's whole repo
+	if filter.IsZero() {
+		h.respondError(w, http.StatusBadRequest, "at least one of repo, area, type, author or created_before is required")
+		return
+	}
+
+	ctx := r.Context()
+
+	if filter.Repo == "" {
+		filter.Repo = GetRepo(ctx)
+	}
+
+	ids, err := h.svc.InvalidateMatching(ctx, types.BulkInvalidation{
+		Filter:       filter,
+		SupersededBy: req.SupersededBy,
+		Reason:       req.Reason,
+		ByName:       GetAuthorName(ctx),
+		ByEmail:      GetAuthorEmail(ctx),
+		DryRun:       req.DryRun,
+	})
+	if err != nil {
+		h.respondServiceError(w, r, "bulk invalidate", err, "failed to invalidate memories")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, apitypes.BulkInvalidateResponse{IDs: ids, DryRun: req.DryRun})
+}
+
+// Revalidate handles PUT /v1/memories/:id/revalidate
+func (h *Handlers) Revalidate(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMemoryID(r)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid memory ID")
+		return
+	}
+
+	ctx := r.Context()
+
+	mem, err := h.svc.Revalidate(ctx, types.Revalidation{
+		ID:      id,
+		ByName:  GetAuthorName(ctx),
+		ByEmail: GetAuthorEmail(ctx),
+	})
+	if err != nil {
+		h.respondServiceError(w, r, "revalidate", err, "failed to revalidate memory")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, apitypes.RevalidateResponse{Memory: mem})
+}
+
+// Merge handles POST /v1/memories/merge
+func (h *Handlers) Merge(w http.ResponseWriter, r *http.Request) {
+	var req apitypes.MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Type == "" || req.Area == "" || req.Content == "" {
+		h.respondError(w, http.StatusBadRequest, "type, area, and content are required")
+		return
+	}
+	if len(req.Sources) == 0 {
+		h.respondError(w, http.StatusBadRequest, "sources are required")
+		return
+	}
+
+	expiresAt, err := parseDate(req.ExpiresAt)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	reviewBy, err := parseDate(req.ReviewBy)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+
+	mem, err := h.svc.Merge(ctx, service.MergeParams{
+		AddParams: service.AddParams{
+			Type:        req.Type,
+			Area:        req.Area,
+			Content:     req.Content,
+			Rationale:   req.Rationale,
+			AuthorName:  GetAuthorName(ctx),
+			AuthorEmail: GetAuthorEmail(ctx),
+			Repo:        GetRepo(ctx),
+			Tags:        req.Tags,
+			ExpiresAt:   expiresAt,
+			ReviewBy:    reviewBy,
+		},
+		Sources: req.Sources,
+		Reason:  req.Reason,
+	})
+	if err != nil {
+		h.respondServiceError(w, r, "merge", err, "failed to merge memories")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, apitypes.MergeResponse{Memory: mem})
+}
@@ -5,10 +5,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
@@ -18,38 +22,71 @@ import (
 	"github.com/MereWhiplash/engram-cogitator/internal/types"
 )
 
-type mockEmbedder struct{}
+type mockEmbedder struct {
+	err error // returned by every embed call when set
+}
 
 func (m *mockEmbedder) EmbedForStorage(text string) ([]float32, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
 	return make([]float32, 768), nil
 }
 
 func (m *mockEmbedder) EmbedForSearch(query string) ([]float32, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
 	return make([]float32, 768), nil
 }
 
+func (m *mockEmbedder) Model() string {
+	return "mock-embed"
+}
+
 type mockStorage struct {
-	memories       []types.Memory
-	nextID         int64
-	invalidateErr  error
-	invalidatedIDs []int64
-	searchRepo     string // captures opts.Repo from the last Search call
-	listRepo       string // captures opts.Repo from the last List call
+	memories         []types.Memory
+	nextID           int64
+	invalidateErr    error
+	invalidatedIDs   []int64
+	lastInvalidation types.Invalidation // captures the last Invalidate call
+	searchRepo       string             // captures opts.Repo from the last Search call
+	listRepo         string             // captures opts.Repo from the last List call
+	tagsRepo         string             // captures the repo from the last Tags call
+	reviewRepo       string             // captures the repo from the last DueForReview call
 }
 
 func (m *mockStorage) Add(ctx context.Context, mem types.Memory, embedding []float32) (*types.Memory, error) {
 	m.nextID++
 	mem.ID = m.nextID
 	mem.IsValid = true
+	mem.Version = 1
 	m.memories = append(m.memories, mem)
 	return &mem, nil
 }
 
+func (m *mockStorage) AddBatch(ctx context.Context, mems []types.Memory, embeddings [][]float32) ([]types.Memory, error) {
+	added := make([]types.Memory, len(mems))
+	for i, mem := range mems {
+		a, err := m.Add(ctx, mem, embeddings[i])
+		if err != nil {
+			return nil, err
+		}
+		added[i] = *a
+	}
+	return added, nil
+}
+
 func (m *mockStorage) Search(ctx context.Context, embedding []float32, opts types.SearchOpts) ([]types.Memory, error) {
 	m.searchRepo = opts.Repo
 	return m.memories, nil
 }
 
+func (m *mockStorage) KeywordSearch(ctx context.Context, query string, opts types.SearchOpts) ([]types.Memory, error) {
+	m.searchRepo = opts.Repo
+	return nil, nil
+}
+
 func (m *mockStorage) List(ctx context.Context, opts types.ListOpts) ([]types.Memory, error) {
 	m.listRepo = opts.Repo
 	// Apply offset and limit
@@ -64,7 +101,19 @@ func (m *mockStorage) List(ctx context.Context, opts types.ListOpts) ([]types.Me
 	return m.memories[start:end], nil
 }
 
-func (m *mockStorage) Invalidate(ctx context.Context, id int64, supersededBy *int64) error {
+func (m *mockStorage) Get(ctx context.Context, id int64) (*types.Memory, error) {
+	for i := range m.memories {
+		if m.memories[i].ID == id {
+			mem := m.memories[i]
+			return &mem, nil
+		}
+	}
+	return nil, types.ErrNotFound
+}
+
+func (m *mockStorage) Invalidate(ctx context.Context, inv types.Invalidation) error {
+	m.lastInvalidation = inv
+	id := inv.ID
 	if m.invalidateErr != nil {
 		return m.invalidateErr
 	}
@@ -83,6 +132,126 @@ func (m *mockStorage) Invalidate(ctx context.Context, id int64, supersededBy *in
 	return nil
 }
 
+func (m *mockStorage) InvalidateMatching(ctx context.Context, bi types.BulkInvalidation) ([]int64, error) {
+	ids := []int64{}
+	for _, mem := range m.memories {
+		if mem.IsValid && bi.Filter.Matches(mem) {
+			ids = append(ids, mem.ID)
+		}
+	}
+	if !bi.DryRun {
+		m.invalidatedIDs = append(m.invalidatedIDs, ids...)
+	}
+	return ids, nil
+}
+
+func (m *mockStorage) Revalidate(ctx context.Context, rev types.Revalidation) (*types.Memory, error) {
+	for i := range m.memories {
+		if m.memories[i].ID == rev.ID {
+			m.memories[i].IsValid = true
+			m.memories[i].SupersededBy = nil
+			m.memories[i].RestoredByName = rev.ByName
+			m.memories[i].RestoredByEmail = rev.ByEmail
+			mem := m.memories[i]
+			return &mem, nil
+		}
+	}
+	return nil, types.ErrNotFound
+}
+
+func (m *mockStorage) Merge(ctx context.Context, mg types.Merge, embedding []float32) (*types.Memory, error) {
+	merged, err := m.Add(ctx, mg.Memory, embedding)
+	if err != nil {
+		return nil, err
+	}
+	for i := range m.memories {
+		if slices.Contains(mg.Sources, m.memories[i].ID) {
+			m.memories[i].IsValid = false
+			m.memories[i].SupersededBy = &merged.ID
+			m.memories[i].InvalidationReason = mg.Reason
+		}
+	}
+	return merged, nil
+}
+
+func (m *mockStorage) Update(ctx context.Context, upd types.MemoryUpdate, embedding []float32) (*types.Memory, error) {
+	for i := range m.memories {
+		if m.memories[i].ID == upd.ID {
+			if m.memories[i].Version != upd.ExpectedVersion {
+				return nil, types.ErrVersionConflict
+			}
+			m.memories[i].Type = upd.Type
+			m.memories[i].Area = upd.Area
+			m.memories[i].Content = upd.Content
+			m.memories[i].Rationale = upd.Rationale
+			m.memories[i].Version++
+			mem := m.memories[i]
+			return &mem, nil
+		}
+	}
+	return nil, types.ErrNotFound
+}
+
+func (m *mockStorage) History(ctx context.Context, id int64) ([]types.Revision, error) {
+	return nil, nil
+}
+
+func (m *mockStorage) Tags(ctx context.Context, repo string) ([]types.TagCount, error) {
+	m.tagsRepo = repo
+	return nil, nil
+}
+
+func (m *mockStorage) Catalog(ctx context.Context, field types.CatalogField, repo string) ([]types.CatalogEntry, error) {
+	return nil, nil
+}
+
+func (m *mockStorage) Stats(ctx context.Context, opts types.StatsOpts) (*types.Stats, error) {
+	stats := &types.Stats{Period: opts.Period.OrDefault()}
+	for _, mem := range m.memories {
+		if opts.Repo != "" && mem.Repo != opts.Repo {
+			continue
+		}
+		if mem.IsValid {
+			stats.Valid++
+		} else {
+			stats.Invalid++
+		}
+	}
+	stats.Total = stats.Valid + stats.Invalid
+	return stats, nil
+}
+
+func (m *mockStorage) Expire(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockStorage) DueForReview(ctx context.Context, repo string, now time.Time, limit int) ([]types.Memory, error) {
+	m.reviewRepo = repo
+	var due []types.Memory
+	for _, mem := range m.memories {
+		if mem.ReviewBy != nil && !mem.ReviewBy.After(now) {
+			due = append(due, mem)
+		}
+	}
+	return due, nil
+}
+
+func (m *mockStorage) RecordRetrievals(ctx context.Context, counts map[int64]int, at time.Time) error {
+	return nil
+}
+
+func (m *mockStorage) Unretrieved(ctx context.Context, repo string, before time.Time, limit int) ([]types.Memory, error) {
+	return nil, nil
+}
+
+func (m *mockStorage) Lineage(ctx context.Context, id int64) ([]types.Memory, error) {
+	mem, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return []types.Memory{*mem}, nil
+}
+
 func (m *mockStorage) Close() error {
 	return nil
 }
@@ -99,6 +268,9 @@ func setupTestServerWithStore() (*mockStorage, *chi.Mux) {
 	r.Post("/v1/memories", handlers.Add)
 	r.Post("/v1/memories/search", handlers.Search)
 	r.Get("/v1/memories", handlers.List)
+	r.Get("/v1/memories/{id}", handlers.Get)
+	r.Patch("/v1/memories/{id}", handlers.Update)
+	r.Get("/v1/memories/{id}/history", handlers.History)
 	r.Put("/v1/memories/{id}/invalidate", handlers.Invalidate)
 
 	return store, r
@@ -116,6 +288,9 @@ func setupTestServer() (*api.Handlers, *chi.Mux) {
 	r.Post("/v1/memories", handlers.Add)
 	r.Post("/v1/memories/search", handlers.Search)
 	r.Get("/v1/memories", handlers.List)
+	r.Get("/v1/memories/{id}", handlers.Get)
+	r.Patch("/v1/memories/{id}", handlers.Update)
+	r.Get("/v1/memories/{id}/history", handlers.History)
 	r.Put("/v1/memories/{id}/invalidate", handlers.Invalidate)
 
 	return handlers, r
@@ -369,6 +544,287 @@ func TestInvalidate(t *testing.T) {
 	}
 }
 
+func TestInvalidate_RecordsAuthorAndReason(t *testing.T) {
+	store, r := setupTestServerWithStore()
+	mem, _ := store.Add(context.Background(), types.Memory{Type: types.TypeDecision, Area: "auth", Content: "Use sessions"}, nil)
+
+	jsonBody, _ := json.Marshal(apitypes.InvalidateRequest{Reason: "moved to JWT"})
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/v1/memories/%d/invalidate", mem.ID), bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-EC-Author-Name", "Test User")
+	req.Header.Set("X-EC-Author-Email", "test@example.com")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	inv := store.lastInvalidation
+	if inv.ID != mem.ID || inv.Reason != "moved to JWT" || inv.ByName != "Test User" || inv.ByEmail != "test@example.com" {
+		t.Errorf("expected the reason and author passed to storage, got %+v", inv)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	store, r := setupTestServerWithStore()
+	mem, _ := store.Add(context.Background(), types.Memory{Type: types.TypeDecision, Area: "auth", Content: "Use sessions", Version: 1}, nil)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       interface{}
+		setup      func()
+		wantStatus int
+		wantCode   string
+	}{
+		{"validation", "POST", "/v1/memories", apitypes.AddRequest{Type: "opinion", Area: "auth", Content: "x"}, nil,
+			http.StatusBadRequest, apitypes.CodeValidation},
+		{"not found", "GET", "/v1/memories/999", nil, nil,
+			http.StatusNotFound, apitypes.CodeNotFound},
+		{"conflict", "PATCH", fmt.Sprintf("/v1/memories/%d", mem.ID), map[string]interface{}{"content": "Use JWT", "expected_version": 7}, nil,
+			http.StatusConflict, apitypes.CodeConflict},
+		{"invalid supersede", "PUT", fmt.Sprintf("/v1/memories/%d/invalidate", mem.ID), apitypes.InvalidateRequest{}, func() {
+			store.invalidateErr = fmt.Errorf("%w: memory %d cannot supersede itself", types.ErrInvalidSupersede, mem.ID)
+		}, http.StatusUnprocessableEntity, apitypes.CodeInvalidSupersede},
+		{"internal", "PUT", fmt.Sprintf("/v1/memories/%d/invalidate", mem.ID), apitypes.InvalidateRequest{}, func() {
+			store.invalidateErr = errors.New("disk full")
+		}, http.StatusInternalServerError, apitypes.CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.invalidateErr = nil
+			if tt.setup != nil {
+				tt.setup()
+			}
+			var buf bytes.Buffer
+			if tt.body != nil {
+				json.NewEncoder(&buf).Encode(tt.body)
+			}
+			req := httptest.NewRequest(tt.method, tt.path, &buf)
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			var resp apitypes.ErrorResponse
+			json.NewDecoder(rr.Body).Decode(&resp)
+			if resp.Code != tt.wantCode || resp.Error == "" {
+				t.Errorf("expected code %q with a message, got %+v", tt.wantCode, resp)
+			}
+		})
+	}
+}
+
+func TestAdd_EmbedderUnavailable(t *testing.T) {
+	svc := service.New(&mockStorage{}, &mockEmbedder{err: errors.New("dial tcp ollama:11434: connection refused")})
+	handlers := api.NewHandlers(svc)
+	r := chi.NewRouter()
+	r.Post("/v1/memories", handlers.Add)
+
+	jsonBody, _ := json.Marshal(apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use JWT"})
+	req := httptest.NewRequest("POST", "/v1/memories", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp apitypes.ErrorResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Code != apitypes.CodeEmbedderUnavailable {
+		t.Errorf("expected code %q, got %q", apitypes.CodeEmbedderUnavailable, resp.Code)
+	}
+	if strings.Contains(resp.Error, "ollama") {
+		t.Errorf("expected the embedder's own error kept out of the response, got %q", resp.Error)
+	}
+}
+
+func TestGet(t *testing.T) {
+	_, r := setupTestServer()
+
+	addBody := apitypes.AddRequest{
+		Type:    "decision",
+		Area:    "auth",
+		Content: "Use JWT tokens",
+	}
+	jsonBody, _ := json.Marshal(addBody)
+	req := httptest.NewRequest("POST", "/v1/memories", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var addResp apitypes.AddResponse
+	json.NewDecoder(rr.Body).Decode(&addResp)
+	memID := addResp.Memory.ID
+
+	// Test successful get
+	req = httptest.NewRequest("GET", fmt.Sprintf("/v1/memories/%d", memID), nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var getResp apitypes.GetResponse
+	json.NewDecoder(rr.Body).Decode(&getResp)
+	if getResp.Memory == nil || getResp.Memory.ID != memID {
+		t.Errorf("expected memory %d, got %+v", memID, getResp.Memory)
+	}
+
+	// Test invalid ID format
+	req = httptest.NewRequest("GET", "/v1/memories/invalid", nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid ID, got %d", rr.Code)
+	}
+
+	// Test not found
+	req = httptest.NewRequest("GET", "/v1/memories/99999", nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for not found, got %d", rr.Code)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	_, r := setupTestServer()
+
+	addBody := apitypes.AddRequest{
+		Type:    "decision",
+		Area:    "auth",
+		Content: "Use JWT tokens",
+	}
+	jsonBody, _ := json.Marshal(addBody)
+	req := httptest.NewRequest("POST", "/v1/memories", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var addResp apitypes.AddResponse
+	json.NewDecoder(rr.Body).Decode(&addResp)
+	memID := addResp.Memory.ID
+
+	patch := func(id string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/v1/memories/"+id, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Test successful update
+	rr = patch(fmt.Sprint(memID), `{"rationale": "Stateless auth", "expected_version": 1}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var updResp apitypes.UpdateResponse
+	json.NewDecoder(rr.Body).Decode(&updResp)
+	if updResp.Memory == nil || updResp.Memory.Version != 2 {
+		t.Fatalf("expected memory at version 2, got %+v", updResp.Memory)
+	}
+	if updResp.Memory.Content != "Use JWT tokens" || updResp.Memory.Rationale != "Stateless auth" {
+		t.Errorf("expected only rationale to change, got %+v", updResp.Memory)
+	}
+
+	// Test stale expected version
+	rr = patch(fmt.Sprint(memID), `{"content": "Use sessions", "expected_version": 1}`)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for stale version, got %d", rr.Code)
+	}
+
+	// Test validation
+	rr = patch(fmt.Sprint(memID), `{}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for empty update, got %d", rr.Code)
+	}
+	rr = patch(fmt.Sprint(memID), `{"type": "opinion"}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid type, got %d", rr.Code)
+	}
+	rr = patch(fmt.Sprint(memID), `{"content": ""}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for empty content, got %d", rr.Code)
+	}
+	rr = patch("invalid", `{"content": "x"}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid ID, got %d", rr.Code)
+	}
+
+	// Test not found
+	rr = patch("99999", `{"content": "x"}`)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for not found, got %d", rr.Code)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	_, r := setupTestServer()
+
+	addBody := apitypes.AddRequest{
+		Type:    "decision",
+		Area:    "auth",
+		Content: "Use JWT tokens",
+	}
+	jsonBody, _ := json.Marshal(addBody)
+	req := httptest.NewRequest("POST", "/v1/memories", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var addResp apitypes.AddResponse
+	json.NewDecoder(rr.Body).Decode(&addResp)
+
+	req = httptest.NewRequest("GET", fmt.Sprintf("/v1/memories/%d/history", addResp.Memory.ID), nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var histResp apitypes.HistoryResponse
+	json.NewDecoder(rr.Body).Decode(&histResp)
+	if histResp.Memory == nil || histResp.Memory.ID != addResp.Memory.ID {
+		t.Errorf("expected current memory in response, got %+v", histResp.Memory)
+	}
+	if histResp.Revisions == nil {
+		t.Error("expected revisions to be an empty list, not null")
+	}
+
+	req = httptest.NewRequest("GET", "/v1/memories/99999/history", nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for not found, got %d", rr.Code)
+	}
+}
+
+func TestSearch_InvalidMode(t *testing.T) {
+	_, r := setupTestServerWithStore()
+
+	body, _ := json.Marshal(apitypes.SearchRequest{Query: "anything", Mode: "fuzzy"})
+	req := httptest.NewRequest("POST", "/v1/memories/search", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
 func TestSearch_FallsBackToContextRepo(t *testing.T) {
 	store, r := setupTestServerWithStore()
 
@@ -0,0 +1,851 @@
+// internal/api/integration_test.go
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/api"
+	"github.com/MereWhiplash/engram-cogitator/internal/apitypes"
+	"github.com/MereWhiplash/engram-cogitator/internal/service"
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+)
+
+// wordEmbedder hashes words into a small bag-of-words vector, so texts
+// sharing words are similar and semantic search has something to rank
+type wordEmbedder struct{}
+
+func (e *wordEmbedder) EmbedForStorage(text string) ([]float32, error) {
+	vec := make([]float32, 64)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		h := fnv.New32a()
+		h.Write([]byte(strings.Trim(word, ".,:")))
+		vec[h.Sum32()%64]++
+	}
+	return vec, nil
+}
+
+func (e *wordEmbedder) EmbedForSearch(query string) ([]float32, error) {
+	return e.EmbedForStorage(query)
+}
+
+func (e *wordEmbedder) Model() string {
+	return "word-embed"
+}
+
+// setupIntegrationServer wires the real service to the in-memory store,
+// which needs no CGO or database server
+func setupIntegrationServer(t *testing.T) *chi.Mux {
+	t.Helper()
+
+	store, err := storage.New(context.Background(), storage.Config{
+		Driver:         "memory",
+		EmbeddingModel: "word-embed",
+		EmbeddingDim:   64,
+	})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	svc := service.New(store, &wordEmbedder{})
+	svc.TrackUsage()
+	handlers := api.NewHandlers(svc)
+
+	r := chi.NewRouter()
+	r.Use(api.GitContext)
+	r.Post("/v1/memories", handlers.Add)
+	r.Post("/v1/memories:batch", handlers.AddBatch)
+	r.Post("/v1/memories/search", handlers.Search)
+	r.Post("/v1/memories/merge", handlers.Merge)
+	r.Post("/v1/memories/invalidate", handlers.BulkInvalidate)
+	r.Get("/v1/memories/review", handlers.Review)
+	r.Get("/v1/memories/stale", handlers.Stale)
+	r.Get("/v1/memories", handlers.List)
+	r.Get("/v1/memories/{id}", handlers.Get)
+	r.Patch("/v1/memories/{id}", handlers.Update)
+	r.Get("/v1/memories/{id}/history", handlers.History)
+	r.Get("/v1/memories/{id}/lineage", handlers.Lineage)
+	r.Put("/v1/memories/{id}/invalidate", handlers.Invalidate)
+	r.Put("/v1/memories/{id}/revalidate", handlers.Revalidate)
+	r.Get("/v1/tags", handlers.Tags)
+	r.Get("/v1/repos", handlers.Repos)
+	r.Get("/v1/areas", handlers.Areas)
+	r.Get("/v1/authors", handlers.Authors)
+	r.Get("/v1/stats", handlers.Stats)
+	return r
+}
+
+func TestIntegration_MemoryLifecycle(t *testing.T) {
+	r := setupIntegrationServer(t)
+
+	do := func(method, path string, body interface{}, out interface{}) int {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-EC-Author-Name", "Test User")
+		req.Header.Set("X-EC-Repo", "testorg/testrepo")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if out != nil {
+			json.NewDecoder(rr.Body).Decode(out)
+		}
+		return rr.Code
+	}
+
+	var jwt, sessions apitypes.AddResponse
+	if code := do("POST", "/v1/memories", apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use JWT tokens for service auth"}, &jwt); code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", code)
+	}
+	do("POST", "/v1/memories", apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use server sessions for the admin UI"}, &sessions)
+	do("POST", "/v1/memories", apitypes.AddRequest{Type: "learning", Area: "db", Content: "Connection pool starves under load"}, nil)
+
+	// Search in every mode finds the JWT decision first
+	for _, mode := range []string{"semantic", "keyword", "hybrid"} {
+		var resp apitypes.SearchResponse
+		code := do("POST", "/v1/memories/search", apitypes.SearchRequest{Query: "JWT tokens", Limit: 5, Mode: mode}, &resp)
+		if code != http.StatusOK {
+			t.Fatalf("%s search: expected status 200, got %d", mode, code)
+		}
+		if len(resp.Memories) == 0 || resp.Memories[0].ID != jwt.Memory.ID {
+			t.Errorf("%s search: expected memory %d first, got %+v", mode, jwt.Memory.ID, resp.Memories)
+		}
+	}
+
+	// Ranking overrides apply per request and are range-checked
+	halfLife, weight := 365.0, 1.5
+	if code := do("POST", "/v1/memories/search", apitypes.SearchRequest{Query: "JWT tokens", RecencyHalfLifeDays: &halfLife}, nil); code != http.StatusOK {
+		t.Errorf("expected status 200 with a half-life override, got %d", code)
+	}
+	var rankErr apitypes.ErrorResponse
+	if code := do("POST", "/v1/memories/search", apitypes.SearchRequest{Query: "JWT tokens", RecencyWeight: &weight}, &rankErr); code != http.StatusBadRequest || rankErr.Code != apitypes.CodeValidation {
+		t.Errorf("expected 400 for a recency weight of 1.5, got %d %+v", code, rankErr)
+	}
+
+	// Update, then read the previous version back from history
+	var upd apitypes.UpdateResponse
+	path := fmt.Sprintf("/v1/memories/%d", jwt.Memory.ID)
+	if code := do("PATCH", path, map[string]interface{}{"content": "Use short-lived JWT tokens", "expected_version": 1}, &upd); code != http.StatusOK {
+		t.Fatalf("expected status 200 for update, got %d", code)
+	}
+	if upd.Memory.Version != 2 {
+		t.Errorf("expected version 2, got %d", upd.Memory.Version)
+	}
+	if code := do("PATCH", path, map[string]interface{}{"content": "stale", "expected_version": 1}, nil); code != http.StatusConflict {
+		t.Errorf("expected status 409 for stale version, got %d", code)
+	}
+
+	var hist apitypes.HistoryResponse
+	do("GET", path+"/history", nil, &hist)
+	if len(hist.Revisions) != 1 || hist.Revisions[0].Content != "Use JWT tokens for service auth" {
+		t.Errorf("expected the original version in history, got %+v", hist.Revisions)
+	}
+
+	// Invalidate the sessions decision in favour of the JWT one
+	supersededBy := jwt.Memory.ID
+	invalidatePath := fmt.Sprintf("/v1/memories/%d/invalidate", sessions.Memory.ID)
+	if code := do("PUT", invalidatePath, apitypes.InvalidateRequest{SupersededBy: &supersededBy}, nil); code != http.StatusOK {
+		t.Fatalf("expected status 200 for invalidate, got %d", code)
+	}
+	if code := do("PUT", "/v1/memories/99999/invalidate", nil, nil); code != http.StatusNotFound {
+		t.Errorf("expected status 404 invalidating a missing memory, got %d", code)
+	}
+
+	var list apitypes.ListResponse
+	do("GET", "/v1/memories?area=auth", nil, &list)
+	if len(list.Memories) != 1 || list.Memories[0].ID != jwt.Memory.ID {
+		t.Errorf("expected only the valid auth memory listed, got %+v", list.Memories)
+	}
+
+	var got apitypes.GetResponse
+	do("GET", fmt.Sprintf("/v1/memories/%d", sessions.Memory.ID), nil, &got)
+	if got.Memory == nil || got.Memory.IsValid || got.Memory.SupersededBy == nil || *got.Memory.SupersededBy != jwt.Memory.ID {
+		t.Errorf("expected memory superseded by %d, got %+v", jwt.Memory.ID, got.Memory)
+	}
+	if got.Memory != nil && got.Memory.Repo != "testorg/testrepo" {
+		t.Errorf("expected repo from the git context headers, got %q", got.Memory.Repo)
+	}
+}
+
+func TestIntegration_ListCursor(t *testing.T) {
+	r := setupIntegrationServer(t)
+
+	add := func(content string) {
+		body, _ := json.Marshal(apitypes.AddRequest{Type: "learning", Area: "paging", Content: content})
+		req := httptest.NewRequest("POST", "/v1/memories", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	list := func(query string) (int, apitypes.ListResponse) {
+		req := httptest.NewRequest("GET", "/v1/memories?"+query, nil)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		var resp apitypes.ListResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return rr.Code, resp
+	}
+
+	for i := 0; i < 5; i++ {
+		add(fmt.Sprintf("memory %d", i))
+	}
+
+	var seen []string
+	query := "limit=2"
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("cursor never ran out")
+		}
+		code, resp := list(query)
+		if code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", code)
+		}
+		for _, m := range resp.Memories {
+			seen = append(seen, m.Content)
+		}
+		if resp.Pagination.HasMore != (resp.Pagination.NextCursor != "") {
+			t.Errorf("has_more %v disagrees with next_cursor %q", resp.Pagination.HasMore, resp.Pagination.NextCursor)
+		}
+		if resp.Pagination.NextCursor == "" {
+			break
+		}
+		// New memories land before the cursor, so they don't repeat a page
+		add(fmt.Sprintf("added after page %d", pages))
+		query = "limit=2&cursor=" + resp.Pagination.NextCursor
+	}
+
+	want := "[memory 4 memory 3 memory 2 memory 1 memory 0]"
+	if fmt.Sprint(seen) != want {
+		t.Errorf("expected %s, got %v", want, seen)
+	}
+
+	if code, _ := list("cursor=not-a-cursor"); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a bad cursor, got %d", code)
+	}
+}
+
+func TestIntegration_Tags(t *testing.T) {
+	r := setupIntegrationServer(t)
+
+	do := func(method, path string, body interface{}, out interface{}) int {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if out != nil {
+			json.NewDecoder(rr.Body).Decode(out)
+		}
+		return rr.Code
+	}
+
+	var pool apitypes.AddResponse
+	do("POST", "/v1/memories", apitypes.AddRequest{Type: "learning", Area: "db", Content: "Connection pool starves under load", Tags: []string{"Postgres", "infra"}}, &pool)
+	do("POST", "/v1/memories", apitypes.AddRequest{Type: "decision", Area: "deploy", Content: "Deploy with blue green releases", Tags: []string{"infra"}}, nil)
+	do("POST", "/v1/memories", apitypes.AddRequest{Type: "pattern", Area: "api", Content: "Wrap handler errors"}, nil)
+
+	if fmt.Sprint(pool.Memory.Tags) != "[infra postgres]" {
+		t.Errorf("expected normalized tags [infra postgres], got %v", pool.Memory.Tags)
+	}
+
+	var list apitypes.ListResponse
+	do("GET", "/v1/memories?tags_any=infra", nil, &list)
+	if len(list.Memories) != 2 {
+		t.Errorf("expected 2 memories tagged infra, got %+v", list.Memories)
+	}
+	do("GET", "/v1/memories?tags_all=infra,postgres", nil, &list)
+	if len(list.Memories) != 1 || list.Memories[0].ID != pool.Memory.ID {
+		t.Errorf("expected only the pool memory tagged infra and postgres, got %+v", list.Memories)
+	}
+
+	var found apitypes.SearchResponse
+	do("POST", "/v1/memories/search", apitypes.SearchRequest{Query: "load", TagsAll: []string{"postgres"}}, &found)
+	if len(found.Memories) != 1 || found.Memories[0].ID != pool.Memory.ID {
+		t.Errorf("expected the pool memory from a tagged search, got %+v", found.Memories)
+	}
+
+	var tags apitypes.TagsResponse
+	if code := do("GET", "/v1/tags", nil, &tags); code != http.StatusOK {
+		t.Fatalf("expected status 200 for tags, got %d", code)
+	}
+	if fmt.Sprint(tags.Tags) != "[{infra 2} {postgres 1}]" {
+		t.Errorf("expected [{infra 2} {postgres 1}], got %v", tags.Tags)
+	}
+
+	if code := do("POST", "/v1/memories", apitypes.AddRequest{Type: "learning", Area: "db", Content: "x", Tags: []string{"two words"}}, nil); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a tag with a space, got %d", code)
+	}
+}
+
+func TestIntegration_ExpiryAndReview(t *testing.T) {
+	r := setupIntegrationServer(t)
+
+	do := func(method, path string, body interface{}, out interface{}) int {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if out != nil {
+			json.NewDecoder(rr.Body).Decode(out)
+		}
+		return rr.Code
+	}
+
+	var staging, pool apitypes.AddResponse
+	if code := do("POST", "/v1/memories", apitypes.AddRequest{Type: "learning", Area: "infra", Content: "Staging runs Postgres 14", ExpiresAt: "2030-03-01"}, &staging); code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", code)
+	}
+	do("POST", "/v1/memories", apitypes.AddRequest{Type: "learning", Area: "db", Content: "Pool maxes at 20", ReviewBy: "2020-01-01"}, &pool)
+	do("POST", "/v1/memories", apitypes.AddRequest{Type: "learning", Area: "db", Content: "Index foreign keys", ReviewBy: "2099-01-01T09:00:00Z"}, nil)
+
+	if staging.Memory.ExpiresAt == nil || staging.Memory.ExpiresAt.Format("2006-01-02") != "2030-03-01" {
+		t.Errorf("expected expires_at 2030-03-01, got %v", staging.Memory.ExpiresAt)
+	}
+
+	var review apitypes.ReviewResponse
+	if code := do("GET", "/v1/memories/review", nil, &review); code != http.StatusOK {
+		t.Fatalf("expected status 200 for review, got %d", code)
+	}
+	if len(review.Memories) != 1 || review.Memories[0].ID != pool.Memory.ID {
+		t.Errorf("expected only the overdue memory, got %+v", review.Memories)
+	}
+
+	// Confirming the memory moves its review date out
+	path := fmt.Sprintf("/v1/memories/%d", pool.Memory.ID)
+	if code := do("PATCH", path, map[string]interface{}{"review_by": "2099-06-01"}, nil); code != http.StatusOK {
+		t.Fatalf("expected status 200 for update, got %d", code)
+	}
+	do("GET", "/v1/memories/review", nil, &review)
+	if len(review.Memories) != 0 {
+		t.Errorf("expected nothing due after review, got %+v", review.Memories)
+	}
+
+	// An empty date clears it
+	var upd apitypes.UpdateResponse
+	do("PATCH", fmt.Sprintf("/v1/memories/%d", staging.Memory.ID), map[string]interface{}{"expires_at": ""}, &upd)
+	if upd.Memory == nil || upd.Memory.ExpiresAt != nil {
+		t.Errorf("expected expires_at cleared, got %+v", upd.Memory)
+	}
+
+	if code := do("POST", "/v1/memories", apitypes.AddRequest{Type: "learning", Area: "db", Content: "x", ExpiresAt: "next spring"}, nil); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unparseable date, got %d", code)
+	}
+}
+
+func TestIntegration_Stale(t *testing.T) {
+	r := setupIntegrationServer(t)
+
+	do := func(path string) (int, []byte) {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, rr.Body.Bytes()
+	}
+
+	add := httptest.NewRequest("POST", "/v1/memories", strings.NewReader(`{"type":"learning","area":"db","content":"Pool maxes at 20"}`))
+	add.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), add)
+
+	// A memory added just now is too new to be stale
+	code, body := do("/v1/memories/stale?min_age_days=1")
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", code, body)
+	}
+	var stale apitypes.StaleResponse
+	if err := json.Unmarshal(body, &stale); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if stale.Memories == nil || len(stale.Memories) != 0 {
+		t.Errorf("expected an empty memories array, got %s", body)
+	}
+
+	if code, _ := do("/v1/memories/stale?min_age_days=soon"); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a non-numeric age, got %d", code)
+	}
+	code, body = do("/v1/memories/stale?min_age_days=-1")
+	var errResp apitypes.ErrorResponse
+	json.Unmarshal(body, &errResp)
+	if code != http.StatusBadRequest || errResp.Code != apitypes.CodeValidation {
+		t.Errorf("expected a 400 validation error for a negative age, got %d %+v", code, errResp)
+	}
+}
+
+func TestIntegration_Lineage(t *testing.T) {
+	r := setupIntegrationServer(t)
+
+	do := func(method, path string, body interface{}, out interface{}) int {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if out != nil {
+			json.NewDecoder(rr.Body).Decode(out)
+		}
+		return rr.Code
+	}
+
+	var sessions, jwt, rotation apitypes.AddResponse
+	do("POST", "/v1/memories", apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use server sessions"}, &sessions)
+	do("POST", "/v1/memories", apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use JWT tokens"}, &jwt)
+	do("POST", "/v1/memories", apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use JWT tokens with key rotation"}, &rotation)
+	do("PUT", fmt.Sprintf("/v1/memories/%d/invalidate", sessions.Memory.ID), apitypes.InvalidateRequest{SupersededBy: &jwt.Memory.ID}, nil)
+	do("PUT", fmt.Sprintf("/v1/memories/%d/invalidate", jwt.Memory.ID), apitypes.InvalidateRequest{SupersededBy: &rotation.Memory.ID}, nil)
+
+	var lineage apitypes.LineageResponse
+	if code := do("GET", fmt.Sprintf("/v1/memories/%d/lineage", jwt.Memory.ID), nil, &lineage); code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if lineage.Memory.ID != jwt.Memory.ID {
+		t.Errorf("expected memory %d, got %d", jwt.Memory.ID, lineage.Memory.ID)
+	}
+	if len(lineage.Ancestors) != 1 || lineage.Ancestors[0].ID != sessions.Memory.ID {
+		t.Errorf("expected memory %d as the only ancestor, got %+v", sessions.Memory.ID, lineage.Ancestors)
+	}
+	if len(lineage.Descendants) != 1 || lineage.Descendants[0].ID != rotation.Memory.ID {
+		t.Errorf("expected memory %d as the only descendant, got %+v", rotation.Memory.ID, lineage.Descendants)
+	}
+	if lineage.Current == nil || lineage.Current.ID != rotation.Memory.ID {
+		t.Errorf("expected memory %d current, got %+v", rotation.Memory.ID, lineage.Current)
+	}
+
+	if code := do("GET", "/v1/memories/99999/lineage", nil, nil); code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a missing memory, got %d", code)
+	}
+}
+
+func TestIntegration_Revalidate(t *testing.T) {
+	r := setupIntegrationServer(t)
+
+	var sessions, jwt apitypes.AddResponse
+	for _, add := range []struct {
+		req apitypes.AddRequest
+		out *apitypes.AddResponse
+	}{
+		{apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use server sessions"}, &sessions},
+		{apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use JWT tokens"}, &jwt},
+	} {
+		body, _ := json.Marshal(add.req)
+		req := httptest.NewRequest("POST", "/v1/memories", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		json.NewDecoder(rr.Body).Decode(add.out)
+	}
+
+	body, _ := json.Marshal(apitypes.InvalidateRequest{SupersededBy: &jwt.Memory.ID, Reason: "JWT instead"})
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/v1/memories/%d/invalidate", sessions.Memory.ID), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/v1/memories/%d/revalidate", sessions.Memory.ID), nil)
+	req.Header.Set("X-EC-Author-Name", "Test User")
+	req.Header.Set("X-EC-Author-Email", "test@example.com")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp apitypes.RevalidateResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	mem := resp.Memory
+	if mem == nil || !mem.IsValid || mem.SupersededBy != nil || mem.InvalidationReason != "" {
+		t.Fatalf("expected a valid memory with no successor or invalidation details, got %+v", mem)
+	}
+	if mem.RestoredAt == nil || mem.RestoredByName != "Test User" || mem.RestoredByEmail != "test@example.com" {
+		t.Errorf("expected when and by whom it was restored, got %+v", mem)
+	}
+
+	req = httptest.NewRequest("GET", "/v1/memories?area=auth", nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	var listed apitypes.ListResponse
+	json.NewDecoder(rr.Body).Decode(&listed)
+	if len(listed.Memories) != 2 {
+		t.Errorf("expected both auth memories listed, got %+v", listed.Memories)
+	}
+
+	req = httptest.NewRequest("PUT", "/v1/memories/99999/revalidate", nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a missing memory, got %d", rr.Code)
+	}
+}
+
+func TestIntegration_InvalidSupersede(t *testing.T) {
+	r := setupIntegrationServer(t)
+
+	body, _ := json.Marshal(apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use server sessions"})
+	req := httptest.NewRequest("POST", "/v1/memories", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	var added apitypes.AddResponse
+	json.NewDecoder(rr.Body).Decode(&added)
+	id := added.Memory.ID
+
+	missing := id + 1000
+	for name, by := range map[string]*int64{"itself": &id, "missing": &missing} {
+		body, _ := json.Marshal(apitypes.InvalidateRequest{SupersededBy: by})
+		req := httptest.NewRequest("PUT", fmt.Sprintf("/v1/memories/%d/invalidate", id), bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status 422, got %d: %s", name, rr.Code, rr.Body.String())
+		}
+		var resp apitypes.ErrorResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		if resp.Code != apitypes.CodeInvalidSupersede {
+			t.Errorf("%s: expected code %q, got %+v", name, apitypes.CodeInvalidSupersede, resp)
+		}
+	}
+}
+
+func TestIntegration_AddBatch(t *testing.T) {
+	r := setupIntegrationServer(t)
+
+	post := func(body interface{}, out interface{}) int {
+		t.Helper()
+		buf, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/v1/memories:batch", bytes.NewReader(buf))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-EC-Author-Name", "Test User")
+		req.Header.Set("X-EC-Repo", "testorg/testrepo")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if out != nil {
+			json.NewDecoder(rr.Body).Decode(out)
+		}
+		return rr.Code
+	}
+
+	var resp apitypes.BatchAddResponse
+	code := post(apitypes.BatchAddRequest{Memories: []apitypes.AddRequest{
+		{Type: "decision", Area: "auth", Content: "Use server sessions", Tags: []string{"security"}},
+		{Type: "decision", Area: "auth"},
+		{Type: "fact", Area: "db", Content: "Postgres is the primary store"},
+		{Type: "learning", Area: "db", Content: "Index foreign keys", ReviewBy: "next week"},
+		{Type: "pattern", Area: "api", Content: "Wrap handler errors", ExpiresAt: "2030-01-01"},
+	}}, &resp)
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if len(resp.Results) != 5 || resp.Added != 2 {
+		t.Fatalf("expected 5 results with 2 added, got %+v", resp)
+	}
+	for _, i := range []int{1, 2, 3} {
+		if res := resp.Results[i]; res.Memory != nil || res.Code != apitypes.CodeValidation || res.Error == "" {
+			t.Errorf("result %d: expected a validation error, got %+v", i, res)
+		}
+	}
+	first, last := resp.Results[0].Memory, resp.Results[4].Memory
+	if first == nil || first.Repo != "testorg/testrepo" || first.AuthorName != "Test User" || len(first.Tags) != 1 {
+		t.Errorf("expected the first memory added with git context, got %+v", first)
+	}
+	if last == nil || last.ExpiresAt == nil || last.Content != "Wrap handler errors" {
+		t.Errorf("expected the last memory added with its expiry, got %+v", last)
+	}
+
+	var search apitypes.SearchResponse
+	buf, _ := json.Marshal(apitypes.SearchRequest{Query: "server sessions", Limit: 1})
+	req := httptest.NewRequest("POST", "/v1/memories/search", bytes.NewReader(buf))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	json.NewDecoder(rr.Body).Decode(&search)
+	if len(search.Memories) != 1 || first == nil || search.Memories[0].ID != first.ID {
+		t.Errorf("expected search to find the batch's first memory, got %+v", search.Memories)
+	}
+
+	var errResp apitypes.ErrorResponse
+	if code := post(apitypes.BatchAddRequest{}, &errResp); code != http.StatusBadRequest || errResp.Code != apitypes.CodeValidation {
+		t.Errorf("expected 400 for an empty batch, got %d %+v", code, errResp)
+	}
+	tooMany := apitypes.BatchAddRequest{Memories: make([]apitypes.AddRequest, service.MaxBatchSize+1)}
+	if code := post(tooMany, nil); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an oversized batch, got %d", code)
+	}
+}
+
+func TestIntegration_BulkInvalidate(t *testing.T) {
+	r := setupIntegrationServer(t)
+
+	post := func(path, repo string, body interface{}, out interface{}) int {
+		t.Helper()
+		buf, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", path, bytes.NewReader(buf))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-EC-Author-Name", "Test User")
+		req.Header.Set("X-EC-Repo", repo)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if out != nil {
+			json.NewDecoder(rr.Body).Decode(out)
+		}
+		return rr.Code
+	}
+
+	var schema, resolvers, elsewhere, rest apitypes.AddResponse
+	post("/v1/memories", "testorg/testrepo", apitypes.AddRequest{Type: "decision", Area: "graphql", Content: "Schema first"}, &schema)
+	post("/v1/memories", "testorg/testrepo", apitypes.AddRequest{Type: "learning", Area: "graphql", Content: "Batch resolvers"}, &resolvers)
+	post("/v1/memories", "testorg/other", apitypes.AddRequest{Type: "decision", Area: "graphql", Content: "Persisted queries"}, &elsewhere)
+	post("/v1/memories", "testorg/testrepo", apitypes.AddRequest{Type: "decision", Area: "rest", Content: "Use REST"}, &rest)
+
+	// The dry run scopes to the caller's repo and changes nothing
+	var preview apitypes.BulkInvalidateResponse
+	code := post("/v1/memories/invalidate", "testorg/testrepo", apitypes.BulkInvalidateRequest{Area: "graphql", DryRun: true}, &preview)
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if !preview.DryRun || fmt.Sprint(preview.IDs) != fmt.Sprint([]int64{schema.Memory.ID, resolvers.Memory.ID}) {
+		t.Fatalf("expected a dry run matching the repo's graphql memories, got %+v", preview)
+	}
+
+	var done apitypes.BulkInvalidateResponse
+	code = post("/v1/memories/invalidate", "testorg/testrepo", apitypes.BulkInvalidateRequest{
+		Area:         "graphql",
+		SupersededBy: &rest.Memory.ID,
+		Reason:       "GraphQL removed",
+	}, &done)
+	if code != http.StatusOK || done.DryRun || fmt.Sprint(done.IDs) != fmt.Sprint(preview.IDs) {
+		t.Fatalf("expected the previewed memories invalidated, got %d %+v", code, done)
+	}
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/v1/memories/%d", schema.Memory.ID), nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	var got apitypes.GetResponse
+	json.NewDecoder(rr.Body).Decode(&got)
+	if got.Memory == nil || got.Memory.IsValid || got.Memory.InvalidationReason != "GraphQL removed" ||
+		got.Memory.InvalidatedByName != "Test User" || got.Memory.SupersededBy == nil || *got.Memory.SupersededBy != rest.Memory.ID {
+		t.Errorf("expected the invalidation recorded, got %+v", got.Memory)
+	}
+
+	req = httptest.NewRequest("GET", fmt.Sprintf("/v1/memories/%d", elsewhere.Memory.ID), nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	json.NewDecoder(rr.Body).Decode(&got)
+	if got.Memory == nil || !got.Memory.IsValid {
+		t.Errorf("expected another repo's memory left valid, got %+v", got.Memory)
+	}
+
+	var errResp apitypes.ErrorResponse
+	if code := post("/v1/memories/invalidate", "testorg/testrepo", apitypes.BulkInvalidateRequest{Reason: "all of it"}, &errResp); code != http.StatusBadRequest || errResp.Code != apitypes.CodeValidation {
+		t.Errorf("expected 400 without filters, got %d %+v", code, errResp)
+	}
+	if code := post("/v1/memories/invalidate", "testorg/testrepo", apitypes.BulkInvalidateRequest{CreatedBefore: "soon"}, nil); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad created_before, got %d", code)
+	}
+}
+
+func TestIntegration_Stats(t *testing.T) {
+	r := setupIntegrationServer(t)
+
+	do := func(method, path, repo string, body interface{}, out interface{}) int {
+		t.Helper()
+		buf, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(buf))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-EC-Author-Email", "test@example.com")
+		if repo != "" {
+			req.Header.Set("X-EC-Repo", repo)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if out != nil {
+			json.NewDecoder(rr.Body).Decode(out)
+		}
+		return rr.Code
+	}
+
+	var gone apitypes.AddResponse
+	do("POST", "/v1/memories", "testorg/testrepo", apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use JWT"}, nil)
+	do("POST", "/v1/memories", "testorg/testrepo", apitypes.AddRequest{Type: "learning", Area: "db", Content: "Index foreign keys"}, &gone)
+	do("POST", "/v1/memories", "testorg/other", apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use sessions"}, nil)
+	do("PUT", fmt.Sprintf("/v1/memories/%d/invalidate", gone.Memory.ID), "testorg/testrepo", nil, nil)
+
+	var resp apitypes.StatsResponse
+	if code := do("GET", "/v1/stats?period=week", "", nil, &resp); code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	stats := resp.Stats
+	if stats == nil || stats.Total != 3 || stats.Valid != 2 || stats.Invalid != 1 || stats.Period != "week" {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if fmt.Sprint(stats.ByArea) != "[{auth 2 0} {db 0 1}]" || fmt.Sprint(stats.ByAuthor) != "[{test@example.com 2 1}]" {
+		t.Errorf("unexpected groups: %v, %v", stats.ByArea, stats.ByAuthor)
+	}
+	if len(stats.Growth) != 1 || stats.Growth[0].Total != 3 {
+		t.Errorf("expected one week's growth of 3, got %+v", stats.Growth)
+	}
+
+	// The caller's repo scopes the counts
+	if do("GET", "/v1/stats", "testorg/other", nil, &resp); resp.Stats == nil || resp.Stats.Total != 1 {
+		t.Errorf("expected 1 memory in testorg/other, got %+v", resp.Stats)
+	}
+
+	var errResp apitypes.ErrorResponse
+	if code := do("GET", "/v1/stats?period=year", "", nil, &errResp); code != http.StatusBadRequest || errResp.Code != apitypes.CodeValidation {
+		t.Errorf("expected 400 for an unknown period, got %d %+v", code, errResp)
+	}
+}
+
+func TestIntegration_Catalog(t *testing.T) {
+	r := setupIntegrationServer(t)
+
+	do := func(method, path, repo string, body interface{}, out interface{}) int {
+		t.Helper()
+		buf, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(buf))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-EC-Author-Name", "Test")
+		req.Header.Set("X-EC-Author-Email", "test@example.com")
+		if repo != "" {
+			req.Header.Set("X-EC-Repo", repo)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if out != nil {
+			json.NewDecoder(rr.Body).Decode(out)
+		}
+		return rr.Code
+	}
+
+	do("POST", "/v1/memories", "testorg/testrepo", apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use JWT"}, nil)
+	do("POST", "/v1/memories", "testorg/testrepo", apitypes.AddRequest{Type: "learning", Area: "auth", Content: "Rotate refresh tokens"}, nil)
+	do("POST", "/v1/memories", "testorg/testrepo", apitypes.AddRequest{Type: "learning", Area: "db", Content: "Index foreign keys"}, nil)
+	do("POST", "/v1/memories", "testorg/other", apitypes.AddRequest{Type: "decision", Area: "billing", Content: "Bill monthly"}, nil)
+
+	// Repos ignores the caller's repo
+	var repos apitypes.ReposResponse
+	if code := do("GET", "/v1/repos", "testorg/testrepo", nil, &repos); code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if len(repos.Repos) != 2 || repos.Repos[0].Value != "testorg/testrepo" || repos.Repos[0].Count != 3 || repos.Repos[0].LastActivity.IsZero() {
+		t.Errorf("unexpected repos: %+v", repos.Repos)
+	}
+
+	var areas apitypes.AreasResponse
+	do("GET", "/v1/areas", "testorg/testrepo", nil, &areas)
+	if len(areas.Areas) != 2 || areas.Areas[0].Value != "auth" || areas.Areas[0].Count != 2 || areas.Areas[1].Value != "db" {
+		t.Errorf("expected auth then db, got %+v", areas.Areas)
+	}
+
+	// The query wins over the header
+	do("GET", "/v1/areas?repo=testorg/other", "testorg/testrepo", nil, &areas)
+	if len(areas.Areas) != 1 || areas.Areas[0].Value != "billing" {
+		t.Errorf("expected billing only, got %+v", areas.Areas)
+	}
+
+	var authors apitypes.AuthorsResponse
+	do("GET", "/v1/authors", "testorg/testrepo", nil, &authors)
+	if len(authors.Authors) != 1 || authors.Authors[0].Value != "test@example.com" || authors.Authors[0].Name != "Test" || authors.Authors[0].Count != 3 {
+		t.Errorf("unexpected authors: %+v", authors.Authors)
+	}
+
+	// An unused repo lists nothing, as [] rather than null
+	var raw map[string]json.RawMessage
+	do("GET", "/v1/areas?repo=testorg/none", "", nil, &raw)
+	if string(raw["areas"]) != "[]" {
+		t.Errorf("expected areas [], got %s", raw["areas"])
+	}
+}
+
+func TestIntegration_Merge(t *testing.T) {
+	r := setupIntegrationServer(t)
+
+	post := func(path string, body interface{}, out interface{}) int {
+		t.Helper()
+		buf, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", path, bytes.NewReader(buf))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-EC-Author-Name", "Test User")
+		req.Header.Set("X-EC-Repo", "testorg/testrepo")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if out != nil {
+			json.NewDecoder(rr.Body).Decode(out)
+		}
+		return rr.Code
+	}
+
+	var sessions, cookies apitypes.AddResponse
+	post("/v1/memories", apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use server sessions"}, &sessions)
+	post("/v1/memories", apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Keep sessions in cookies"}, &cookies)
+
+	var merged apitypes.MergeResponse
+	code := post("/v1/memories/merge", apitypes.MergeRequest{
+		AddRequest: apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use server sessions kept in cookies"},
+		Sources:    []int64{sessions.Memory.ID, cookies.Memory.ID},
+		Reason:     "duplicates",
+	}, &merged)
+	if code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", code)
+	}
+	mem := merged.Memory
+	if mem == nil || !mem.IsValid || mem.Repo != "testorg/testrepo" || mem.AuthorName != "Test User" {
+		t.Fatalf("expected a valid merged memory with git context, got %+v", mem)
+	}
+	if mem.OriginatedAt == nil || !mem.OriginatedAt.Equal(sessions.Memory.CreatedAt) {
+		t.Errorf("expected originated_at %v, got %v", sessions.Memory.CreatedAt, mem.OriginatedAt)
+	}
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/v1/memories/%d", sessions.Memory.ID), nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	var source apitypes.GetResponse
+	json.NewDecoder(rr.Body).Decode(&source)
+	if source.Memory == nil || source.Memory.IsValid || source.Memory.SupersededBy == nil || *source.Memory.SupersededBy != mem.ID {
+		t.Errorf("expected the source superseded by %d, got %+v", mem.ID, source.Memory)
+	}
+	if source.Memory != nil && (source.Memory.InvalidationReason != "duplicates" || source.Memory.InvalidatedByName != "Test User") {
+		t.Errorf("expected the merge recorded on the source, got %+v", source.Memory)
+	}
+
+	// Sources that are already merged can't be merged again
+	var errResp apitypes.ErrorResponse
+	code = post("/v1/memories/merge", apitypes.MergeRequest{
+		AddRequest: apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use sessions"},
+		Sources:    []int64{sessions.Memory.ID, mem.ID},
+	}, &errResp)
+	if code != http.StatusUnprocessableEntity || errResp.Code != apitypes.CodeInvalidSupersede {
+		t.Errorf("expected 422 %q, got %d %+v", apitypes.CodeInvalidSupersede, code, errResp)
+	}
+
+	code = post("/v1/memories/merge", apitypes.MergeRequest{
+		AddRequest: apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use sessions"},
+		Sources:    []int64{mem.ID},
+	}, &errResp)
+	if code != http.StatusBadRequest || errResp.Code != apitypes.CodeValidation {
+		t.Errorf("expected 400 %q for a single source, got %d %+v", apitypes.CodeValidation, code, errResp)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/apitypes"
 )
 
 // Context keys for git info
@@ -93,7 +95,7 @@ func CORSMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
 
 			if allowed && origin != "" {
 				w.Header().Set("Access-Control-Allow-Origin", origin)
-				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-EC-Author-Name, X-EC-Author-Email, X-EC-Repo, X-Request-ID")
 				w.Header().Set("Access-Control-Max-Age", "86400")
 			}
@@ -226,7 +228,7 @@ func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 
 		if !rl.Allow(ip) {
 			w.Header().Set("Retry-After", "60")
-			http.Error(w, `{"error":"rate limit exceeded"}`, http.StatusTooManyRequests)
+			http.Error(w, `{"error":"rate limit exceeded","code":"`+apitypes.CodeRateLimited+`"}`, http.StatusTooManyRequests)
 			return
 		}
 
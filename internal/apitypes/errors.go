@@ -0,0 +1,51 @@
+package apitypes
+
+import (
+	"errors"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// Error codes sent in ErrorResponse.Code
+const (
+	CodeValidation          = "validation"
+	CodeNotFound            = "not_found"
+	CodeConflict            = "conflict"
+	CodeInvalidSupersede    = "invalid_supersede"
+	CodeEmbedderUnavailable = "embedder_unavailable"
+	CodeRateLimited         = "rate_limited"
+	CodeInternal            = "internal"
+)
+
+// codeErrors pairs each code with the error it stands for
+var codeErrors = []struct {
+	code string
+	err  error
+}{
+	{CodeValidation, types.ErrValidation},
+	{CodeNotFound, types.ErrNotFound},
+	{CodeConflict, types.ErrVersionConflict},
+	{CodeInvalidSupersede, types.ErrInvalidSupersede},
+	{CodeEmbedderUnavailable, types.ErrEmbedderUnavailable},
+}
+
+// ErrorCode returns the code for err, or CodeInternal if it is none of the
+// errors in types
+func ErrorCode(err error) string {
+	for _, ce := range codeErrors {
+		if errors.Is(err, ce.err) {
+			return ce.code
+		}
+	}
+	return CodeInternal
+}
+
+// CodeError returns the error in types that code stands for, or nil
+func CodeError(code string) error {
+	for _, ce := range codeErrors {
+		if ce.code == code {
+			return ce.err
+		}
+	}
+	return nil
+}
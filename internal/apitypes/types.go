@@ -11,6 +11,11 @@ type AddRequest struct {
 	Area      string `json:"area"`
 	Content   string `json:"content"`
 	Rationale string `json:"rationale,omitempty"`
+	// Tags are lowercased and deduplicated; they cannot contain spaces or commas
+	Tags []string `json:"tags,omitempty"`
+	// ExpiresAt and ReviewBy are YYYY-MM-DD dates or RFC 3339 timestamps
+	ExpiresAt string `json:"expires_at,omitempty"`
+	ReviewBy  string `json:"review_by,omitempty"`
 }
 
 // AddResponse is the response for POST /v1/memories
@@ -18,6 +23,29 @@ type AddResponse struct {
 	Memory *types.Memory `json:"memory"`
 }
 
+// BatchAddRequest is the request body for POST /v1/memories:batch
+type BatchAddRequest struct {
+	// Memories are added together; each is validated as an AddRequest
+	Memories []AddRequest `json:"memories"`
+}
+
+// BatchAddResult is the outcome for one memory in a BatchAddRequest, in the
+// same position as the request's memory
+type BatchAddResult struct {
+	// Memory is the added memory, or nil if it was rejected
+	Memory *types.Memory `json:"memory,omitempty"`
+	// Error and Code say why the memory was rejected, as in ErrorResponse
+	Error string `json:"error,omitempty"`
+	Code  string `json:"code,omitempty"`
+}
+
+// BatchAddResponse is the response for POST /v1/memories:batch
+type BatchAddResponse struct {
+	Results []BatchAddResult `json:"results"`
+	// Added counts the results with a memory
+	Added int `json:"added"`
+}
+
 // SearchRequest is the request body for POST /v1/memories/search
 type SearchRequest struct {
 	Query string `json:"query"`
@@ -25,6 +53,17 @@ type SearchRequest struct {
 	Type  string `json:"type,omitempty"`
 	Area  string `json:"area,omitempty"`
 	Repo  string `json:"repo,omitempty"` // empty = all repos
+	// Mode is semantic, keyword, or hybrid (default)
+	Mode string `json:"mode,omitempty"`
+	// TagsAny matches memories with at least one of these tags, TagsAll
+	// those with every one
+	TagsAny []string `json:"tags_any,omitempty"`
+	TagsAll []string `json:"tags_all,omitempty"`
+	// RecencyWeight, RecencyHalfLifeDays and UsageWeight override the
+	// server's ranking policy for this search
+	RecencyWeight       *float64 `json:"recency_weight,omitempty"`
+	RecencyHalfLifeDays *float64 `json:"recency_half_life_days,omitempty"`
+	UsageWeight         *float64 `json:"usage_weight,omitempty"`
 }
 
 // SearchResponse is the response for POST /v1/memories/search
@@ -43,11 +82,51 @@ type PaginationInfo struct {
 	Limit   int  `json:"limit"`
 	Offset  int  `json:"offset"`
 	HasMore bool `json:"has_more"`
+	// NextCursor fetches the following page via ?cursor=; unlike offset it
+	// stays stable while memories are added. Empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// GetResponse is the response for GET /v1/memories/:id
+type GetResponse struct {
+	Memory *types.Memory `json:"memory"`
+}
+
+// UpdateRequest is the request body for PATCH /v1/memories/:id.
+// Omitted fields are left unchanged.
+type UpdateRequest struct {
+	Type      *string `json:"type,omitempty"`
+	Area      *string `json:"area,omitempty"`
+	Content   *string `json:"content,omitempty"`
+	Rationale *string `json:"rationale,omitempty"`
+	// ExpiresAt and ReviewBy take a date as in AddRequest, or "" to clear it
+	ExpiresAt *string `json:"expires_at,omitempty"`
+	ReviewBy  *string `json:"review_by,omitempty"`
+	// ExpectedVersion rejects the update with 409 if the memory has changed since it was read
+	ExpectedVersion int `json:"expected_version,omitempty"`
+}
+
+// UpdateResponse is the response for PATCH /v1/memories/:id
+type UpdateResponse struct {
+	Memory *types.Memory `json:"memory"`
+}
+
+// HistoryResponse is the response for GET /v1/memories/:id/history
+type HistoryResponse struct {
+	Memory    *types.Memory    `json:"memory"`
+	Revisions []types.Revision `json:"revisions"`
+}
+
+// LineageResponse is the response for GET /v1/memories/:id/lineage
+type LineageResponse struct {
+	types.Lineage
 }
 
 // InvalidateRequest is the request body for PUT /v1/memories/:id/invalidate
 type InvalidateRequest struct {
 	SupersededBy *int64 `json:"superseded_by,omitempty"`
+	// Reason says why the memory no longer holds
+	Reason string `json:"reason,omitempty"`
 }
 
 // InvalidateResponse is the response for PUT /v1/memories/:id/invalidate
@@ -55,9 +134,90 @@ type InvalidateResponse struct {
 	Message string `json:"message"`
 }
 
+// BulkInvalidateRequest is the request body for POST /v1/memories/invalidate.
+// It needs at least one of the filters, which all have to match.
+type BulkInvalidateRequest struct {
+	// Repo defaults to the caller's repo from the X-EC-Repo header
+	Repo string `json:"repo,omitempty"`
+	Area string `json:"area,omitempty"`
+	Type string `json:"type,omitempty"`
+	// Author matches the author's name or email
+	Author string `json:"author,omitempty"`
+	// CreatedBefore is a YYYY-MM-DD date or RFC 3339 timestamp
+	CreatedBefore string `json:"created_before,omitempty"`
+	SupersededBy  *int64 `json:"superseded_by,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	// DryRun returns the matching IDs without invalidating them
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// BulkInvalidateResponse is the response for POST /v1/memories/invalidate
+type BulkInvalidateResponse struct {
+	// IDs are the matching memories, ascending
+	IDs    []int64 `json:"ids"`
+	DryRun bool    `json:"dry_run"`
+}
+
+// RevalidateResponse is the response for PUT /v1/memories/:id/revalidate
+type RevalidateResponse struct {
+	Memory *types.Memory `json:"memory"`
+}
+
+// MergeRequest is the request body for POST /v1/memories/merge. The fields
+// shared with AddRequest describe the consolidated memory.
+type MergeRequest struct {
+	AddRequest
+	// Sources are the IDs of the memories being merged, at least two
+	Sources []int64 `json:"sources"`
+	// Reason is recorded on each source as it is invalidated
+	Reason string `json:"reason,omitempty"`
+}
+
+// MergeResponse is the response for POST /v1/memories/merge
+type MergeResponse struct {
+	Memory *types.Memory `json:"memory"`
+}
+
+// ReviewResponse is the response for GET /v1/memories/review
+type ReviewResponse struct {
+	Memories []types.Memory `json:"memories"`
+}
+
+// StaleResponse is the response for GET /v1/memories/stale
+type StaleResponse struct {
+	Memories []types.Memory `json:"memories"`
+}
+
+// TagsResponse is the response for GET /v1/tags
+type TagsResponse struct {
+	Tags []types.TagCount `json:"tags"`
+}
+
+// ReposResponse is the response for GET /v1/repos
+type ReposResponse struct {
+	Repos []types.CatalogEntry `json:"repos"`
+}
+
+// AreasResponse is the response for GET /v1/areas
+type AreasResponse struct {
+	Areas []types.CatalogEntry `json:"areas"`
+}
+
+// AuthorsResponse is the response for GET /v1/authors
+type AuthorsResponse struct {
+	Authors []types.CatalogEntry `json:"authors"`
+}
+
+// StatsResponse is the response for GET /v1/stats
+type StatsResponse struct {
+	Stats *types.Stats `json:"stats"`
+}
+
 // ErrorResponse is returned on errors
 type ErrorResponse struct {
 	Error string `json:"error"`
+	// Code is one of the Code constants, for clients to act on
+	Code string `json:"code,omitempty"`
 }
 
 // HealthResponse is the response for GET /health
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/MereWhiplash/engram-cogitator/internal/apitypes"
@@ -68,24 +69,38 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body interf
 	return c.http.Do(req)
 }
 
+// APIError is an error response from the API. It matches the error in types
+// for its code with errors.Is, so callers can tell a missing memory from an
+// invalid request or an unavailable embedder.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("API error: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("API error: %s", e.Message)
+}
+
+// Unwrap returns the error in types for the code, if any
+func (e *APIError) Unwrap() error {
+	return apitypes.CodeError(e.Code)
+}
+
 // parseErrorResponse extracts error message from API response
 func parseErrorResponse(resp *http.Response) error {
 	var errResp apitypes.ErrorResponse
-	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Error == "" {
-		return fmt.Errorf("API error: status %d", resp.StatusCode)
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+		return &APIError{StatusCode: resp.StatusCode}
 	}
-	return fmt.Errorf("API error: %s", errResp.Error)
+	return &APIError{StatusCode: resp.StatusCode, Code: errResp.Code, Message: errResp.Error}
 }
 
 // Add creates a new memory
-func (c *Client) Add(ctx context.Context, memType, area, content, rationale string) (*types.Memory, error) {
-	req := apitypes.AddRequest{
-		Type:      memType,
-		Area:      area,
-		Content:   content,
-		Rationale: rationale,
-	}
-
+func (c *Client) Add(ctx context.Context, req apitypes.AddRequest) (*types.Memory, error) {
 	resp, err := c.doRequest(ctx, "POST", "/v1/memories", req)
 	if err != nil {
 		return nil, err
@@ -104,13 +119,40 @@ func (c *Client) Add(ctx context.Context, memType, area, content, rationale stri
 	return result.Memory, nil
 }
 
+// AddBatch creates the memories in req together and returns a result for
+// each, in order
+func (c *Client) AddBatch(ctx context.Context, req apitypes.BatchAddRequest) (*apitypes.BatchAddResponse, error) {
+	resp, err := c.doRequest(ctx, "POST", "/v1/memories:batch", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	var result apitypes.BatchAddResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
 // Search finds memories by query
-func (c *Client) Search(ctx context.Context, query string, limit int, memType, area string) ([]types.Memory, error) {
+func (c *Client) Search(ctx context.Context, query string, limit int, memType, area, mode string, tags types.TagFilter, rank types.RankingOverride) ([]types.Memory, error) {
 	req := apitypes.SearchRequest{
-		Query: query,
-		Limit: limit,
-		Type:  memType,
-		Area:  area,
+		Query:               query,
+		Limit:               limit,
+		Type:                memType,
+		Area:                area,
+		Mode:                mode,
+		TagsAny:             tags.Any,
+		TagsAll:             tags.All,
+		RecencyWeight:       rank.RecencyWeight,
+		RecencyHalfLifeDays: rank.RecencyHalfLifeDays,
+		UsageWeight:         rank.UsageWeight,
 	}
 
 	resp, err := c.doRequest(ctx, "POST", "/v1/memories/search", req)
@@ -131,8 +173,9 @@ func (c *Client) Search(ctx context.Context, query string, limit int, memType, a
 	return result.Memories, nil
 }
 
-// List returns recent memories
-func (c *Client) List(ctx context.Context, limit int, memType, area string, includeInvalid bool) ([]types.Memory, error) {
+// List returns a page of memories, newest first, and the cursor for the
+// next page ("" on the last one). Pass "" as cursor to start from the newest.
+func (c *Client) List(ctx context.Context, limit int, memType, area string, includeInvalid bool, cursor string, tags types.TagFilter) ([]types.Memory, string, error) {
 	params := url.Values{}
 	params.Set("limit", strconv.Itoa(limit))
 	if memType != "" {
@@ -144,8 +187,66 @@ func (c *Client) List(ctx context.Context, limit int, memType, area string, incl
 	if includeInvalid {
 		params.Set("include_invalid", "true")
 	}
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+	if len(tags.Any) > 0 {
+		params.Set("tags_any", strings.Join(tags.Any, ","))
+	}
+	if len(tags.All) > 0 {
+		params.Set("tags_all", strings.Join(tags.All, ","))
+	}
 	path := "/v1/memories?" + params.Encode()
 
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", parseErrorResponse(resp)
+	}
+
+	var result apitypes.ListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if result.Pagination != nil {
+		next = result.Pagination.NextCursor
+	}
+	return result.Memories, next, nil
+}
+
+// Tags returns the tags in use on valid memories with their counts, most used first
+func (c *Client) Tags(ctx context.Context) ([]types.TagCount, error) {
+	resp, err := c.doRequest(ctx, "GET", "/v1/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	var result apitypes.TagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Tags, nil
+}
+
+// Stats counts the memories by repo, area, type, author and validity, with
+// growth per period ("day", "week" or "month"; empty uses the server default)
+func (c *Client) Stats(ctx context.Context, period string) (*types.Stats, error) {
+	path := "/v1/stats"
+	if period != "" {
+		path += "?period=" + url.QueryEscape(period)
+	}
 	resp, err := c.doRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
@@ -156,7 +257,125 @@ func (c *Client) List(ctx context.Context, limit int, memType, area string, incl
 		return nil, parseErrorResponse(resp)
 	}
 
-	var result apitypes.ListResponse
+	var result apitypes.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Stats, nil
+}
+
+// Repos returns every repo with valid memories, most used first
+func (c *Client) Repos(ctx context.Context) ([]types.CatalogEntry, error) {
+	resp, err := c.doRequest(ctx, "GET", "/v1/repos", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	var result apitypes.ReposResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Repos, nil
+}
+
+// Areas returns the areas of valid memories in the client's repo, most used first
+func (c *Client) Areas(ctx context.Context) ([]types.CatalogEntry, error) {
+	resp, err := c.doRequest(ctx, "GET", "/v1/areas", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	var result apitypes.AreasResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Areas, nil
+}
+
+// Authors returns the authors of valid memories in the client's repo, most
+// prolific first
+func (c *Client) Authors(ctx context.Context) ([]types.CatalogEntry, error) {
+	resp, err := c.doRequest(ctx, "GET", "/v1/authors", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	var result apitypes.AuthorsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Authors, nil
+}
+
+// DueForReview returns valid memories whose review date has passed, most
+// overdue first. A limit of 0 uses the server default.
+func (c *Client) DueForReview(ctx context.Context, limit int) ([]types.Memory, error) {
+	path := "/v1/memories/review"
+	if limit > 0 {
+		path += "?limit=" + strconv.Itoa(limit)
+	}
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	var result apitypes.ReviewResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Memories, nil
+}
+
+// Stale returns valid memories at least minAgeDays old that have never been
+// retrieved, oldest first. Zero for either uses the server default.
+func (c *Client) Stale(ctx context.Context, limit, minAgeDays int) ([]types.Memory, error) {
+	params := url.Values{}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	if minAgeDays > 0 {
+		params.Set("min_age_days", strconv.Itoa(minAgeDays))
+	}
+	path := "/v1/memories/stale"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	var result apitypes.StaleResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
 	}
@@ -164,12 +383,86 @@ func (c *Client) List(ctx context.Context, limit int, memType, area string, incl
 	return result.Memories, nil
 }
 
-// Invalidate marks a memory as invalid
-func (c *Client) Invalidate(ctx context.Context, id int64, supersededBy *int64) error {
-	req := apitypes.InvalidateRequest{
-		SupersededBy: supersededBy,
+// Get returns a single memory by ID. A missing ID returns types.ErrNotFound.
+func (c *Client) Get(ctx context.Context, id int64) (*types.Memory, error) {
+	path := fmt.Sprintf("/v1/memories/%d", id)
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, types.ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	var result apitypes.GetResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Memory, nil
+}
+
+// Lineage returns a memory's supersession chain. A missing ID returns
+// types.ErrNotFound.
+func (c *Client) Lineage(ctx context.Context, id int64) (*types.Lineage, error) {
+	path := fmt.Sprintf("/v1/memories/%d/lineage", id)
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, types.ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
 	}
 
+	var result apitypes.LineageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result.Lineage, nil
+}
+
+// Update edits a memory in place. A missing ID returns types.ErrNotFound and a
+// stale expected version returns types.ErrVersionConflict.
+func (c *Client) Update(ctx context.Context, id int64, req apitypes.UpdateRequest) (*types.Memory, error) {
+	path := fmt.Sprintf("/v1/memories/%d", id)
+	resp, err := c.doRequest(ctx, "PATCH", path, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, types.ErrNotFound
+	case http.StatusConflict:
+		return nil, fmt.Errorf("%w: %v", types.ErrVersionConflict, parseErrorResponse(resp))
+	default:
+		return nil, parseErrorResponse(resp)
+	}
+
+	var result apitypes.UpdateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Memory, nil
+}
+
+// Invalidate marks a memory as invalid. The server records the git author
+// sent with the request as the invalidator.
+func (c *Client) Invalidate(ctx context.Context, id int64, req apitypes.InvalidateRequest) error {
 	path := fmt.Sprintf("/v1/memories/%d/invalidate", id)
 	resp, err := c.doRequest(ctx, "PUT", path, req)
 	if err != nil {
@@ -183,3 +476,70 @@ func (c *Client) Invalidate(ctx context.Context, id int64, supersededBy *int64)
 
 	return nil
 }
+
+// Revalidate restores an invalidated memory and returns it. The server
+// records the git author sent with the request as the restorer.
+func (c *Client) Revalidate(ctx context.Context, id int64) (*types.Memory, error) {
+	path := fmt.Sprintf("/v1/memories/%d/revalidate", id)
+	resp, err := c.doRequest(ctx, "PUT", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, types.ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	var result apitypes.RevalidateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Memory, nil
+}
+
+// BulkInvalidate invalidates the memories matching req's filters, or with
+// req.DryRun only finds them, and returns their IDs
+func (c *Client) BulkInvalidate(ctx context.Context, req apitypes.BulkInvalidateRequest) (*apitypes.BulkInvalidateResponse, error) {
+	resp, err := c.doRequest(ctx, "POST", "/v1/memories/invalidate", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	var result apitypes.BulkInvalidateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Merge consolidates the memories in req.Sources into a new memory, which
+// supersedes them, and returns it
+func (c *Client) Merge(ctx context.Context, req apitypes.MergeRequest) (*types.Memory, error) {
+	resp, err := c.doRequest(ctx, "POST", "/v1/memories/merge", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, parseErrorResponse(resp)
+	}
+
+	var result apitypes.MergeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Memory, nil
+}
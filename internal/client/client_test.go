@@ -3,6 +3,7 @@ package client_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -40,6 +41,9 @@ func TestClient_Add_Success(t *testing.T) {
 		if req.Type != "decision" {
 			t.Errorf("expected type 'decision', got %q", req.Type)
 		}
+		if len(req.Tags) != 1 || req.Tags[0] != "security" {
+			t.Errorf("expected tags [security], got %v", req.Tags)
+		}
 
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(apitypes.AddResponse{Memory: expectedMem})
@@ -47,7 +51,7 @@ func TestClient_Add_Success(t *testing.T) {
 	defer server.Close()
 
 	c := client.New(server.URL, nil)
-	mem, err := c.Add(context.Background(), "decision", "auth", "Use JWT", "Stateless")
+	mem, err := c.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use JWT", Rationale: "Stateless", Tags: []string{"security"}})
 	if err != nil {
 		t.Fatalf("Add failed: %v", err)
 	}
@@ -73,7 +77,7 @@ func TestClient_Add_WithGitInfo(t *testing.T) {
 	}
 
 	c := client.New(server.URL, gitInfo)
-	_, err := c.Add(context.Background(), "decision", "auth", "test", "")
+	_, err := c.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "test"})
 	if err != nil {
 		t.Fatalf("Add failed: %v", err)
 	}
@@ -97,7 +101,7 @@ func TestClient_Add_Error(t *testing.T) {
 	defer server.Close()
 
 	c := client.New(server.URL, nil)
-	_, err := c.Add(context.Background(), "invalid", "auth", "test", "")
+	_, err := c.Add(context.Background(), apitypes.AddRequest{Type: "invalid", Area: "auth", Content: "test"})
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
@@ -134,7 +138,7 @@ func TestClient_Search_Success(t *testing.T) {
 	defer server.Close()
 
 	c := client.New(server.URL, nil)
-	mems, err := c.Search(context.Background(), "test query", 5, "", "")
+	mems, err := c.Search(context.Background(), "test query", 5, "", "", "", types.TagFilter{}, types.RankingOverride{})
 	if err != nil {
 		t.Fatalf("Search failed: %v", err)
 	}
@@ -154,7 +158,8 @@ func TestClient_Search_WithFilters(t *testing.T) {
 	defer server.Close()
 
 	c := client.New(server.URL, nil)
-	_, _ = c.Search(context.Background(), "test", 10, "decision", "auth")
+	weight := 0.5
+	_, _ = c.Search(context.Background(), "test", 10, "decision", "auth", "keyword", types.TagFilter{}, types.RankingOverride{RecencyWeight: &weight})
 
 	if capturedReq.Type != "decision" {
 		t.Errorf("expected type 'decision', got %q", capturedReq.Type)
@@ -162,6 +167,12 @@ func TestClient_Search_WithFilters(t *testing.T) {
 	if capturedReq.Area != "auth" {
 		t.Errorf("expected area 'auth', got %q", capturedReq.Area)
 	}
+	if capturedReq.Mode != "keyword" {
+		t.Errorf("expected mode 'keyword', got %q", capturedReq.Mode)
+	}
+	if capturedReq.RecencyWeight == nil || *capturedReq.RecencyWeight != 0.5 || capturedReq.RecencyHalfLifeDays != nil {
+		t.Errorf("expected only recency_weight 0.5, got %v, %v", capturedReq.RecencyWeight, capturedReq.RecencyHalfLifeDays)
+	}
 }
 
 func TestClient_List_Success(t *testing.T) {
@@ -184,18 +195,24 @@ func TestClient_List_Success(t *testing.T) {
 		}
 
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(apitypes.ListResponse{Memories: expectedMems})
+		json.NewEncoder(w).Encode(apitypes.ListResponse{
+			Memories:   expectedMems,
+			Pagination: &apitypes.PaginationInfo{Limit: 10, HasMore: true, NextCursor: "next-page"},
+		})
 	}))
 	defer server.Close()
 
 	c := client.New(server.URL, nil)
-	mems, err := c.List(context.Background(), 10, "", "", false)
+	mems, next, err := c.List(context.Background(), 10, "", "", false, "", types.TagFilter{})
 	if err != nil {
 		t.Fatalf("List failed: %v", err)
 	}
 	if len(mems) != 2 {
 		t.Errorf("expected 2 results, got %d", len(mems))
 	}
+	if next != "next-page" {
+		t.Errorf("expected next cursor 'next-page', got %q", next)
+	}
 }
 
 func TestClient_List_WithFilters(t *testing.T) {
@@ -210,6 +227,12 @@ func TestClient_List_WithFilters(t *testing.T) {
 		if query.Get("include_invalid") != "true" {
 			t.Errorf("expected include_invalid=true, got %q", query.Get("include_invalid"))
 		}
+		if query.Get("cursor") != "page-2" {
+			t.Errorf("expected cursor=page-2, got %q", query.Get("cursor"))
+		}
+		if query.Get("tags_any") != "db,payments" || query.Get("tags_all") != "postgres" {
+			t.Errorf("expected tags_any=db,payments and tags_all=postgres, got %q and %q", query.Get("tags_any"), query.Get("tags_all"))
+		}
 
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(apitypes.ListResponse{Memories: []types.Memory{}})
@@ -217,7 +240,459 @@ func TestClient_List_WithFilters(t *testing.T) {
 	defer server.Close()
 
 	c := client.New(server.URL, nil)
-	_, _ = c.List(context.Background(), 5, "decision", "auth", true)
+	_, _, _ = c.List(context.Background(), 5, "decision", "auth", true, "page-2", types.TagFilter{Any: []string{"db", "payments"}, All: []string{"postgres"}})
+}
+
+func TestClient_Tags(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/v1/tags" {
+			t.Errorf("expected GET /v1/tags, got %s %s", r.Method, r.URL.Path)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(apitypes.TagsResponse{Tags: []types.TagCount{{Tag: "db", Count: 3}, {Tag: "payments", Count: 1}}})
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, nil)
+	tags, err := c.Tags(context.Background())
+	if err != nil {
+		t.Fatalf("Tags failed: %v", err)
+	}
+	if len(tags) != 2 || tags[0].Tag != "db" || tags[0].Count != 3 {
+		t.Errorf("expected db (3) first of 2 tags, got %v", tags)
+	}
+}
+
+func TestClient_Stats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/v1/stats" {
+			t.Errorf("expected GET /v1/stats, got %s %s", r.Method, r.URL.Path)
+		}
+		if r.URL.Query().Get("period") != "week" {
+			t.Errorf("expected period=week, got %q", r.URL.Query().Get("period"))
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(apitypes.StatsResponse{Stats: &types.Stats{
+			Total: 3, Valid: 2, Invalid: 1, Period: types.PeriodWeek,
+			ByArea: []types.StatCount{{Value: "auth", Valid: 2, Invalid: 1}},
+		}})
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, nil)
+	stats, err := c.Stats(context.Background(), "week")
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Total != 3 || stats.Period != types.PeriodWeek || len(stats.ByArea) != 1 || stats.ByArea[0].Invalid != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestClient_Catalog(t *testing.T) {
+	active := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("expected GET, got %s", r.Method)
+		}
+		entries := []types.CatalogEntry{{Value: "v", Name: "n", Count: 2, LastActivity: active}}
+
+		w.WriteHeader(http.StatusOK)
+		switch r.URL.Path {
+		case "/v1/repos":
+			json.NewEncoder(w).Encode(apitypes.ReposResponse{Repos: entries})
+		case "/v1/areas":
+			json.NewEncoder(w).Encode(apitypes.AreasResponse{Areas: entries})
+		case "/v1/authors":
+			json.NewEncoder(w).Encode(apitypes.AuthorsResponse{Authors: entries})
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, nil)
+	for name, list := range map[string]func(context.Context) ([]types.CatalogEntry, error){
+		"Repos":   c.Repos,
+		"Areas":   c.Areas,
+		"Authors": c.Authors,
+	} {
+		entries, err := list(context.Background())
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		if len(entries) != 1 || entries[0].Value != "v" || entries[0].Count != 2 || !entries[0].LastActivity.Equal(active) {
+			t.Errorf("%s: unexpected entries %+v", name, entries)
+		}
+	}
+}
+
+func TestClient_DueForReview(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/v1/memories/review" {
+			t.Errorf("expected GET /v1/memories/review, got %s %s", r.Method, r.URL.Path)
+		}
+		if r.URL.Query().Get("limit") != "5" {
+			t.Errorf("expected limit=5, got %q", r.URL.Query().Get("limit"))
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(apitypes.ReviewResponse{Memories: []types.Memory{{ID: 7, Content: "Pool maxes at 20"}}})
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, nil)
+	memories, err := c.DueForReview(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("DueForReview failed: %v", err)
+	}
+	if len(memories) != 1 || memories[0].ID != 7 {
+		t.Errorf("expected memory 7, got %+v", memories)
+	}
+}
+
+func TestClient_Stale(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/v1/memories/stale" {
+			t.Errorf("expected GET /v1/memories/stale, got %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.URL.Query().Get("min_age_days"); got != "90" {
+			t.Errorf("expected min_age_days=90, got %q", got)
+		}
+		if r.URL.Query().Has("limit") {
+			t.Errorf("expected no limit, got %q", r.URL.Query().Get("limit"))
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(apitypes.StaleResponse{Memories: []types.Memory{{ID: 7, Content: "Pool maxes at 20"}}})
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, nil)
+	memories, err := c.Stale(context.Background(), 0, 90)
+	if err != nil {
+		t.Fatalf("Stale failed: %v", err)
+	}
+	if len(memories) != 1 || memories[0].ID != 7 {
+		t.Errorf("expected memory 7, got %+v", memories)
+	}
+}
+
+func TestClient_Get_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("expected GET, got %s", r.Method)
+		}
+		if r.URL.Path != "/v1/memories/123" {
+			t.Errorf("expected /v1/memories/123, got %s", r.URL.Path)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(apitypes.GetResponse{Memory: &types.Memory{ID: 123, Content: "Memory 123"}})
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, nil)
+	mem, err := c.Get(context.Background(), 123)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if mem.ID != 123 {
+		t.Errorf("expected ID 123, got %d", mem.ID)
+	}
+}
+
+func TestClient_Get_NotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(apitypes.ErrorResponse{Error: "memory not found"})
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, nil)
+	_, err := c.Get(context.Background(), 999)
+	if !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("expected types.ErrNotFound, got %v", err)
+	}
+}
+
+func TestClient_Lineage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/memories/999/lineage" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(apitypes.ErrorResponse{Error: "memory not found"})
+			return
+		}
+		if r.URL.Path != "/v1/memories/1/lineage" {
+			t.Errorf("expected /v1/memories/1/lineage, got %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(apitypes.LineageResponse{Lineage: types.Lineage{
+			Memory:      types.Memory{ID: 1},
+			Descendants: []types.Memory{{ID: 2, IsValid: true}},
+			Current:     &types.Memory{ID: 2, IsValid: true},
+		}})
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, nil)
+	lineage, err := c.Lineage(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Lineage failed: %v", err)
+	}
+	if lineage.Memory.ID != 1 || lineage.Current == nil || lineage.Current.ID != 2 {
+		t.Errorf("expected memory 1 superseded by current memory 2, got %+v", lineage)
+	}
+
+	if _, err := c.Lineage(context.Background(), 999); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("expected types.ErrNotFound, got %v", err)
+	}
+}
+
+func TestClient_Revalidate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/memories/999/revalidate" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(apitypes.ErrorResponse{Error: "memory not found"})
+			return
+		}
+		if r.Method != "PUT" {
+			t.Errorf("expected PUT, got %s", r.Method)
+		}
+		if r.URL.Path != "/v1/memories/1/revalidate" {
+			t.Errorf("expected /v1/memories/1/revalidate, got %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(apitypes.RevalidateResponse{Memory: &types.Memory{ID: 1, IsValid: true}})
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, nil)
+	mem, err := c.Revalidate(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Revalidate failed: %v", err)
+	}
+	if mem.ID != 1 || !mem.IsValid {
+		t.Errorf("expected valid memory 1, got %+v", mem)
+	}
+
+	if _, err := c.Revalidate(context.Background(), 999); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("expected types.ErrNotFound, got %v", err)
+	}
+}
+
+func TestClient_AddBatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/memories:batch" {
+			t.Errorf("expected POST /v1/memories:batch, got %s %s", r.Method, r.URL.Path)
+		}
+		var req apitypes.BatchAddRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Memories) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(apitypes.ErrorResponse{Error: "memories are required", Code: apitypes.CodeValidation})
+			return
+		}
+		json.NewEncoder(w).Encode(apitypes.BatchAddResponse{
+			Results: []apitypes.BatchAddResult{
+				{Memory: &types.Memory{ID: 1, Content: req.Memories[0].Content}},
+				{Error: "type, area, and content are required", Code: apitypes.CodeValidation},
+			},
+			Added: 1,
+		})
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, nil)
+	resp, err := c.AddBatch(context.Background(), apitypes.BatchAddRequest{Memories: []apitypes.AddRequest{
+		{Type: "decision", Area: "auth", Content: "Use JWT"},
+		{Type: "decision"},
+	}})
+	if err != nil {
+		t.Fatalf("AddBatch failed: %v", err)
+	}
+	if resp.Added != 1 || len(resp.Results) != 2 || resp.Results[0].Memory.Content != "Use JWT" || resp.Results[1].Code != apitypes.CodeValidation {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	_, err = c.AddBatch(context.Background(), apitypes.BatchAddRequest{})
+	if !errors.Is(err, types.ErrValidation) {
+		t.Errorf("expected types.ErrValidation, got %v", err)
+	}
+}
+
+func TestClient_BulkInvalidate(t *testing.T) {
+	var captured apitypes.BulkInvalidateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/memories/invalidate" {
+			t.Errorf("expected POST /v1/memories/invalidate, got %s %s", r.Method, r.URL.Path)
+		}
+		captured = apitypes.BulkInvalidateRequest{}
+		json.NewDecoder(r.Body).Decode(&captured)
+		if captured.Area == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(apitypes.ErrorResponse{Error: "at least one of repo, area, type, author or created_before is required", Code: apitypes.CodeValidation})
+			return
+		}
+		json.NewEncoder(w).Encode(apitypes.BulkInvalidateResponse{IDs: []int64{4, 7}, DryRun: captured.DryRun})
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, nil)
+	resp, err := c.BulkInvalidate(context.Background(), apitypes.BulkInvalidateRequest{Area: "graphql", Reason: "removed", DryRun: true})
+	if err != nil {
+		t.Fatalf("BulkInvalidate failed: %v", err)
+	}
+	if !resp.DryRun || len(resp.IDs) != 2 || resp.IDs[1] != 7 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if captured.Reason != "removed" {
+		t.Errorf("unexpected request: %+v", captured)
+	}
+
+	_, err = c.BulkInvalidate(context.Background(), apitypes.BulkInvalidateRequest{})
+	if !errors.Is(err, types.ErrValidation) {
+		t.Errorf("expected types.ErrValidation, got %v", err)
+	}
+}
+
+func TestClient_Merge(t *testing.T) {
+	var captured apitypes.MergeRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/memories/merge" {
+			t.Errorf("expected POST /v1/memories/merge, got %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&captured)
+		if len(captured.Sources) < 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(apitypes.ErrorResponse{Error: "merge needs at least two distinct source memories", Code: apitypes.CodeValidation})
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(apitypes.MergeResponse{Memory: &types.Memory{ID: 3, Content: captured.Content, IsValid: true}})
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, nil)
+	mem, err := c.Merge(context.Background(), apitypes.MergeRequest{
+		AddRequest: apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use JWT"},
+		Sources:    []int64{1, 2},
+		Reason:     "duplicates",
+	})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if mem.ID != 3 || mem.Content != "Use JWT" {
+		t.Errorf("unexpected memory: %+v", mem)
+	}
+	if captured.Reason != "duplicates" || captured.Type != "decision" {
+		t.Errorf("unexpected request: %+v", captured)
+	}
+
+	_, err = c.Merge(context.Background(), apitypes.MergeRequest{Sources: []int64{1}})
+	if !errors.Is(err, types.ErrValidation) {
+		t.Errorf("expected types.ErrValidation, got %v", err)
+	}
+}
+
+func TestClient_Update_Success(t *testing.T) {
+	var capturedReq apitypes.UpdateRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" {
+			t.Errorf("expected PATCH, got %s", r.Method)
+		}
+		if r.URL.Path != "/v1/memories/123" {
+			t.Errorf("expected /v1/memories/123, got %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&capturedReq)
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(apitypes.UpdateResponse{Memory: &types.Memory{ID: 123, Version: 2}})
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, nil)
+	content := "Use PASETO"
+	mem, err := c.Update(context.Background(), 123, apitypes.UpdateRequest{Content: &content, ExpectedVersion: 1})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if mem.Version != 2 {
+		t.Errorf("expected version 2, got %d", mem.Version)
+	}
+	if capturedReq.Content == nil || *capturedReq.Content != "Use PASETO" {
+		t.Errorf("expected content to be sent, got %v", capturedReq.Content)
+	}
+	if capturedReq.Area != nil {
+		t.Errorf("expected unset area to be omitted, got %q", *capturedReq.Area)
+	}
+	if capturedReq.ExpectedVersion != 1 {
+		t.Errorf("expected expected_version=1, got %d", capturedReq.ExpectedVersion)
+	}
+}
+
+func TestClient_Update_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr error
+	}{
+		{"not found", http.StatusNotFound, types.ErrNotFound},
+		{"conflict", http.StatusConflict, types.ErrVersionConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				json.NewEncoder(w).Encode(apitypes.ErrorResponse{Error: tt.name})
+			}))
+			defer server.Close()
+
+			c := client.New(server.URL, nil)
+			content := "x"
+			_, err := c.Update(context.Background(), 1, apitypes.UpdateRequest{Content: &content})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestClient_ErrorCodes(t *testing.T) {
+	tests := []struct {
+		code    string
+		status  int
+		wantErr error
+	}{
+		{apitypes.CodeValidation, http.StatusBadRequest, types.ErrValidation},
+		{apitypes.CodeInvalidSupersede, http.StatusUnprocessableEntity, types.ErrInvalidSupersede},
+		{apitypes.CodeEmbedderUnavailable, http.StatusServiceUnavailable, types.ErrEmbedderUnavailable},
+		{apitypes.CodeConflict, http.StatusConflict, types.ErrVersionConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				json.NewEncoder(w).Encode(apitypes.ErrorResponse{Error: "rejected", Code: tt.code})
+			}))
+			defer server.Close()
+
+			c := client.New(server.URL, nil)
+			err := c.Invalidate(context.Background(), 1, apitypes.InvalidateRequest{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+			var apiErr *client.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || apiErr.Message != "rejected" {
+				t.Errorf("expected an APIError with status %d, got %#v", tt.status, err)
+			}
+		})
+	}
 }
 
 func TestClient_Invalidate_Success(t *testing.T) {
@@ -235,7 +710,7 @@ func TestClient_Invalidate_Success(t *testing.T) {
 	defer server.Close()
 
 	c := client.New(server.URL, nil)
-	err := c.Invalidate(context.Background(), 123, nil)
+	err := c.Invalidate(context.Background(), 123, apitypes.InvalidateRequest{})
 	if err != nil {
 		t.Fatalf("Invalidate failed: %v", err)
 	}
@@ -253,7 +728,7 @@ func TestClient_Invalidate_WithSupersededBy(t *testing.T) {
 
 	c := client.New(server.URL, nil)
 	supersededBy := int64(456)
-	err := c.Invalidate(context.Background(), 123, &supersededBy)
+	err := c.Invalidate(context.Background(), 123, apitypes.InvalidateRequest{SupersededBy: &supersededBy, Reason: "replaced by JWT"})
 	if err != nil {
 		t.Fatalf("Invalidate failed: %v", err)
 	}
@@ -261,6 +736,9 @@ func TestClient_Invalidate_WithSupersededBy(t *testing.T) {
 	if capturedReq.SupersededBy == nil || *capturedReq.SupersededBy != 456 {
 		t.Errorf("expected superseded_by=456, got %v", capturedReq.SupersededBy)
 	}
+	if capturedReq.Reason != "replaced by JWT" {
+		t.Errorf("expected the reason sent, got %q", capturedReq.Reason)
+	}
 }
 
 func TestClient_Invalidate_Error(t *testing.T) {
@@ -271,7 +749,7 @@ func TestClient_Invalidate_Error(t *testing.T) {
 	defer server.Close()
 
 	c := client.New(server.URL, nil)
-	err := c.Invalidate(context.Background(), 999, nil)
+	err := c.Invalidate(context.Background(), 999, apitypes.InvalidateRequest{})
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
@@ -284,22 +762,22 @@ func TestClient_NetworkError(t *testing.T) {
 
 	c := client.New(server.URL, nil)
 
-	_, err := c.Add(context.Background(), "decision", "auth", "test", "")
+	_, err := c.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "test"})
 	if err == nil {
 		t.Error("expected network error, got nil")
 	}
 
-	_, err = c.Search(context.Background(), "test", 5, "", "")
+	_, err = c.Search(context.Background(), "test", 5, "", "", "", types.TagFilter{}, types.RankingOverride{})
 	if err == nil {
 		t.Error("expected network error, got nil")
 	}
 
-	_, err = c.List(context.Background(), 10, "", "", false)
+	_, _, err = c.List(context.Background(), 10, "", "", false, "", types.TagFilter{})
 	if err == nil {
 		t.Error("expected network error, got nil")
 	}
 
-	err = c.Invalidate(context.Background(), 1, nil)
+	err = c.Invalidate(context.Background(), 1, apitypes.InvalidateRequest{})
 	if err == nil {
 		t.Error("expected network error, got nil")
 	}
@@ -318,7 +796,7 @@ func TestClient_ContextCancellation(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // Cancel immediately
 
-	_, err := c.Add(ctx, "decision", "auth", "test", "")
+	_, err := c.Add(ctx, apitypes.AddRequest{Type: "decision", Area: "auth", Content: "test"})
 	if err == nil {
 		t.Error("expected context cancellation error, got nil")
 	}
@@ -6,4 +6,31 @@ type Embedder interface {
 	EmbedForStorage(text string) ([]float32, error)
 	// EmbedForSearch creates an embedding optimized for search queries
 	EmbedForSearch(query string) ([]float32, error)
+	// Model names the embedding model, recorded alongside every stored vector
+	Model() string
+}
+
+// BatchEmbedder is implemented by embedders that can embed several documents
+// in one call
+type BatchEmbedder interface {
+	// EmbedBatchForStorage embeds texts as EmbedForStorage would, returning
+	// one vector per text in order
+	EmbedBatchForStorage(texts []string) ([][]float32, error)
+}
+
+// EmbedAllForStorage embeds texts for storage, in one call if e is a
+// BatchEmbedder and one at a time otherwise
+func EmbedAllForStorage(e Embedder, texts []string) ([][]float32, error) {
+	if b, ok := e.(BatchEmbedder); ok {
+		return b.EmbedBatchForStorage(texts)
+	}
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		v, err := e.EmbedForStorage(text)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
 }
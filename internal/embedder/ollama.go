@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -25,6 +26,32 @@ type embeddingResponse struct {
 	Embedding []float32 `json:"embedding"`
 }
 
+// batchRequest and batchResponse are the /api/embed forms, which take many inputs
+type batchRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type batchResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// knownDimensions lists the output size of common Ollama embedding models
+var knownDimensions = map[string]int{
+	"nomic-embed-text":       768,
+	"mxbai-embed-large":      1024,
+	"all-minilm":             384,
+	"snowflake-arctic-embed": 1024,
+	"bge-m3":                 1024,
+}
+
+// KnownDimension returns the embedding size of a well-known Ollama model
+// (ignoring any ":tag" suffix), or 0 if the model is not known
+func KnownDimension(model string) int {
+	name, _, _ := strings.Cut(model, ":")
+	return knownDimensions[name]
+}
+
 // NewOllama creates a new Ollama embedder
 func NewOllama(baseURL, model string) *Ollama {
 	return &Ollama{
@@ -70,6 +97,42 @@ func (o *Ollama) embed(text string) ([]float32, error) {
 	return embResp.Embedding, nil
 }
 
+func (o *Ollama) embedBatch(texts []string) ([][]float32, error) {
+	jsonBody, err := json.Marshal(batchRequest{Model: o.model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := o.http.Post(
+		fmt.Sprintf("%s/api/embed", o.baseURL),
+		"application/json",
+		bytes.NewReader(jsonBody),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var embResp batchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(embResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama returned %d embeddings for %d inputs", len(embResp.Embeddings), len(texts))
+	}
+
+	return embResp.Embeddings, nil
+}
+
+func (o *Ollama) Model() string {
+	return o.model
+}
+
 func (o *Ollama) EmbedForStorage(text string) ([]float32, error) {
 	if o.model == "nomic-embed-text" {
 		return o.embed("search_document: " + text)
@@ -83,3 +146,14 @@ func (o *Ollama) EmbedForSearch(query string) ([]float32, error) {
 	}
 	return o.embed(query)
 }
+
+func (o *Ollama) EmbedBatchForStorage(texts []string) ([][]float32, error) {
+	if o.model != "nomic-embed-text" {
+		return o.embedBatch(texts)
+	}
+	prefixed := make([]string, len(texts))
+	for i, text := range texts {
+		prefixed[i] = "search_document: " + text
+	}
+	return o.embedBatch(prefixed)
+}
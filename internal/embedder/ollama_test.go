@@ -136,3 +136,64 @@ func TestOllama_HTTPError(t *testing.T) {
 		t.Error("expected error on HTTP 500")
 	}
 }
+
+func TestOllama_EmbedBatchForStorage(t *testing.T) {
+	var received batchRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+
+		resp := batchResponse{}
+		for range received.Input {
+			resp.Embeddings = append(resp.Embeddings, make([]float32, 768))
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewOllama(server.URL, "nomic-embed-text")
+	embs, err := client.EmbedBatchForStorage([]string{"first", "second"})
+	if err != nil {
+		t.Fatalf("EmbedBatchForStorage failed: %v", err)
+	}
+
+	if len(embs) != 2 || len(embs[1]) != 768 {
+		t.Errorf("expected 2 embeddings of 768 dimensions, got %d", len(embs))
+	}
+	if len(received.Input) != 2 || received.Input[0] != "search_document: first" {
+		t.Errorf("expected prefixed inputs, got %q", received.Input)
+	}
+}
+
+func TestOllama_EmbedBatchForStorage_CountMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(batchResponse{Embeddings: [][]float32{make([]float32, 768)}})
+	}))
+	defer server.Close()
+
+	client := NewOllama(server.URL, "all-minilm")
+	if _, err := client.EmbedBatchForStorage([]string{"first", "second"}); err == nil {
+		t.Error("expected an error when fewer embeddings come back than inputs")
+	}
+}
+
+func TestKnownDimension(t *testing.T) {
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{"nomic-embed-text", 768},
+		{"nomic-embed-text:latest", 768},
+		{"mxbai-embed-large", 1024},
+		{"my-custom-model", 0},
+	}
+
+	for _, tt := range tests {
+		if got := KnownDimension(tt.model); got != tt.want {
+			t.Errorf("KnownDimension(%q) = %d, want %d", tt.model, got, tt.want)
+		}
+	}
+}
@@ -5,19 +5,51 @@ package mcptypes
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
+	"github.com/MereWhiplash/engram-cogitator/internal/apitypes"
 	"github.com/MereWhiplash/engram-cogitator/internal/types"
 )
 
 // AddInput defines the input schema for ec_add
 type AddInput struct {
-	Type      string `json:"type" jsonschema:"required" jsonschema_description:"Type of memory: decision, learning, or pattern"`
-	Area      string `json:"area" jsonschema:"required" jsonschema_description:"Domain area (e.g. auth, permissions, ui, api)"`
-	Content   string `json:"content" jsonschema:"required" jsonschema_description:"The actual content to remember"`
-	Rationale string `json:"rationale,omitempty" jsonschema_description:"Why this matters or additional context"`
+	Type      string   `json:"type" jsonschema:"required" jsonschema_description:"Type of memory: decision, learning, or pattern"`
+	Area      string   `json:"area" jsonschema:"required" jsonschema_description:"Domain area (e.g. auth, permissions, ui, api)"`
+	Content   string   `json:"content" jsonschema:"required" jsonschema_description:"The actual content to remember"`
+	Rationale string   `json:"rationale,omitempty" jsonschema_description:"Why this matters or additional context"`
+	Tags      []string `json:"tags,omitempty" jsonschema_description:"Labels that cut across areas (e.g. db, payments); reuse existing ones from ec_tags"`
+	ExpiresAt string   `json:"expires_at,omitempty" jsonschema_description:"Date (YYYY-MM-DD) after which this stops being true; the memory is invalidated then"`
+	ReviewBy  string   `json:"review_by,omitempty" jsonschema_description:"Date (YYYY-MM-DD) by which this should be rechecked; it then shows up in ec_review_due"`
+}
+
+// Request converts the input to an API add request
+func (in AddInput) Request() apitypes.AddRequest {
+	return apitypes.AddRequest{
+		Type:      in.Type,
+		Area:      in.Area,
+		Content:   in.Content,
+		Rationale: in.Rationale,
+		Tags:      in.Tags,
+		ExpiresAt: in.ExpiresAt,
+		ReviewBy:  in.ReviewBy,
+	}
+}
+
+// Dates parses the input's expiry and review dates; omitted ones are nil
+func (in AddInput) Dates() (expiresAt, reviewBy *time.Time, err error) {
+	if expiresAt, err = parseDate(in.ExpiresAt); err != nil {
+		return nil, nil, err
+	}
+	if reviewBy, err = parseDate(in.ReviewBy); err != nil {
+		return nil, nil, err
+	}
+	return expiresAt, reviewBy, nil
 }
 
 // AddOutput defines the output schema for ec_add
@@ -25,12 +57,55 @@ type AddOutput struct {
 	Memory *types.Memory `json:"memory"`
 }
 
+// AddBatchInput defines the input schema for ec_add_batch
+type AddBatchInput struct {
+	Memories []AddInput `json:"memories" jsonschema:"required" jsonschema_description:"Memories to add, each with the fields of ec_add; at most 100"`
+}
+
+// Request converts the input to the API request
+func (in AddBatchInput) Request() apitypes.BatchAddRequest {
+	req := apitypes.BatchAddRequest{Memories: make([]apitypes.AddRequest, len(in.Memories))}
+	for i, m := range in.Memories {
+		req.Memories[i] = m.Request()
+	}
+	return req
+}
+
+// AddBatchOutput defines the output schema for ec_add_batch
+type AddBatchOutput struct {
+	// Results has one entry per input memory, in order
+	Results []apitypes.BatchAddResult `json:"results"`
+	Added   int                       `json:"added"`
+}
+
 // SearchInput defines the input schema for ec_search
 type SearchInput struct {
-	Query string `json:"query" jsonschema:"required" jsonschema_description:"Search query to find relevant memories"`
-	Limit int    `json:"limit,omitempty" jsonschema_description:"Maximum number of results (default: 5)"`
-	Type  string `json:"type,omitempty" jsonschema_description:"Filter by type (decision, learning, or pattern)"`
-	Area  string `json:"area,omitempty" jsonschema_description:"Filter by domain area"`
+	Query   string   `json:"query" jsonschema:"required" jsonschema_description:"Search query to find relevant memories"`
+	Limit   int      `json:"limit,omitempty" jsonschema_description:"Maximum number of results (default: 5)"`
+	Type    string   `json:"type,omitempty" jsonschema_description:"Filter by type (decision, learning, or pattern)"`
+	Area    string   `json:"area,omitempty" jsonschema_description:"Filter by domain area"`
+	Mode    string   `json:"mode,omitempty" jsonschema_description:"semantic (meaning), keyword (exact terms like error codes or identifiers), or hybrid (both, default)"`
+	TagsAny []string `json:"tags_any,omitempty" jsonschema_description:"Only memories with at least one of these tags"`
+	TagsAll []string `json:"tags_all,omitempty" jsonschema_description:"Only memories with every one of these tags"`
+	// Ranking overrides, for searches that need more or less recency than
+	// the server's policy gives
+	RecencyWeight       *float64 `json:"recency_weight,omitempty" jsonschema_description:"How much recency counts against relevance for this search, from 0 (relevance only) to 1 (recency only)"`
+	RecencyHalfLifeDays *float64 `json:"recency_half_life_days,omitempty" jsonschema_description:"Age in days at which a memory's recency boost halves, for this search"`
+	UsageWeight         *float64 `json:"usage_weight,omitempty" jsonschema_description:"How much how often a memory has been retrieved counts for this search, from 0 (ignored) to 1 (usage only)"`
+}
+
+// TagFilter returns the input's tag filter
+func (in SearchInput) TagFilter() types.TagFilter {
+	return types.TagFilter{Any: in.TagsAny, All: in.TagsAll}
+}
+
+// Ranking returns the input's ranking overrides
+func (in SearchInput) Ranking() types.RankingOverride {
+	return types.RankingOverride{
+		RecencyWeight:       in.RecencyWeight,
+		RecencyHalfLifeDays: in.RecencyHalfLifeDays,
+		UsageWeight:         in.UsageWeight,
+	}
 }
 
 // SearchOutput defines the output schema for ec_search
@@ -38,10 +113,105 @@ type SearchOutput struct {
 	Memories []types.Memory `json:"memories"`
 }
 
+// GetInput defines the input schema for ec_get
+type GetInput struct {
+	ID int64 `json:"id" jsonschema:"required" jsonschema_description:"ID of the memory to fetch"`
+}
+
+// GetOutput defines the output schema for ec_get
+type GetOutput struct {
+	Memory *types.Memory `json:"memory"`
+}
+
+// UpdateInput defines the input schema for ec_update
+type UpdateInput struct {
+	ID              int64  `json:"id" jsonschema:"required" jsonschema_description:"ID of the memory to edit"`
+	Type            string `json:"type,omitempty" jsonschema_description:"New type (decision, learning, or pattern); omit to keep the current type"`
+	Area            string `json:"area,omitempty" jsonschema_description:"New domain area; omit to keep the current area"`
+	Content         string `json:"content,omitempty" jsonschema_description:"New content; omit to keep the current content"`
+	Rationale       string `json:"rationale,omitempty" jsonschema_description:"New rationale; omit to keep the current rationale"`
+	ExpiresAt       string `json:"expires_at,omitempty" jsonschema_description:"New expiry date (YYYY-MM-DD), or none to remove it; omit to keep the current date"`
+	ReviewBy        string `json:"review_by,omitempty" jsonschema_description:"New review date (YYYY-MM-DD), or none to remove it; omit to keep the current date"`
+	ExpectedVersion int    `json:"expected_version,omitempty" jsonschema_description:"Version you last read; the edit is rejected if the memory changed since (optional)"`
+}
+
+// noDate is the UpdateInput date value that removes a date
+const noDate = "none"
+
+// HasChanges reports whether the input sets any field to edit
+func (in UpdateInput) HasChanges() bool {
+	return in.Type != "" || in.Area != "" || in.Content != "" || in.Rationale != "" ||
+		in.ExpiresAt != "" || in.ReviewBy != ""
+}
+
+// DateChanges parses the input's date edits as service.UpdateParams takes
+// them: nil leaves a date unchanged and the zero time removes it
+func (in UpdateInput) DateChanges() (expiresAt, reviewBy *time.Time, err error) {
+	change := func(s string) (*time.Time, error) {
+		if s == noDate {
+			return &time.Time{}, nil
+		}
+		return parseDate(s)
+	}
+	if expiresAt, err = change(in.ExpiresAt); err != nil {
+		return nil, nil, err
+	}
+	if reviewBy, err = change(in.ReviewBy); err != nil {
+		return nil, nil, err
+	}
+	return expiresAt, reviewBy, nil
+}
+
+// Request converts the input to an API update request. Empty fields are
+// left unchanged.
+func (in UpdateInput) Request() apitypes.UpdateRequest {
+	optional := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+	// The API clears a date when given an empty one
+	date := func(s string) *string {
+		if s == noDate {
+			s = ""
+			return &s
+		}
+		return optional(s)
+	}
+	return apitypes.UpdateRequest{
+		Type:            optional(in.Type),
+		Area:            optional(in.Area),
+		Content:         optional(in.Content),
+		Rationale:       optional(in.Rationale),
+		ExpiresAt:       date(in.ExpiresAt),
+		ReviewBy:        date(in.ReviewBy),
+		ExpectedVersion: in.ExpectedVersion,
+	}
+}
+
+// parseDate parses an optional date; empty means none
+func parseDate(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := types.ParseDate(s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// UpdateOutput defines the output schema for ec_update
+type UpdateOutput struct {
+	Memory *types.Memory `json:"memory"`
+}
+
 // InvalidateInput defines the input schema for ec_invalidate
 type InvalidateInput struct {
-	ID           int64 `json:"id" jsonschema:"required" jsonschema_description:"ID of the memory to invalidate"`
-	SupersededBy int64 `json:"superseded_by,omitempty" jsonschema_description:"ID of the memory that supersedes this one"`
+	ID           int64  `json:"id" jsonschema:"required" jsonschema_description:"ID of the memory to invalidate"`
+	SupersededBy int64  `json:"superseded_by,omitempty" jsonschema_description:"ID of the memory that supersedes this one"`
+	Reason       string `json:"reason,omitempty" jsonschema_description:"Why the memory no longer holds, kept with it for later readers"`
 }
 
 // InvalidateOutput defines the output schema for ec_invalidate
@@ -49,17 +219,151 @@ type InvalidateOutput struct {
 	Message string `json:"message"`
 }
 
+// RestoreInput defines the input schema for ec_restore
+type RestoreInput struct {
+	ID int64 `json:"id" jsonschema:"required" jsonschema_description:"ID of the invalidated memory to restore"`
+}
+
+// RestoreOutput defines the output schema for ec_restore
+type RestoreOutput struct {
+	Memory *types.Memory `json:"memory"`
+}
+
+// MergeInput defines the input schema for ec_merge
+type MergeInput struct {
+	Sources   []int64  `json:"sources" jsonschema:"required" jsonschema_description:"IDs of the valid memories to merge, at least two"`
+	Type      string   `json:"type" jsonschema:"required" jsonschema_description:"Type of the merged memory: decision, learning, or pattern"`
+	Area      string   `json:"area" jsonschema:"required" jsonschema_description:"Domain area of the merged memory"`
+	Content   string   `json:"content" jsonschema:"required" jsonschema_description:"The consolidated content, covering everything the sources said"`
+	Rationale string   `json:"rationale,omitempty" jsonschema_description:"Why this matters or additional context"`
+	Tags      []string `json:"tags,omitempty" jsonschema_description:"Labels for the merged memory; the sources' tags are not carried over"`
+	ExpiresAt string   `json:"expires_at,omitempty" jsonschema_description:"Date (YYYY-MM-DD) after which this stops being true"`
+	ReviewBy  string   `json:"review_by,omitempty" jsonschema_description:"Date (YYYY-MM-DD) by which this should be rechecked"`
+	Reason    string   `json:"reason,omitempty" jsonschema_description:"Why the sources were merged, kept with each of them"`
+}
+
+// memory returns the input's description of the merged memory
+func (in MergeInput) memory() AddInput {
+	return AddInput{
+		Type:      in.Type,
+		Area:      in.Area,
+		Content:   in.Content,
+		Rationale: in.Rationale,
+		Tags:      in.Tags,
+		ExpiresAt: in.ExpiresAt,
+		ReviewBy:  in.ReviewBy,
+	}
+}
+
+// Request converts the input to an API merge request
+func (in MergeInput) Request() apitypes.MergeRequest {
+	return apitypes.MergeRequest{AddRequest: in.memory().Request(), Sources: in.Sources, Reason: in.Reason}
+}
+
+// Dates parses the input's expiry and review dates; omitted ones are nil
+func (in MergeInput) Dates() (expiresAt, reviewBy *time.Time, err error) {
+	return in.memory().Dates()
+}
+
+// MergeOutput defines the output schema for ec_merge
+type MergeOutput struct {
+	Memory *types.Memory `json:"memory"`
+}
+
 // ListInput defines the input schema for ec_list
 type ListInput struct {
-	Limit          int    `json:"limit,omitempty" jsonschema_description:"Maximum number of results (default: 10)"`
-	Type           string `json:"type,omitempty" jsonschema_description:"Filter by type (decision, learning, or pattern)"`
-	Area           string `json:"area,omitempty" jsonschema_description:"Filter by domain area"`
-	IncludeInvalid bool   `json:"include_invalid,omitempty" jsonschema_description:"Include invalidated entries (default: false)"`
+	Limit          int      `json:"limit,omitempty" jsonschema_description:"Maximum number of results (default: 10)"`
+	Type           string   `json:"type,omitempty" jsonschema_description:"Filter by type (decision, learning, or pattern)"`
+	Area           string   `json:"area,omitempty" jsonschema_description:"Filter by domain area"`
+	IncludeInvalid bool     `json:"include_invalid,omitempty" jsonschema_description:"Include invalidated entries (default: false)"`
+	Cursor         string   `json:"cursor,omitempty" jsonschema_description:"next_cursor from the previous ec_list call, to fetch the following page"`
+	TagsAny        []string `json:"tags_any,omitempty" jsonschema_description:"Only memories with at least one of these tags"`
+	TagsAll        []string `json:"tags_all,omitempty" jsonschema_description:"Only memories with every one of these tags"`
+}
+
+// TagFilter returns the input's tag filter
+func (in ListInput) TagFilter() types.TagFilter {
+	return types.TagFilter{Any: in.TagsAny, All: in.TagsAll}
 }
 
 // ListOutput defines the output schema for ec_list
 type ListOutput struct {
 	Memories []types.Memory `json:"memories"`
+	// NextCursor is set when more memories follow this page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// TagsInput defines the input schema for ec_tags, which takes no arguments
+type TagsInput struct{}
+
+// TagsOutput defines the output schema for ec_tags
+type TagsOutput struct {
+	Tags []types.TagCount `json:"tags"`
+}
+
+// ReposInput defines the input schema for ec_repos, which takes no arguments
+type ReposInput struct{}
+
+// ReposOutput defines the output schema for ec_repos
+type ReposOutput struct {
+	Repos []types.CatalogEntry `json:"repos"`
+}
+
+// AreasInput defines the input schema for ec_areas, which takes no arguments
+type AreasInput struct{}
+
+// AreasOutput defines the output schema for ec_areas
+type AreasOutput struct {
+	Areas []types.CatalogEntry `json:"areas"`
+}
+
+// AuthorsInput defines the input schema for ec_authors, which takes no arguments
+type AuthorsInput struct{}
+
+// AuthorsOutput defines the output schema for ec_authors
+type AuthorsOutput struct {
+	Authors []types.CatalogEntry `json:"authors"`
+}
+
+// StatsInput defines the input schema for ec_stats
+type StatsInput struct {
+	Period string `json:"period,omitempty" jsonschema_description:"Length of the growth buckets: day, week, or month (default)"`
+}
+
+// StatsOutput defines the output schema for ec_stats
+type StatsOutput struct {
+	Stats *types.Stats `json:"stats"`
+}
+
+// ReviewInput defines the input schema for ec_review_due
+type ReviewInput struct {
+	Limit int `json:"limit,omitempty" jsonschema_description:"Maximum number of results (default: 20)"`
+}
+
+// ReviewOutput defines the output schema for ec_review_due
+type ReviewOutput struct {
+	Memories []types.Memory `json:"memories"`
+}
+
+// StaleInput defines the input schema for ec_stale
+type StaleInput struct {
+	Limit      int `json:"limit,omitempty" jsonschema_description:"Maximum number of results (default: 20)"`
+	MinAgeDays int `json:"min_age_days,omitempty" jsonschema_description:"Only memories at least this many days old (default: 30)"`
+}
+
+// StaleOutput defines the output schema for ec_stale
+type StaleOutput struct {
+	Memories []types.Memory `json:"memories"`
+}
+
+// LineageInput defines the input schema for ec_lineage
+type LineageInput struct {
+	ID int64 `json:"id" jsonschema:"required" jsonschema_description:"ID of the memory whose supersession chain to show"`
+}
+
+// LineageOutput defines the output schema for ec_lineage
+type LineageOutput struct {
+	Lineage *types.Lineage `json:"lineage"`
 }
 
 // TextResult creates a successful MCP result with text content
@@ -90,6 +394,48 @@ func EmptyListOutput() ListOutput {
 	return ListOutput{Memories: []types.Memory{}}
 }
 
+// EmptyTagsOutput returns a TagsOutput whose tags field marshals as []
+// rather than null (see EmptySearchOutput).
+func EmptyTagsOutput() TagsOutput {
+	return TagsOutput{Tags: []types.TagCount{}}
+}
+
+// EmptyReposOutput returns a ReposOutput whose repos field marshals as []
+// rather than null (see EmptySearchOutput).
+func EmptyReposOutput() ReposOutput {
+	return ReposOutput{Repos: []types.CatalogEntry{}}
+}
+
+// EmptyAreasOutput returns an AreasOutput whose areas field marshals as []
+// rather than null (see EmptySearchOutput).
+func EmptyAreasOutput() AreasOutput {
+	return AreasOutput{Areas: []types.CatalogEntry{}}
+}
+
+// EmptyAuthorsOutput returns an AuthorsOutput whose authors field marshals
+// as [] rather than null (see EmptySearchOutput).
+func EmptyAuthorsOutput() AuthorsOutput {
+	return AuthorsOutput{Authors: []types.CatalogEntry{}}
+}
+
+// EmptyAddBatchOutput returns an AddBatchOutput whose results field
+// marshals as [] rather than null (see EmptySearchOutput).
+func EmptyAddBatchOutput() AddBatchOutput {
+	return AddBatchOutput{Results: []apitypes.BatchAddResult{}}
+}
+
+// EmptyReviewOutput returns a ReviewOutput whose memories field marshals as
+// [] rather than null (see EmptySearchOutput).
+func EmptyReviewOutput() ReviewOutput {
+	return ReviewOutput{Memories: []types.Memory{}}
+}
+
+// EmptyStaleOutput returns a StaleOutput whose memories field marshals as
+// [] rather than null (see EmptySearchOutput).
+func EmptyStaleOutput() StaleOutput {
+	return StaleOutput{Memories: []types.Memory{}}
+}
+
 // DefaultSearchLimit returns a default limit for search operations
 func DefaultSearchLimit(limit int) int {
 	if limit <= 0 {
@@ -115,6 +461,58 @@ func MemoryAddedResult(memory *types.Memory) (*mcp.CallToolResult, error) {
 	return TextResult(fmt.Sprintf("Memory added successfully:\n%s", string(result))), nil
 }
 
+// BatchAddedResult formats the results of ec_add_batch, listing the
+// memories that were rejected and why
+func BatchAddedResult(out AddBatchOutput) (*mcp.CallToolResult, error) {
+	result, err := json.MarshalIndent(out.Results, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format response: %w", err)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Added %d of %d memories.\n", out.Added, len(out.Results))
+	for i, res := range out.Results {
+		if res.Memory == nil {
+			fmt.Fprintf(&b, "Memory %d was not added, fix it and retry: %s\n", i+1, res.Error)
+		}
+	}
+	return TextResult(b.String() + string(result)), nil
+}
+
+// MemoryResult formats a single memory
+func MemoryResult(memory *types.Memory) (*mcp.CallToolResult, error) {
+	result, err := json.MarshalIndent(memory, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format response: %w", err)
+	}
+	return TextResult(string(result)), nil
+}
+
+// MemoryUpdatedResult formats a successful update response
+func MemoryUpdatedResult(memory *types.Memory) (*mcp.CallToolResult, error) {
+	result, err := json.MarshalIndent(memory, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format response: %w", err)
+	}
+	return TextResult(fmt.Sprintf("Memory updated to version %d:\n%s", memory.Version, string(result))), nil
+}
+
+// ErrorMsg builds the error message for a failed tool call, naming the kind
+// of failure so the agent knows whether to retry, fix its input or give up.
+// id is the memory the call was about, or zero; action completes "failed to".
+func ErrorMsg(id int64, action string, err error) string {
+	switch {
+	case errors.Is(err, types.ErrNotFound) && id != 0:
+		return fmt.Sprintf("memory %d not found", id)
+	case errors.Is(err, types.ErrVersionConflict):
+		return fmt.Sprintf("memory %d was changed since you read it; fetch it with ec_get and retry: %v", id, err)
+	case errors.Is(err, types.ErrValidation), errors.Is(err, types.ErrInvalidSupersede):
+		return fmt.Sprintf("failed to %s, fix the request and retry: %v", action, err)
+	case errors.Is(err, types.ErrEmbedderUnavailable):
+		return fmt.Sprintf("failed to %s: the embedding model is unavailable, try again later: %v", action, err)
+	}
+	return fmt.Sprintf("failed to %s: %v", action, err)
+}
+
 // MemoriesResult formats a list of memories or an empty message
 func MemoriesResult(memories []types.Memory, emptyMsg string) (*mcp.CallToolResult, error) {
 	if len(memories) == 0 {
@@ -127,6 +525,80 @@ func MemoriesResult(memories []types.Memory, emptyMsg string) (*mcp.CallToolResu
 	return TextResult(string(result)), nil
 }
 
+// ListResult formats a page of memories, telling the caller how to fetch
+// the next one if nextCursor is set
+func ListResult(memories []types.Memory, nextCursor string) (*mcp.CallToolResult, error) {
+	result, err := MemoriesResult(memories, "No memories found.")
+	if err != nil || nextCursor == "" {
+		return result, err
+	}
+	result.Content = append(result.Content, &mcp.TextContent{
+		Text: fmt.Sprintf("More memories follow; call ec_list again with cursor %q.", nextCursor),
+	})
+	return result, nil
+}
+
+// TagsResult formats tag counts, one per line
+func TagsResult(tags []types.TagCount) *mcp.CallToolResult {
+	if len(tags) == 0 {
+		return TextResult("No tags in use.")
+	}
+	var b strings.Builder
+	for _, tc := range tags {
+		fmt.Fprintf(&b, "%s (%d)\n", tc.Tag, tc.Count)
+	}
+	return TextResult(strings.TrimSuffix(b.String(), "\n"))
+}
+
+// CatalogResult formats catalog entries, one per line with their count and
+// the day of their last activity, or none if there are no entries
+func CatalogResult(entries []types.CatalogEntry, none string) *mcp.CallToolResult {
+	if len(entries) == 0 {
+		return TextResult(none)
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		label := e.Value
+		if e.Name != "" && e.Name != e.Value {
+			label = fmt.Sprintf("%s <%s>", e.Name, e.Value)
+		}
+		fmt.Fprintf(&b, "%s (%d, last active %s)\n", label, e.Count, e.LastActivity.Format(time.DateOnly))
+	}
+	return TextResult(strings.TrimSuffix(b.String(), "\n"))
+}
+
+// StatsResult formats memory counts, leading with the totals
+func StatsResult(stats *types.Stats) (*mcp.CallToolResult, error) {
+	result, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format response: %w", err)
+	}
+	return TextResult(fmt.Sprintf("%d memories: %d valid, %d invalid.\n%s",
+		stats.Total, stats.Valid, stats.Invalid, string(result))), nil
+}
+
+// LineageResult formats a lineage, leading with where the current version is
+func LineageResult(lineage *types.Lineage) (*mcp.CallToolResult, error) {
+	result, err := json.MarshalIndent(lineage, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format response: %w", err)
+	}
+
+	var summary string
+	switch {
+	case lineage.Current == nil:
+		summary = fmt.Sprintf("Memory %d has no valid successor.", lineage.Memory.ID)
+	case lineage.Current.ID == lineage.Memory.ID:
+		summary = fmt.Sprintf("Memory %d is current.", lineage.Memory.ID)
+	default:
+		summary = fmt.Sprintf("Memory %d was superseded; the current version is memory %d.", lineage.Memory.ID, lineage.Current.ID)
+	}
+	if lineage.Cycle {
+		summary += " Its superseded_by links form a cycle."
+	}
+	return TextResult(summary + "\n" + string(result)), nil
+}
+
 // InvalidateMsg builds the invalidation confirmation message
 func InvalidateMsg(id int64, supersededBy *int64) string {
 	msg := fmt.Sprintf("Memory %d has been invalidated.", id)
@@ -136,6 +608,29 @@ func InvalidateMsg(id int64, supersededBy *int64) string {
 	return msg
 }
 
+// RestoredResult formats a restored memory
+func RestoredResult(memory *types.Memory) (*mcp.CallToolResult, error) {
+	result, err := json.MarshalIndent(memory, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format response: %w", err)
+	}
+	return TextResult(fmt.Sprintf("Memory %d has been restored.\n%s", memory.ID, string(result))), nil
+}
+
+// MergedResult formats a memory created by merging sources
+func MergedResult(memory *types.Memory, sources []int64) (*mcp.CallToolResult, error) {
+	result, err := json.MarshalIndent(memory, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format response: %w", err)
+	}
+	ids := make([]string, len(sources))
+	for i, id := range sources {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	return TextResult(fmt.Sprintf("Merged memories %s into memory %d; they are now superseded by it.\n%s",
+		strings.Join(ids, ", "), memory.ID, string(result))), nil
+}
+
 // Tool definitions (shared between server and shim)
 var (
 	AddTool = &mcp.Tool{
@@ -143,9 +638,24 @@ var (
 		Description: "Add a new memory entry (decision, learning, or pattern)",
 	}
 
+	AddBatchTool = &mcp.Tool{
+		Name:        "ec_add_batch",
+		Description: "Add several memory entries at once, such as the decisions in a design doc; invalid entries are reported and the rest are added together",
+	}
+
 	SearchTool = &mcp.Tool{
 		Name:        "ec_search",
-		Description: "Search memories by semantic similarity",
+		Description: "Search memories by meaning and keywords (hybrid by default)",
+	}
+
+	GetTool = &mcp.Tool{
+		Name:        "ec_get",
+		Description: "Fetch a single memory entry by ID, including invalidated entries",
+	}
+
+	UpdateTool = &mcp.Tool{
+		Name:        "ec_update",
+		Description: "Edit a memory in place, keeping its ID (the previous version is kept in history)",
 	}
 
 	InvalidateTool = &mcp.Tool{
@@ -153,8 +663,58 @@ var (
 		Description: "Invalidate a memory entry (soft delete)",
 	}
 
+	RestoreTool = &mcp.Tool{
+		Name:        "ec_restore",
+		Description: "Restore an invalidated memory, making it valid again and clearing its superseded_by link",
+	}
+
+	MergeTool = &mcp.Tool{
+		Name:        "ec_merge",
+		Description: "Merge duplicate or overlapping memories into one new memory; the sources are invalidated as superseded by it in a single step",
+	}
+
 	ListTool = &mcp.Tool{
 		Name:        "ec_list",
-		Description: "List recent memory entries",
+		Description: "List memory entries, newest first. Pass next_cursor back as cursor to page through them all.",
+	}
+
+	StatsTool = &mcp.Tool{
+		Name:        "ec_stats",
+		Description: "Count memories by repo, area, type, author and valid/invalid, with how many were added per day, week or month",
+	}
+
+	ReviewTool = &mcp.Tool{
+		Name:        "ec_review_due",
+		Description: "List valid memories whose review_by date has passed, most overdue first. Confirm each with ec_update (a new review_by), correct it, or invalidate it.",
+	}
+
+	StaleTool = &mcp.Tool{
+		Name:        "ec_stale",
+		Description: "List valid memories that no search or get has ever returned, oldest first. Candidates to check and invalidate if no longer true or useful.",
+	}
+
+	LineageTool = &mcp.Tool{
+		Name:        "ec_lineage",
+		Description: "Show a memory's supersession chain: what it replaced, what replaced it, and the current valid version",
+	}
+
+	TagsTool = &mcp.Tool{
+		Name:        "ec_tags",
+		Description: "List the tags in use on valid memories, with how many memories carry each",
+	}
+
+	ReposTool = &mcp.Tool{
+		Name:        "ec_repos",
+		Description: "List every repo with valid memories, with how many each has and when one was last added or updated",
+	}
+
+	AreasTool = &mcp.Tool{
+		Name:        "ec_areas",
+		Description: "List the areas in use on this repo's valid memories, most used first, with when each was last active. Check it before ec_add and reuse an existing area where one fits.",
+	}
+
+	AuthorsTool = &mcp.Tool{
+		Name:        "ec_authors",
+		Description: "List who wrote this repo's valid memories, with how many each wrote and when they were last active",
 	}
 )
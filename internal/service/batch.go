@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/embedder"
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// MaxBatchSize is the most memories AddBatch accepts in one call
+const MaxBatchSize = 100
+
+// BatchResult is the outcome of one item passed to AddBatch: the added
+// memory, or the error that kept it out
+type BatchResult struct {
+	Memory *types.Memory
+	Err    error
+}
+
+// AddBatch creates memories for items, embedding them in one call where the
+// embedder supports it and storing them in a single transaction. Items that
+// fail validation get an error in their result and are skipped; the rest are
+// added together. The returned error is for failures of the whole batch,
+// such as the embedder or storage being unavailable, in which case nothing
+// was added.
+func (s *Service) AddBatch(ctx context.Context, items []AddParams) ([]BatchResult, error) {
+	if len(items) > MaxBatchSize {
+		return nil, types.Invalidf("batch has %d memories, the maximum is %d", len(items), MaxBatchSize)
+	}
+
+	results := make([]BatchResult, len(items))
+	var (
+		mems  []types.Memory
+		texts []string
+		index []int // index[i] is the item mems[i] came from
+	)
+	for i, params := range items {
+		mem, err := s.batchMemory(params)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		mems = append(mems, mem)
+		texts = append(texts, embeddingText(params.Area, params.Content, params.Rationale))
+		index = append(index, i)
+	}
+	if len(mems) == 0 {
+		return results, nil
+	}
+
+	embeddings, err := embedder.EmbedAllForStorage(s.embedder, texts)
+	if err != nil {
+		return nil, embedFailed(err)
+	}
+
+	added, err := s.storage.AddBatch(ctx, mems, embeddings)
+	if err != nil {
+		return nil, err
+	}
+	for i := range added {
+		results[index[i]].Memory = &added[i]
+	}
+	return results, nil
+}
+
+// batchMemory validates params and builds the memory AddBatch stores for it
+func (s *Service) batchMemory(params AddParams) (types.Memory, error) {
+	memType := types.MemoryType(params.Type)
+	if err := memType.Validate(); err != nil {
+		return types.Memory{}, err
+	}
+	if params.Area == "" || params.Content == "" {
+		return types.Memory{}, types.Invalidf("area and content cannot be empty")
+	}
+	tags, err := types.NormalizeTags(params.Tags)
+	if err != nil {
+		return types.Memory{}, err
+	}
+
+	return types.Memory{
+		Type:           memType,
+		Area:           params.Area,
+		Content:        params.Content,
+		Rationale:      params.Rationale,
+		AuthorName:     params.AuthorName,
+		AuthorEmail:    params.AuthorEmail,
+		Repo:           params.Repo,
+		Tags:           tags,
+		ExpiresAt:      params.ExpiresAt,
+		ReviewBy:       params.ReviewBy,
+		EmbeddingModel: s.embedder.Model(),
+	}, nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// defaultReviewLimit caps DueForReview when no limit is given
+const defaultReviewLimit = 20
+
+// ExpireDue invalidates every valid memory whose expiry has passed and
+// returns how many there were
+func (s *Service) ExpireDue(ctx context.Context) (int64, error) {
+	return s.storage.Expire(ctx, time.Now())
+}
+
+// DueForReview returns valid memories whose review date has passed, most
+// overdue first. A non-empty repo restricts them to that repo.
+func (s *Service) DueForReview(ctx context.Context, repo string, limit int) ([]types.Memory, error) {
+	if limit <= 0 {
+		limit = defaultReviewLimit
+	}
+	return s.storage.DueForReview(ctx, repo, time.Now(), limit)
+}
+
+// RunExpirySweeper calls ExpireDue every interval until ctx is done. Failures
+// are logged and retried on the next tick.
+func (s *Service) RunExpirySweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := s.ExpireDue(ctx)
+		if err != nil {
+			log.Printf("Expiry sweep failed: %v", err)
+		} else if expired > 0 {
+			log.Printf("Expired %d memories", expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
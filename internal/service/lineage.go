@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// Lineage returns the supersession chain around the memory with id: what it
+// replaced, what replaced it, and the newest valid memory along the way
+func (s *Service) Lineage(ctx context.Context, id int64) (*types.Lineage, error) {
+	memories, err := s.storage.Lineage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return buildLineage(id, memories)
+}
+
+// buildLineage orders the memories returned by Storage.Lineage around id
+func buildLineage(id int64, memories []types.Memory) (*types.Lineage, error) {
+	byID := make(map[int64]types.Memory, len(memories))
+	for _, mem := range memories {
+		byID[mem.ID] = mem
+	}
+	mem, ok := byID[id]
+	if !ok {
+		return nil, types.ErrNotFound
+	}
+
+	lineage := &types.Lineage{
+		Memory:      mem,
+		Ancestors:   []types.Memory{},
+		Descendants: []types.Memory{},
+	}
+	if mem.IsValid {
+		lineage.Current = &mem
+	}
+
+	// A dangling superseded_by (its memory was never stored) ends the chain
+	onChain := map[int64]bool{id: true}
+	for next := mem.SupersededBy; next != nil; {
+		if onChain[*next] {
+			lineage.Cycle = true
+			break
+		}
+		succ, ok := byID[*next]
+		if !ok {
+			break
+		}
+		onChain[succ.ID] = true
+		lineage.Descendants = append(lineage.Descendants, succ)
+		if succ.IsValid {
+			current := succ
+			lineage.Current = &current
+		}
+		next = succ.SupersededBy
+	}
+
+	for _, m := range memories {
+		if !onChain[m.ID] {
+			lineage.Ancestors = append(lineage.Ancestors, m)
+		}
+	}
+	sort.SliceStable(lineage.Ancestors, func(i, j int) bool {
+		a, b := lineage.Ancestors[i], lineage.Ancestors[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+
+	return lineage, nil
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// MergeParams holds parameters for Merge
+type MergeParams struct {
+	// AddParams describes the consolidated memory; its author is also
+	// recorded as having invalidated the sources
+	AddParams
+	// Sources are the memories being merged; repeated IDs count once
+	Sources []int64
+	// Reason is recorded on each invalidated source
+	Reason string
+}
+
+// Merge consolidates two or more valid memories into a new one. The new
+// memory is added and every source invalidated as superseded by it in a
+// single storage transaction, so either all of it happens or none does.
+func (s *Service) Merge(ctx context.Context, params MergeParams) (*types.Memory, error) {
+	var sources []int64
+	seen := make(map[int64]bool, len(params.Sources))
+	for _, id := range params.Sources {
+		if !seen[id] {
+			seen[id] = true
+			sources = append(sources, id)
+		}
+	}
+	if len(sources) < 2 {
+		return nil, types.Invalidf("merge needs at least two distinct source memories")
+	}
+
+	memType := types.MemoryType(params.Type)
+	if err := memType.Validate(); err != nil {
+		return nil, err
+	}
+	if params.Area == "" || params.Content == "" {
+		return nil, types.Invalidf("area and content cannot be empty")
+	}
+	tags, err := types.NormalizeTags(params.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	embedding, err := s.embedder.EmbedForStorage(embeddingText(params.Area, params.Content, params.Rationale))
+	if err != nil {
+		return nil, embedFailed(err)
+	}
+
+	m := types.Merge{
+		Memory: types.Memory{
+			Type:           memType,
+			Area:           params.Area,
+			Content:        params.Content,
+			Rationale:      params.Rationale,
+			AuthorName:     params.AuthorName,
+			AuthorEmail:    params.AuthorEmail,
+			Repo:           params.Repo,
+			Tags:           tags,
+			ExpiresAt:      params.ExpiresAt,
+			ReviewBy:       params.ReviewBy,
+			EmbeddingModel: s.embedder.Model(),
+		},
+		Sources:  sources,
+		SameRepo: s.supersedeSameRepo,
+		Reason:   params.Reason,
+		ByName:   params.AuthorName,
+		ByEmail:  params.AuthorEmail,
+	}
+	return s.storage.Merge(ctx, m, embedding)
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// MaxSearchOverfetch caps RankingPolicy.SearchOverfetch, which multiplies
+// how many memories every search reads
+const MaxSearchOverfetch = 10
+
+// RankingPolicy controls how search results are re-ranked after storage
+// returns them. A repo of long-lived architecture decisions wants little
+// recency decay; one of incident learnings wants a lot.
+type RankingPolicy struct {
+	// RecencyWeight is how much recency counts against similarity, from 0
+	// (similarity only) to 1 (recency only)
+	RecencyWeight float64 `json:"recency_weight"`
+	// RecencyHalfLifeDays is the age in days at which a memory's recency
+	// boost has halved
+	RecencyHalfLifeDays float64 `json:"recency_half_life_days"`
+	// TypeHalfLifeDays overrides RecencyHalfLifeDays for memories of a type
+	TypeHalfLifeDays map[types.MemoryType]float64 `json:"type_half_life_days,omitempty"`
+	// UsageWeight is how much a memory's retrieval count counts against the
+	// similarity and recency score, from 0 (ignored, the default) to 1 (usage
+	// only)
+	UsageWeight float64 `json:"usage_weight"`
+	// SearchOverfetch multiplies the limit to fetch extra results for
+	// re-ranking
+	SearchOverfetch int `json:"search_overfetch"`
+}
+
+// DefaultRankingPolicy returns the policy a Service starts with
+func DefaultRankingPolicy() RankingPolicy {
+	return RankingPolicy{
+		RecencyWeight:       0.15,
+		RecencyHalfLifeDays: 30,
+		SearchOverfetch:     2,
+	}
+}
+
+// Validate returns a validation error if any setting is out of range
+func (p RankingPolicy) Validate() error {
+	if p.RecencyWeight < 0 || p.RecencyWeight > 1 || math.IsNaN(p.RecencyWeight) {
+		return types.Invalidf("recency weight must be between 0 and 1, got %v", p.RecencyWeight)
+	}
+	if !(p.RecencyHalfLifeDays > 0) {
+		return types.Invalidf("recency half-life must be a positive number of days, got %v", p.RecencyHalfLifeDays)
+	}
+	for memType, days := range p.TypeHalfLifeDays {
+		if err := memType.Validate(); err != nil {
+			return err
+		}
+		if !(days > 0) {
+			return types.Invalidf("%s half-life must be a positive number of days, got %v", memType, days)
+		}
+	}
+	if p.UsageWeight < 0 || p.UsageWeight > 1 || math.IsNaN(p.UsageWeight) {
+		return types.Invalidf("usage weight must be between 0 and 1, got %v", p.UsageWeight)
+	}
+	if p.SearchOverfetch < 1 || p.SearchOverfetch > MaxSearchOverfetch {
+		return types.Invalidf("search overfetch must be between 1 and %d, got %d", MaxSearchOverfetch, p.SearchOverfetch)
+	}
+	return nil
+}
+
+// Override returns p with o's settings applied and checks the result. A
+// half-life override applies to every type.
+func (p RankingPolicy) Override(o types.RankingOverride) (RankingPolicy, error) {
+	if o.RecencyWeight != nil {
+		p.RecencyWeight = *o.RecencyWeight
+	}
+	if o.RecencyHalfLifeDays != nil {
+		p.RecencyHalfLifeDays = *o.RecencyHalfLifeDays
+		p.TypeHalfLifeDays = nil
+	}
+	if o.UsageWeight != nil {
+		p.UsageWeight = *o.UsageWeight
+	}
+	if err := p.Validate(); err != nil {
+		return RankingPolicy{}, err
+	}
+	return p, nil
+}
+
+// halfLife returns the recency half-life in days for memories of memType
+func (p RankingPolicy) halfLife(memType types.MemoryType) float64 {
+	if days, ok := p.TypeHalfLifeDays[memType]; ok {
+		return days
+	}
+	return p.RecencyHalfLifeDays
+}
+
+// LoadRankingPolicy reads a JSON ranking policy from path. Settings the
+// file leaves out keep their defaults.
+func LoadRankingPolicy(path string) (RankingPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RankingPolicy{}, fmt.Errorf("failed to read ranking config: %w", err)
+	}
+	policy := DefaultRankingPolicy()
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return RankingPolicy{}, fmt.Errorf("failed to parse ranking config %s: %w", path, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return RankingPolicy{}, fmt.Errorf("ranking config %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// ParseTypeHalfLives parses per-type half-lives written as
+// "decision=365,learning=14"
+func ParseTypeHalfLives(s string) (map[types.MemoryType]float64, error) {
+	halfLives := make(map[types.MemoryType]float64)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, types.Invalidf("invalid type half-life %q: want type=days", pair)
+		}
+		memType := types.MemoryType(strings.TrimSpace(name))
+		if err := memType.Validate(); err != nil {
+			return nil, err
+		}
+		days, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, types.Invalidf("invalid %s half-life %q: %v", memType, value, err)
+		}
+		halfLives[memType] = days
+	}
+	return halfLives, nil
+}
+
+// RankingFlags defines the ranking flags on fs and returns a function that,
+// once fs is parsed, builds the policy they describe: the defaults, then
+// the --ranking-config file if one is given, then any ranking flag set
+// explicitly.
+func RankingFlags(fs *flag.FlagSet) func() (RankingPolicy, error) {
+	def := DefaultRankingPolicy()
+	path := fs.String("ranking-config", "", "JSON file with the search ranking policy; ranking flags set explicitly override it")
+	weight := fs.Float64("recency-weight", def.RecencyWeight, "How much recency counts in search ranking, from 0 (similarity only) to 1 (recency only)")
+	halfLife := fs.Float64("recency-half-life-days", def.RecencyHalfLifeDays, "Age in days at which a memory's recency boost halves")
+	typeHalfLives := fs.String("type-half-life-days", "", "Per-type recency half-lives overriding --recency-half-life-days, e.g. decision=365,learning=14")
+	usageWeight := fs.Float64("usage-weight", def.UsageWeight, "How much retrieval counts count in search ranking, from 0 (ignored) to 1 (usage only)")
+	overfetch := fs.Int("search-overfetch", def.SearchOverfetch, "Multiple of the limit each search fetches for re-ranking")
+
+	return func() (RankingPolicy, error) {
+		policy := def
+		if *path != "" {
+			var err error
+			if policy, err = LoadRankingPolicy(*path); err != nil {
+				return RankingPolicy{}, err
+			}
+		}
+
+		var err error
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "recency-weight":
+				policy.RecencyWeight = *weight
+			case "recency-half-life-days":
+				policy.RecencyHalfLifeDays = *halfLife
+			case "type-half-life-days":
+				policy.TypeHalfLifeDays, err = ParseTypeHalfLives(*typeHalfLives)
+			case "usage-weight":
+				policy.UsageWeight = *usageWeight
+			case "search-overfetch":
+				policy.SearchOverfetch = *overfetch
+			}
+		})
+		if err != nil {
+			return RankingPolicy{}, err
+		}
+		return policy, policy.Validate()
+	}
+}
+
+// SetRankingPolicy replaces the policy searches are ranked by
+func (s *Service) SetRankingPolicy(p RankingPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	s.ranking = p
+	return nil
+}
@@ -0,0 +1,164 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/service"
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+func TestRankingPolicy_Validate(t *testing.T) {
+	if err := service.DefaultRankingPolicy().Validate(); err != nil {
+		t.Fatalf("default policy invalid: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*service.RankingPolicy)
+	}{
+		{"negative weight", func(p *service.RankingPolicy) { p.RecencyWeight = -0.1 }},
+		{"weight above 1", func(p *service.RankingPolicy) { p.RecencyWeight = 1.5 }},
+		{"zero half-life", func(p *service.RankingPolicy) { p.RecencyHalfLifeDays = 0 }},
+		{"unknown type", func(p *service.RankingPolicy) {
+			p.TypeHalfLifeDays = map[types.MemoryType]float64{"opinion": 10}
+		}},
+		{"negative type half-life", func(p *service.RankingPolicy) {
+			p.TypeHalfLifeDays = map[types.MemoryType]float64{types.TypeDecision: -1}
+		}},
+		{"negative usage weight", func(p *service.RankingPolicy) { p.UsageWeight = -0.1 }},
+		{"usage weight above 1", func(p *service.RankingPolicy) { p.UsageWeight = 2 }},
+		{"zero overfetch", func(p *service.RankingPolicy) { p.SearchOverfetch = 0 }},
+		{"huge overfetch", func(p *service.RankingPolicy) { p.SearchOverfetch = service.MaxSearchOverfetch + 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := service.DefaultRankingPolicy()
+			tt.modify(&p)
+			if err := p.Validate(); !errors.Is(err, types.ErrValidation) {
+				t.Errorf("expected types.ErrValidation, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParseTypeHalfLives(t *testing.T) {
+	got, err := service.ParseTypeHalfLives(" decision=365, learning=14.5 ,")
+	if err != nil {
+		t.Fatalf("ParseTypeHalfLives failed: %v", err)
+	}
+	want := map[types.MemoryType]float64{types.TypeDecision: 365, types.TypeLearning: 14.5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	for _, bad := range []string{"decision", "opinion=3", "decision=soon"} {
+		if _, err := service.ParseTypeHalfLives(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestRankingFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ranking.json")
+	config := `{"recency_weight": 0.4, "type_half_life_days": {"decision": 365}}`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	build := service.RankingFlags(fs)
+	if err := fs.Parse([]string{"--ranking-config", path, "--recency-weight", "0.05", "--usage-weight", "0.3", "--search-overfetch", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := build()
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	// Explicit flags win over the file, which wins over the defaults
+	want := service.RankingPolicy{
+		RecencyWeight:       0.05,
+		RecencyHalfLifeDays: service.DefaultRankingPolicy().RecencyHalfLifeDays,
+		TypeHalfLifeDays:    map[types.MemoryType]float64{types.TypeDecision: 365},
+		UsageWeight:         0.3,
+		SearchOverfetch:     3,
+	}
+	if !reflect.DeepEqual(policy, want) {
+		t.Errorf("expected %+v, got %+v", want, policy)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	build = service.RankingFlags(fs)
+	fs.Parse([]string{"--recency-weight", "2"})
+	if _, err := build(); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("expected types.ErrValidation for a weight of 2, got %v", err)
+	}
+}
+
+func TestService_Search_RankingPolicy(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	// An old, closely matching decision and a fresh, weaker learning
+	store := &mockStorage{memories: []types.Memory{
+		{ID: 1, Type: types.TypeDecision, Content: "old", SimilarityScore: 0.9, CreatedAt: now.AddDate(0, 0, -90)},
+		{ID: 2, Type: types.TypeLearning, Content: "new", SimilarityScore: 0.6, CreatedAt: now},
+	}}
+	svc := service.New(store, &mockEmbedder{})
+
+	first := func(rank types.RankingOverride) int64 {
+		t.Helper()
+		results, err := svc.SearchWithRepo(ctx, "q", 1, "", "", "", string(types.SearchSemantic), types.TagFilter{}, rank)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("expected 1 result, got %d", len(results))
+		}
+		return results[0].ID
+	}
+	weight := func(w float64) *float64 { return &w }
+
+	if got := first(types.RankingOverride{}); got != 1 {
+		t.Errorf("default policy: expected the closer match first, got %d", got)
+	}
+	if store.searchLimit != 2 {
+		t.Errorf("expected the default overfetch of 2, got limit %d", store.searchLimit)
+	}
+	if got := first(types.RankingOverride{RecencyWeight: weight(0.5)}); got != 2 {
+		t.Errorf("heavy recency: expected the fresh memory first, got %d", got)
+	}
+
+	// A long decision half-life keeps the old decision on top even with
+	// heavy recency, until the request overrides every half-life
+	policy := service.DefaultRankingPolicy()
+	policy.RecencyWeight = 0.5
+	policy.TypeHalfLifeDays = map[types.MemoryType]float64{types.TypeDecision: 3650}
+	policy.SearchOverfetch = 4
+	if err := svc.SetRankingPolicy(policy); err != nil {
+		t.Fatalf("SetRankingPolicy failed: %v", err)
+	}
+	if got := first(types.RankingOverride{}); got != 1 {
+		t.Errorf("decision half-life: expected the old decision first, got %d", got)
+	}
+	if store.searchLimit != 4 {
+		t.Errorf("expected an overfetch of 4, got limit %d", store.searchLimit)
+	}
+	if got := first(types.RankingOverride{RecencyHalfLifeDays: weight(7)}); got != 2 {
+		t.Errorf("half-life override: expected the fresh memory first, got %d", got)
+	}
+
+	if _, err := svc.SearchWithRepo(ctx, "q", 1, "", "", "", "", types.TagFilter{}, types.RankingOverride{RecencyWeight: weight(-1)}); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("expected types.ErrValidation for a negative weight, got %v", err)
+	}
+	if err := svc.SetRankingPolicy(service.RankingPolicy{}); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("expected SetRankingPolicy to reject a zero policy, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/embedder"
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+const (
+	// defaultReembedBatch is how many memories are embedded between progress reports
+	defaultReembedBatch = 50
+	// reembedSampleSize is how many memories a dry run embeds to time the model
+	reembedSampleSize = 3
+)
+
+// ReembedOptions configures Reembed
+type ReembedOptions struct {
+	BatchSize int
+	// DryRun counts the work and times a few sample embeddings without writing
+	DryRun bool
+	// Progress is called after each batch with the number of memories staged so far
+	Progress func(done, total int)
+}
+
+// ReembedResult summarizes a re-embed run
+type ReembedResult struct {
+	From  storage.EmbeddingSpec
+	To    storage.EmbeddingSpec
+	Total int
+	// Resumed counts memories already staged by an interrupted earlier run
+	Resumed  int
+	Embedded int
+	// Estimate is the projected embedding time for the remaining memories (dry run only)
+	Estimate  time.Duration
+	Committed bool
+}
+
+// Reembed rebuilds every memory's vector with emb, whose output must match
+// target. Vectors are staged and swapped in atomically once all are ready; if
+// interrupted, running again with the same target resumes from the staging.
+func Reembed(ctx context.Context, store storage.Storage, emb embedder.Embedder, target storage.EmbeddingSpec, opts ReembedOptions) (*ReembedResult, error) {
+	r, ok := store.(storage.Reembedder)
+	if !ok {
+		return nil, fmt.Errorf("storage driver does not support re-embedding")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultReembedBatch
+	}
+
+	from, err := storage.StoredEmbeddingSpec(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	staged, total, err := r.ReembedProgress(ctx, target)
+	if errors.Is(err, storage.ErrReembedUnsupported) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read re-embed progress: %w", err)
+	}
+	result := &ReembedResult{From: from, To: target, Total: total, Resumed: staged}
+
+	if opts.DryRun {
+		result.Estimate, err = estimateReembed(ctx, store, emb, target, total-staged)
+		return result, err
+	}
+
+	if err := r.PrepareReembed(ctx, target); err != nil {
+		return nil, fmt.Errorf("failed to prepare re-embed: %w", err)
+	}
+
+	done := staged
+	for {
+		batch, err := r.PendingReembed(ctx, opts.BatchSize)
+		if err != nil {
+			return result, fmt.Errorf("failed to read pending memories: %w", err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, mem := range batch {
+			embedding, err := embedForReembed(emb, mem, target)
+			if err != nil {
+				return result, err
+			}
+			if err := r.StageEmbedding(ctx, mem.ID, mem.Version, embedding); err != nil {
+				return result, fmt.Errorf("failed to stage memory %d: %w", mem.ID, err)
+			}
+			result.Embedded++
+			done++
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(done, total)
+		}
+	}
+
+	if err := r.CommitReembed(ctx); err != nil {
+		return result, fmt.Errorf("failed to commit re-embed: %w", err)
+	}
+	result.Committed = true
+	return result, nil
+}
+
+// embedForReembed embeds the same text Add would, checking the vector fits target
+func embedForReembed(emb embedder.Embedder, mem types.Memory, target storage.EmbeddingSpec) ([]float32, error) {
+	embedding, err := emb.EmbedForStorage(embeddingText(mem.Area, mem.Content, mem.Rationale))
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed memory %d: %w: %w", mem.ID, types.ErrEmbedderUnavailable, err)
+	}
+	if len(embedding) != target.Dimension {
+		return nil, fmt.Errorf("model %q returned %d dimensions, expected %d",
+			target.Model, len(embedding), target.Dimension)
+	}
+	return embedding, nil
+}
+
+// estimateReembed times a few sample embeddings and projects them over remaining
+func estimateReembed(ctx context.Context, store storage.Storage, emb embedder.Embedder, target storage.EmbeddingSpec, remaining int) (time.Duration, error) {
+	if remaining == 0 {
+		return 0, nil
+	}
+
+	sample, err := store.List(ctx, types.ListOpts{Limit: reembedSampleSize, IncludeInvalid: true})
+	if err != nil {
+		return 0, err
+	}
+	if len(sample) == 0 {
+		return 0, nil
+	}
+
+	start := time.Now()
+	for _, mem := range sample {
+		if _, err := embedForReembed(emb, mem, target); err != nil {
+			return 0, err
+		}
+	}
+	perMemory := time.Since(start) / time.Duration(len(sample))
+	return perMemory * time.Duration(remaining), nil
+}
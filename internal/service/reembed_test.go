@@ -0,0 +1,125 @@
+//go:build cgo
+
+package service_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/service"
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// sizedEmbedder returns vectors of a fixed size and records the texts it embedded
+type sizedEmbedder struct {
+	model string
+	dim   int
+	texts []string
+}
+
+func (e *sizedEmbedder) EmbedForStorage(text string) ([]float32, error) {
+	e.texts = append(e.texts, text)
+	v := make([]float32, e.dim)
+	v[len(e.texts)%e.dim] = 1
+	return v, nil
+}
+
+func (e *sizedEmbedder) EmbedForSearch(query string) ([]float32, error) {
+	return e.EmbedForStorage(query)
+}
+
+func (e *sizedEmbedder) Model() string {
+	return e.model
+}
+
+func TestReembed(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.New(ctx, storage.Config{
+		Driver:         "sqlite",
+		SQLitePath:     filepath.Join(t.TempDir(), "m.db"),
+		EmbeddingModel: "nomic-embed-text",
+		EmbeddingDim:   768,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	svc := service.New(store, &sizedEmbedder{model: "nomic-embed-text", dim: 768})
+	mem, err := svc.Add(ctx, types.TypeDecision, "auth", "Use JWT", "Stateless")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Add(ctx, types.TypeLearning, "db", "Pool maxes at 20", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	target := storage.EmbeddingSpec{Model: "mxbai-embed-large", Dimension: 1024}
+	newEmb := &sizedEmbedder{model: target.Model, dim: target.Dimension}
+
+	dry, err := service.Reembed(ctx, store, newEmb, target, service.ReembedOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if dry.Total != 2 || dry.Committed || dry.From.Model != "nomic-embed-text" {
+		t.Fatalf("unexpected dry run result: %+v", dry)
+	}
+
+	var reports int
+	result, err := service.Reembed(ctx, store, newEmb, target, service.ReembedOptions{
+		BatchSize: 1,
+		Progress:  func(done, total int) { reports++ },
+	})
+	if err != nil {
+		t.Fatalf("Reembed failed: %v", err)
+	}
+	if !result.Committed || result.Embedded != 2 || reports != 2 {
+		t.Fatalf("expected 2 memories embedded over 2 batches, got %+v with %d reports", result, reports)
+	}
+
+	// The text matches what Add embeds
+	if !slices.Contains(newEmb.texts, "auth: Use JWT Stateless") {
+		t.Errorf("expected Add's embedding text, got %q", newEmb.texts)
+	}
+
+	got, err := svc.Get(ctx, mem.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.EmbeddingModel != target.Model {
+		t.Errorf("expected memory to record %q, got %q", target.Model, got.EmbeddingModel)
+	}
+	spec, err := storage.StoredEmbeddingSpec(ctx, store)
+	if err != nil || spec != target {
+		t.Errorf("expected stored spec %+v, got %+v (%v)", target, spec, err)
+	}
+}
+
+func TestReembed_WrongDimension(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.New(ctx, storage.Config{
+		Driver:         "sqlite",
+		SQLitePath:     filepath.Join(t.TempDir(), "m.db"),
+		EmbeddingModel: "nomic-embed-text",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	svc := service.New(store, &sizedEmbedder{model: "nomic-embed-text", dim: 768})
+	if _, err := svc.Add(ctx, types.TypeDecision, "auth", "Use JWT", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// The model actually returns 384 dimensions, not the configured 1024
+	target := storage.EmbeddingSpec{Model: "all-minilm", Dimension: 1024}
+	_, err = service.Reembed(ctx, store, &sizedEmbedder{model: "all-minilm", dim: 384}, target, service.ReembedOptions{DryRun: true})
+	if err == nil || !strings.Contains(err.Error(), "384") {
+		t.Errorf("expected dimension error from dry run, got %v", err)
+	}
+}
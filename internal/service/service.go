@@ -12,19 +12,24 @@ import (
 	"github.com/MereWhiplash/engram-cogitator/internal/types"
 )
 
-const (
-	// recencyWeight controls how much recency affects ranking (0.0 = pure similarity, 1.0 = pure recency)
-	recencyWeight = 0.15
-	// recencyHalfLifeDays is the number of days after which recency boost decays to 50%
-	recencyHalfLifeDays = 30.0
-	// searchOverfetch multiplier — fetch extra results for re-ranking
-	searchOverfetch = 2
-)
+// rrfK damps the weight of top ranks in reciprocal rank fusion (60 is the
+// value from the original RRF paper)
+const rrfK = 60
+
+// usageHalfRetrievals is the retrieval count at which a memory's usage
+// signal reaches half its maximum
+const usageHalfRetrievals = 10
 
 // Service contains the business logic for memory operations
 type Service struct {
 	storage  storage.Storage
 	embedder embedder.Embedder
+	// supersedeSameRepo rejects successors from another repo
+	supersedeSameRepo bool
+	// ranking re-ranks search results
+	ranking RankingPolicy
+	// usage buffers retrieval counts; nil unless TrackUsage was called
+	usage *usageTracker
 }
 
 // New creates a new Service
@@ -32,72 +37,204 @@ func New(store storage.Storage, emb embedder.Embedder) *Service {
 	return &Service{
 		storage:  store,
 		embedder: emb,
+		ranking:  DefaultRankingPolicy(),
+	}
+}
+
+// embeddingText builds the text that is embedded for a memory
+func embeddingText(area, content, rationale string) string {
+	text := fmt.Sprintf("%s: %s", area, content)
+	if rationale != "" {
+		text += " " + rationale
 	}
+	return text
 }
 
-// Add creates a new memory entry
+// SetSupersedeSameRepo sets whether invalidations and merges reject links
+// between memories in different repos
+func (s *Service) SetSupersedeSameRepo(on bool) {
+	s.supersedeSameRepo = on
+}
+
+// embedFailed marks an embedding error as types.ErrEmbedderUnavailable
+func embedFailed(err error) error {
+	return fmt.Errorf("failed to generate embedding: %w: %w", types.ErrEmbedderUnavailable, err)
+}
+
+// Add creates a memory with no author, repo or tags; see AddWithContext
 func (s *Service) Add(ctx context.Context, memType types.MemoryType, area, content, rationale string) (*types.Memory, error) {
-	if err := memType.Validate(); err != nil {
+	return s.AddWithContext(ctx, AddParams{Type: string(memType), Area: area, Content: content, Rationale: rationale})
+}
+
+// Search finds memories in any repo with the default ranking; see SearchWithRepo
+func (s *Service) Search(ctx context.Context, query string, limit int, memType types.MemoryType, area string, mode types.SearchMode) ([]types.Memory, error) {
+	return s.SearchWithRepo(ctx, query, limit, string(memType), area, "", string(mode), types.TagFilter{}, types.RankingOverride{})
+}
+
+// List returns the most recent memories in any repo; see ListWithRepo
+func (s *Service) List(ctx context.Context, limit int, memType types.MemoryType, area string, includeInvalid bool) ([]types.Memory, error) {
+	return s.ListWithRepo(ctx, limit, string(memType), area, "", includeInvalid, 0, nil, types.TagFilter{})
+}
+
+// Get returns a single memory by ID, including invalidated memories
+func (s *Service) Get(ctx context.Context, id int64) (*types.Memory, error) {
+	mem, err := s.storage.Get(ctx, id)
+	if err != nil {
 		return nil, err
 	}
+	s.recordRetrievals(*mem)
+	return mem, nil
+}
 
-	// Build text for embedding
-	textToEmbed := fmt.Sprintf("%s: %s", area, content)
-	if rationale != "" {
-		textToEmbed += " " + rationale
+// Invalidate marks a memory as invalid, recording who invalidated it and why.
+// A successor is checked by storage and rejected with
+// types.ErrInvalidSupersede if it is unusable.
+func (s *Service) Invalidate(ctx context.Context, inv types.Invalidation) error {
+	if s.supersedeSameRepo {
+		inv.SameRepo = true
 	}
+	return s.storage.Invalidate(ctx, inv)
+}
 
-	embedding, err := s.embedder.EmbedForStorage(textToEmbed)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate embedding: %w", err)
+// InvalidateMatching invalidates every valid memory matching bi.Filter in
+// one storage transaction and returns their IDs, or with bi.DryRun only
+// returns the IDs. The filter must narrow the memories by at least one field,
+// so a mistake can't retire everything.
+func (s *Service) InvalidateMatching(ctx context.Context, bi types.BulkInvalidation) ([]int64, error) {
+	if bi.Filter.IsZero() {
+		return nil, types.Invalidf("bulk invalidation needs at least one filter")
 	}
-
-	mem := types.Memory{
-		Type:      memType,
-		Area:      area,
-		Content:   content,
-		Rationale: rationale,
+	if bi.Filter.Type != "" {
+		if err := bi.Filter.Type.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if s.supersedeSameRepo {
+		bi.SameRepo = true
 	}
+	return s.storage.InvalidateMatching(ctx, bi)
+}
 
-	return s.storage.Add(ctx, mem, embedding)
+// Revalidate restores an invalidated memory, recording who restored it
+func (s *Service) Revalidate(ctx context.Context, rev types.Revalidation) (*types.Memory, error) {
+	return s.storage.Revalidate(ctx, rev)
 }
 
-// Search finds memories by semantic similarity with recency boost
-func (s *Service) Search(ctx context.Context, query string, limit int, memType types.MemoryType, area string) ([]types.Memory, error) {
-	embedding, err := s.embedder.EmbedForSearch(query)
+// UpdateParams holds parameters for Update. Nil fields are left unchanged.
+type UpdateParams struct {
+	ID        int64
+	Type      *string
+	Area      *string
+	Content   *string
+	Rationale *string
+	// ExpectedVersion rejects the update with types.ErrVersionConflict unless
+	// the memory is still at this version. Zero skips the check.
+	ExpectedVersion int
+	EditorName      string
+	EditorEmail     string
+	// ExpiresAt and ReviewBy replace the memory's dates; a zero time clears one
+	ExpiresAt *time.Time
+	ReviewBy  *time.Time
+}
+
+// Update edits a memory in place, keeping its ID. The replaced version is kept
+// in the revision history, and the memory is re-embedded when its text changes.
+func (s *Service) Update(ctx context.Context, params UpdateParams) (*types.Memory, error) {
+	current, err := s.storage.Get(ctx, params.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate embedding: %w", err)
+		return nil, err
+	}
+	if params.ExpectedVersion != 0 && params.ExpectedVersion != current.Version {
+		return nil, fmt.Errorf("%w: memory %d is at version %d, expected %d", types.ErrVersionConflict, params.ID, current.Version, params.ExpectedVersion)
 	}
 
-	opts := types.SearchOpts{
-		Limit: limit * searchOverfetch,
-		Type:  memType,
-		Area:  area,
+	upd := types.MemoryUpdate{
+		ID:        current.ID,
+		Type:      current.Type,
+		Area:      current.Area,
+		Content:   current.Content,
+		Rationale: current.Rationale,
+		// Always pin to the version read above so a concurrent edit between
+		// this read and the write is detected by storage
+		ExpectedVersion: current.Version,
+		EditorName:      params.EditorName,
+		EditorEmail:     params.EditorEmail,
+		ExpiresAt:       current.ExpiresAt,
+		ReviewBy:        current.ReviewBy,
+	}
+	if params.Type != nil {
+		upd.Type = types.MemoryType(*params.Type)
+	}
+	if params.Area != nil {
+		upd.Area = *params.Area
+	}
+	if params.Content != nil {
+		upd.Content = *params.Content
+	}
+	if params.Rationale != nil {
+		upd.Rationale = *params.Rationale
+	}
+	if params.ExpiresAt != nil {
+		upd.ExpiresAt = optionalTime(*params.ExpiresAt)
+	}
+	if params.ReviewBy != nil {
+		upd.ReviewBy = optionalTime(*params.ReviewBy)
 	}
 
-	memories, err := s.storage.Search(ctx, embedding, opts)
-	if err != nil {
+	if err := upd.Type.Validate(); err != nil {
 		return nil, err
 	}
+	if upd.Area == "" || upd.Content == "" {
+		return nil, types.Invalidf("area and content cannot be empty")
+	}
+
+	if upd.Type == current.Type && upd.Area == current.Area &&
+		upd.Content == current.Content && upd.Rationale == current.Rationale &&
+		sameTime(upd.ExpiresAt, current.ExpiresAt) && sameTime(upd.ReviewBy, current.ReviewBy) {
+		return current, nil
+	}
+
+	var embedding []float32
+	newText := embeddingText(upd.Area, upd.Content, upd.Rationale)
+	if newText != embeddingText(current.Area, current.Content, current.Rationale) {
+		embedding, err = s.embedder.EmbedForStorage(newText)
+		if err != nil {
+			return nil, embedFailed(err)
+		}
+		upd.EmbeddingModel = s.embedder.Model()
+	}
 
-	return applyRecencyBoost(memories, limit), nil
+	return s.storage.Update(ctx, upd, embedding)
 }
 
-// List returns recent memories
-func (s *Service) List(ctx context.Context, limit int, memType types.MemoryType, area string, includeInvalid bool) ([]types.Memory, error) {
-	opts := types.ListOpts{
-		Limit:          limit,
-		Type:           memType,
-		Area:           area,
-		IncludeInvalid: includeInvalid,
+// optionalTime returns nil for the zero time, which clears a date
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
 	}
+	return &t
+}
 
-	return s.storage.List(ctx, opts)
+// sameTime reports whether two optional dates are equal
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
 }
 
-// Invalidate marks a memory as invalid
-func (s *Service) Invalidate(ctx context.Context, id int64, supersededBy *int64) error {
-	return s.storage.Invalidate(ctx, id, supersededBy)
+// History returns a memory and its previous versions, oldest first. Looking
+// at history is not a retrieval, so it leaves the usage counts alone.
+func (s *Service) History(ctx context.Context, id int64) (*types.Memory, []types.Revision, error) {
+	mem, err := s.storage.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	revisions, err := s.storage.History(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mem, revisions, nil
 }
 
 // Close cleans up resources
@@ -114,6 +251,11 @@ type AddParams struct {
 	AuthorName  string
 	AuthorEmail string
 	Repo        string
+	// Tags are normalized with types.NormalizeTags
+	Tags []string
+	// ExpiresAt and ReviewBy are optional
+	ExpiresAt *time.Time
+	ReviewBy  *time.Time
 }
 
 // AddWithContext creates a new memory with full context (for team mode)
@@ -122,55 +264,98 @@ func (s *Service) AddWithContext(ctx context.Context, params AddParams) (*types.
 	if err := memType.Validate(); err != nil {
 		return nil, err
 	}
-
-	textToEmbed := fmt.Sprintf("%s: %s", params.Area, params.Content)
-	if params.Rationale != "" {
-		textToEmbed += " " + params.Rationale
+	tags, err := types.NormalizeTags(params.Tags)
+	if err != nil {
+		return nil, err
 	}
 
-	embedding, err := s.embedder.EmbedForStorage(textToEmbed)
+	embedding, err := s.embedder.EmbedForStorage(embeddingText(params.Area, params.Content, params.Rationale))
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate embedding: %w", err)
+		return nil, embedFailed(err)
 	}
 
 	mem := types.Memory{
-		Type:        memType,
-		Area:        params.Area,
-		Content:     params.Content,
-		Rationale:   params.Rationale,
-		AuthorName:  params.AuthorName,
-		AuthorEmail: params.AuthorEmail,
-		Repo:        params.Repo,
+		Type:           memType,
+		Area:           params.Area,
+		Content:        params.Content,
+		Rationale:      params.Rationale,
+		AuthorName:     params.AuthorName,
+		AuthorEmail:    params.AuthorEmail,
+		Repo:           params.Repo,
+		Tags:           tags,
+		ExpiresAt:      params.ExpiresAt,
+		ReviewBy:       params.ReviewBy,
+		EmbeddingModel: s.embedder.Model(),
 	}
 
 	return s.storage.Add(ctx, mem, embedding)
 }
 
-// SearchWithRepo finds memories with optional repo filter and recency boost
-func (s *Service) SearchWithRepo(ctx context.Context, query string, limit int, memType, area, repo string) ([]types.Memory, error) {
-	embedding, err := s.embedder.EmbedForSearch(query)
+// SearchWithRepo finds memories with optional repo and tag filters, ranked
+// per mode with recency boost. rank adjusts the ranking policy for this
+// search only.
+func (s *Service) SearchWithRepo(ctx context.Context, query string, limit int, memType, area, repo, mode string, tags types.TagFilter, rank types.RankingOverride) ([]types.Memory, error) {
+	tags, err := tags.Normalize()
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate embedding: %w", err)
+		return nil, err
 	}
-
 	opts := types.SearchOpts{
-		Limit: limit * searchOverfetch,
-		Type:  types.MemoryType(memType),
-		Area:  area,
-		Repo:  repo,
+		Type: types.MemoryType(memType),
+		Area: area,
+		Repo: repo,
+		Tags: tags,
 	}
 
-	memories, err := s.storage.Search(ctx, embedding, opts)
+	return s.search(ctx, query, limit, opts, types.SearchMode(mode), rank)
+}
+
+// search runs the rankings mode calls for and combines them. Semantic search
+// keeps similarity scores; keyword and hybrid results are scored by
+// reciprocal rank fusion, except that hybrid keeps the similarity scores when
+// no keyword matches. An empty mode means hybrid.
+func (s *Service) search(ctx context.Context, query string, limit int, opts types.SearchOpts, mode types.SearchMode, rank types.RankingOverride) ([]types.Memory, error) {
+	if err := mode.Validate(); err != nil {
+		return nil, err
+	}
+	policy, err := s.ranking.Override(rank)
 	if err != nil {
 		return nil, err
 	}
+	opts.Limit = limit * policy.SearchOverfetch
+
+	var semantic []types.Memory
+	if mode != types.SearchKeyword {
+		embedding, err := s.embedder.EmbedForSearch(query)
+		if err != nil {
+			return nil, embedFailed(err)
+		}
+		semantic, err = s.storage.Search(ctx, embedding, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ranked := semantic
+	if mode != types.SearchSemantic {
+		keyword, err := s.storage.KeywordSearch(ctx, query, opts)
+		if err != nil {
+			return nil, fmt.Errorf("keyword search failed: %w", err)
+		}
+		// Fusing a single list would only replace its scores with ranks
+		if mode == types.SearchKeyword || len(keyword) > 0 {
+			ranked = fuseRankings(semantic, keyword)
+		}
+	}
 
-	return applyRecencyBoost(memories, limit), nil
+	results := applyRecencyBoost(ranked, limit, policy)
+	s.recordRetrievals(results...)
+	return results, nil
 }
 
-// applyRecencyBoost re-ranks search results using a hybrid of similarity and recency.
-// Results must already have SimilarityScore populated by the storage layer.
-func applyRecencyBoost(memories []types.Memory, limit int) []types.Memory {
+// applyRecencyBoost re-ranks search results using a hybrid of similarity and
+// recency, then folds in usage, weighted by policy. Results must already have
+// SimilarityScore populated by the storage layer.
+func applyRecencyBoost(memories []types.Memory, limit int, policy RankingPolicy) []types.Memory {
 	if len(memories) == 0 {
 		return memories
 	}
@@ -179,8 +364,11 @@ func applyRecencyBoost(memories []types.Memory, limit int) []types.Memory {
 	for i := range memories {
 		similarity := memories[i].SimilarityScore
 		ageDays := now.Sub(memories[i].CreatedAt).Hours() / 24
-		recency := math.Exp(-ageDays * math.Ln2 / recencyHalfLifeDays)
-		memories[i].SimilarityScore = similarity*(1-recencyWeight) + recency*recencyWeight
+		recency := math.Exp(-ageDays * math.Ln2 / policy.halfLife(memories[i].Type))
+		score := similarity*(1-policy.RecencyWeight) + recency*policy.RecencyWeight
+		n := float64(memories[i].RetrievalCount)
+		usage := n / (n + usageHalfRetrievals)
+		memories[i].SimilarityScore = score*(1-policy.UsageWeight) + usage*policy.UsageWeight
 	}
 
 	sort.Slice(memories, func(i, j int) bool {
@@ -193,16 +381,78 @@ func applyRecencyBoost(memories []types.Memory, limit int) []types.Memory {
 	return memories
 }
 
-// ListWithRepo returns memories with optional repo filter
-func (s *Service) ListWithRepo(ctx context.Context, limit int, memType, area, repo string, includeInvalid bool, offset int) ([]types.Memory, error) {
+// fuseRankings merges ranked lists by reciprocal rank fusion: each memory
+// scores the sum of 1/(rrfK+rank) over the lists it appears in. Scores are
+// scaled so a memory ranked first in every non-empty list scores 1.
+func fuseRankings(lists ...[]types.Memory) []types.Memory {
+	scores := make(map[int64]float64)
+	var fused []types.Memory
+	nonEmpty := 0
+
+	for _, list := range lists {
+		if len(list) > 0 {
+			nonEmpty++
+		}
+		for rank, mem := range list {
+			if _, seen := scores[mem.ID]; !seen {
+				fused = append(fused, mem)
+			}
+			scores[mem.ID] += 1 / float64(rrfK+rank+1)
+		}
+	}
+
+	maxScore := float64(nonEmpty) / float64(rrfK+1)
+	for i := range fused {
+		fused[i].SimilarityScore = scores[fused[i].ID] / maxScore
+	}
+
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].SimilarityScore > fused[j].SimilarityScore
+	})
+	return fused
+}
+
+// ListWithRepo returns memories with optional repo and tag filters. after,
+// if set, continues from a cursor; offset then skips memories past it.
+func (s *Service) ListWithRepo(ctx context.Context, limit int, memType, area, repo string, includeInvalid bool, offset int, after *types.ListCursor, tags types.TagFilter) ([]types.Memory, error) {
+	tags, err := tags.Normalize()
+	if err != nil {
+		return nil, err
+	}
 	opts := types.ListOpts{
 		Limit:          limit,
 		Offset:         offset,
+		After:          after,
 		Type:           types.MemoryType(memType),
 		Area:           area,
 		Repo:           repo,
+		Tags:           tags,
 		IncludeInvalid: includeInvalid,
 	}
 
 	return s.storage.List(ctx, opts)
 }
+
+// Tags returns the tags on valid memories with how many carry each, most
+// used first. A non-empty repo counts only that repo's memories.
+func (s *Service) Tags(ctx context.Context, repo string) ([]types.TagCount, error) {
+	return s.storage.Tags(ctx, repo)
+}
+
+// Catalog lists the values field takes on valid memories, with how many
+// have each and when one was last added or updated, most used first. A
+// non-empty repo lists only that repo's values.
+func (s *Service) Catalog(ctx context.Context, field types.CatalogField, repo string) ([]types.CatalogEntry, error) {
+	return s.storage.Catalog(ctx, field, repo)
+}
+
+// Stats counts the memories by repo, area, type, author and validity, with
+// growth per period ("day", "week" or "month", the default). A non-empty
+// repo counts only that repo's memories.
+func (s *Service) Stats(ctx context.Context, repo, period string) (*types.Stats, error) {
+	p := types.StatsPeriod(period)
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return s.storage.Stats(ctx, types.StatsOpts{Repo: repo, Period: p})
+}
@@ -2,49 +2,273 @@ package service_test
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/MereWhiplash/engram-cogitator/internal/service"
 	"github.com/MereWhiplash/engram-cogitator/internal/types"
 )
 
 // mockEmbedder implements embedder.Embedder for testing
-type mockEmbedder struct{}
+type mockEmbedder struct {
+	storageCalls int
+	searchCalls  int
+	err          error // returned by every embed call when set
+}
 
 func (m *mockEmbedder) EmbedForStorage(text string) ([]float32, error) {
+	m.storageCalls++
+	if m.err != nil {
+		return nil, m.err
+	}
 	return make([]float32, 768), nil
 }
 
 func (m *mockEmbedder) EmbedForSearch(query string) ([]float32, error) {
+	m.searchCalls++
+	if m.err != nil {
+		return nil, m.err
+	}
 	return make([]float32, 768), nil
 }
 
+func (m *mockEmbedder) Model() string {
+	return "mock-embed"
+}
+
+// mockBatchEmbedder is a mockEmbedder that also implements
+// embedder.BatchEmbedder
+type mockBatchEmbedder struct {
+	mockEmbedder
+	batchCalls int
+}
+
+func (m *mockBatchEmbedder) EmbedBatchForStorage(texts []string) ([][]float32, error) {
+	m.batchCalls++
+	out := make([][]float32, len(texts))
+	for i := range texts {
+		out[i] = make([]float32, 768)
+	}
+	return out, nil
+}
+
 // mockStorage implements storage.Storage for testing
 type mockStorage struct {
-	memories []types.Memory
-	nextID   int64
+	memories     []types.Memory
+	nextID       int64
+	invalidation types.Invalidation     // captures the last Invalidate call
+	merge        types.Merge            // captures the last Merge call
+	bulk         types.BulkInvalidation // captures the last InvalidateMatching call
+	searchLimit  int                    // captures opts.Limit from the last Search call
+	retrievals   map[int64]int          // sums every RecordRetrievals call
+	retrievalErr error                  // returned by RecordRetrievals when set
+	flushes      int                    // counts successful RecordRetrievals calls
 }
 
 func (m *mockStorage) Add(ctx context.Context, mem types.Memory, embedding []float32) (*types.Memory, error) {
 	m.nextID++
 	mem.ID = m.nextID
 	mem.IsValid = true
+	mem.Version = 1
 	m.memories = append(m.memories, mem)
 	return &mem, nil
 }
 
+func (m *mockStorage) AddBatch(ctx context.Context, mems []types.Memory, embeddings [][]float32) ([]types.Memory, error) {
+	added := make([]types.Memory, len(mems))
+	for i, mem := range mems {
+		a, err := m.Add(ctx, mem, embeddings[i])
+		if err != nil {
+			return nil, err
+		}
+		added[i] = *a
+	}
+	return added, nil
+}
+
 func (m *mockStorage) Search(ctx context.Context, embedding []float32, opts types.SearchOpts) ([]types.Memory, error) {
-	return m.memories, nil
+	m.searchLimit = opts.Limit
+	return slices.Clone(m.memories), nil
+}
+
+// KeywordSearch matches memories containing any query term, case-insensitively
+func (m *mockStorage) KeywordSearch(ctx context.Context, query string, opts types.SearchOpts) ([]types.Memory, error) {
+	var results []types.Memory
+	for _, mem := range m.memories {
+		for _, term := range strings.Fields(strings.ToLower(query)) {
+			if strings.Contains(strings.ToLower(mem.Content), term) {
+				results = append(results, mem)
+				break
+			}
+		}
+	}
+	return results, nil
 }
 
 func (m *mockStorage) List(ctx context.Context, opts types.ListOpts) ([]types.Memory, error) {
 	return m.memories, nil
 }
 
-func (m *mockStorage) Invalidate(ctx context.Context, id int64, supersededBy *int64) error {
+func (m *mockStorage) Get(ctx context.Context, id int64) (*types.Memory, error) {
+	for i := range m.memories {
+		if m.memories[i].ID == id {
+			mem := m.memories[i]
+			return &mem, nil
+		}
+	}
+	return nil, types.ErrNotFound
+}
+
+func (m *mockStorage) Invalidate(ctx context.Context, inv types.Invalidation) error {
+	m.invalidation = inv
 	return nil
 }
 
+func (m *mockStorage) InvalidateMatching(ctx context.Context, bi types.BulkInvalidation) ([]int64, error) {
+	m.bulk = bi
+	ids := []int64{}
+	for _, mem := range m.memories {
+		if mem.IsValid && bi.Filter.Matches(mem) {
+			ids = append(ids, mem.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (m *mockStorage) Revalidate(ctx context.Context, rev types.Revalidation) (*types.Memory, error) {
+	for i := range m.memories {
+		if m.memories[i].ID == rev.ID {
+			m.memories[i].IsValid = true
+			m.memories[i].SupersededBy = nil
+			m.memories[i].RestoredByName = rev.ByName
+			m.memories[i].RestoredByEmail = rev.ByEmail
+			mem := m.memories[i]
+			return &mem, nil
+		}
+	}
+	return nil, types.ErrNotFound
+}
+
+func (m *mockStorage) Merge(ctx context.Context, mg types.Merge, embedding []float32) (*types.Memory, error) {
+	m.merge = mg
+	merged, err := m.Add(ctx, mg.Memory, embedding)
+	if err != nil {
+		return nil, err
+	}
+	for i := range m.memories {
+		if slices.Contains(mg.Sources, m.memories[i].ID) {
+			m.memories[i].IsValid = false
+			m.memories[i].SupersededBy = &merged.ID
+			m.memories[i].InvalidationReason = mg.Reason
+		}
+	}
+	return merged, nil
+}
+
+func (m *mockStorage) Update(ctx context.Context, upd types.MemoryUpdate, embedding []float32) (*types.Memory, error) {
+	for i := range m.memories {
+		if m.memories[i].ID == upd.ID {
+			if m.memories[i].Version != upd.ExpectedVersion {
+				return nil, types.ErrVersionConflict
+			}
+			m.memories[i].Type = upd.Type
+			m.memories[i].Area = upd.Area
+			m.memories[i].Content = upd.Content
+			m.memories[i].Rationale = upd.Rationale
+			m.memories[i].ExpiresAt = upd.ExpiresAt
+			m.memories[i].ReviewBy = upd.ReviewBy
+			m.memories[i].Version++
+			mem := m.memories[i]
+			return &mem, nil
+		}
+	}
+	return nil, types.ErrNotFound
+}
+
+func (m *mockStorage) History(ctx context.Context, id int64) ([]types.Revision, error) {
+	return nil, nil
+}
+
+func (m *mockStorage) Tags(ctx context.Context, repo string) ([]types.TagCount, error) {
+	return nil, nil
+}
+
+func (m *mockStorage) Catalog(ctx context.Context, field types.CatalogField, repo string) ([]types.CatalogEntry, error) {
+	return nil, nil
+}
+
+func (m *mockStorage) Stats(ctx context.Context, opts types.StatsOpts) (*types.Stats, error) {
+	stats := &types.Stats{Period: opts.Period.OrDefault()}
+	for _, mem := range m.memories {
+		if opts.Repo != "" && mem.Repo != opts.Repo {
+			continue
+		}
+		if mem.IsValid {
+			stats.Valid++
+		} else {
+			stats.Invalid++
+		}
+	}
+	stats.Total = stats.Valid + stats.Invalid
+	return stats, nil
+}
+
+func (m *mockStorage) Expire(ctx context.Context, now time.Time) (int64, error) {
+	var expired int64
+	for i := range m.memories {
+		if m.memories[i].IsValid && m.memories[i].ExpiresAt != nil && !m.memories[i].ExpiresAt.After(now) {
+			m.memories[i].IsValid = false
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+func (m *mockStorage) DueForReview(ctx context.Context, repo string, now time.Time, limit int) ([]types.Memory, error) {
+	var due []types.Memory
+	for _, mem := range m.memories {
+		if mem.IsValid && mem.ReviewBy != nil && !mem.ReviewBy.After(now) {
+			due = append(due, mem)
+		}
+	}
+	return due, nil
+}
+
+func (m *mockStorage) RecordRetrievals(ctx context.Context, counts map[int64]int, at time.Time) error {
+	if m.retrievalErr != nil {
+		return m.retrievalErr
+	}
+	if m.retrievals == nil {
+		m.retrievals = make(map[int64]int)
+	}
+	for id, n := range counts {
+		m.retrievals[id] += n
+	}
+	m.flushes++
+	return nil
+}
+
+func (m *mockStorage) Unretrieved(ctx context.Context, repo string, before time.Time, limit int) ([]types.Memory, error) {
+	var stale []types.Memory
+	for _, mem := range m.memories {
+		if mem.IsValid && mem.RetrievalCount == 0 && mem.CreatedAt.Before(before) {
+			stale = append(stale, mem)
+		}
+	}
+	return stale, nil
+}
+
+// Lineage returns every stored memory: lineage tests store only one lineage
+func (m *mockStorage) Lineage(ctx context.Context, id int64) ([]types.Memory, error) {
+	if _, err := m.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return append([]types.Memory(nil), m.memories...), nil
+}
+
 func (m *mockStorage) Close() error {
 	return nil
 }
@@ -81,7 +305,7 @@ func TestService_Search(t *testing.T) {
 		t.Fatalf("Add failed: %v", err)
 	}
 
-	results, err := svc.Search(ctx, "jwt tokens", 5, "", "")
+	results, err := svc.Search(ctx, "jwt tokens", 5, "", "", "")
 	if err != nil {
 		t.Fatalf("Search failed: %v", err)
 	}
@@ -91,6 +315,76 @@ func TestService_Search(t *testing.T) {
 	}
 }
 
+func TestService_Search_Modes(t *testing.T) {
+	store := &mockStorage{}
+	emb := &mockEmbedder{}
+	svc := service.New(store, emb)
+
+	ctx := context.Background()
+	for _, content := range []string{"Use JWT", "Retry on 503", "ERR_QUOTA means the plan limit was hit"} {
+		if _, err := svc.Add(ctx, types.TypeLearning, "api", content, ""); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	// The mock's semantic ranking is insertion order, so only keyword
+	// matching can lift the last memory to the top
+	semantic, err := svc.Search(ctx, "err_quota", 5, "", "", types.SearchSemantic)
+	if err != nil {
+		t.Fatalf("semantic Search failed: %v", err)
+	}
+	if len(semantic) != 3 {
+		t.Errorf("expected 3 semantic results, got %d", len(semantic))
+	}
+
+	searchCalls := emb.searchCalls
+	keyword, err := svc.Search(ctx, "err_quota", 5, "", "", types.SearchKeyword)
+	if err != nil {
+		t.Fatalf("keyword Search failed: %v", err)
+	}
+	if len(keyword) != 1 || keyword[0].ID != 3 {
+		t.Errorf("expected only memory 3, got %+v", keyword)
+	}
+	if emb.searchCalls != searchCalls {
+		t.Error("expected keyword search not to embed the query")
+	}
+
+	hybrid, err := svc.Search(ctx, "err_quota", 5, "", "", "")
+	if err != nil {
+		t.Fatalf("hybrid Search failed: %v", err)
+	}
+	if len(hybrid) != 3 || hybrid[0].ID != 3 {
+		t.Fatalf("expected keyword match ranked first of 3, got %+v", hybrid)
+	}
+	if hybrid[0].SimilarityScore <= hybrid[1].SimilarityScore {
+		t.Errorf("expected fused scores to descend, got %v then %v",
+			hybrid[0].SimilarityScore, hybrid[1].SimilarityScore)
+	}
+
+	if _, err := svc.Search(ctx, "err_quota", 5, "", "", "fuzzy"); err == nil {
+		t.Error("expected error for unknown search mode")
+	}
+}
+
+func TestService_Search_HybridWithoutKeywordMatches(t *testing.T) {
+	store := &mockStorage{memories: []types.Memory{
+		{ID: 1, Content: "Use JWT", SimilarityScore: 0.9},
+		{ID: 2, Content: "Retry on 503", SimilarityScore: 0.4},
+	}}
+	svc := service.New(store, &mockEmbedder{})
+
+	// Nothing to fuse with, so the cosine scores survive rather than
+	// flattening into near-equal ranks
+	noRecency := 0.0
+	results, err := svc.SearchWithRepo(context.Background(), "bearer tokens", 5, "", "", "", "", types.TagFilter{}, types.RankingOverride{RecencyWeight: &noRecency})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].SimilarityScore != 0.9 || results[1].SimilarityScore != 0.4 {
+		t.Errorf("expected the semantic scores 0.9 and 0.4, got %+v", results)
+	}
+}
+
 func TestService_Add_InvalidType(t *testing.T) {
 	store := &mockStorage{}
 	emb := &mockEmbedder{}
@@ -98,7 +392,424 @@ func TestService_Add_InvalidType(t *testing.T) {
 
 	ctx := context.Background()
 	_, err := svc.Add(ctx, "invalid", "auth", "Should fail", "")
-	if err == nil {
+	if !errors.Is(err, types.ErrValidation) {
+		t.Errorf("expected types.ErrValidation for invalid type, got %v", err)
+	}
+}
+
+func TestService_EmbedderUnavailable(t *testing.T) {
+	store := &mockStorage{}
+	emb := &mockEmbedder{err: errors.New("connection refused")}
+	svc := service.New(store, emb)
+
+	ctx := context.Background()
+	if _, err := svc.Add(ctx, types.TypeDecision, "auth", "Use JWT", ""); !errors.Is(err, types.ErrEmbedderUnavailable) {
+		t.Errorf("Add: expected types.ErrEmbedderUnavailable, got %v", err)
+	}
+	if _, err := svc.Search(ctx, "auth", 5, "", "", types.SearchSemantic); !errors.Is(err, types.ErrEmbedderUnavailable) {
+		t.Errorf("Search: expected types.ErrEmbedderUnavailable, got %v", err)
+	}
+	if _, err := svc.Search(ctx, "auth", 5, "", "", types.SearchKeyword); err != nil {
+		t.Errorf("keyword Search should not need the embedder, got %v", err)
+	}
+}
+
+func TestService_Update(t *testing.T) {
+	store := &mockStorage{}
+	emb := &mockEmbedder{}
+	svc := service.New(store, emb)
+
+	ctx := context.Background()
+	mem, err := svc.Add(ctx, types.TypeDecision, "auth", "Use JWT", "")
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	rationale := "Stateless"
+	updated, err := svc.Update(ctx, service.UpdateParams{ID: mem.ID, Rationale: &rationale})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.ID != mem.ID {
+		t.Errorf("expected ID %d to be kept, got %d", mem.ID, updated.ID)
+	}
+	if updated.Content != "Use JWT" || updated.Rationale != "Stateless" {
+		t.Errorf("expected merged fields, got %+v", updated)
+	}
+	if updated.Version != 2 {
+		t.Errorf("expected version 2, got %d", updated.Version)
+	}
+	if emb.storageCalls != 2 {
+		t.Errorf("expected changed text to be re-embedded, got %d embed calls", emb.storageCalls)
+	}
+
+	// Changing only the type does not change the embedded text
+	memType := string(types.TypePattern)
+	if _, err := svc.Update(ctx, service.UpdateParams{ID: mem.ID, Type: &memType}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if emb.storageCalls != 2 {
+		t.Errorf("expected type-only change to skip re-embedding, got %d embed calls", emb.storageCalls)
+	}
+}
+
+func TestService_Update_VersionConflict(t *testing.T) {
+	store := &mockStorage{}
+	svc := service.New(store, &mockEmbedder{})
+
+	ctx := context.Background()
+	mem, _ := svc.Add(ctx, types.TypeDecision, "auth", "Use JWT", "")
+
+	content := "Use PASETO"
+	_, err := svc.Update(ctx, service.UpdateParams{ID: mem.ID, Content: &content, ExpectedVersion: 3})
+	if !errors.Is(err, types.ErrVersionConflict) {
+		t.Errorf("expected types.ErrVersionConflict, got %v", err)
+	}
+}
+
+func TestService_Update_Dates(t *testing.T) {
+	store := &mockStorage{}
+	emb := &mockEmbedder{}
+	svc := service.New(store, emb)
+
+	ctx := context.Background()
+	reviewBy := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	mem, err := svc.AddWithContext(ctx, service.AddParams{Type: "learning", Area: "db", Content: "Pool maxes at 20", ReviewBy: &reviewBy})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	expiresAt := time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated, err := svc.Update(ctx, service.UpdateParams{ID: mem.ID, ExpiresAt: &expiresAt})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.ExpiresAt == nil || !updated.ExpiresAt.Equal(expiresAt) || updated.ReviewBy == nil {
+		t.Errorf("expected expires_at set and review_by kept, got %v and %v", updated.ExpiresAt, updated.ReviewBy)
+	}
+	if emb.storageCalls != 1 {
+		t.Errorf("expected a date change to skip re-embedding, got %d embed calls", emb.storageCalls)
+	}
+
+	// The zero time clears a date
+	updated, err = svc.Update(ctx, service.UpdateParams{ID: mem.ID, ReviewBy: &time.Time{}})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.ReviewBy != nil || updated.ExpiresAt == nil {
+		t.Errorf("expected review_by cleared and expires_at kept, got %v and %v", updated.ReviewBy, updated.ExpiresAt)
+	}
+
+	// Setting the same date again is not an edit
+	same, err := svc.Update(ctx, service.UpdateParams{ID: mem.ID, ExpiresAt: &expiresAt})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if same.Version != updated.Version {
+		t.Errorf("expected an unchanged date to keep version %d, got %d", updated.Version, same.Version)
+	}
+}
+
+func TestService_ExpireDue(t *testing.T) {
+	store := &mockStorage{}
+	svc := service.New(store, &mockEmbedder{})
+
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	expired, _ := svc.AddWithContext(ctx, service.AddParams{Type: "learning", Area: "infra", Content: "Staging on PG 14", ExpiresAt: &past})
+	svc.AddWithContext(ctx, service.AddParams{Type: "learning", Area: "infra", Content: "Freeze until Friday", ExpiresAt: &future})
+	svc.AddWithContext(ctx, service.AddParams{Type: "learning", Area: "infra", Content: "Use blue green", ReviewBy: &past})
+
+	n, err := svc.ExpireDue(ctx)
+	if err != nil {
+		t.Fatalf("ExpireDue failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 memory expired, got %d", n)
+	}
+	if got, _ := svc.Get(ctx, expired.ID); got == nil || got.IsValid {
+		t.Error("expected the past-expiry memory to be invalidated")
+	}
+
+	due, err := svc.DueForReview(ctx, "", 0)
+	if err != nil {
+		t.Fatalf("DueForReview failed: %v", err)
+	}
+	if len(due) != 1 || due[0].Content != "Use blue green" {
+		t.Errorf("expected the overdue memory, got %+v", due)
+	}
+}
+
+func TestService_Update_InvalidType(t *testing.T) {
+	store := &mockStorage{}
+	svc := service.New(store, &mockEmbedder{})
+
+	ctx := context.Background()
+	mem, _ := svc.Add(ctx, types.TypeDecision, "auth", "Use JWT", "")
+
+	memType := "invalid"
+	if _, err := svc.Update(ctx, service.UpdateParams{ID: mem.ID, Type: &memType}); err == nil {
 		t.Error("expected error for invalid type, got nil")
 	}
 }
+
+func TestService_Invalidate_SupersedeSameRepo(t *testing.T) {
+	store := &mockStorage{}
+	svc := service.New(store, &mockEmbedder{})
+	ctx := context.Background()
+	by := int64(2)
+
+	svc.Invalidate(ctx, types.Invalidation{ID: 1, SupersededBy: &by})
+	if store.invalidation.SameRepo {
+		t.Error("expected SameRepo off by default")
+	}
+
+	svc.SetSupersedeSameRepo(true)
+	svc.Invalidate(ctx, types.Invalidation{ID: 1, SupersededBy: &by})
+	if !store.invalidation.SameRepo {
+		t.Error("expected SameRepo set once required")
+	}
+}
+
+func TestService_InvalidateMatching(t *testing.T) {
+	store := &mockStorage{memories: []types.Memory{
+		{ID: 1, Area: "graphql", IsValid: true},
+		{ID: 2, Area: "rest", IsValid: true},
+		{ID: 3, Area: "graphql", IsValid: true},
+	}}
+	svc := service.New(store, &mockEmbedder{})
+	svc.SetSupersedeSameRepo(true)
+	ctx := context.Background()
+
+	ids, err := svc.InvalidateMatching(ctx, types.BulkInvalidation{
+		Filter: types.MemoryFilter{Area: "graphql"},
+		Reason: "GraphQL removed",
+		DryRun: true,
+	})
+	if err != nil {
+		t.Fatalf("InvalidateMatching failed: %v", err)
+	}
+	if !slices.Equal(ids, []int64{1, 3}) {
+		t.Errorf("expected [1 3], got %v", ids)
+	}
+	if !store.bulk.SameRepo || !store.bulk.DryRun || store.bulk.Reason != "GraphQL removed" {
+		t.Errorf("unexpected bulk invalidation: %+v", store.bulk)
+	}
+
+	tests := []struct {
+		name   string
+		filter types.MemoryFilter
+	}{
+		{"no filter", types.MemoryFilter{}},
+		{"bad type", types.MemoryFilter{Type: "fact"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.InvalidateMatching(ctx, types.BulkInvalidation{Filter: tt.filter})
+			if !errors.Is(err, types.ErrValidation) {
+				t.Errorf("expected ErrValidation, got %v", err)
+			}
+		})
+	}
+}
+
+func TestService_Stats(t *testing.T) {
+	store := &mockStorage{memories: []types.Memory{
+		{ID: 1, Repo: "org/one", IsValid: true},
+		{ID: 2, Repo: "org/one"},
+		{ID: 3, Repo: "org/two", IsValid: true},
+	}}
+	svc := service.New(store, &mockEmbedder{})
+
+	stats, err := svc.Stats(context.Background(), "org/one", "")
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Total != 2 || stats.Valid != 1 || stats.Period != types.PeriodMonth {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	if _, err := svc.Stats(context.Background(), "", "year"); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("expected ErrValidation for an unknown period, got %v", err)
+	}
+}
+
+func TestService_Merge(t *testing.T) {
+	store := &mockStorage{}
+	svc := service.New(store, &mockEmbedder{})
+	svc.SetSupersedeSameRepo(true)
+	ctx := context.Background()
+
+	merged, err := svc.Merge(ctx, service.MergeParams{
+		AddParams: service.AddParams{
+			Type:       "decision",
+			Area:       "auth",
+			Content:    "Use JWT with rotation",
+			AuthorName: "Alice",
+			Tags:       []string{"Security"},
+		},
+		Sources: []int64{3, 1, 3},
+		Reason:  "duplicates",
+	})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if merged.EmbeddingModel != "mock-embed" || merged.AuthorName != "Alice" {
+		t.Errorf("unexpected merged memory: %+v", merged)
+	}
+	got := store.merge
+	if !slices.Equal(got.Sources, []int64{3, 1}) {
+		t.Errorf("expected deduplicated sources [3 1], got %v", got.Sources)
+	}
+	if !got.SameRepo || got.ByName != "Alice" || got.Reason != "duplicates" {
+		t.Errorf("unexpected merge: %+v", got)
+	}
+	if !slices.Equal(got.Memory.Tags, []string{"security"}) {
+		t.Errorf("expected normalized tags, got %v", got.Memory.Tags)
+	}
+}
+
+func TestService_Merge_Invalid(t *testing.T) {
+	svc := service.New(&mockStorage{}, &mockEmbedder{})
+	valid := service.AddParams{Type: "decision", Area: "auth", Content: "Use JWT"}
+
+	tests := []struct {
+		name   string
+		params service.MergeParams
+	}{
+		{"one source", service.MergeParams{AddParams: valid, Sources: []int64{1}}},
+		{"repeated source", service.MergeParams{AddParams: valid, Sources: []int64{1, 1}}},
+		{"bad type", service.MergeParams{AddParams: service.AddParams{Type: "fact", Area: "auth", Content: "x"}, Sources: []int64{1, 2}}},
+		{"no content", service.MergeParams{AddParams: service.AddParams{Type: "decision", Area: "auth"}, Sources: []int64{1, 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.Merge(context.Background(), tt.params)
+			if !errors.Is(err, types.ErrValidation) {
+				t.Errorf("expected ErrValidation, got %v", err)
+			}
+		})
+	}
+}
+
+func TestService_AddBatch(t *testing.T) {
+	store := &mockStorage{}
+	emb := &mockBatchEmbedder{}
+	svc := service.New(store, emb)
+
+	results, err := svc.AddBatch(context.Background(), []service.AddParams{
+		{Type: "decision", Area: "auth", Content: "Use JWT", Repo: "org/one", Tags: []string{"Security"}},
+		{Type: "fact", Area: "auth", Content: "Not a type"},
+		{Type: "learning", Area: "db", Content: "Index foreign keys", AuthorName: "Alice"},
+		{Type: "pattern", Area: "api"},
+	})
+	if err != nil {
+		t.Fatalf("AddBatch failed: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+	for _, i := range []int{1, 3} {
+		if results[i].Memory != nil || !errors.Is(results[i].Err, types.ErrValidation) {
+			t.Errorf("result %d: expected ErrValidation, got %+v", i, results[i])
+		}
+	}
+	if r := results[0]; r.Err != nil || r.Memory == nil || r.Memory.Content != "Use JWT" || !slices.Equal(r.Memory.Tags, []string{"security"}) {
+		t.Errorf("result 0: unexpected %+v", r)
+	}
+	if r := results[2]; r.Err != nil || r.Memory == nil || r.Memory.AuthorName != "Alice" || r.Memory.EmbeddingModel != "mock-embed" {
+		t.Errorf("result 2: unexpected %+v", r)
+	}
+	if emb.batchCalls != 1 || emb.storageCalls != 0 {
+		t.Errorf("expected one batch embed call, got %d batch and %d single", emb.batchCalls, emb.storageCalls)
+	}
+	if len(store.memories) != 2 {
+		t.Errorf("expected 2 memories stored, got %d", len(store.memories))
+	}
+}
+
+func TestService_AddBatch_Fallback(t *testing.T) {
+	emb := &mockEmbedder{}
+	svc := service.New(&mockStorage{}, emb)
+
+	params := service.AddParams{Type: "decision", Area: "auth", Content: "Use JWT"}
+	results, err := svc.AddBatch(context.Background(), []service.AddParams{params, params, params})
+	if err != nil {
+		t.Fatalf("AddBatch failed: %v", err)
+	}
+	for i, r := range results {
+		if r.Err != nil || r.Memory == nil {
+			t.Errorf("result %d: unexpected %+v", i, r)
+		}
+	}
+	if emb.storageCalls != 3 {
+		t.Errorf("expected one embed call per memory without batch support, got %d", emb.storageCalls)
+	}
+
+	emb.err = errors.New("connection refused")
+	if _, err := svc.AddBatch(context.Background(), []service.AddParams{params}); !errors.Is(err, types.ErrEmbedderUnavailable) {
+		t.Errorf("expected ErrEmbedderUnavailable, got %v", err)
+	}
+
+	tooMany := make([]service.AddParams, service.MaxBatchSize+1)
+	if _, err := svc.AddBatch(context.Background(), tooMany); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("expected ErrValidation for an oversized batch, got %v", err)
+	}
+}
+
+func TestService_Lineage(t *testing.T) {
+	ctx := context.Background()
+	ref := func(id int64) *int64 { return &id }
+	now := time.Now()
+
+	// 4 and 1 were merged into 2, which 3 replaced; 4 is the oldest
+	store := &mockStorage{memories: []types.Memory{
+		{ID: 1, Content: "Use sessions", SupersededBy: ref(2), CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: 2, Content: "Use JWT", SupersededBy: ref(3), CreatedAt: now.Add(-time.Hour)},
+		{ID: 3, Content: "Use JWT with rotation", IsValid: true, CreatedAt: now},
+		{ID: 4, Content: "Use cookies", SupersededBy: ref(2), CreatedAt: now.Add(-3 * time.Hour)},
+	}}
+	svc := service.New(store, &mockEmbedder{})
+
+	lineage, err := svc.Lineage(ctx, 2)
+	if err != nil {
+		t.Fatalf("Lineage failed: %v", err)
+	}
+	if len(lineage.Ancestors) != 2 || lineage.Ancestors[0].ID != 4 || lineage.Ancestors[1].ID != 1 {
+		t.Errorf("expected ancestors 4 then 1, got %+v", lineage.Ancestors)
+	}
+	if len(lineage.Descendants) != 1 || lineage.Descendants[0].ID != 3 {
+		t.Errorf("expected descendant 3, got %+v", lineage.Descendants)
+	}
+	if lineage.Current == nil || lineage.Current.ID != 3 || lineage.Cycle {
+		t.Errorf("expected memory 3 current and no cycle, got %+v", lineage)
+	}
+
+	if _, err := svc.Lineage(ctx, 99); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestService_Lineage_Cycle(t *testing.T) {
+	ref := func(id int64) *int64 { return &id }
+	store := &mockStorage{memories: []types.Memory{
+		{ID: 1, Content: "Use tabs", SupersededBy: ref(2)},
+		{ID: 2, Content: "Use spaces", SupersededBy: ref(1)},
+	}}
+	svc := service.New(store, &mockEmbedder{})
+
+	lineage, err := svc.Lineage(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Lineage failed: %v", err)
+	}
+	if !lineage.Cycle {
+		t.Error("expected the cycle reported")
+	}
+	if len(lineage.Descendants) != 1 || lineage.Descendants[0].ID != 2 || len(lineage.Ancestors) != 0 {
+		t.Errorf("expected memory 2 as the only descendant, got %+v", lineage)
+	}
+	if lineage.Current != nil {
+		t.Errorf("expected no current memory, got %+v", lineage.Current)
+	}
+}
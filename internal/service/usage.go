@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// defaultStaleAgeDays is how old a never-retrieved memory must be before
+// StaleCandidates lists it when no minimum age is given
+const defaultStaleAgeDays = 30
+
+// usageTracker buffers retrieval counts between flushes so reads don't each
+// write to storage
+type usageTracker struct {
+	mu     sync.Mutex
+	counts map[int64]int
+	// flushed is set once a flush reaches storage, which records when
+	// tracking started even if nothing has been retrieved yet
+	flushed bool
+}
+
+// TrackUsage makes the service count each memory returned by a search or
+// get. Counts are buffered until FlushUsage writes them to storage. Call it
+// before the service starts serving.
+func (s *Service) TrackUsage() {
+	s.usage = &usageTracker{counts: make(map[int64]int)}
+}
+
+// recordRetrievals buffers one retrieval of each memory if usage is tracked
+func (s *Service) recordRetrievals(memories ...types.Memory) {
+	if s.usage == nil || len(memories) == 0 {
+		return
+	}
+	s.usage.mu.Lock()
+	defer s.usage.mu.Unlock()
+	for _, mem := range memories {
+		s.usage.counts[mem.ID]++
+	}
+}
+
+// FlushUsage writes the buffered retrieval counts to storage. Counts that
+// fail to write stay buffered for the next flush.
+func (s *Service) FlushUsage(ctx context.Context) error {
+	if s.usage == nil {
+		return nil
+	}
+
+	s.usage.mu.Lock()
+	counts := s.usage.counts
+	s.usage.counts = make(map[int64]int)
+	flushed := s.usage.flushed
+	s.usage.mu.Unlock()
+
+	if len(counts) == 0 && flushed {
+		return nil
+	}
+	if err := s.storage.RecordRetrievals(ctx, counts, time.Now()); err != nil {
+		s.usage.mu.Lock()
+		for id, n := range counts {
+			s.usage.counts[id] += n
+		}
+		s.usage.mu.Unlock()
+		return err
+	}
+	s.usage.mu.Lock()
+	s.usage.flushed = true
+	s.usage.mu.Unlock()
+	return nil
+}
+
+// RunUsageFlusher calls FlushUsage every interval until ctx is done.
+// Failures are logged and retried on the next tick.
+func (s *Service) RunUsageFlusher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.FlushUsage(ctx); err != nil {
+			log.Printf("Usage flush failed: %v", err)
+		}
+	}
+}
+
+// StaleCandidates returns valid memories at least minAgeDays old (30 if
+// zero) that have never been retrieved, oldest first. A non-empty repo
+// restricts them to that repo. Age counts from when usage tracking first
+// flushed to the database, so an upgraded database lists nothing until
+// tracking has been on for minAgeDays. Without usage tracking nothing is ever
+// counted, so every old memory would look stale; it returns a validation
+// error instead.
+func (s *Service) StaleCandidates(ctx context.Context, repo string, minAgeDays, limit int) ([]types.Memory, error) {
+	if s.usage == nil {
+		return nil, types.Invalidf("usage tracking is off on this server, so stale memories can't be told apart from ones in use")
+	}
+	if minAgeDays < 0 {
+		return nil, types.Invalidf("min age must be a non-negative number of days, got %d", minAgeDays)
+	}
+	if minAgeDays == 0 {
+		minAgeDays = defaultStaleAgeDays
+	}
+	if limit <= 0 {
+		limit = defaultReviewLimit
+	}
+	before := time.Now().AddDate(0, 0, -minAgeDays)
+	return s.storage.Unretrieved(ctx, repo, before, limit)
+}
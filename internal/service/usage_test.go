@@ -0,0 +1,167 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/service"
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+func TestService_TrackUsage(t *testing.T) {
+	ctx := context.Background()
+	store := &mockStorage{memories: []types.Memory{
+		{ID: 1, Content: "first", IsValid: true, SimilarityScore: 0.9},
+		{ID: 2, Content: "second", IsValid: true, SimilarityScore: 0.8},
+	}}
+	svc := service.New(store, &mockEmbedder{})
+
+	// Without tracking nothing is counted
+	if _, err := svc.Get(ctx, 1); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if err := svc.FlushUsage(ctx); err != nil || store.retrievals != nil {
+		t.Fatalf("expected no retrievals recorded untracked, got %v (err %v)", store.retrievals, err)
+	}
+
+	svc.TrackUsage()
+	if _, err := svc.SearchWithRepo(ctx, "q", 5, "", "", "", string(types.SearchSemantic), types.TagFilter{}, types.RankingOverride{}); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if _, err := svc.Get(ctx, 1); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if _, err := svc.Get(ctx, 99); err == nil {
+		t.Fatal("expected Get of a missing memory to fail")
+	}
+	if store.retrievals != nil {
+		t.Fatalf("expected reads to be buffered until a flush, got %v", store.retrievals)
+	}
+
+	// A failed flush keeps the counts for the next one
+	store.retrievalErr = errors.New("database is locked")
+	if err := svc.FlushUsage(ctx); err == nil {
+		t.Fatal("expected the flush to fail")
+	}
+	store.retrievalErr = nil
+	if _, err := svc.Get(ctx, 2); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if err := svc.FlushUsage(ctx); err != nil {
+		t.Fatalf("FlushUsage failed: %v", err)
+	}
+	if store.retrievals[1] != 2 || store.retrievals[2] != 2 || len(store.retrievals) != 2 {
+		t.Errorf("expected 2 retrievals of each memory, got %v", store.retrievals)
+	}
+
+	// The buffer is empty after a flush
+	store.retrievals = nil
+	if err := svc.FlushUsage(ctx); err != nil || store.retrievals != nil {
+		t.Errorf("expected an empty flush to write nothing, got %v (err %v)", store.retrievals, err)
+	}
+}
+
+func TestService_History_NotARetrieval(t *testing.T) {
+	ctx := context.Background()
+	store := &mockStorage{memories: []types.Memory{{ID: 1, Content: "first", IsValid: true}}}
+	svc := service.New(store, &mockEmbedder{})
+	svc.TrackUsage()
+
+	mem, _, err := svc.History(ctx, 1)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if mem == nil || mem.ID != 1 {
+		t.Errorf("expected memory 1, got %+v", mem)
+	}
+	if _, _, err := svc.History(ctx, 99); err == nil {
+		t.Error("expected History of a missing memory to fail")
+	}
+	if err := svc.FlushUsage(ctx); err != nil || len(store.retrievals) != 0 {
+		t.Errorf("expected viewing history to record no retrievals, got %v (err %v)", store.retrievals, err)
+	}
+}
+
+func TestService_FlushUsage_RecordsStart(t *testing.T) {
+	ctx := context.Background()
+	store := &mockStorage{}
+	svc := service.New(store, &mockEmbedder{})
+	svc.TrackUsage()
+
+	// The first flush reaches storage with nothing retrieved, so the
+	// database learns when tracking started; later empty ones are skipped
+	for i := 0; i < 3; i++ {
+		if err := svc.FlushUsage(ctx); err != nil {
+			t.Fatalf("FlushUsage failed: %v", err)
+		}
+	}
+	if store.flushes != 1 || len(store.retrievals) != 0 {
+		t.Errorf("expected one empty flush, got %d writing %v", store.flushes, store.retrievals)
+	}
+}
+
+func TestService_Search_UsageWeight(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	// A well-used memory and a slightly closer one nobody has retrieved
+	store := &mockStorage{memories: []types.Memory{
+		{ID: 1, Content: "unused", SimilarityScore: 0.8, CreatedAt: now},
+		{ID: 2, Content: "used", SimilarityScore: 0.75, CreatedAt: now, RetrievalCount: 40},
+	}}
+	svc := service.New(store, &mockEmbedder{})
+
+	first := func(rank types.RankingOverride) int64 {
+		t.Helper()
+		results, err := svc.SearchWithRepo(ctx, "q", 1, "", "", "", string(types.SearchSemantic), types.TagFilter{}, rank)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		return results[0].ID
+	}
+	weight := func(w float64) *float64 { return &w }
+
+	// Usage is opt-in, so default scores don't shift as counts build up
+	if got := first(types.RankingOverride{}); got != 1 {
+		t.Errorf("default policy: expected the closer match first, got %d", got)
+	}
+	if got := first(types.RankingOverride{UsageWeight: weight(0.1)}); got != 2 {
+		t.Errorf("usage weighted: expected the used memory first, got %d", got)
+	}
+	if _, err := svc.SearchWithRepo(ctx, "q", 1, "", "", "", "", types.TagFilter{}, types.RankingOverride{UsageWeight: weight(-1)}); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("expected types.ErrValidation for a negative usage weight, got %v", err)
+	}
+}
+
+func TestService_StaleCandidates(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := &mockStorage{memories: []types.Memory{
+		{ID: 1, Content: "old and unused", IsValid: true, CreatedAt: now.AddDate(0, 0, -90)},
+		{ID: 2, Content: "old and used", IsValid: true, CreatedAt: now.AddDate(0, 0, -90), RetrievalCount: 3},
+		{ID: 3, Content: "new", IsValid: true, CreatedAt: now.AddDate(0, 0, -5)},
+	}}
+	svc := service.New(store, &mockEmbedder{})
+
+	// Untracked, no memory has a count to go by
+	if _, err := svc.StaleCandidates(ctx, "", 0, 0); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("expected types.ErrValidation without usage tracking, got %v", err)
+	}
+
+	svc.TrackUsage()
+	stale, err := svc.StaleCandidates(ctx, "", 0, 0)
+	if err != nil {
+		t.Fatalf("StaleCandidates failed: %v", err)
+	}
+	if len(stale) != 1 || stale[0].ID != 1 {
+		t.Errorf("expected only the old unused memory by default, got %+v", stale)
+	}
+	if stale, _ := svc.StaleCandidates(ctx, "", 1, 0); len(stale) != 2 {
+		t.Errorf("expected a 1-day minimum age to include the new memory, got %+v", stale)
+	}
+	if _, err := svc.StaleCandidates(ctx, "", -1, 0); !errors.Is(err, types.ErrValidation) {
+		t.Errorf("expected types.ErrValidation for a negative age, got %v", err)
+	}
+}
@@ -3,20 +3,33 @@ package shim
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
+	"github.com/MereWhiplash/engram-cogitator/internal/apitypes"
 	"github.com/MereWhiplash/engram-cogitator/internal/mcptypes"
 	"github.com/MereWhiplash/engram-cogitator/internal/types"
 )
 
 // APIClient defines the interface for the central API client
 type APIClient interface {
-	Add(ctx context.Context, memType, area, content, rationale string) (*types.Memory, error)
-	Search(ctx context.Context, query string, limit int, memType, area string) ([]types.Memory, error)
-	List(ctx context.Context, limit int, memType, area string, includeInvalid bool) ([]types.Memory, error)
-	Invalidate(ctx context.Context, id int64, supersededBy *int64) error
+	Add(ctx context.Context, req apitypes.AddRequest) (*types.Memory, error)
+	AddBatch(ctx context.Context, req apitypes.BatchAddRequest) (*apitypes.BatchAddResponse, error)
+	Search(ctx context.Context, query string, limit int, memType, area, mode string, tags types.TagFilter, rank types.RankingOverride) ([]types.Memory, error)
+	List(ctx context.Context, limit int, memType, area string, includeInvalid bool, cursor string, tags types.TagFilter) ([]types.Memory, string, error)
+	Tags(ctx context.Context) ([]types.TagCount, error)
+	Repos(ctx context.Context) ([]types.CatalogEntry, error)
+	Areas(ctx context.Context) ([]types.CatalogEntry, error)
+	Authors(ctx context.Context) ([]types.CatalogEntry, error)
+	Stats(ctx context.Context, period string) (*types.Stats, error)
+	DueForReview(ctx context.Context, limit int) ([]types.Memory, error)
+	Stale(ctx context.Context, limit, minAgeDays int) ([]types.Memory, error)
+	Get(ctx context.Context, id int64) (*types.Memory, error)
+	Lineage(ctx context.Context, id int64) (*types.Lineage, error)
+	Update(ctx context.Context, id int64, req apitypes.UpdateRequest) (*types.Memory, error)
+	Invalidate(ctx context.Context, id int64, req apitypes.InvalidateRequest) error
+	Revalidate(ctx context.Context, id int64) (*types.Memory, error)
+	Merge(ctx context.Context, req apitypes.MergeRequest) (*types.Memory, error)
 }
 
 // Handler holds shim dependencies
@@ -32,9 +45,22 @@ func NewHandler(c APIClient) *Handler {
 // Register adds all EC tools to the MCP server
 func Register(server *mcp.Server, h *Handler) {
 	mcp.AddTool(server, mcptypes.AddTool, h.Add)
+	mcp.AddTool(server, mcptypes.AddBatchTool, h.AddBatch)
 	mcp.AddTool(server, mcptypes.SearchTool, h.Search)
+	mcp.AddTool(server, mcptypes.GetTool, h.Get)
+	mcp.AddTool(server, mcptypes.UpdateTool, h.Update)
 	mcp.AddTool(server, mcptypes.InvalidateTool, h.Invalidate)
+	mcp.AddTool(server, mcptypes.RestoreTool, h.Restore)
+	mcp.AddTool(server, mcptypes.MergeTool, h.Merge)
+	mcp.AddTool(server, mcptypes.LineageTool, h.Lineage)
 	mcp.AddTool(server, mcptypes.ListTool, h.List)
+	mcp.AddTool(server, mcptypes.TagsTool, h.Tags)
+	mcp.AddTool(server, mcptypes.ReposTool, h.Repos)
+	mcp.AddTool(server, mcptypes.AreasTool, h.Areas)
+	mcp.AddTool(server, mcptypes.AuthorsTool, h.Authors)
+	mcp.AddTool(server, mcptypes.StatsTool, h.Stats)
+	mcp.AddTool(server, mcptypes.ReviewTool, h.Review)
+	mcp.AddTool(server, mcptypes.StaleTool, h.Stale)
 }
 
 func (h *Handler) Add(ctx context.Context, _ *mcp.CallToolRequest, input mcptypes.AddInput) (*mcp.CallToolResult, mcptypes.AddOutput, error) {
@@ -42,9 +68,9 @@ func (h *Handler) Add(ctx context.Context, _ *mcp.CallToolRequest, input mcptype
 		return mcptypes.ErrorResult("type, area, and content are required"), mcptypes.AddOutput{}, nil
 	}
 
-	memory, err := h.client.Add(ctx, input.Type, input.Area, input.Content, input.Rationale)
+	memory, err := h.client.Add(ctx, input.Request())
 	if err != nil {
-		return mcptypes.ErrorResult(fmt.Sprintf("failed to store memory: %v", err)), mcptypes.AddOutput{}, nil
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "store memory", err)), mcptypes.AddOutput{}, nil
 	}
 
 	result, fmtErr := mcptypes.MemoryAddedResult(memory)
@@ -54,6 +80,24 @@ func (h *Handler) Add(ctx context.Context, _ *mcp.CallToolRequest, input mcptype
 	return result, mcptypes.AddOutput{Memory: memory}, nil
 }
 
+func (h *Handler) AddBatch(ctx context.Context, _ *mcp.CallToolRequest, input mcptypes.AddBatchInput) (*mcp.CallToolResult, mcptypes.AddBatchOutput, error) {
+	if len(input.Memories) == 0 {
+		return mcptypes.ErrorResult("memories are required"), mcptypes.EmptyAddBatchOutput(), nil
+	}
+
+	resp, err := h.client.AddBatch(ctx, input.Request())
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "store memories", err)), mcptypes.EmptyAddBatchOutput(), nil
+	}
+
+	out := mcptypes.AddBatchOutput{Results: resp.Results, Added: resp.Added}
+	result, fmtErr := mcptypes.BatchAddedResult(out)
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.EmptyAddBatchOutput(), nil
+	}
+	return result, out, nil
+}
+
 func (h *Handler) Search(ctx context.Context, _ *mcp.CallToolRequest, input mcptypes.SearchInput) (*mcp.CallToolResult, mcptypes.SearchOutput, error) {
 	if input.Query == "" {
 		return mcptypes.ErrorResult("query is required"), mcptypes.EmptySearchOutput(), nil
@@ -61,9 +105,9 @@ func (h *Handler) Search(ctx context.Context, _ *mcp.CallToolRequest, input mcpt
 
 	limit := mcptypes.DefaultSearchLimit(input.Limit)
 
-	memories, err := h.client.Search(ctx, input.Query, limit, input.Type, input.Area)
+	memories, err := h.client.Search(ctx, input.Query, limit, input.Type, input.Area, input.Mode, input.TagFilter(), input.Ranking())
 	if err != nil {
-		return mcptypes.ErrorResult(fmt.Sprintf("failed to search: %v", err)), mcptypes.EmptySearchOutput(), nil
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "search", err)), mcptypes.EmptySearchOutput(), nil
 	}
 
 	result, fmtErr := mcptypes.MemoriesResult(memories, "No matching memories found.")
@@ -76,6 +120,43 @@ func (h *Handler) Search(ctx context.Context, _ *mcp.CallToolRequest, input mcpt
 	return result, mcptypes.SearchOutput{Memories: memories}, nil
 }
 
+func (h *Handler) Get(ctx context.Context, _ *mcp.CallToolRequest, input mcptypes.GetInput) (*mcp.CallToolResult, mcptypes.GetOutput, error) {
+	if input.ID == 0 {
+		return mcptypes.ErrorResult("id is required"), mcptypes.GetOutput{}, nil
+	}
+
+	memory, err := h.client.Get(ctx, input.ID)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(input.ID, "get memory", err)), mcptypes.GetOutput{}, nil
+	}
+
+	result, fmtErr := mcptypes.MemoryResult(memory)
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.GetOutput{}, nil
+	}
+	return result, mcptypes.GetOutput{Memory: memory}, nil
+}
+
+func (h *Handler) Update(ctx context.Context, _ *mcp.CallToolRequest, input mcptypes.UpdateInput) (*mcp.CallToolResult, mcptypes.UpdateOutput, error) {
+	if input.ID == 0 {
+		return mcptypes.ErrorResult("id is required"), mcptypes.UpdateOutput{}, nil
+	}
+	if !input.HasChanges() {
+		return mcptypes.ErrorResult("at least one of type, area, content, rationale, expires_at, or review_by is required"), mcptypes.UpdateOutput{}, nil
+	}
+
+	memory, err := h.client.Update(ctx, input.ID, input.Request())
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(input.ID, "update memory", err)), mcptypes.UpdateOutput{}, nil
+	}
+
+	result, fmtErr := mcptypes.MemoryUpdatedResult(memory)
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.UpdateOutput{}, nil
+	}
+	return result, mcptypes.UpdateOutput{Memory: memory}, nil
+}
+
 func (h *Handler) Invalidate(ctx context.Context, _ *mcp.CallToolRequest, input mcptypes.InvalidateInput) (*mcp.CallToolResult, mcptypes.InvalidateOutput, error) {
 	if input.ID == 0 {
 		return mcptypes.ErrorResult("id is required"), mcptypes.InvalidateOutput{}, nil
@@ -86,28 +167,172 @@ func (h *Handler) Invalidate(ctx context.Context, _ *mcp.CallToolRequest, input
 		supersededBy = &input.SupersededBy
 	}
 
-	if err := h.client.Invalidate(ctx, input.ID, supersededBy); err != nil {
-		return mcptypes.ErrorResult(fmt.Sprintf("failed to invalidate: %v", err)), mcptypes.InvalidateOutput{}, nil
+	err := h.client.Invalidate(ctx, input.ID, apitypes.InvalidateRequest{SupersededBy: supersededBy, Reason: input.Reason})
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(input.ID, "invalidate", err)), mcptypes.InvalidateOutput{}, nil
 	}
 
 	msg := mcptypes.InvalidateMsg(input.ID, supersededBy)
 	return mcptypes.TextResult(msg), mcptypes.InvalidateOutput{Message: msg}, nil
 }
 
+func (h *Handler) Restore(ctx context.Context, _ *mcp.CallToolRequest, input mcptypes.RestoreInput) (*mcp.CallToolResult, mcptypes.RestoreOutput, error) {
+	if input.ID == 0 {
+		return mcptypes.ErrorResult("id is required"), mcptypes.RestoreOutput{}, nil
+	}
+
+	memory, err := h.client.Revalidate(ctx, input.ID)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(input.ID, "restore", err)), mcptypes.RestoreOutput{}, nil
+	}
+
+	result, fmtErr := mcptypes.RestoredResult(memory)
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.RestoreOutput{}, nil
+	}
+	return result, mcptypes.RestoreOutput{Memory: memory}, nil
+}
+
+func (h *Handler) Merge(ctx context.Context, _ *mcp.CallToolRequest, input mcptypes.MergeInput) (*mcp.CallToolResult, mcptypes.MergeOutput, error) {
+	if len(input.Sources) == 0 {
+		return mcptypes.ErrorResult("sources are required"), mcptypes.MergeOutput{}, nil
+	}
+	if input.Type == "" || input.Area == "" || input.Content == "" {
+		return mcptypes.ErrorResult("type, area, and content are required"), mcptypes.MergeOutput{}, nil
+	}
+
+	memory, err := h.client.Merge(ctx, input.Request())
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "merge memories", err)), mcptypes.MergeOutput{}, nil
+	}
+
+	result, fmtErr := mcptypes.MergedResult(memory, input.Sources)
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.MergeOutput{}, nil
+	}
+	return result, mcptypes.MergeOutput{Memory: memory}, nil
+}
+
 func (h *Handler) List(ctx context.Context, _ *mcp.CallToolRequest, input mcptypes.ListInput) (*mcp.CallToolResult, mcptypes.ListOutput, error) {
 	limit := mcptypes.DefaultListLimit(input.Limit)
 
-	memories, err := h.client.List(ctx, limit, input.Type, input.Area, input.IncludeInvalid)
+	memories, nextCursor, err := h.client.List(ctx, limit, input.Type, input.Area, input.IncludeInvalid, input.Cursor, input.TagFilter())
 	if err != nil {
-		return mcptypes.ErrorResult(fmt.Sprintf("failed to list: %v", err)), mcptypes.EmptyListOutput(), nil
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "list", err)), mcptypes.EmptyListOutput(), nil
 	}
 
-	result, fmtErr := mcptypes.MemoriesResult(memories, "No memories found.")
+	result, fmtErr := mcptypes.ListResult(memories, nextCursor)
 	if fmtErr != nil {
 		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.EmptyListOutput(), nil
 	}
 	if memories == nil {
 		memories = []types.Memory{}
 	}
-	return result, mcptypes.ListOutput{Memories: memories}, nil
+	return result, mcptypes.ListOutput{Memories: memories, NextCursor: nextCursor}, nil
+}
+
+func (h *Handler) Tags(ctx context.Context, _ *mcp.CallToolRequest, _ mcptypes.TagsInput) (*mcp.CallToolResult, mcptypes.TagsOutput, error) {
+	tags, err := h.client.Tags(ctx)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "list tags", err)), mcptypes.EmptyTagsOutput(), nil
+	}
+	if tags == nil {
+		tags = []types.TagCount{}
+	}
+	return mcptypes.TagsResult(tags), mcptypes.TagsOutput{Tags: tags}, nil
+}
+
+func (h *Handler) Repos(ctx context.Context, _ *mcp.CallToolRequest, _ mcptypes.ReposInput) (*mcp.CallToolResult, mcptypes.ReposOutput, error) {
+	repos, err := h.client.Repos(ctx)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "list repos", err)), mcptypes.EmptyReposOutput(), nil
+	}
+	if repos == nil {
+		repos = []types.CatalogEntry{}
+	}
+	return mcptypes.CatalogResult(repos, "No repos have memories."), mcptypes.ReposOutput{Repos: repos}, nil
+}
+
+func (h *Handler) Areas(ctx context.Context, _ *mcp.CallToolRequest, _ mcptypes.AreasInput) (*mcp.CallToolResult, mcptypes.AreasOutput, error) {
+	areas, err := h.client.Areas(ctx)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "list areas", err)), mcptypes.EmptyAreasOutput(), nil
+	}
+	if areas == nil {
+		areas = []types.CatalogEntry{}
+	}
+	return mcptypes.CatalogResult(areas, "No areas in use."), mcptypes.AreasOutput{Areas: areas}, nil
+}
+
+func (h *Handler) Authors(ctx context.Context, _ *mcp.CallToolRequest, _ mcptypes.AuthorsInput) (*mcp.CallToolResult, mcptypes.AuthorsOutput, error) {
+	authors, err := h.client.Authors(ctx)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "list authors", err)), mcptypes.EmptyAuthorsOutput(), nil
+	}
+	if authors == nil {
+		authors = []types.CatalogEntry{}
+	}
+	return mcptypes.CatalogResult(authors, "No authors yet."), mcptypes.AuthorsOutput{Authors: authors}, nil
+}
+
+func (h *Handler) Stats(ctx context.Context, _ *mcp.CallToolRequest, input mcptypes.StatsInput) (*mcp.CallToolResult, mcptypes.StatsOutput, error) {
+	stats, err := h.client.Stats(ctx, input.Period)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "count memories", err)), mcptypes.StatsOutput{}, nil
+	}
+
+	result, fmtErr := mcptypes.StatsResult(stats)
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.StatsOutput{}, nil
+	}
+	return result, mcptypes.StatsOutput{Stats: stats}, nil
+}
+
+func (h *Handler) Review(ctx context.Context, _ *mcp.CallToolRequest, input mcptypes.ReviewInput) (*mcp.CallToolResult, mcptypes.ReviewOutput, error) {
+	memories, err := h.client.DueForReview(ctx, input.Limit)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "list memories due for review", err)), mcptypes.EmptyReviewOutput(), nil
+	}
+
+	result, fmtErr := mcptypes.MemoriesResult(memories, "No memories are due for review.")
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.EmptyReviewOutput(), nil
+	}
+	if memories == nil {
+		memories = []types.Memory{}
+	}
+	return result, mcptypes.ReviewOutput{Memories: memories}, nil
+}
+
+func (h *Handler) Stale(ctx context.Context, _ *mcp.CallToolRequest, input mcptypes.StaleInput) (*mcp.CallToolResult, mcptypes.StaleOutput, error) {
+	memories, err := h.client.Stale(ctx, input.Limit, input.MinAgeDays)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "list stale memories", err)), mcptypes.EmptyStaleOutput(), nil
+	}
+
+	result, fmtErr := mcptypes.MemoriesResult(memories, "No stale candidates.")
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.EmptyStaleOutput(), nil
+	}
+	if memories == nil {
+		memories = []types.Memory{}
+	}
+	return result, mcptypes.StaleOutput{Memories: memories}, nil
+}
+
+func (h *Handler) Lineage(ctx context.Context, _ *mcp.CallToolRequest, input mcptypes.LineageInput) (*mcp.CallToolResult, mcptypes.LineageOutput, error) {
+	if input.ID == 0 {
+		return mcptypes.ErrorResult("id is required"), mcptypes.LineageOutput{}, nil
+	}
+
+	lineage, err := h.client.Lineage(ctx, input.ID)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(input.ID, "get memory lineage", err)), mcptypes.LineageOutput{}, nil
+	}
+
+	result, fmtErr := mcptypes.LineageResult(lineage)
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.LineageOutput{}, nil
+	}
+	return result, mcptypes.LineageOutput{Lineage: lineage}, nil
 }
@@ -4,11 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
+	"github.com/MereWhiplash/engram-cogitator/internal/apitypes"
 	"github.com/MereWhiplash/engram-cogitator/internal/mcptypes"
 	"github.com/MereWhiplash/engram-cogitator/internal/shim"
 	"github.com/MereWhiplash/engram-cogitator/internal/types"
@@ -16,32 +19,66 @@ import (
 
 // mockAPIClient implements shim.APIClient for testing
 type mockAPIClient struct {
-	memories   []types.Memory
-	nextID     int64
-	addErr     error
-	searchErr  error
-	listErr    error
-	invalidErr error
+	memories    []types.Memory
+	nextID      int64
+	addErr      error
+	searchErr   error
+	listErr     error
+	listCursor  string // captures the cursor from the last List call
+	nextCursor  string // returned by List
+	getErr      error
+	updateErr   error
+	invalidErr  error
+	reviewLimit int                    // captures the limit from the last DueForReview call
+	staleAge    int                    // captures minAgeDays from the last Stale call
+	lastUpdate  apitypes.UpdateRequest // captures the request from the last Update call
+	lastMerge   apitypes.MergeRequest  // captures the request from the last Merge call
 }
 
-func (m *mockAPIClient) Add(ctx context.Context, memType, area, content, rationale string) (*types.Memory, error) {
+func (m *mockAPIClient) Add(ctx context.Context, req apitypes.AddRequest) (*types.Memory, error) {
 	if m.addErr != nil {
 		return nil, m.addErr
 	}
 	m.nextID++
 	mem := types.Memory{
 		ID:        m.nextID,
-		Type:      types.MemoryType(memType),
-		Area:      area,
-		Content:   content,
-		Rationale: rationale,
+		Type:      types.MemoryType(req.Type),
+		Area:      req.Area,
+		Content:   req.Content,
+		Rationale: req.Rationale,
 		IsValid:   true,
+		Version:   1,
+		Tags:      req.Tags,
+	}
+	if req.ReviewBy != "" {
+		reviewBy, err := types.ParseDate(req.ReviewBy)
+		if err != nil {
+			return nil, err
+		}
+		mem.ReviewBy = &reviewBy
 	}
 	m.memories = append(m.memories, mem)
 	return &mem, nil
 }
 
-func (m *mockAPIClient) Search(ctx context.Context, query string, limit int, memType, area string) ([]types.Memory, error) {
+func (m *mockAPIClient) AddBatch(ctx context.Context, req apitypes.BatchAddRequest) (*apitypes.BatchAddResponse, error) {
+	if m.addErr != nil {
+		return nil, m.addErr
+	}
+	resp := &apitypes.BatchAddResponse{Results: make([]apitypes.BatchAddResult, len(req.Memories))}
+	for i, r := range req.Memories {
+		mem, err := m.Add(ctx, r)
+		if err != nil {
+			resp.Results[i] = apitypes.BatchAddResult{Error: err.Error(), Code: apitypes.ErrorCode(err)}
+			continue
+		}
+		resp.Results[i].Memory = mem
+		resp.Added++
+	}
+	return resp, nil
+}
+
+func (m *mockAPIClient) Search(ctx context.Context, query string, limit int, memType, area, mode string, tags types.TagFilter, rank types.RankingOverride) ([]types.Memory, error) {
 	if m.searchErr != nil {
 		return nil, m.searchErr
 	}
@@ -53,6 +90,9 @@ func (m *mockAPIClient) Search(ctx context.Context, query string, limit int, mem
 		if area != "" && mem.Area != area {
 			continue
 		}
+		if !tags.Matches(mem.Tags) {
+			continue
+		}
 		results = append(results, mem)
 		if len(results) >= limit {
 			break
@@ -61,9 +101,10 @@ func (m *mockAPIClient) Search(ctx context.Context, query string, limit int, mem
 	return results, nil
 }
 
-func (m *mockAPIClient) List(ctx context.Context, limit int, memType, area string, includeInvalid bool) ([]types.Memory, error) {
+func (m *mockAPIClient) List(ctx context.Context, limit int, memType, area string, includeInvalid bool, cursor string, tags types.TagFilter) ([]types.Memory, string, error) {
+	m.listCursor = cursor
 	if m.listErr != nil {
-		return nil, m.listErr
+		return nil, "", m.listErr
 	}
 	var results []types.Memory
 	for _, mem := range m.memories {
@@ -76,28 +117,208 @@ func (m *mockAPIClient) List(ctx context.Context, limit int, memType, area strin
 		if area != "" && mem.Area != area {
 			continue
 		}
+		if !tags.Matches(mem.Tags) {
+			continue
+		}
 		results = append(results, mem)
 		if limit > 0 && len(results) >= limit {
 			break
 		}
 	}
-	return results, nil
+	return results, m.nextCursor, nil
+}
+
+func (m *mockAPIClient) Get(ctx context.Context, id int64) (*types.Memory, error) {
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	for i := range m.memories {
+		if m.memories[i].ID == id {
+			mem := m.memories[i]
+			return &mem, nil
+		}
+	}
+	return nil, types.ErrNotFound
+}
+
+func (m *mockAPIClient) Update(ctx context.Context, id int64, req apitypes.UpdateRequest) (*types.Memory, error) {
+	if m.updateErr != nil {
+		return nil, m.updateErr
+	}
+	m.lastUpdate = req
+	for i := range m.memories {
+		if m.memories[i].ID == id {
+			if req.ExpectedVersion != 0 && req.ExpectedVersion != m.memories[i].Version {
+				return nil, types.ErrVersionConflict
+			}
+			if req.Content != nil {
+				m.memories[i].Content = *req.Content
+			}
+			if req.Rationale != nil {
+				m.memories[i].Rationale = *req.Rationale
+			}
+			m.memories[i].Version++
+			mem := m.memories[i]
+			return &mem, nil
+		}
+	}
+	return nil, types.ErrNotFound
 }
 
-func (m *mockAPIClient) Invalidate(ctx context.Context, id int64, supersededBy *int64) error {
+func (m *mockAPIClient) Invalidate(ctx context.Context, id int64, req apitypes.InvalidateRequest) error {
 	if m.invalidErr != nil {
 		return m.invalidErr
 	}
 	for i := range m.memories {
 		if m.memories[i].ID == id {
 			m.memories[i].IsValid = false
-			m.memories[i].SupersededBy = supersededBy
+			m.memories[i].SupersededBy = req.SupersededBy
+			m.memories[i].InvalidationReason = req.Reason
 			return nil
 		}
 	}
 	return types.ErrNotFound
 }
 
+func (m *mockAPIClient) Revalidate(ctx context.Context, id int64) (*types.Memory, error) {
+	for i := range m.memories {
+		if m.memories[i].ID == id {
+			m.memories[i].IsValid = true
+			m.memories[i].SupersededBy = nil
+			m.memories[i].InvalidationReason = ""
+			mem := m.memories[i]
+			return &mem, nil
+		}
+	}
+	return nil, types.ErrNotFound
+}
+
+func (m *mockAPIClient) Merge(ctx context.Context, req apitypes.MergeRequest) (*types.Memory, error) {
+	m.lastMerge = req
+	for _, id := range req.Sources {
+		if _, err := m.Get(ctx, id); err != nil {
+			return nil, fmt.Errorf("%w: memory %d does not exist", types.ErrInvalidSupersede, id)
+		}
+	}
+	merged, err := m.Add(ctx, req.AddRequest)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range req.Sources {
+		m.Invalidate(ctx, id, apitypes.InvalidateRequest{SupersededBy: &merged.ID, Reason: req.Reason})
+	}
+	return merged, nil
+}
+
+// Lineage follows superseded_by forward only, which is all the shim tests need
+func (m *mockAPIClient) Lineage(ctx context.Context, id int64) (*types.Lineage, error) {
+	mem, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	lineage := &types.Lineage{Memory: *mem, Ancestors: []types.Memory{}, Descendants: []types.Memory{}}
+	if mem.IsValid {
+		lineage.Current = mem
+	}
+	for next := mem.SupersededBy; next != nil; {
+		succ, err := m.Get(ctx, *next)
+		if err != nil {
+			break
+		}
+		lineage.Descendants = append(lineage.Descendants, *succ)
+		if succ.IsValid {
+			lineage.Current = succ
+		}
+		next = succ.SupersededBy
+	}
+	return lineage, nil
+}
+
+func (m *mockAPIClient) Stale(ctx context.Context, limit, minAgeDays int) ([]types.Memory, error) {
+	m.staleAge = minAgeDays
+	var stale []types.Memory
+	for _, mem := range m.memories {
+		if mem.IsValid && mem.RetrievalCount == 0 {
+			stale = append(stale, mem)
+		}
+	}
+	return stale, nil
+}
+
+func (m *mockAPIClient) DueForReview(ctx context.Context, limit int) ([]types.Memory, error) {
+	m.reviewLimit = limit
+	var due []types.Memory
+	for _, mem := range m.memories {
+		if mem.IsValid && mem.ReviewBy != nil && mem.ReviewBy.Before(time.Now()) {
+			due = append(due, mem)
+		}
+	}
+	return due, nil
+}
+
+func (m *mockAPIClient) Stats(ctx context.Context, period string) (*types.Stats, error) {
+	p := types.StatsPeriod(period)
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	stats := &types.Stats{Period: p.OrDefault()}
+	for _, mem := range m.memories {
+		if mem.IsValid {
+			stats.Valid++
+		} else {
+			stats.Invalid++
+		}
+	}
+	stats.Total = stats.Valid + stats.Invalid
+	return stats, nil
+}
+
+func (m *mockAPIClient) Repos(ctx context.Context) ([]types.CatalogEntry, error) {
+	return nil, nil
+}
+
+func (m *mockAPIClient) Areas(ctx context.Context) ([]types.CatalogEntry, error) {
+	index := make(map[string]int)
+	var areas []types.CatalogEntry
+	for _, mem := range m.memories {
+		if !mem.IsValid {
+			continue
+		}
+		i, ok := index[mem.Area]
+		if !ok {
+			i = len(areas)
+			index[mem.Area] = i
+			areas = append(areas, types.CatalogEntry{Value: mem.Area})
+		}
+		areas[i].Count++
+		if mem.CreatedAt.After(areas[i].LastActivity) {
+			areas[i].LastActivity = mem.CreatedAt
+		}
+	}
+	return areas, nil
+}
+
+func (m *mockAPIClient) Authors(ctx context.Context) ([]types.CatalogEntry, error) {
+	return nil, nil
+}
+
+func (m *mockAPIClient) Tags(ctx context.Context) ([]types.TagCount, error) {
+	counts := make(map[string]int)
+	var tags []types.TagCount
+	for _, mem := range m.memories {
+		for _, tag := range mem.Tags {
+			if counts[tag] == 0 {
+				tags = append(tags, types.TagCount{Tag: tag})
+			}
+			counts[tag]++
+		}
+	}
+	for i := range tags {
+		tags[i].Count = counts[tags[i].Tag]
+	}
+	return tags, nil
+}
+
 func TestShimHandler_Add_Success(t *testing.T) {
 	client := &mockAPIClient{}
 	handler := shim.NewHandler(client)
@@ -170,8 +391,8 @@ func TestShimHandler_Add_ClientError(t *testing.T) {
 func TestShimHandler_Search_Success(t *testing.T) {
 	client := &mockAPIClient{}
 	// Pre-populate with memories
-	client.Add(context.Background(), "decision", "auth", "Use JWT", "")
-	client.Add(context.Background(), "learning", "auth", "JWT gotcha", "")
+	client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use JWT"})
+	client.Add(context.Background(), apitypes.AddRequest{Type: "learning", Area: "auth", Content: "JWT gotcha"})
 
 	handler := shim.NewHandler(client)
 
@@ -210,7 +431,7 @@ func TestShimHandler_Search_DefaultLimit(t *testing.T) {
 	client := &mockAPIClient{}
 	// Add more than default limit
 	for i := 0; i < 10; i++ {
-		client.Add(context.Background(), "decision", "test", "Memory", "")
+		client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "test", Content: "Memory"})
 	}
 
 	handler := shim.NewHandler(client)
@@ -260,6 +481,32 @@ func TestShimHandler_Search_ClientError_OutputMarshalsMemoriesAsArray(t *testing
 	}
 }
 
+func TestShimHandler_ErrorKinds(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"embedder", fmt.Errorf("API error: embedder unavailable: %w", types.ErrEmbedderUnavailable), "the embedding model is unavailable, try again later"},
+		{"validation", types.Invalidf("invalid tag %q", "a b"), "fix the request and retry"},
+		{"other", errors.New("api down"), "failed to search: api down"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := shim.NewHandler(&mockAPIClient{searchErr: tt.err})
+
+			result, _, _ := handler.Search(context.Background(), nil, mcptypes.SearchInput{Query: "anything"})
+			if !result.IsError {
+				t.Fatal("expected error result when client fails")
+			}
+			if text := result.Content[0].(*mcp.TextContent).Text; !strings.Contains(text, tt.want) {
+				t.Errorf("expected %q in %q", tt.want, text)
+			}
+		})
+	}
+}
+
 func TestShimHandler_List_ClientError_OutputMarshalsMemoriesAsArray(t *testing.T) {
 	client := &mockAPIClient{listErr: errors.New("api down")}
 	handler := shim.NewHandler(client)
@@ -279,8 +526,8 @@ func TestShimHandler_List_ClientError_OutputMarshalsMemoriesAsArray(t *testing.T
 
 func TestShimHandler_List_Success(t *testing.T) {
 	client := &mockAPIClient{}
-	client.Add(context.Background(), "decision", "auth", "Decision 1", "")
-	client.Add(context.Background(), "learning", "db", "Learning 1", "")
+	client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Decision 1"})
+	client.Add(context.Background(), apitypes.AddRequest{Type: "learning", Area: "db", Content: "Learning 1"})
 
 	handler := shim.NewHandler(client)
 
@@ -302,7 +549,7 @@ func TestShimHandler_List_Success(t *testing.T) {
 func TestShimHandler_List_DefaultLimit(t *testing.T) {
 	client := &mockAPIClient{}
 	for i := 0; i < 15; i++ {
-		client.Add(context.Background(), "decision", "test", "Memory", "")
+		client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "test", Content: "Memory"})
 	}
 
 	handler := shim.NewHandler(client)
@@ -316,9 +563,305 @@ func TestShimHandler_List_DefaultLimit(t *testing.T) {
 	}
 }
 
+func TestShimHandler_List_Cursor(t *testing.T) {
+	client := &mockAPIClient{nextCursor: "page-3"}
+	client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Decision 1"})
+
+	handler := shim.NewHandler(client)
+
+	result, output, _ := handler.List(context.Background(), nil, mcptypes.ListInput{Cursor: "page-2"})
+	if client.listCursor != "page-2" {
+		t.Errorf("expected cursor 'page-2' passed to the client, got %q", client.listCursor)
+	}
+	if output.NextCursor != "page-3" {
+		t.Errorf("expected next cursor 'page-3', got %q", output.NextCursor)
+	}
+	if len(result.Content) != 2 {
+		t.Errorf("expected a hint about the next page, got %d content blocks", len(result.Content))
+	}
+}
+
+func TestShimHandler_List_Tags(t *testing.T) {
+	client := &mockAPIClient{}
+	client.Add(context.Background(), apitypes.AddRequest{Type: "learning", Area: "db", Content: "Pool starves", Tags: []string{"infra", "postgres"}})
+	client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "deploy", Content: "Blue green", Tags: []string{"infra"}})
+
+	handler := shim.NewHandler(client)
+
+	_, output, _ := handler.List(context.Background(), nil, mcptypes.ListInput{TagsAll: []string{"postgres"}})
+	if len(output.Memories) != 1 || output.Memories[0].Content != "Pool starves" {
+		t.Errorf("expected only the postgres memory, got %+v", output.Memories)
+	}
+}
+
+func TestShimHandler_Tags_Success(t *testing.T) {
+	client := &mockAPIClient{}
+	client.Add(context.Background(), apitypes.AddRequest{Type: "learning", Area: "db", Content: "Pool starves", Tags: []string{"infra", "postgres"}})
+	client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "deploy", Content: "Blue green", Tags: []string{"infra"}})
+
+	handler := shim.NewHandler(client)
+
+	result, output, err := handler.Tags(context.Background(), nil, mcptypes.TagsInput{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("expected success, got error: %v", result.Content)
+	}
+	if len(output.Tags) != 2 || output.Tags[0].Tag != "infra" || output.Tags[0].Count != 2 {
+		t.Errorf("expected infra used twice first, got %+v", output.Tags)
+	}
+}
+
+func TestShimHandler_Areas(t *testing.T) {
+	client := &mockAPIClient{}
+	client.Add(context.Background(), apitypes.AddRequest{Type: "learning", Area: "db", Content: "Pool starves"})
+	client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "db", Content: "Use pgx"})
+
+	handler := shim.NewHandler(client)
+
+	result, output, err := handler.Areas(context.Background(), nil, mcptypes.AreasInput{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("expected success, got error: %v", result.Content)
+	}
+	if len(output.Areas) != 1 || output.Areas[0].Value != "db" || output.Areas[0].Count != 2 {
+		t.Errorf("expected db used twice, got %+v", output.Areas)
+	}
+	text := result.Content[0].(*mcp.TextContent).Text
+	if !strings.HasPrefix(text, "db (2, last active ") {
+		t.Errorf("expected the area with its count, got %q", text)
+	}
+
+	// No repos yet marshals as [] with a note
+	result, repos, _ := handler.Repos(context.Background(), nil, mcptypes.ReposInput{})
+	if repos.Repos == nil || len(repos.Repos) != 0 {
+		t.Errorf("expected empty repos, got %+v", repos.Repos)
+	}
+	if text := result.Content[0].(*mcp.TextContent).Text; text != "No repos have memories." {
+		t.Errorf("unexpected text %q", text)
+	}
+}
+
+func TestShimHandler_Stats(t *testing.T) {
+	client := &mockAPIClient{}
+	client.Add(context.Background(), apitypes.AddRequest{Type: "learning", Area: "db", Content: "Pool starves"})
+	client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "deploy", Content: "Blue green"})
+
+	handler := shim.NewHandler(client)
+
+	result, output, err := handler.Stats(context.Background(), nil, mcptypes.StatsInput{Period: "week"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("expected success, got error: %v", result.Content)
+	}
+	if output.Stats == nil || output.Stats.Total != 2 || output.Stats.Period != types.PeriodWeek {
+		t.Errorf("unexpected stats: %+v", output.Stats)
+	}
+	text := result.Content[0].(*mcp.TextContent).Text
+	if !strings.HasPrefix(text, "2 memories: 2 valid, 0 invalid.") {
+		t.Errorf("expected the totals first, got %q", text)
+	}
+
+	result, _, _ = handler.Stats(context.Background(), nil, mcptypes.StatsInput{Period: "year"})
+	if !result.IsError {
+		t.Error("expected error result for an unknown period")
+	}
+}
+
+func TestShimHandler_Review_Success(t *testing.T) {
+	client := &mockAPIClient{}
+	client.Add(context.Background(), apitypes.AddRequest{Type: "learning", Area: "db", Content: "Pool maxes at 20", ReviewBy: "2020-01-01"})
+	client.Add(context.Background(), apitypes.AddRequest{Type: "learning", Area: "db", Content: "Index foreign keys", ReviewBy: "2099-01-01"})
+
+	handler := shim.NewHandler(client)
+
+	result, output, err := handler.Review(context.Background(), nil, mcptypes.ReviewInput{Limit: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("expected success, got error: %v", result.Content)
+	}
+	if client.reviewLimit != 5 {
+		t.Errorf("expected limit 5 passed to the client, got %d", client.reviewLimit)
+	}
+	if len(output.Memories) != 1 || output.Memories[0].Content != "Pool maxes at 20" {
+		t.Errorf("expected only the overdue memory, got %+v", output.Memories)
+	}
+}
+
+func TestShimHandler_Stale(t *testing.T) {
+	client := &mockAPIClient{}
+	unused, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "learning", Area: "db", Content: "Pool maxes at 20"})
+	used, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "learning", Area: "db", Content: "Index foreign keys"})
+	client.memories[used.ID-1].RetrievalCount = 4
+
+	handler := shim.NewHandler(client)
+
+	result, output, err := handler.Stale(context.Background(), nil, mcptypes.StaleInput{MinAgeDays: 90})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("expected success, got error: %v", result.Content)
+	}
+	if client.staleAge != 90 {
+		t.Errorf("expected min age 90 passed to the client, got %d", client.staleAge)
+	}
+	if len(output.Memories) != 1 || output.Memories[0].ID != unused.ID {
+		t.Errorf("expected only the unused memory, got %+v", output.Memories)
+	}
+}
+
+func TestShimHandler_Update_ClearsDate(t *testing.T) {
+	client := &mockAPIClient{}
+	mem, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "learning", Area: "db", Content: "Pool maxes at 20"})
+
+	handler := shim.NewHandler(client)
+
+	result, _, _ := handler.Update(context.Background(), nil, mcptypes.UpdateInput{ID: mem.ID, ExpiresAt: "none"})
+	if result.IsError {
+		t.Fatalf("expected success, got error: %v", result.Content)
+	}
+	if client.lastUpdate.ExpiresAt == nil || *client.lastUpdate.ExpiresAt != "" {
+		t.Errorf("expected an empty expires_at sent to clear the date, got %v", client.lastUpdate.ExpiresAt)
+	}
+}
+
+func TestShimHandler_Get_Success(t *testing.T) {
+	client := &mockAPIClient{}
+	mem, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use JWT"})
+
+	handler := shim.NewHandler(client)
+
+	result, output, err := handler.Get(context.Background(), nil, mcptypes.GetInput{ID: mem.ID})
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("Get returned error result: %v", result.Content)
+	}
+	if output.Memory == nil || output.Memory.ID != mem.ID {
+		t.Errorf("expected memory %d, got %+v", mem.ID, output.Memory)
+	}
+}
+
+func TestShimHandler_Get_NotFound(t *testing.T) {
+	client := &mockAPIClient{}
+	handler := shim.NewHandler(client)
+
+	result, output, _ := handler.Get(context.Background(), nil, mcptypes.GetInput{ID: 42})
+	if !result.IsError {
+		t.Error("expected error result for missing memory")
+	}
+	if output.Memory != nil {
+		t.Errorf("expected nil memory, got %+v", output.Memory)
+	}
+}
+
+func TestShimHandler_Lineage_Success(t *testing.T) {
+	client := &mockAPIClient{}
+	old, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use sessions"})
+	replacement, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use JWT"})
+	client.Invalidate(context.Background(), old.ID, apitypes.InvalidateRequest{SupersededBy: &replacement.ID})
+
+	handler := shim.NewHandler(client)
+
+	result, output, err := handler.Lineage(context.Background(), nil, mcptypes.LineageInput{ID: old.ID})
+	if err != nil {
+		t.Fatalf("Lineage returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("Lineage returned error result: %v", result.Content)
+	}
+	if output.Lineage == nil || output.Lineage.Current == nil || output.Lineage.Current.ID != replacement.ID {
+		t.Errorf("expected memory %d current, got %+v", replacement.ID, output.Lineage)
+	}
+	text := result.Content[0].(*mcp.TextContent).Text
+	if !strings.Contains(text, "the current version is memory 2") {
+		t.Errorf("expected the current version named, got %q", text)
+	}
+}
+
+func TestShimHandler_Lineage_NotFound(t *testing.T) {
+	handler := shim.NewHandler(&mockAPIClient{})
+
+	result, output, _ := handler.Lineage(context.Background(), nil, mcptypes.LineageInput{ID: 42})
+	if !result.IsError {
+		t.Error("expected error result for missing memory")
+	}
+	if output.Lineage != nil {
+		t.Errorf("expected nil lineage, got %+v", output.Lineage)
+	}
+}
+
+func TestShimHandler_Get_MissingID(t *testing.T) {
+	client := &mockAPIClient{}
+	handler := shim.NewHandler(client)
+
+	result, _, _ := handler.Get(context.Background(), nil, mcptypes.GetInput{})
+	if !result.IsError {
+		t.Error("expected error for missing ID")
+	}
+}
+
+func TestShimHandler_Update_Success(t *testing.T) {
+	client := &mockAPIClient{}
+	mem, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use JWT"})
+
+	handler := shim.NewHandler(client)
+
+	input := mcptypes.UpdateInput{ID: mem.ID, Rationale: "Stateless auth", ExpectedVersion: 1}
+	result, output, err := handler.Update(context.Background(), nil, input)
+	if err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("Update returned error result: %v", result.Content)
+	}
+	if output.Memory.Version != 2 {
+		t.Errorf("expected version 2, got %d", output.Memory.Version)
+	}
+	if output.Memory.Content != "Use JWT" || output.Memory.Rationale != "Stateless auth" {
+		t.Errorf("expected only rationale to change, got %+v", output.Memory)
+	}
+}
+
+func TestShimHandler_Update_NoChanges(t *testing.T) {
+	client := &mockAPIClient{}
+	handler := shim.NewHandler(client)
+
+	result, _, _ := handler.Update(context.Background(), nil, mcptypes.UpdateInput{ID: 1})
+	if !result.IsError {
+		t.Error("expected error when no fields are set")
+	}
+}
+
+func TestShimHandler_Update_VersionConflict(t *testing.T) {
+	client := &mockAPIClient{}
+	mem, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use JWT"})
+
+	handler := shim.NewHandler(client)
+
+	input := mcptypes.UpdateInput{ID: mem.ID, Content: "Use PASETO", ExpectedVersion: 5}
+	result, _, _ := handler.Update(context.Background(), nil, input)
+	if !result.IsError {
+		t.Fatal("expected error result for stale version")
+	}
+	text := result.Content[0].(*mcp.TextContent).Text
+	if !strings.Contains(text, "ec_get") {
+		t.Errorf("conflict message should tell the agent to re-read, got %q", text)
+	}
+}
+
 func TestShimHandler_Invalidate_Success(t *testing.T) {
 	client := &mockAPIClient{}
-	mem, _ := client.Add(context.Background(), "decision", "auth", "Old", "")
+	mem, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Old"})
 
 	handler := shim.NewHandler(client)
 
@@ -337,6 +880,21 @@ func TestShimHandler_Invalidate_Success(t *testing.T) {
 	}
 }
 
+func TestShimHandler_Invalidate_Reason(t *testing.T) {
+	client := &mockAPIClient{}
+	mem, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use sessions"})
+
+	handler := shim.NewHandler(client)
+
+	result, _, _ := handler.Invalidate(context.Background(), nil, mcptypes.InvalidateInput{ID: mem.ID, Reason: "moved to JWT"})
+	if result.IsError {
+		t.Fatalf("Invalidate returned error result: %v", result.Content)
+	}
+	if got, _ := client.Get(context.Background(), mem.ID); got == nil || got.InvalidationReason != "moved to JWT" {
+		t.Errorf("expected the reason passed to the API, got %+v", got)
+	}
+}
+
 func TestShimHandler_Invalidate_MissingID(t *testing.T) {
 	client := &mockAPIClient{}
 	handler := shim.NewHandler(client)
@@ -350,10 +908,129 @@ func TestShimHandler_Invalidate_MissingID(t *testing.T) {
 	}
 }
 
+func TestShimHandler_Restore_Success(t *testing.T) {
+	client := &mockAPIClient{}
+	old, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use sessions"})
+	replacement, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use JWT"})
+	client.Invalidate(context.Background(), old.ID, apitypes.InvalidateRequest{SupersededBy: &replacement.ID})
+
+	handler := shim.NewHandler(client)
+
+	result, output, err := handler.Restore(context.Background(), nil, mcptypes.RestoreInput{ID: old.ID})
+	if err != nil {
+		t.Fatalf("Restore returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("Restore returned error result: %v", result.Content)
+	}
+	if output.Memory == nil || !output.Memory.IsValid || output.Memory.SupersededBy != nil {
+		t.Errorf("expected a valid memory with no successor, got %+v", output.Memory)
+	}
+	text := result.Content[0].(*mcp.TextContent).Text
+	if !strings.HasPrefix(text, "Memory 1 has been restored.") {
+		t.Errorf("expected a restore confirmation, got %q", text)
+	}
+}
+
+func TestShimHandler_Restore_NotFound(t *testing.T) {
+	handler := shim.NewHandler(&mockAPIClient{})
+
+	result, output, _ := handler.Restore(context.Background(), nil, mcptypes.RestoreInput{ID: 42})
+	if !result.IsError {
+		t.Error("expected error result for missing memory")
+	}
+	if output.Memory != nil {
+		t.Errorf("expected nil memory, got %+v", output.Memory)
+	}
+}
+
+func TestShimHandler_Merge(t *testing.T) {
+	client := &mockAPIClient{}
+	a, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Use sessions"})
+	b, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Keep sessions in cookies"})
+
+	handler := shim.NewHandler(client)
+
+	result, output, err := handler.Merge(context.Background(), nil, mcptypes.MergeInput{
+		Sources: []int64{a.ID, b.ID},
+		Type:    "decision",
+		Area:    "auth",
+		Content: "Use sessions kept in cookies",
+		Reason:  "duplicates",
+	})
+	if err != nil {
+		t.Fatalf("Merge returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("Merge returned error result: %v", result.Content)
+	}
+	if output.Memory == nil || output.Memory.ID != 3 {
+		t.Fatalf("expected merged memory 3, got %+v", output.Memory)
+	}
+	if client.lastMerge.Reason != "duplicates" || client.lastMerge.Content != "Use sessions kept in cookies" {
+		t.Errorf("unexpected request: %+v", client.lastMerge)
+	}
+	text := result.Content[0].(*mcp.TextContent).Text
+	if !strings.HasPrefix(text, "Merged memories 1, 2 into memory 3;") {
+		t.Errorf("expected a merge confirmation, got %q", text)
+	}
+
+	result, _, _ = handler.Merge(context.Background(), nil, mcptypes.MergeInput{
+		Sources: []int64{a.ID, 42},
+		Type:    "decision",
+		Area:    "auth",
+		Content: "Use sessions",
+	})
+	if !result.IsError {
+		t.Error("expected error result for a missing source")
+	}
+	text = result.Content[0].(*mcp.TextContent).Text
+	if !strings.Contains(text, "fix the request and retry") {
+		t.Errorf("expected the error to ask for a fixed request, got %q", text)
+	}
+
+	result, _, _ = handler.Merge(context.Background(), nil, mcptypes.MergeInput{Type: "decision", Area: "auth", Content: "x"})
+	if !result.IsError {
+		t.Error("expected error result without sources")
+	}
+}
+
+func TestShimHandler_AddBatch(t *testing.T) {
+	client := &mockAPIClient{}
+	handler := shim.NewHandler(client)
+
+	result, output, err := handler.AddBatch(context.Background(), nil, mcptypes.AddBatchInput{Memories: []mcptypes.AddInput{
+		{Type: "decision", Area: "auth", Content: "Use JWT"},
+		{Type: "learning", Area: "db", Content: "Index foreign keys", ReviewBy: "not a date"},
+		{Type: "pattern", Area: "api", Content: "Wrap errors"},
+	}})
+	if err != nil {
+		t.Fatalf("AddBatch returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("AddBatch returned error result: %v", result.Content)
+	}
+	if output.Added != 2 || len(output.Results) != 3 || output.Results[1].Memory != nil {
+		t.Fatalf("unexpected output: %+v", output)
+	}
+	if len(client.memories) != 2 {
+		t.Errorf("expected 2 memories added, got %d", len(client.memories))
+	}
+	text := result.Content[0].(*mcp.TextContent).Text
+	if !strings.HasPrefix(text, "Added 2 of 3 memories.\nMemory 2 was not added") {
+		t.Errorf("expected a summary naming the rejected memory, got %q", text)
+	}
+
+	result, output, _ = handler.AddBatch(context.Background(), nil, mcptypes.AddBatchInput{})
+	if !result.IsError || output.Results == nil {
+		t.Errorf("expected error result with empty results for no memories, got %+v", output)
+	}
+}
+
 func TestShimHandler_Invalidate_WithSupersededBy(t *testing.T) {
 	client := &mockAPIClient{}
-	oldMem, _ := client.Add(context.Background(), "decision", "auth", "Old", "")
-	newMem, _ := client.Add(context.Background(), "decision", "auth", "New", "")
+	oldMem, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "Old"})
+	newMem, _ := client.Add(context.Background(), apitypes.AddRequest{Type: "decision", Area: "auth", Content: "New"})
 
 	handler := shim.NewHandler(client)
 
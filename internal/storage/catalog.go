@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// catalogField returns the stats field that groups memories as field does
+func catalogField(field types.CatalogField) (statsField, error) {
+	switch field {
+	case types.CatalogRepo:
+		return statsRepo, nil
+	case types.CatalogArea:
+		return statsArea, nil
+	case types.CatalogAuthor:
+		return statsAuthor, nil
+	}
+	return 0, fmt.Errorf("unknown catalog field %q", field)
+}
+
+// finishCatalog drops entries without a value, clears the name outside
+// author entries, and orders the rest most used first, ties by value
+func finishCatalog(field types.CatalogField, entries []types.CatalogEntry) []types.CatalogEntry {
+	out := make([]types.CatalogEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.Value == "" {
+			continue
+		}
+		if field != types.CatalogAuthor {
+			e.Name = ""
+		}
+		e.LastActivity = e.LastActivity.UTC()
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Value < out[j].Value
+	})
+	return out
+}
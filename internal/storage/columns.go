@@ -0,0 +1,22 @@
+package storage
+
+import "github.com/MereWhiplash/engram-cogitator/internal/types"
+
+// memoryColumns is the memories column list read by the SQL backends' row
+// scanners, in scan order. Queries must alias the memories table as m.
+const memoryColumns = `m.id, m.type, m.area, m.content, m.rationale, m.is_valid,
+		       m.superseded_by, m.created_at, m.author_name, m.author_email, m.repo,
+		       m.version, m.updated_at, m.embedding_model, m.expires_at, m.review_by,
+		       m.invalidated_at, m.invalidated_by_name, m.invalidated_by_email, m.invalidation_reason,
+		       m.restored_at, m.restored_by_name, m.restored_by_email, m.originated_at,
+		       m.retrieval_count, m.last_retrieved_at`
+
+// containsMemory reports whether memories includes the memory with id
+func containsMemory(memories []types.Memory, id int64) bool {
+	for _, mem := range memories {
+		if mem.ID == id {
+			return true
+		}
+	}
+	return false
+}
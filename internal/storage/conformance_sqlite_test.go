@@ -0,0 +1,22 @@
+//go:build cgo
+
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+	"github.com/MereWhiplash/engram-cogitator/internal/storage/storagetest"
+)
+
+func TestConformance_SQLite(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		store, err := storage.NewSQLite(filepath.Join(t.TempDir(), "memory.db"))
+		if err != nil {
+			t.Fatalf("failed to create storage: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
@@ -0,0 +1,75 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+	"github.com/MereWhiplash/engram-cogitator/internal/storage/storagetest"
+)
+
+func TestConformance_InMemory(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		store, err := storage.NewInMemory("")
+		if err != nil {
+			t.Fatalf("failed to create storage: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+func TestConformance_Postgres(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set, skipping Postgres tests")
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		cleanupPostgres(t, dsn)
+		store, err := storage.NewPostgres(context.Background(), dsn)
+		if err != nil {
+			t.Fatalf("failed to create storage: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+func TestConformance_MongoDB(t *testing.T) {
+	uri := os.Getenv("TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGODB_URI not set, skipping MongoDB tests")
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		cleanupMongoDB(t, uri, "engram_test")
+		store, err := storage.NewMongoDB(context.Background(), uri, "engram_test")
+		if err != nil {
+			t.Fatalf("failed to create storage: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+// TestConformance_MongoDBStandalone runs the suite with transactions off, as
+// on a standalone mongod, against whatever TEST_MONGODB_URI points at
+func TestConformance_MongoDBStandalone(t *testing.T) {
+	uri := os.Getenv("TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGODB_URI not set, skipping MongoDB tests")
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		cleanupMongoDB(t, uri, "engram_test")
+		store, err := storage.NewMongoDB(context.Background(), uri, "engram_test")
+		if err != nil {
+			t.Fatalf("failed to create storage: %v", err)
+		}
+		storage.DisableMongoTransactions(store)
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// ErrCopyMismatch is returned by Copy when the destination doesn't match the
+// source after copying
+var ErrCopyMismatch = errors.New("copy verification failed")
+
+// metaDatabaseID names the metadata key holding a database's random identity
+const metaDatabaseID = "database_id"
+
+// defaultCopyBatch is how many memories Copy reads from the source at a time
+const defaultCopyBatch = 100
+
+// copyTimeResolution is the coarsest timestamp a backend keeps (SQLite
+// stores whole seconds), so verification compares times truncated to it
+const copyTimeResolution = time.Second
+
+// ExportedMemory is a memory together with its stored vector
+type ExportedMemory struct {
+	types.Memory
+	Embedding []float32
+}
+
+// Exporter is implemented by backends that can be copied from
+type Exporter interface {
+	// ExportMemories returns up to limit memories with IDs above afterID,
+	// valid or not, in ID order, with their embeddings
+	ExportMemories(ctx context.Context, afterID int64, limit int) ([]ExportedMemory, error)
+}
+
+// Importer is implemented by backends that can be copied into. Imports are
+// keyed by source database and source ID, so repeating one is a no-op.
+type Importer interface {
+	// ImportMemory inserts mem under a new ID, keeping its validity,
+	// timestamps (including expiry and review dates), version, author, repo,
+	// tags and embedding, and returns the new ID. SupersededBy is not copied;
+	// link it with SetSupersededBy once the target exists. A memory already
+	// imported from source returns its ID.
+	ImportMemory(ctx context.Context, source string, mem ExportedMemory) (int64, error)
+	// ImportedIDs maps source IDs to local IDs for everything imported from source
+	ImportedIDs(ctx context.Context, source string) (map[int64]int64, error)
+	// SetSupersededBy points an imported memory at its replacement
+	SetSupersededBy(ctx context.Context, id, supersededBy int64) error
+}
+
+// CopyOptions configures Copy
+type CopyOptions struct {
+	BatchSize int
+	// Progress is called after each batch with the number of source memories read so far
+	Progress func(read int)
+}
+
+// CopyResult summarizes a Copy run
+type CopyResult struct {
+	// Total is the number of memories in the source
+	Total int
+	// Copied counts memories imported by this run; Resumed those imported by an earlier one
+	Copied  int
+	Resumed int
+	// Linked counts superseded_by links set; Unlinked those whose target
+	// doesn't exist in the source, which are dropped
+	Linked   int
+	Unlinked int
+}
+
+// DatabaseID returns the random identity recorded in a database's metadata,
+// creating it on first use. Copy uses it to key imports by source.
+func DatabaseID(ctx context.Context, store Storage) (string, error) {
+	ms, ok := store.(metadataStore)
+	if !ok {
+		return "", fmt.Errorf("storage driver does not record metadata")
+	}
+
+	id, err := ms.getMetadata(ctx, metaDatabaseID)
+	if err != nil || id != "" {
+		return id, err
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id = hex.EncodeToString(b)
+	if err := ms.setMetadata(ctx, metaDatabaseID, id); err != nil {
+		return "", fmt.Errorf("failed to record database id: %w", err)
+	}
+	return id, nil
+}
+
+// Copy copies every memory in src to dst, remapping IDs and superseded_by
+// links, then checks each copy against its source. It is resumable: memories
+// already imported from src by an earlier run are skipped. Edits made to the
+// source after a memory was copied are not carried over.
+func Copy(ctx context.Context, src, dst Storage, opts CopyOptions) (*CopyResult, error) {
+	exp, ok := src.(Exporter)
+	if !ok {
+		return nil, fmt.Errorf("source storage driver does not support export")
+	}
+	imp, ok := dst.(Importer)
+	if !ok {
+		return nil, fmt.Errorf("destination storage driver does not support import")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultCopyBatch
+	}
+
+	source, err := DatabaseID(ctx, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to identify source: %w", err)
+	}
+	dest, err := DatabaseID(ctx, dst)
+	if err != nil {
+		return nil, fmt.Errorf("failed to identify destination: %w", err)
+	}
+	if source == dest {
+		return nil, fmt.Errorf("source and destination are the same database")
+	}
+
+	imported, err := imp.ImportedIDs(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read earlier imports: %w", err)
+	}
+
+	// Embeddings are dropped once written; the rest is kept for verification
+	var originals []types.Memory
+	result := &CopyResult{}
+
+	var afterID int64
+	for {
+		batch, err := exp.ExportMemories(ctx, afterID, opts.BatchSize)
+		if err != nil {
+			return result, fmt.Errorf("failed to export memories: %w", err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, mem := range batch {
+			afterID = mem.ID
+			originals = append(originals, mem.Memory)
+			result.Total++
+
+			if _, done := imported[mem.ID]; done {
+				result.Resumed++
+				continue
+			}
+			newID, err := imp.ImportMemory(ctx, source, mem)
+			if err != nil {
+				return result, fmt.Errorf("failed to import memory %d: %w", mem.ID, err)
+			}
+			imported[mem.ID] = newID
+			result.Copied++
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(result.Total)
+		}
+	}
+
+	// Links go in once every target exists; setting one twice is harmless
+	for _, mem := range originals {
+		if mem.SupersededBy == nil {
+			continue
+		}
+		target, ok := imported[*mem.SupersededBy]
+		if !ok {
+			result.Unlinked++
+			continue
+		}
+		if err := imp.SetSupersededBy(ctx, imported[mem.ID], target); err != nil {
+			return result, fmt.Errorf("failed to link memory %d: %w", mem.ID, err)
+		}
+		result.Linked++
+	}
+
+	if err := verifyCopy(ctx, dst, imp, source, originals, imported); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// verifyCopy checks the destination holds one copy of every source memory,
+// with the same text, tags, author, repo, creation time, embedding model,
+// validity and (remapped) superseded_by link
+func verifyCopy(ctx context.Context, dst Storage, imp Importer, source string, originals []types.Memory, imported map[int64]int64) error {
+	recorded, err := imp.ImportedIDs(ctx, source)
+	if err != nil {
+		return fmt.Errorf("failed to read imports: %w", err)
+	}
+	if len(recorded) != len(originals) {
+		return fmt.Errorf("%w: source has %d memories, destination recorded %d imports",
+			ErrCopyMismatch, len(originals), len(recorded))
+	}
+
+	for _, want := range originals {
+		got, err := dst.Get(ctx, imported[want.ID])
+		if err != nil {
+			return fmt.Errorf("%w: memory %d: %v", ErrCopyMismatch, want.ID, err)
+		}
+
+		var wantLink *int64
+		if want.SupersededBy != nil {
+			if target, ok := imported[*want.SupersededBy]; ok {
+				wantLink = &target
+			}
+		}
+
+		switch {
+		case got.Content != want.Content || got.Area != want.Area || got.Type != want.Type:
+			return fmt.Errorf("%w: memory %d (now %d) has different text", ErrCopyMismatch, want.ID, got.ID)
+		case fmt.Sprint(got.Tags) != fmt.Sprint(uniqueTags(want.Tags)):
+			return fmt.Errorf("%w: memory %d (now %d) has different tags", ErrCopyMismatch, want.ID, got.ID)
+		case got.AuthorName != want.AuthorName || got.AuthorEmail != want.AuthorEmail:
+			return fmt.Errorf("%w: memory %d (now %d) has a different author", ErrCopyMismatch, want.ID, got.ID)
+		case got.Repo != want.Repo:
+			return fmt.Errorf("%w: memory %d (now %d) has a different repo", ErrCopyMismatch, want.ID, got.ID)
+		case !got.CreatedAt.Truncate(copyTimeResolution).Equal(want.CreatedAt.Truncate(copyTimeResolution)):
+			return fmt.Errorf("%w: memory %d (now %d) has a different created_at", ErrCopyMismatch, want.ID, got.ID)
+		case got.EmbeddingModel != want.EmbeddingModel:
+			return fmt.Errorf("%w: memory %d (now %d) has a different embedding model", ErrCopyMismatch, want.ID, got.ID)
+		case got.IsValid != want.IsValid:
+			return fmt.Errorf("%w: memory %d (now %d) has different validity", ErrCopyMismatch, want.ID, got.ID)
+		case !sameLink(got.SupersededBy, wantLink):
+			return fmt.Errorf("%w: memory %d (now %d) has the wrong superseded_by", ErrCopyMismatch, want.ID, got.ID)
+		}
+	}
+	return nil
+}
+
+func sameLink(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// DisableMongoTransactions makes m write as it does on a standalone mongod,
+// so tests against a replica set also cover the path without transactions
+func DisableMongoTransactions(m *MongoDB) {
+	m.transactions = false
+}
+
+// VerifyCopy checks dst against originals as Copy does once it has copied
+// them, so tests can hand it a source that no longer matches
+func VerifyCopy(ctx context.Context, dst Storage, source string, originals []types.Memory, imported map[int64]int64) error {
+	return verifyCopy(ctx, dst, dst.(Importer), source, originals, imported)
+}
@@ -7,7 +7,7 @@ import (
 
 // Config holds storage configuration
 type Config struct {
-	Driver string // "sqlite", "postgres", "mongodb"
+	Driver string // "sqlite", "postgres", "mongodb", "memory"
 
 	// SQLite
 	SQLitePath string
@@ -18,22 +18,73 @@ type Config struct {
 	// MongoDB
 	MongoDBURI      string
 	MongoDBDatabase string
+	// MongoDBFallbackCandidates caps how many memories are ranked in-process
+	// when Atlas Vector Search is unavailable (default DefaultMongoFallbackCandidates)
+	MongoDBFallbackCandidates int
+
+	// Memory: optional snapshot file, loaded on open and written on Close
+	MemorySnapshotPath string
+
+	// EmbeddingModel and EmbeddingDim describe the vectors this process will
+	// write. They are recorded when the database is created and checked on
+	// every later open; an empty model skips the check. EmbeddingDim defaults
+	// to DefaultEmbeddingDim.
+	EmbeddingModel string
+	EmbeddingDim   int
+
+	// NoMigrate opens the database without applying pending migrations.
+	// A database migrated by a newer build is still refused.
+	NoMigrate bool
 }
 
-// New creates a Storage implementation based on config
+// New creates a Storage implementation based on config and brings its
+// schema up to date (unless cfg.NoMigrate is set)
 func New(ctx context.Context, cfg Config) (Storage, error) {
+	if cfg.EmbeddingDim <= 0 {
+		cfg.EmbeddingDim = DefaultEmbeddingDim
+	}
+
+	store, err := open(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	migrated, err := prepareSchema(ctx, store, cfg.NoMigrate)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	// An unmigrated database has nowhere to record the model yet
+	if cfg.EmbeddingModel != "" && migrated {
+		spec := EmbeddingSpec{Model: cfg.EmbeddingModel, Dimension: cfg.EmbeddingDim}
+		if err := checkEmbeddingSpec(ctx, store, spec); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// migratingStorage is a backend that also manages its own schema and metadata
+type migratingStorage interface {
+	Storage
+	Migrator
+	metadataStore
+}
+
+// open connects to the configured backend without touching the schema
+func open(ctx context.Context, cfg Config) (migratingStorage, error) {
 	switch cfg.Driver {
 	case "sqlite":
 		if cfg.SQLitePath == "" {
 			return nil, fmt.Errorf("sqlite path is required")
 		}
-		return NewSQLite(cfg.SQLitePath)
+		return openSQLite(cfg.SQLitePath, cfg.EmbeddingDim)
 
 	case "postgres":
 		if cfg.PostgresDSN == "" {
 			return nil, fmt.Errorf("postgres DSN is required")
 		}
-		return NewPostgres(ctx, cfg.PostgresDSN)
+		return openPostgres(ctx, cfg.PostgresDSN, cfg.EmbeddingDim)
 
 	case "mongodb":
 		if cfg.MongoDBURI == "" {
@@ -42,7 +93,13 @@ func New(ctx context.Context, cfg Config) (Storage, error) {
 		if cfg.MongoDBDatabase == "" {
 			cfg.MongoDBDatabase = "engram"
 		}
-		return NewMongoDB(ctx, cfg.MongoDBURI, cfg.MongoDBDatabase)
+		if cfg.MongoDBFallbackCandidates <= 0 {
+			cfg.MongoDBFallbackCandidates = DefaultMongoFallbackCandidates
+		}
+		return openMongoDB(ctx, cfg.MongoDBURI, cfg.MongoDBDatabase, cfg.MongoDBFallbackCandidates)
+
+	case "memory":
+		return NewInMemory(cfg.MemorySnapshotPath)
 
 	default:
 		return nil, fmt.Errorf("unknown storage driver: %s", cfg.Driver)
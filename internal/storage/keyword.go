@@ -0,0 +1,26 @@
+package storage
+
+import "strings"
+
+// keywordTerms splits a search query into the words KeywordSearch matches.
+// Quotes are dropped so user input can't open an unterminated phrase.
+func keywordTerms(query string) []string {
+	var terms []string
+	for _, field := range strings.Fields(query) {
+		if term := strings.ReplaceAll(field, `"`, ""); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// anyPhraseQuery quotes each term as a phrase and joins them with orOp, so
+// identifiers like ERR_CONN_RESET or db.pool.max match as written instead of
+// being parsed as query operators
+func anyPhraseQuery(terms []string, orOp string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"`
+	}
+	return strings.Join(quoted, " "+orOp+" ")
+}
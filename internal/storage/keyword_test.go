@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeywordTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"DATABASE_URL", []string{"DATABASE_URL"}},
+		{"  pool   timeout ", []string{"pool", "timeout"}},
+		{`"ERR_CONN" retry`, []string{"ERR_CONN", "retry"}},
+		{`"" "`, nil},
+	}
+
+	for _, tt := range tests {
+		if got := keywordTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("keywordTerms(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestAnyPhraseQuery(t *testing.T) {
+	got := anyPhraseQuery([]string{"db.pool", "NOT", "retry"}, "OR")
+	want := `"db.pool" OR "NOT" OR "retry"`
+	if got != want {
+		t.Errorf("anyPhraseQuery = %s, want %s", got, want)
+	}
+}
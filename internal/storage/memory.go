@@ -0,0 +1,881 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// InMemory implements Storage in process memory with brute-force cosine
+// search. It needs no CGO or server, which suits tests and throwaway
+// sessions. If a snapshot path is set, the store is loaded from it on open
+// (when the file exists) and written back on Close.
+type InMemory struct {
+	mu        sync.RWMutex
+	snapshot  string
+	nextID    int64
+	memories  map[int64]*memoryRecord
+	revisions map[int64][]types.Revision
+	metadata  map[string]string
+}
+
+// memoryRecord is a stored memory with its vector
+type memoryRecord struct {
+	types.Memory
+	Embedding []float32 `json:"embedding"`
+}
+
+// memorySnapshot is the on-disk form of an InMemory store
+type memorySnapshot struct {
+	NextID    int64             `json:"next_id"`
+	Memories  []memoryRecord    `json:"memories"`
+	Revisions []types.Revision  `json:"revisions,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// NewInMemory creates an in-memory storage. snapshotPath may be empty, in
+// which case nothing outlives the process.
+func NewInMemory(snapshotPath string) (*InMemory, error) {
+	s := &InMemory{
+		snapshot:  snapshotPath,
+		memories:  make(map[int64]*memoryRecord),
+		revisions: make(map[int64][]types.Revision),
+		metadata:  make(map[string]string),
+	}
+	if snapshotPath == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snap memorySnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", snapshotPath, err)
+	}
+	s.nextID = snap.NextID
+	for i := range snap.Memories {
+		rec := snap.Memories[i]
+		s.memories[rec.ID] = &rec
+		if rec.ID > s.nextID {
+			s.nextID = rec.ID
+		}
+	}
+	for _, rev := range snap.Revisions {
+		s.revisions[rev.MemoryID] = append(s.revisions[rev.MemoryID], rev)
+	}
+	for k, v := range snap.Metadata {
+		s.metadata[k] = v
+	}
+	return s, nil
+}
+
+// Close writes the snapshot, if one is configured. The store stays usable.
+func (s *InMemory) Close() error {
+	if s.snapshot == "" {
+		return nil
+	}
+
+	s.mu.RLock()
+	snap := memorySnapshot{NextID: s.nextID, Metadata: s.metadata}
+	for _, id := range s.sortedIDs() {
+		snap.Memories = append(snap.Memories, *s.memories[id])
+		snap.Revisions = append(snap.Revisions, s.revisions[id]...)
+	}
+	data, err := json.Marshal(snap)
+	s.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	// Write beside the target and rename, so a crash never leaves half a snapshot
+	tmp, err := os.CreateTemp(filepath.Dir(s.snapshot), filepath.Base(s.snapshot)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.snapshot); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+func (s *InMemory) Add(ctx context.Context, mem types.Memory, embedding []float32) (*types.Memory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := s.insert(mem, embedding).copyMemory()
+	return &out, nil
+}
+
+func (s *InMemory) AddBatch(ctx context.Context, mems []types.Memory, embeddings [][]float32) ([]types.Memory, error) {
+	if len(embeddings) != len(mems) {
+		return nil, fmt.Errorf("got %d embeddings for %d memories", len(embeddings), len(mems))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added := make([]types.Memory, len(mems))
+	for i, mem := range mems {
+		added[i] = s.insert(mem, embeddings[i]).copyMemory()
+	}
+	return added, nil
+}
+
+// insert stores mem as a new valid memory; the caller holds the write lock
+func (s *InMemory) insert(mem types.Memory, embedding []float32) *memoryRecord {
+	s.nextID++
+	rec := &memoryRecord{
+		Memory: types.Memory{
+			ID:             s.nextID,
+			Type:           mem.Type,
+			Area:           mem.Area,
+			Content:        mem.Content,
+			Rationale:      mem.Rationale,
+			IsValid:        true,
+			CreatedAt:      time.Now(),
+			Version:        1,
+			EmbeddingModel: mem.EmbeddingModel,
+			AuthorName:     mem.AuthorName,
+			AuthorEmail:    mem.AuthorEmail,
+			Repo:           mem.Repo,
+			Tags:           uniqueTags(mem.Tags),
+			ExpiresAt:      copyTime(mem.ExpiresAt),
+			ReviewBy:       copyTime(mem.ReviewBy),
+			OriginatedAt:   copyTime(mem.OriginatedAt),
+		},
+		Embedding: append([]float32(nil), embedding...),
+	}
+	s.memories[rec.ID] = rec
+	return rec
+}
+
+func (s *InMemory) Search(ctx context.Context, embedding []float32, opts types.SearchOpts) ([]types.Memory, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 5
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var memories []types.Memory
+	for _, id := range s.sortedIDs() {
+		rec := s.memories[id]
+		if !rec.matchesSearch(opts) || len(rec.Embedding) != len(embedding) {
+			continue
+		}
+		mem := rec.copyMemory()
+		mem.SimilarityScore = cosineSimilarity(embedding, rec.Embedding)
+		memories = append(memories, mem)
+	}
+
+	return topByScore(memories, limit), nil
+}
+
+// KeywordSearch matches terms against whole words, case-insensitively, and
+// scores matches by TF-IDF like the SQLite FTS4 index
+func (s *InMemory) KeywordSearch(ctx context.Context, query string, opts types.SearchOpts) ([]types.Memory, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 5
+	}
+
+	terms := keywordTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	phrases := make([][]string, 0, len(terms))
+	for _, term := range terms {
+		if words := wordTokens(term); len(words) > 0 {
+			phrases = append(phrases, words)
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Document frequencies are taken over every row, as FTS does
+	hits := make(map[int64][]int, len(s.memories))
+	docs := make([]int, len(phrases))
+	for id, rec := range s.memories {
+		words := wordTokens(rec.Area + " " + rec.Content + " " + rec.Rationale)
+		counts := make([]int, len(phrases))
+		for i, phrase := range phrases {
+			if counts[i] = countPhrase(words, phrase); counts[i] > 0 {
+				docs[i]++
+			}
+		}
+		hits[id] = counts
+	}
+
+	rows := float64(len(s.memories))
+	var memories []types.Memory
+	for _, id := range s.sortedIDs() {
+		rec := s.memories[id]
+		if !rec.matchesSearch(opts) {
+			continue
+		}
+		var score float64
+		for i, n := range hits[id] {
+			if n > 0 {
+				score += float64(n) * math.Log(1+rows/float64(docs[i]))
+			}
+		}
+		if score == 0 {
+			continue
+		}
+		mem := rec.copyMemory()
+		mem.SimilarityScore = score
+		memories = append(memories, mem)
+	}
+
+	return topByScore(memories, limit), nil
+}
+
+func (s *InMemory) List(ctx context.Context, opts types.ListOpts) ([]types.Memory, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var memories []types.Memory
+	for _, id := range s.sortedIDs() {
+		rec := s.memories[id]
+		if !opts.IncludeInvalid && !rec.IsValid {
+			continue
+		}
+		if (opts.Type != "" && rec.Type != opts.Type) ||
+			(opts.Area != "" && rec.Area != opts.Area) ||
+			(opts.Repo != "" && rec.Repo != opts.Repo) ||
+			!opts.Tags.Matches(rec.Tags) {
+			continue
+		}
+		if opts.After != nil && !pastCursor(opts.After, rec.Memory) {
+			continue
+		}
+		memories = append(memories, rec.copyMemory())
+	}
+
+	// Newest first; IDs break ties between memories created in the same instant
+	sort.SliceStable(memories, func(i, j int) bool {
+		if !memories[i].CreatedAt.Equal(memories[j].CreatedAt) {
+			return memories[i].CreatedAt.After(memories[j].CreatedAt)
+		}
+		return memories[i].ID > memories[j].ID
+	})
+
+	if opts.Offset >= len(memories) {
+		return nil, nil
+	}
+	memories = memories[max(opts.Offset, 0):]
+	if len(memories) > limit {
+		memories = memories[:limit]
+	}
+	return memories, nil
+}
+
+func (s *InMemory) Get(ctx context.Context, id int64) (*types.Memory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.memories[id]
+	if !ok {
+		return nil, types.ErrNotFound
+	}
+	mem := rec.copyMemory()
+	return &mem, nil
+}
+
+func (s *InMemory) Invalidate(ctx context.Context, inv types.Invalidation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := checkSupersede(inv, func(id int64) (*supersedeLink, error) {
+		rec, ok := s.memories[id]
+		if !ok {
+			return nil, nil
+		}
+		return &supersedeLink{Repo: rec.Repo, SupersededBy: rec.SupersededBy}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	rec := s.memories[inv.ID]
+	now := time.Now()
+	rec.IsValid = false
+	rec.InvalidatedAt = &now
+	rec.InvalidatedByName = inv.ByName
+	rec.InvalidatedByEmail = inv.ByEmail
+	rec.InvalidationReason = inv.Reason
+	// Invalidating again replaces the link too
+	rec.SupersededBy = nil
+	if inv.SupersededBy != nil {
+		sup := *inv.SupersededBy
+		rec.SupersededBy = &sup
+	}
+	return nil
+}
+
+func (s *InMemory) InvalidateMatching(ctx context.Context, bi types.BulkInvalidation) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := []int64{}
+	for id, rec := range s.memories {
+		if rec.IsValid && bi.Filter.Matches(rec.Memory) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	err := checkBulkSupersede(bi, ids, func(id int64) (*supersedeLink, error) {
+		rec, ok := s.memories[id]
+		if !ok {
+			return nil, nil
+		}
+		return &supersedeLink{Repo: rec.Repo, SupersededBy: rec.SupersededBy}, nil
+	})
+	if err != nil || bi.DryRun {
+		return ids, err
+	}
+
+	now := time.Now()
+	for _, id := range ids {
+		rec := s.memories[id]
+		rec.IsValid = false
+		rec.InvalidatedAt = &now
+		rec.InvalidatedByName = bi.ByName
+		rec.InvalidatedByEmail = bi.ByEmail
+		rec.InvalidationReason = bi.Reason
+		rec.SupersededBy = nil
+		if bi.SupersededBy != nil {
+			sup := *bi.SupersededBy
+			rec.SupersededBy = &sup
+		}
+	}
+	return ids, nil
+}
+
+func (s *InMemory) Revalidate(ctx context.Context, rev types.Revalidation) (*types.Memory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.memories[rev.ID]
+	if !ok {
+		return nil, types.ErrNotFound
+	}
+	if !rec.IsValid {
+		now := time.Now()
+		rec.IsValid = true
+		rec.SupersededBy = nil
+		rec.InvalidatedAt = nil
+		rec.InvalidatedByName = ""
+		rec.InvalidatedByEmail = ""
+		rec.InvalidationReason = ""
+		// A passed expiry would have the next sweep invalidate the memory again
+		if rec.ExpiresAt != nil && !rec.ExpiresAt.After(now) {
+			rec.ExpiresAt = nil
+		}
+		rec.RestoredAt = &now
+		rec.RestoredByName = rev.ByName
+		rec.RestoredByEmail = rev.ByEmail
+	}
+	mem := rec.copyMemory()
+	return &mem, nil
+}
+
+func (s *InMemory) Merge(ctx context.Context, m types.Merge, embedding []float32) (*types.Memory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	originatedAt, err := checkMerge(m, func(id int64) (*mergeSource, error) {
+		rec, ok := s.memories[id]
+		if !ok {
+			return nil, nil
+		}
+		return &mergeSource{Repo: rec.Repo, IsValid: rec.IsValid, CreatedAt: rec.CreatedAt, OriginatedAt: rec.OriginatedAt}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mem := m.Memory
+	mem.OriginatedAt = &originatedAt
+	merged := s.insert(mem, embedding)
+
+	now := time.Now()
+	for _, id := range m.Sources {
+		rec := s.memories[id]
+		sup := merged.ID
+		rec.IsValid = false
+		rec.SupersededBy = &sup
+		rec.InvalidatedAt = &now
+		rec.InvalidatedByName = m.ByName
+		rec.InvalidatedByEmail = m.ByEmail
+		rec.InvalidationReason = m.Reason
+	}
+
+	out := merged.copyMemory()
+	return &out, nil
+}
+
+func (s *InMemory) Update(ctx context.Context, upd types.MemoryUpdate, embedding []float32) (*types.Memory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.memories[upd.ID]
+	if !ok {
+		return nil, types.ErrNotFound
+	}
+	if rec.Version != upd.ExpectedVersion {
+		return nil, fmt.Errorf("%w: memory %d is at version %d, expected %d", types.ErrVersionConflict, upd.ID, rec.Version, upd.ExpectedVersion)
+	}
+
+	now := time.Now()
+	s.revisions[upd.ID] = append(s.revisions[upd.ID], types.Revision{
+		MemoryID:       upd.ID,
+		Version:        rec.Version,
+		Type:           rec.Type,
+		Area:           rec.Area,
+		Content:        rec.Content,
+		Rationale:      rec.Rationale,
+		RevisedAt:      now,
+		RevisedByName:  upd.EditorName,
+		RevisedByEmail: upd.EditorEmail,
+	})
+
+	rec.Type = upd.Type
+	rec.Area = upd.Area
+	rec.Content = upd.Content
+	rec.Rationale = upd.Rationale
+	rec.ExpiresAt = copyTime(upd.ExpiresAt)
+	rec.ReviewBy = copyTime(upd.ReviewBy)
+	rec.Version++
+	rec.UpdatedAt = &now
+	if embedding != nil {
+		rec.Embedding = append([]float32(nil), embedding...)
+		rec.EmbeddingModel = upd.EmbeddingModel
+	}
+
+	mem := rec.copyMemory()
+	return &mem, nil
+}
+
+func (s *InMemory) History(ctx context.Context, id int64) ([]types.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.memories[id]; !ok {
+		return nil, types.ErrNotFound
+	}
+	return append([]types.Revision(nil), s.revisions[id]...), nil
+}
+
+func (s *InMemory) Tags(ctx context.Context, repo string) ([]types.TagCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, rec := range s.memories {
+		if !rec.IsValid || (repo != "" && rec.Repo != repo) {
+			continue
+		}
+		for _, tag := range rec.Tags {
+			counts[tag]++
+		}
+	}
+	return sortTagCounts(counts), nil
+}
+
+func (s *InMemory) Catalog(ctx context.Context, field types.CatalogField, repo string) ([]types.CatalogEntry, error) {
+	sf, err := catalogField(field)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byValue := make(map[string]*types.CatalogEntry)
+	for _, rec := range s.memories {
+		if !rec.IsValid || (repo != "" && rec.Repo != repo) {
+			continue
+		}
+		value := statsValue(rec.Memory, sf)
+		e, ok := byValue[value]
+		if !ok {
+			e = &types.CatalogEntry{Value: value}
+			byValue[value] = e
+		}
+		e.Count++
+		if rec.AuthorName > e.Name {
+			e.Name = rec.AuthorName
+		}
+		last := rec.CreatedAt
+		if rec.UpdatedAt != nil {
+			last = *rec.UpdatedAt
+		}
+		if last.After(e.LastActivity) {
+			e.LastActivity = last
+		}
+	}
+
+	entries := make([]types.CatalogEntry, 0, len(byValue))
+	for _, e := range byValue {
+		entries = append(entries, *e)
+	}
+	return finishCatalog(field, entries), nil
+}
+
+func (s *InMemory) Stats(ctx context.Context, opts types.StatsOpts) (*types.Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	group := func(field statsField) ([]types.StatCount, error) {
+		byValue := make(map[string]*types.StatCount)
+		var counts []types.StatCount
+		for _, rec := range s.memories {
+			if opts.Repo != "" && rec.Repo != opts.Repo {
+				continue
+			}
+			value := statsValue(rec.Memory, field)
+			c, ok := byValue[value]
+			if !ok {
+				c = &types.StatCount{Value: value}
+				byValue[value] = c
+			}
+			if rec.IsValid {
+				c.Valid++
+			} else {
+				c.Invalid++
+			}
+		}
+		for _, c := range byValue {
+			counts = append(counts, *c)
+		}
+		return counts, nil
+	}
+
+	days := func() ([]dayCount, error) {
+		byDay := make(map[time.Time]int)
+		for _, rec := range s.memories {
+			if opts.Repo == "" || rec.Repo == opts.Repo {
+				byDay[types.PeriodDay.Start(rec.CreatedAt)]++
+			}
+		}
+		out := make([]dayCount, 0, len(byDay))
+		for day, n := range byDay {
+			out = append(out, dayCount{Day: day, Count: n})
+		}
+		return out, nil
+	}
+
+	return collectStats(opts, group, days)
+}
+
+// statsValue returns mem's value of field
+func statsValue(mem types.Memory, field statsField) string {
+	switch field {
+	case statsRepo:
+		return mem.Repo
+	case statsArea:
+		return mem.Area
+	case statsType:
+		return string(mem.Type)
+	}
+	if mem.AuthorEmail != "" {
+		return mem.AuthorEmail
+	}
+	return mem.AuthorName
+}
+
+func (s *InMemory) Expire(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired int64
+	for _, rec := range s.memories {
+		if rec.IsValid && rec.ExpiresAt != nil && !rec.ExpiresAt.After(now) {
+			at := now
+			rec.IsValid = false
+			rec.InvalidatedAt = &at
+			rec.InvalidatedByName = ""
+			rec.InvalidatedByEmail = ""
+			rec.InvalidationReason = types.ExpiredReason
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+func (s *InMemory) DueForReview(ctx context.Context, repo string, now time.Time, limit int) ([]types.Memory, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var memories []types.Memory
+	for _, id := range s.sortedIDs() {
+		rec := s.memories[id]
+		if !rec.IsValid || rec.ReviewBy == nil || rec.ReviewBy.After(now) ||
+			(repo != "" && rec.Repo != repo) {
+			continue
+		}
+		memories = append(memories, rec.copyMemory())
+	}
+
+	// Most overdue first; sortedIDs keeps ties in ID order
+	sort.SliceStable(memories, func(i, j int) bool {
+		return memories[i].ReviewBy.Before(*memories[j].ReviewBy)
+	})
+	if len(memories) > limit {
+		memories = memories[:limit]
+	}
+	return memories, nil
+}
+
+func (s *InMemory) RecordRetrievals(ctx context.Context, counts map[int64]int, at time.Time) error {
+	if err := markUsageTracked(ctx, s, at); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, n := range counts {
+		rec, ok := s.memories[id]
+		if !ok {
+			continue
+		}
+		rec.RetrievalCount += n
+		if rec.LastRetrievedAt == nil || rec.LastRetrievedAt.Before(at) {
+			rec.LastRetrievedAt = &at
+		}
+	}
+	return nil
+}
+
+func (s *InMemory) Unretrieved(ctx context.Context, repo string, before time.Time, limit int) ([]types.Memory, error) {
+	// A memory created before tracking started may have been used while no
+	// one was counting, so its age only counts from the start of tracking
+	if tracked, err := usageTrackedBefore(ctx, s, before); err != nil || !tracked {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var memories []types.Memory
+	for _, id := range s.sortedIDs() {
+		rec := s.memories[id]
+		if !rec.IsValid || rec.RetrievalCount > 0 || !rec.CreatedAt.Before(before) ||
+			(repo != "" && rec.Repo != repo) {
+			continue
+		}
+		memories = append(memories, rec.copyMemory())
+	}
+
+	// Oldest first; sortedIDs keeps ties in ID order
+	sort.SliceStable(memories, func(i, j int) bool {
+		return memories[i].CreatedAt.Before(memories[j].CreatedAt)
+	})
+	if len(memories) > limit {
+		memories = memories[:limit]
+	}
+	return memories, nil
+}
+
+func (s *InMemory) Lineage(ctx context.Context, id int64) ([]types.Memory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.memories[id]; !ok {
+		return nil, types.ErrNotFound
+	}
+	inLineage := map[int64]bool{id: true}
+	for next := s.memories[id].SupersededBy; next != nil && !inLineage[*next]; {
+		succ, ok := s.memories[*next]
+		if !ok {
+			break
+		}
+		inLineage[succ.ID] = true
+		next = succ.SupersededBy
+	}
+	// Sweep for predecessors until a pass finds no new one
+	predecessors := map[int64]bool{id: true}
+	for found := true; found; {
+		found = false
+		for _, rec := range s.memories {
+			if rec.SupersededBy != nil && predecessors[*rec.SupersededBy] && !predecessors[rec.ID] {
+				predecessors[rec.ID] = true
+				inLineage[rec.ID] = true
+				found = true
+			}
+		}
+	}
+
+	var memories []types.Memory
+	for _, mid := range s.sortedIDs() {
+		if inLineage[mid] {
+			memories = append(memories, s.memories[mid].copyMemory())
+		}
+	}
+	return memories, nil
+}
+
+// Migrate is a no-op: there is no schema to keep in step
+func (s *InMemory) Migrate(ctx context.Context) error {
+	return nil
+}
+
+func (s *InMemory) MigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	return &MigrationStatus{}, nil
+}
+
+func (s *InMemory) getMetadata(ctx context.Context, key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.metadata[key], nil
+}
+
+func (s *InMemory) setMetadata(ctx context.Context, key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metadata[key] = value
+	return nil
+}
+
+func (s *InMemory) vectorDimension(ctx context.Context) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, rec := range s.memories {
+		return len(rec.Embedding), nil
+	}
+	return 0, nil
+}
+
+// sortedIDs returns every memory ID in ascending order, so scans and
+// snapshots are deterministic. Callers hold s.mu.
+func (s *InMemory) sortedIDs() []int64 {
+	ids := make([]int64, 0, len(s.memories))
+	for id := range s.memories {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// copyMemory returns the memory without sharing its pointers or tags, so
+// callers can't modify the store through it
+func (r *memoryRecord) copyMemory() types.Memory {
+	mem := r.Memory
+	mem.Tags = append([]string(nil), r.Tags...)
+	if r.SupersededBy != nil {
+		sup := *r.SupersededBy
+		mem.SupersededBy = &sup
+	}
+	mem.UpdatedAt = copyTime(r.UpdatedAt)
+	mem.ExpiresAt = copyTime(r.ExpiresAt)
+	mem.ReviewBy = copyTime(r.ReviewBy)
+	mem.InvalidatedAt = copyTime(r.InvalidatedAt)
+	mem.RestoredAt = copyTime(r.RestoredAt)
+	mem.OriginatedAt = copyTime(r.OriginatedAt)
+	mem.LastRetrievedAt = copyTime(r.LastRetrievedAt)
+	return mem
+}
+
+// copyTime returns a pointer to a copy of *t, or nil
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+// pastCursor reports whether mem follows the cursor in newest-first order
+func pastCursor(after *types.ListCursor, mem types.Memory) bool {
+	if !mem.CreatedAt.Equal(after.CreatedAt) {
+		return mem.CreatedAt.Before(after.CreatedAt)
+	}
+	return mem.ID < after.ID
+}
+
+// matchesSearch applies the filters both search paths share
+func (r *memoryRecord) matchesSearch(opts types.SearchOpts) bool {
+	return r.IsValid &&
+		(opts.Type == "" || r.Type == opts.Type) &&
+		(opts.Area == "" || r.Area == opts.Area) &&
+		(opts.Repo == "" || r.Repo == opts.Repo) &&
+		opts.Tags.Matches(r.Tags)
+}
+
+// topByScore orders memories best first and keeps the top limit
+func topByScore(memories []types.Memory, limit int) []types.Memory {
+	sort.SliceStable(memories, func(i, j int) bool {
+		return memories[i].SimilarityScore > memories[j].SimilarityScore
+	})
+	if len(memories) > limit {
+		memories = memories[:limit]
+	}
+	return memories
+}
+
+// wordTokens lowercases text and splits it into letter and digit runs, the
+// way the SQL backends' default tokenizers do
+func wordTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// countPhrase counts occurrences of phrase as consecutive words
+func countPhrase(words, phrase []string) int {
+	n := 0
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		match := true
+		for j, w := range phrase {
+			if words[i+j] != w {
+				match = false
+				break
+			}
+		}
+		if match {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,249 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+func TestInMemoryStorage_Search(t *testing.T) {
+	store, err := storage.NewInMemory("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	near, _ := store.Add(ctx, types.Memory{Type: types.TypeDecision, Area: "auth", Content: "Use JWT"}, []float32{1, 0, 0})
+	far, _ := store.Add(ctx, types.Memory{Type: types.TypeDecision, Area: "auth", Content: "Use sessions"}, []float32{0, 1, 0})
+	other, _ := store.Add(ctx, types.Memory{Type: types.TypeLearning, Area: "db", Content: "Pool size"}, []float32{1, 0.1, 0})
+	if err := store.Invalidate(ctx, types.Invalidation{ID: other.ID}); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+
+	results, err := store.Search(ctx, []float32{1, 0.2, 0}, types.SearchOpts{Limit: 5})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 valid results, got %d", len(results))
+	}
+	if results[0].ID != near.ID || results[1].ID != far.ID {
+		t.Errorf("expected [%d %d] by similarity, got [%d %d]", near.ID, far.ID, results[0].ID, results[1].ID)
+	}
+	if results[0].SimilarityScore <= results[1].SimilarityScore {
+		t.Errorf("expected descending scores, got %v", results)
+	}
+
+	results, err = store.Search(ctx, []float32{1, 0, 0}, types.SearchOpts{Limit: 5, Type: types.TypeLearning})
+	if err != nil {
+		t.Fatalf("Search with type filter failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected invalidated memory to be excluded, got %d results", len(results))
+	}
+}
+
+func TestInMemoryStorage_KeywordSearch(t *testing.T) {
+	store, _ := storage.NewInMemory("")
+	ctx := context.Background()
+
+	conn, _ := store.Add(ctx, types.Memory{Type: types.TypeLearning, Area: "network", Content: "Retry on ERR_CONN_RESET from the proxy"}, nil)
+	pool, _ := store.Add(ctx, types.Memory{Type: types.TypeLearning, Area: "db", Content: "Set db.pool.max to 20", Rationale: "the pool starves otherwise"}, nil)
+	store.Add(ctx, types.Memory{Type: types.TypeDecision, Area: "api", Content: "Use REST"}, nil)
+
+	results, err := store.KeywordSearch(ctx, "ERR_CONN_RESET", types.SearchOpts{})
+	if err != nil {
+		t.Fatalf("KeywordSearch failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != conn.ID {
+		t.Fatalf("expected only memory %d, got %v", conn.ID, results)
+	}
+
+	results, err = store.KeywordSearch(ctx, "proxy POOL", types.SearchOpts{})
+	if err != nil {
+		t.Fatalf("KeywordSearch failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != pool.ID {
+		t.Fatalf("expected memory %d (two hits) first of 2, got %v", pool.ID, results)
+	}
+
+	results, err = store.KeywordSearch(ctx, "pool", types.SearchOpts{Area: "network"})
+	if err != nil {
+		t.Fatalf("KeywordSearch failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected area filter to exclude matches, got %v", results)
+	}
+}
+
+func TestInMemoryStorage_ListOffset(t *testing.T) {
+	store, _ := storage.NewInMemory("")
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		store.Add(ctx, types.Memory{Type: types.TypeDecision, Area: "test", Content: fmt.Sprintf("Memory %d", i)}, nil)
+	}
+
+	page, err := store.List(ctx, types.ListOpts{Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(page) != 2 || page[0].Content != "Memory 4" || page[1].Content != "Memory 3" {
+		t.Errorf("expected Memory 4, Memory 3; got %v", page)
+	}
+
+	page, err = store.List(ctx, types.ListOpts{Offset: 10})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(page) != 0 {
+		t.Errorf("expected an empty page past the end, got %d", len(page))
+	}
+}
+
+func TestInMemoryStorage_Update(t *testing.T) {
+	store, _ := storage.NewInMemory("")
+	ctx := context.Background()
+
+	added, _ := store.Add(ctx, types.Memory{Type: types.TypeDecision, Area: "auth", Content: "Use JWT"}, []float32{1, 0})
+
+	updated, err := store.Update(ctx, types.MemoryUpdate{
+		ID: added.ID, Type: types.TypeDecision, Area: "auth", Content: "Use JWT with rotation",
+		ExpectedVersion: 1, EditorName: "Ana",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Version != 2 || updated.UpdatedAt == nil {
+		t.Errorf("expected version 2 with updated_at, got %+v", updated)
+	}
+
+	_, err = store.Update(ctx, types.MemoryUpdate{ID: added.ID, Content: "stale", ExpectedVersion: 1}, nil)
+	if !errors.Is(err, types.ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict, got %v", err)
+	}
+
+	history, err := store.History(ctx, added.ID)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Content != "Use JWT" || history[0].RevisedByName != "Ana" {
+		t.Errorf("expected the original version in history, got %+v", history)
+	}
+
+	if _, err := store.History(ctx, 999); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for missing memory, got %v", err)
+	}
+}
+
+func TestInMemoryStorage_Snapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memories.json")
+	ctx := context.Background()
+
+	store, err := storage.New(ctx, storage.Config{
+		Driver:             "memory",
+		MemorySnapshotPath: path,
+		EmbeddingModel:     "nomic-embed-text",
+		EmbeddingDim:       2,
+	})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	first, _ := store.Add(ctx, types.Memory{Type: types.TypeDecision, Area: "auth", Content: "Use JWT"}, []float32{1, 0})
+	second, _ := store.Add(ctx, types.Memory{Type: types.TypeDecision, Area: "auth", Content: "Use OAuth"}, []float32{0, 1})
+	store.Invalidate(ctx, types.Invalidation{ID: first.ID, SupersededBy: &second.ID})
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, err := storage.New(ctx, storage.Config{
+		Driver:             "memory",
+		MemorySnapshotPath: path,
+		EmbeddingModel:     "nomic-embed-text",
+		EmbeddingDim:       2,
+	})
+	if err != nil {
+		t.Fatalf("failed to reopen storage: %v", err)
+	}
+	defer reopened.Close()
+
+	got, err := reopened.Get(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.IsValid || got.SupersededBy == nil || *got.SupersededBy != second.ID {
+		t.Errorf("expected invalidated memory superseded by %d, got %+v", second.ID, got)
+	}
+
+	results, err := reopened.Search(ctx, []float32{0, 1}, types.SearchOpts{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != second.ID {
+		t.Errorf("expected embeddings to survive the snapshot, got %v", results)
+	}
+
+	third, _ := reopened.Add(ctx, types.Memory{Type: types.TypeLearning, Area: "auth", Content: "New"}, []float32{1, 1})
+	if third.ID != second.ID+1 {
+		t.Errorf("expected IDs to continue from %d, got %d", second.ID, third.ID)
+	}
+
+	// The snapshot carries the recorded model, so a different one is refused
+	_, err = storage.New(ctx, storage.Config{
+		Driver:             "memory",
+		MemorySnapshotPath: path,
+		EmbeddingModel:     "mxbai-embed-large",
+		EmbeddingDim:       1024,
+	})
+	if !errors.Is(err, storage.ErrEmbeddingMismatch) {
+		t.Errorf("expected ErrEmbeddingMismatch, got %v", err)
+	}
+}
+
+func TestInMemoryStorage_Concurrent(t *testing.T) {
+	store, _ := storage.NewInMemory("")
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			mem, err := store.Add(ctx, types.Memory{Type: types.TypeLearning, Area: "race", Content: fmt.Sprintf("Memory %d", i)}, []float32{1, float32(i)})
+			if err != nil {
+				t.Errorf("Add failed: %v", err)
+				return
+			}
+			store.Search(ctx, []float32{1, 1}, types.SearchOpts{})
+			store.List(ctx, types.ListOpts{Limit: 100})
+			store.Update(ctx, types.MemoryUpdate{ID: mem.ID, Type: mem.Type, Area: mem.Area, Content: "edited", ExpectedVersion: 1}, nil)
+		}(i)
+	}
+	wg.Wait()
+
+	all, err := store.List(ctx, types.ListOpts{Limit: 100})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(all) != 20 {
+		t.Fatalf("expected 20 memories, got %d", len(all))
+	}
+	seen := make(map[int64]bool)
+	for _, mem := range all {
+		if seen[mem.ID] {
+			t.Errorf("duplicate ID %d", mem.ID)
+		}
+		seen[mem.ID] = true
+		if mem.Version != 2 {
+			t.Errorf("memory %d: expected version 2, got %d", mem.ID, mem.Version)
+		}
+	}
+}
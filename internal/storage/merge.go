@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// mergeSource is what checkMerge reads about a source memory
+type mergeSource struct {
+	Repo         string
+	IsValid      bool
+	CreatedAt    time.Time
+	OriginatedAt *time.Time
+}
+
+// origin is when the source's knowledge was first recorded
+func (s mergeSource) origin() time.Time {
+	if s.OriginatedAt != nil && s.OriginatedAt.Before(s.CreatedAt) {
+		return *s.OriginatedAt
+	}
+	return s.CreatedAt
+}
+
+// checkMerge validates m.Sources and returns the earliest time any of them
+// originated. Sources must be distinct, exist, still be valid, and with
+// m.SameRepo be in m.Memory's repo. Backends call it inside the transaction
+// that merges, with lookup returning nil for a missing memory.
+func checkMerge(m types.Merge, lookup func(id int64) (*mergeSource, error)) (time.Time, error) {
+	if len(m.Sources) == 0 {
+		return time.Time{}, types.Invalidf("merge needs at least one source memory")
+	}
+
+	var earliest time.Time
+	seen := make(map[int64]bool, len(m.Sources))
+	for _, id := range m.Sources {
+		if seen[id] {
+			return time.Time{}, types.Invalidf("memory %d is listed twice in the merge sources", id)
+		}
+		seen[id] = true
+
+		src, err := lookup(id)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if src == nil {
+			return time.Time{}, fmt.Errorf("%w: memory %d does not exist", types.ErrInvalidSupersede, id)
+		}
+		if !src.IsValid {
+			return time.Time{}, fmt.Errorf("%w: memory %d is already invalid", types.ErrInvalidSupersede, id)
+		}
+		if m.SameRepo && src.Repo != m.Memory.Repo {
+			return time.Time{}, fmt.Errorf("%w: memory %d is in repo %q, not %q",
+				types.ErrInvalidSupersede, id, src.Repo, m.Memory.Repo)
+		}
+		if origin := src.origin(); earliest.IsZero() || origin.Before(earliest) {
+			earliest = origin
+		}
+	}
+	return earliest, nil
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultEmbeddingDim is the output size of nomic-embed-text, the default model
+const DefaultEmbeddingDim = 768
+
+// ErrEmbeddingMismatch is returned when the configured embedding model or
+// dimension differs from the one the database was created with. Vectors from
+// different models are not comparable, so searching would return nonsense.
+var ErrEmbeddingMismatch = errors.New("embedding model mismatch")
+
+// Metadata keys
+const (
+	metaEmbeddingModel = "embedding_model"
+	metaEmbeddingDim   = "embedding_dim"
+)
+
+// EmbeddingSpec identifies the model that produced a database's vectors
+type EmbeddingSpec struct {
+	Model     string
+	Dimension int
+}
+
+// metadataStore is the key/value metadata every backend keeps alongside its memories
+type metadataStore interface {
+	// getMetadata returns the value for key, or "" if it is unset
+	getMetadata(ctx context.Context, key string) (string, error)
+	setMetadata(ctx context.Context, key, value string) error
+	// vectorDimension reports the size of stored vectors, or 0 if it cannot
+	// be determined (e.g. an empty MongoDB collection)
+	vectorDimension(ctx context.Context) (int, error)
+}
+
+// checkEmbeddingSpec records want on first use and rejects a later open with
+// a different model or dimension. Databases created before metadata existed
+// adopt the configured model as long as the stored vectors fit it.
+func checkEmbeddingSpec(ctx context.Context, store metadataStore, want EmbeddingSpec) error {
+	stored, err := readEmbeddingSpec(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	if stored.Model == "" {
+		dim, err := store.vectorDimension(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read vector dimension: %w", err)
+		}
+		if dim != 0 && dim != want.Dimension {
+			return fmt.Errorf("%w: database holds %d-dimension vectors but model %q is configured with %d",
+				ErrEmbeddingMismatch, dim, want.Model, want.Dimension)
+		}
+		if err := store.setMetadata(ctx, metaEmbeddingModel, want.Model); err != nil {
+			return fmt.Errorf("failed to record embedding model: %w", err)
+		}
+		if err := store.setMetadata(ctx, metaEmbeddingDim, strconv.Itoa(want.Dimension)); err != nil {
+			return fmt.Errorf("failed to record embedding dimension: %w", err)
+		}
+		return nil
+	}
+
+	if normalizeModel(stored.Model) != normalizeModel(want.Model) || stored.Dimension != want.Dimension {
+		return fmt.Errorf("%w: database was created with %q (%d dimensions) but %q (%d dimensions) is configured",
+			ErrEmbeddingMismatch, stored.Model, stored.Dimension, want.Model, want.Dimension)
+	}
+	return nil
+}
+
+// readEmbeddingSpec returns the recorded spec, or a zero spec if none is recorded
+func readEmbeddingSpec(ctx context.Context, store metadataStore) (EmbeddingSpec, error) {
+	return readSpec(ctx, store, metaEmbeddingModel, metaEmbeddingDim)
+}
+
+// readSpec reads a model/dimension pair stored under the given metadata keys
+func readSpec(ctx context.Context, store metadataStore, modelKey, dimKey string) (EmbeddingSpec, error) {
+	model, err := store.getMetadata(ctx, modelKey)
+	if err != nil {
+		return EmbeddingSpec{}, fmt.Errorf("failed to read %s: %w", modelKey, err)
+	}
+	dimStr, err := store.getMetadata(ctx, dimKey)
+	if err != nil {
+		return EmbeddingSpec{}, fmt.Errorf("failed to read %s: %w", dimKey, err)
+	}
+
+	spec := EmbeddingSpec{Model: model}
+	if dimStr != "" {
+		if spec.Dimension, err = strconv.Atoi(dimStr); err != nil {
+			return EmbeddingSpec{}, fmt.Errorf("invalid %s %q: %w", dimKey, dimStr, err)
+		}
+	}
+	return spec, nil
+}
+
+// normalizeModel treats "model" and "model:latest" as the same Ollama model
+func normalizeModel(model string) string {
+	return strings.TrimSuffix(model, ":latest")
+}
@@ -0,0 +1,98 @@
+//go:build cgo
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+func TestEmbeddingSpec_RecordedAndChecked(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "m.db")
+	open := func(model string, dim int) (Storage, error) {
+		return New(ctx, Config{Driver: "sqlite", SQLitePath: path, EmbeddingModel: model, EmbeddingDim: dim})
+	}
+
+	store, err := open("nomic-embed-text", 768)
+	if err != nil {
+		t.Fatalf("first open failed: %v", err)
+	}
+	spec, err := readEmbeddingSpec(ctx, store.(*SQLite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Model != "nomic-embed-text" || spec.Dimension != 768 {
+		t.Fatalf("expected recorded spec, got %+v", spec)
+	}
+	store.Close()
+
+	store, err = open("nomic-embed-text:latest", 768)
+	if err != nil {
+		t.Fatalf("expected :latest tag to match, got %v", err)
+	}
+	store.Close()
+
+	for _, tt := range []struct {
+		model string
+		dim   int
+	}{
+		{"mxbai-embed-large", 1024},
+		{"other-768-model", 768},
+	} {
+		if _, err := open(tt.model, tt.dim); !errors.Is(err, ErrEmbeddingMismatch) {
+			t.Errorf("%s/%d: expected ErrEmbeddingMismatch, got %v", tt.model, tt.dim, err)
+		}
+	}
+}
+
+func TestEmbeddingSpec_CustomDimension(t *testing.T) {
+	ctx := context.Background()
+	store, err := New(ctx, Config{
+		Driver:         "sqlite",
+		SQLitePath:     filepath.Join(t.TempDir(), "m.db"),
+		EmbeddingModel: "mxbai-embed-large",
+		EmbeddingDim:   1024,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	embedding := make([]float32, 1024)
+	embedding[0] = 1
+	if _, err := store.Add(ctx, types.Memory{Type: types.TypeDecision, Area: "a", Content: "c"}, embedding); err != nil {
+		t.Fatalf("Add with 1024-dim embedding failed: %v", err)
+	}
+	results, err := store.Search(ctx, embedding, types.SearchOpts{Limit: 1})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Search failed: %v (%d results)", err, len(results))
+	}
+}
+
+// TestEmbeddingSpec_UnrecordedDatabase covers databases created before the
+// metadata table: the configured model is adopted only if the vectors fit.
+func TestEmbeddingSpec_UnrecordedDatabase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "m.db")
+
+	s, err := NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	if _, err := New(ctx, Config{Driver: "sqlite", SQLitePath: path, EmbeddingModel: "mxbai-embed-large", EmbeddingDim: 1024}); !errors.Is(err, ErrEmbeddingMismatch) {
+		t.Fatalf("expected ErrEmbeddingMismatch for 768-dim table, got %v", err)
+	}
+
+	store, err := New(ctx, Config{Driver: "sqlite", SQLitePath: path, EmbeddingModel: "nomic-embed-text", EmbeddingDim: 768})
+	if err != nil {
+		t.Fatalf("expected matching model to be adopted, got %v", err)
+	}
+	store.Close()
+}
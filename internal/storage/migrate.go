@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSchemaTooNew is returned when the database has migrations applied that
+// this build does not know about, i.e. it was migrated by a newer release.
+var ErrSchemaTooNew = errors.New("database schema is newer than this build supports")
+
+// Migrator is implemented by backends with versioned schema migrations.
+// Applied migrations are recorded in a schema_migrations table (or collection).
+type Migrator interface {
+	// Migrate applies pending migrations in version order
+	Migrate(ctx context.Context) error
+	// MigrationStatus reports applied and pending migrations without changing the database
+	MigrationStatus(ctx context.Context) (*MigrationStatus, error)
+}
+
+// Migration describes one numbered schema change
+type Migration struct {
+	Version     int
+	Description string
+}
+
+// AppliedMigration is a migration recorded in schema_migrations
+type AppliedMigration struct {
+	Version     int
+	Description string
+	AppliedAt   time.Time
+}
+
+// MigrationStatus compares the database against the migrations this build knows
+type MigrationStatus struct {
+	Current int // highest applied version, 0 for an empty database
+	Latest  int // highest version known to this build
+	Applied []AppliedMigration
+	Pending []Migration
+}
+
+// UpToDate reports whether every known migration has been applied
+func (s *MigrationStatus) UpToDate() bool {
+	return len(s.Pending) == 0 && s.Current <= s.Latest
+}
+
+// TooNew reports whether the database was migrated by a newer build
+func (s *MigrationStatus) TooNew() bool {
+	return s.Current > s.Latest
+}
+
+// buildMigrationStatus diffs the known migrations against those recorded in the database
+func buildMigrationStatus(known []Migration, applied []AppliedMigration) *MigrationStatus {
+	status := &MigrationStatus{Applied: applied}
+
+	done := make(map[int]bool, len(applied))
+	for _, a := range applied {
+		done[a.Version] = true
+		if a.Version > status.Current {
+			status.Current = a.Version
+		}
+	}
+	for _, m := range known {
+		if m.Version > status.Latest {
+			status.Latest = m.Version
+		}
+		if !done[m.Version] {
+			status.Pending = append(status.Pending, m)
+		}
+	}
+	return status
+}
+
+// checkSchemaVersion refuses a database migrated past what this build knows,
+// since older code may silently misread or corrupt a newer schema
+func checkSchemaVersion(status *MigrationStatus) error {
+	if status.TooNew() {
+		return fmt.Errorf("%w: database is at version %d, this build knows up to %d; upgrade before connecting",
+			ErrSchemaTooNew, status.Current, status.Latest)
+	}
+	return nil
+}
+
+// prepareSchema runs pending migrations, or with noMigrate only verifies that
+// the database is not ahead of this build. It reports whether the schema is
+// fully migrated afterwards.
+func prepareSchema(ctx context.Context, m Migrator, noMigrate bool) (bool, error) {
+	if !noMigrate {
+		return true, m.Migrate(ctx)
+	}
+	status, err := m.MigrationStatus(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to read migration status: %w", err)
+	}
+	return status.UpToDate(), checkSchemaVersion(status)
+}
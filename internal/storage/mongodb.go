@@ -2,8 +2,12 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -15,10 +19,25 @@ import (
 
 // MongoDB implements Storage using MongoDB with Atlas Vector Search
 type MongoDB struct {
-	client   *mongo.Client
-	db       *mongo.Database
-	memories *mongo.Collection
-	counters *mongo.Collection
+	client     *mongo.Client
+	db         *mongo.Database
+	memories   *mongo.Collection
+	counters   *mongo.Collection
+	revisions  *mongo.Collection
+	migrations *mongo.Collection
+	metadata   *mongo.Collection
+
+	// transactions is set on replica sets and sharded clusters; a standalone
+	// mongod rejects multi-document transactions
+	transactions bool
+
+	// fallbackCandidates caps how many memories a search scores in-process
+	// when Atlas Vector Search is unavailable
+	fallbackCandidates int
+	warnFallback       sync.Once
+	// warnCapHit logs the first search the candidate cap cuts short;
+	// cap_hits in expvar counts the rest
+	warnCapHit sync.Once
 }
 
 // memoryDoc is the MongoDB document structure
@@ -31,17 +50,82 @@ type memoryDoc struct {
 	IsValid      bool      `bson:"is_valid"`
 	SupersededBy *int64    `bson:"superseded_by,omitempty"`
 	CreatedAt    time.Time `bson:"created_at"`
-	Author       struct {
+	// Version is absent on documents written before in-place updates; treated as 1
+	Version        int        `bson:"version,omitempty"`
+	UpdatedAt      *time.Time `bson:"updated_at,omitempty"`
+	EmbeddingModel string     `bson:"embedding_model,omitempty"`
+	Author         struct {
 		Name  string `bson:"name"`
 		Email string `bson:"email"`
 	} `bson:"author"`
-	Repo            string    `bson:"repo"`
-	Embedding       []float32 `bson:"embedding"`
-	SimilarityScore float64   `bson:"similarity_score,omitempty"`
+	Repo            string     `bson:"repo"`
+	Tags            []string   `bson:"tags,omitempty"`
+	ExpiresAt       *time.Time `bson:"expires_at,omitempty"`
+	ReviewBy        *time.Time `bson:"review_by,omitempty"`
+	Embedding       []float32  `bson:"embedding"`
+	SimilarityScore float64    `bson:"similarity_score,omitempty"`
+	// ImportedFrom is set on memories copied in from another database
+	ImportedFrom *importRef `bson:"imported_from,omitempty"`
+	// Invalidated records when, by whom and why the memory was invalidated
+	Invalidated *invalidationDoc `bson:"invalidated,omitempty"`
+	// Restored records when and by whom the memory was made valid again
+	Restored *restoreDoc `bson:"restored,omitempty"`
+	// OriginatedAt is set on memories created by merging others
+	OriginatedAt *time.Time `bson:"originated_at,omitempty"`
+	// Usage, counted in batches of retrievals
+	RetrievalCount  int        `bson:"retrieval_count,omitempty"`
+	LastRetrievedAt *time.Time `bson:"last_retrieved_at,omitempty"`
+}
+
+type invalidationDoc struct {
+	At      time.Time `bson:"at"`
+	ByName  string    `bson:"by_name,omitempty"`
+	ByEmail string    `bson:"by_email,omitempty"`
+	Reason  string    `bson:"reason,omitempty"`
+}
+
+type restoreDoc struct {
+	At      time.Time `bson:"at"`
+	ByName  string    `bson:"by_name,omitempty"`
+	ByEmail string    `bson:"by_email,omitempty"`
+}
+
+// importRef identifies the source database and ID of an imported memory
+type importRef struct {
+	Source string `bson:"source"`
+	ID     int64  `bson:"id"`
+}
+
+// revisionDoc is a previous version of a memory document
+type revisionDoc struct {
+	MemoryID  int64     `bson:"memory_id"`
+	Version   int       `bson:"version"`
+	Type      string    `bson:"type"`
+	Area      string    `bson:"area"`
+	Content   string    `bson:"content"`
+	Rationale string    `bson:"rationale,omitempty"`
+	RevisedAt time.Time `bson:"revised_at"`
+	RevisedBy struct {
+		Name  string `bson:"name"`
+		Email string `bson:"email"`
+	} `bson:"revised_by"`
 }
 
-// NewMongoDB creates a new MongoDB storage
+// NewMongoDB creates a new MongoDB storage, applying any pending migrations
 func NewMongoDB(ctx context.Context, uri, database string) (*MongoDB, error) {
+	m, err := openMongoDB(ctx, uri, database, DefaultMongoFallbackCandidates)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Migrate(ctx); err != nil {
+		m.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	return m, nil
+}
+
+// openMongoDB connects without touching indexes
+func openMongoDB(ctx context.Context, uri, database string, fallbackCandidates int) (*MongoDB, error) {
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to mongodb: %w", err)
@@ -52,40 +136,80 @@ func NewMongoDB(ctx context.Context, uri, database string) (*MongoDB, error) {
 		return nil, fmt.Errorf("failed to ping mongodb: %w", err)
 	}
 
+	transactions, err := supportsTransactions(ctx, client)
+	if err != nil {
+		client.Disconnect(ctx)
+		return nil, fmt.Errorf("failed to check mongodb topology: %w", err)
+	}
+	if !transactions {
+		log.Printf("MongoDB is a standalone server without transactions; multi-document writes are applied one at a time. Use a replica set to make them atomic.")
+	}
+
 	db := client.Database(database)
 
-	m := &MongoDB{
-		client:   client,
-		db:       db,
-		memories: db.Collection("memories"),
-		counters: db.Collection("counters"),
-	}
+	return &MongoDB{
+		client:     client,
+		db:         db,
+		memories:   db.Collection("memories"),
+		counters:   db.Collection("counters"),
+		revisions:  db.Collection("memory_revisions"),
+		migrations: db.Collection("schema_migrations"),
+		metadata:   db.Collection("metadata"),
 
-	if err := m.initIndexes(ctx); err != nil {
-		client.Disconnect(ctx)
-		return nil, fmt.Errorf("failed to create indexes: %w", err)
-	}
+		transactions: transactions,
 
-	return m, nil
+		fallbackCandidates: fallbackCandidates,
+	}, nil
 }
 
-func (m *MongoDB) initIndexes(ctx context.Context) error {
-	indexes := []mongo.IndexModel{
-		{Keys: bson.D{{Key: "type", Value: 1}}},
-		{Keys: bson.D{{Key: "area", Value: 1}}},
-		{Keys: bson.D{{Key: "is_valid", Value: 1}}},
-		{Keys: bson.D{{Key: "repo", Value: 1}}},
-		{Keys: bson.D{{Key: "author.email", Value: 1}}},
-		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+// supportsTransactions reports whether the deployment is a replica set or a
+// sharded cluster, the topologies that accept multi-document transactions
+func supportsTransactions(ctx context.Context, client *mongo.Client) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
 	}
+	admin := client.Database("admin")
+	err := admin.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		// Servers before 4.4.2 only know the legacy name
+		err = admin.RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello)
+	}
+	if err != nil {
+		return false, err
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
 
-	_, err := m.memories.Indexes().CreateMany(ctx, indexes)
+// inTransaction runs fn in a transaction where the deployment supports them.
+// On a standalone server fn runs without one and its writes apply one by
+// one, so fn should make each write conditional and undo what it can on error.
+func (m *MongoDB) inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !m.transactions {
+		return fn(ctx)
+	}
+
+	session, err := m.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
 	return err
 }
 
 // nextID atomically generates the next memory ID using a counters collection.
 // This is safe for multi-instance deployments.
 func (m *MongoDB) nextID(ctx context.Context) (int64, error) {
+	return m.reserveIDs(ctx, 1)
+}
+
+// reserveIDs advances the ID counter by n and returns the last ID reserved;
+// the n IDs ending there are the caller's
+func (m *MongoDB) reserveIDs(ctx context.Context, n int64) (int64, error) {
 	opts := options.FindOneAndUpdate().
 		SetUpsert(true).
 		SetReturnDocument(options.After)
@@ -97,7 +221,7 @@ func (m *MongoDB) nextID(ctx context.Context) (int64, error) {
 	err := m.counters.FindOneAndUpdate(
 		ctx,
 		bson.D{{Key: "_id", Value: "memory_id"}},
-		bson.D{{Key: "$inc", Value: bson.D{{Key: "value", Value: 1}}}},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "value", Value: n}}}},
 		opts,
 	).Decode(&result)
 
@@ -119,39 +243,82 @@ func (m *MongoDB) Add(ctx context.Context, mem types.Memory, embedding []float32
 	if err != nil {
 		return nil, err
 	}
-	now := time.Now()
-
-	doc := memoryDoc{
-		ID:        id,
-		Type:      string(mem.Type),
-		Area:      mem.Area,
-		Content:   mem.Content,
-		Rationale: mem.Rationale,
-		IsValid:   true,
-		CreatedAt: now,
-		Repo:      mem.Repo,
-		Embedding: embedding,
-	}
-	doc.Author.Name = mem.AuthorName
-	doc.Author.Email = mem.AuthorEmail
 
+	doc := newMemoryDoc(id, mem, embedding, time.Now())
 	_, err = m.memories.InsertOne(ctx, doc)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert memory: %w", err)
 	}
 
-	return &types.Memory{
-		ID:          id,
-		Type:        mem.Type,
-		Area:        mem.Area,
-		Content:     mem.Content,
-		Rationale:   mem.Rationale,
-		IsValid:     true,
-		CreatedAt:   now,
-		AuthorName:  mem.AuthorName,
-		AuthorEmail: mem.AuthorEmail,
-		Repo:        mem.Repo,
-	}, nil
+	added := docToMemory(doc)
+	return &added, nil
+}
+
+func (m *MongoDB) AddBatch(ctx context.Context, mems []types.Memory, embeddings [][]float32) ([]types.Memory, error) {
+	if len(embeddings) != len(mems) {
+		return nil, fmt.Errorf("got %d embeddings for %d memories", len(embeddings), len(mems))
+	}
+	added := make([]types.Memory, 0, len(mems))
+	if len(mems) == 0 {
+		return added, nil
+	}
+
+	last, err := m.reserveIDs(ctx, int64(len(mems)))
+	if err != nil {
+		return nil, err
+	}
+	first := last - int64(len(mems)) + 1
+
+	now := time.Now()
+	docs := make([]interface{}, len(mems))
+	for i, mem := range mems {
+		doc := newMemoryDoc(first+int64(i), mem, embeddings[i], now)
+		docs[i] = doc
+		added = append(added, docToMemory(doc))
+	}
+
+	err = m.inTransaction(ctx, func(ctx context.Context) error {
+		_, err := m.memories.InsertMany(ctx, docs)
+		if err != nil && !m.transactions {
+			// The IDs were reserved for this batch, so any document in the
+			// range is one of its partial inserts
+			_, delErr := m.memories.DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{
+				{Key: "$gte", Value: first}, {Key: "$lte", Value: last},
+			}}})
+			if delErr != nil {
+				log.Printf("Failed to remove memories %d-%d of a failed batch: %v", first, last, delErr)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert memories: %w", err)
+	}
+	return added, nil
+}
+
+// newMemoryDoc returns the document for mem added as a new valid memory
+func newMemoryDoc(id int64, mem types.Memory, embedding []float32, now time.Time) memoryDoc {
+	doc := memoryDoc{
+		ID:             id,
+		Type:           string(mem.Type),
+		Area:           mem.Area,
+		Content:        mem.Content,
+		Rationale:      mem.Rationale,
+		IsValid:        true,
+		CreatedAt:      now,
+		Version:        1,
+		EmbeddingModel: mem.EmbeddingModel,
+		Repo:           mem.Repo,
+		Tags:           uniqueTags(mem.Tags),
+		ExpiresAt:      copyTime(mem.ExpiresAt),
+		ReviewBy:       copyTime(mem.ReviewBy),
+		OriginatedAt:   copyTime(mem.OriginatedAt),
+		Embedding:      embedding,
+	}
+	doc.Author.Name = mem.AuthorName
+	doc.Author.Email = mem.AuthorEmail
+	return doc
 }
 
 func (m *MongoDB) Search(ctx context.Context, embedding []float32, opts types.SearchOpts) ([]types.Memory, error) {
@@ -171,10 +338,11 @@ func (m *MongoDB) Search(ctx context.Context, embedding []float32, opts types.Se
 	if opts.Repo != "" {
 		filter = append(filter, bson.E{Key: "repo", Value: opts.Repo})
 	}
+	filter = append(filter, mongoTagFilter(opts.Tags)...)
 
 	// Atlas Vector Search pipeline
 	// Note: This requires an Atlas Vector Search index named "embedding_index"
-	// For non-Atlas deployments, falls back to regular query (no vector search)
+	// For non-Atlas deployments, falls back to scoring candidates in-process
 	pipeline := mongo.Pipeline{
 		{{Key: "$vectorSearch", Value: bson.D{
 			{Key: "index", Value: "embedding_index"},
@@ -193,23 +361,65 @@ func (m *MongoDB) Search(ctx context.Context, embedding []float32, opts types.Se
 
 	cursor, err := m.memories.Aggregate(ctx, pipeline)
 	if err != nil {
-		// Fallback to regular query if vector search not available
-		log.Printf("WARNING: Atlas Vector Search unavailable, falling back to list query (no semantic search): %v", err)
-		return m.listFallback(ctx, opts)
+		// Fallback to in-process cosine ranking if vector search not available
+		m.warnFallback.Do(func() {
+			log.Printf("WARNING: Atlas Vector Search unavailable, ranking up to %d candidates in-process: %v", m.fallbackCandidates, err)
+		})
+		return m.vectorFallback(ctx, embedding, filter, limit)
 	}
 	defer cursor.Close(ctx)
 
-	return m.cursorToMemoriesWithScore(ctx, cursor)
+	memories, err := m.cursorToMemoriesWithScore(ctx, cursor)
+	if err != nil {
+		return nil, err
+	}
+	// Atlas scales cosine similarity to [0, 1]; undo it to match the other backends
+	for i := range memories {
+		memories[i].SimilarityScore = 2*memories[i].SimilarityScore - 1
+	}
+	return memories, nil
 }
 
-func (m *MongoDB) listFallback(ctx context.Context, opts types.SearchOpts) ([]types.Memory, error) {
-	listOpts := types.ListOpts{
-		Limit: opts.Limit,
-		Type:  opts.Type,
-		Area:  opts.Area,
-		Repo:  opts.Repo,
+func (m *MongoDB) KeywordSearch(ctx context.Context, query string, opts types.SearchOpts) ([]types.Memory, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 5
 	}
-	return m.List(ctx, listOpts)
+
+	terms := keywordTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	// $text ORs bare terms; quoting them would make every term required
+	filter := bson.D{
+		{Key: "$text", Value: bson.D{{Key: "$search", Value: strings.Join(terms, " ")}}},
+		{Key: "is_valid", Value: true},
+	}
+	if opts.Type != "" {
+		filter = append(filter, bson.E{Key: "type", Value: string(opts.Type)})
+	}
+	if opts.Area != "" {
+		filter = append(filter, bson.E{Key: "area", Value: opts.Area})
+	}
+	if opts.Repo != "" {
+		filter = append(filter, bson.E{Key: "repo", Value: opts.Repo})
+	}
+	filter = append(filter, mongoTagFilter(opts.Tags)...)
+
+	textScore := bson.D{{Key: "$meta", Value: "textScore"}}
+	findOpts := options.Find().
+		SetProjection(bson.D{{Key: "similarity_score", Value: textScore}, {Key: "embedding", Value: 0}}).
+		SetSort(bson.D{{Key: "similarity_score", Value: textScore}}).
+		SetLimit(int64(limit))
+
+	cursor, err := m.memories.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	return m.cursorToMemoriesWithScore(ctx, cursor)
 }
 
 func (m *MongoDB) List(ctx context.Context, opts types.ListOpts) ([]types.Memory, error) {
@@ -231,9 +441,20 @@ func (m *MongoDB) List(ctx context.Context, opts types.ListOpts) ([]types.Memory
 	if opts.Repo != "" {
 		filter = append(filter, bson.E{Key: "repo", Value: opts.Repo})
 	}
+	filter = append(filter, mongoTagFilter(opts.Tags)...)
+	if opts.After != nil {
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "created_at", Value: bson.D{{Key: "$lt", Value: opts.After.CreatedAt}}}},
+			bson.D{
+				{Key: "created_at", Value: opts.After.CreatedAt},
+				{Key: "_id", Value: bson.D{{Key: "$lt", Value: opts.After.ID}}},
+			},
+		}})
+	}
 
 	findOpts := options.Find().
-		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(max(opts.Offset, 0))).
 		SetLimit(int64(limit))
 
 	cursor, err := m.memories.Find(ctx, filter, findOpts)
@@ -245,25 +466,682 @@ func (m *MongoDB) List(ctx context.Context, opts types.ListOpts) ([]types.Memory
 	return m.cursorToMemories(ctx, cursor)
 }
 
-func (m *MongoDB) Invalidate(ctx context.Context, id int64, supersededBy *int64) error {
-	update := bson.D{{Key: "$set", Value: bson.D{{Key: "is_valid", Value: false}}}}
-	if supersededBy != nil {
-		update = bson.D{{Key: "$set", Value: bson.D{
+func (m *MongoDB) Get(ctx context.Context, id int64) (*types.Memory, error) {
+	var doc memoryDoc
+	err := m.memories.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, types.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mem := docToMemory(doc)
+	return &mem, nil
+}
+
+func (m *MongoDB) Invalidate(ctx context.Context, inv types.Invalidation) error {
+	set := bson.D{
+		{Key: "is_valid", Value: false},
+		{Key: "invalidated", Value: invalidationDoc{
+			At:      time.Now().UTC(),
+			ByName:  inv.ByName,
+			ByEmail: inv.ByEmail,
+			Reason:  inv.Reason,
+		}},
+	}
+	// Invalidating again replaces the link too, so a memory re-invalidated
+	// without a successor no longer points at the old one
+	var update bson.D
+	if inv.SupersededBy != nil {
+		set = append(set, bson.E{Key: "superseded_by", Value: *inv.SupersededBy})
+		update = bson.D{{Key: "$set", Value: set}}
+	} else {
+		update = bson.D{
+			{Key: "$set", Value: set},
+			{Key: "$unset", Value: bson.D{{Key: "superseded_by", Value: ""}}},
+		}
+	}
+
+	return m.inTransaction(ctx, func(ctx context.Context) error {
+		err := checkSupersede(inv, func(id int64) (*supersedeLink, error) {
+			var doc memoryDoc
+			err := m.memories.FindOne(ctx, bson.D{{Key: "_id", Value: id}},
+				options.FindOne().SetProjection(bson.D{{Key: "repo", Value: 1}, {Key: "superseded_by", Value: 1}}),
+			).Decode(&doc)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			return &supersedeLink{Repo: doc.Repo, SupersededBy: doc.SupersededBy}, nil
+		})
+		if err != nil {
+			return err
+		}
+		_, err = m.memories.UpdateOne(ctx, bson.D{{Key: "_id", Value: inv.ID}}, update)
+		return err
+	})
+}
+
+func (m *MongoDB) InvalidateMatching(ctx context.Context, bi types.BulkInvalidation) ([]int64, error) {
+	set := bson.D{
+		{Key: "is_valid", Value: false},
+		{Key: "invalidated", Value: invalidationDoc{
+			At:      time.Now().UTC(),
+			ByName:  bi.ByName,
+			ByEmail: bi.ByEmail,
+			Reason:  bi.Reason,
+		}},
+	}
+	// Like Invalidate, the link is replaced rather than kept
+	var update bson.D
+	if bi.SupersededBy != nil {
+		set = append(set, bson.E{Key: "superseded_by", Value: *bi.SupersededBy})
+		update = bson.D{{Key: "$set", Value: set}}
+	} else {
+		update = bson.D{
+			{Key: "$set", Value: set},
+			{Key: "$unset", Value: bson.D{{Key: "superseded_by", Value: ""}}},
+		}
+	}
+
+	ids := []int64{}
+	err := m.inTransaction(ctx, func(ctx context.Context) error {
+		filter := append(bson.D{{Key: "is_valid", Value: true}}, mongoMemoryFilter(bi.Filter)...)
+		cursor, err := m.memories.Find(ctx, filter, options.Find().
+			SetProjection(bson.D{{Key: "_id", Value: 1}}).
+			SetSort(bson.D{{Key: "_id", Value: 1}}))
+		if err != nil {
+			return err
+		}
+		var docs []struct {
+			ID int64 `bson:"_id"`
+		}
+		if err := cursor.All(ctx, &docs); err != nil {
+			return err
+		}
+		ids = ids[:0]
+		for _, doc := range docs {
+			ids = append(ids, doc.ID)
+		}
+
+		err = checkBulkSupersede(bi, ids, func(id int64) (*supersedeLink, error) {
+			var doc memoryDoc
+			err := m.memories.FindOne(ctx, bson.D{{Key: "_id", Value: id}},
+				options.FindOne().SetProjection(bson.D{{Key: "repo", Value: 1}, {Key: "superseded_by", Value: 1}}),
+			).Decode(&doc)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			return &supersedeLink{Repo: doc.Repo, SupersededBy: doc.SupersededBy}, nil
+		})
+		if err != nil || bi.DryRun || len(ids) == 0 {
+			return err
+		}
+		// Still valid, so without a transaction a memory invalidated since
+		// the match keeps its own details
+		_, err = m.memories.UpdateMany(ctx,
+			bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}, {Key: "is_valid", Value: true}},
+			update,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (m *MongoDB) Revalidate(ctx context.Context, rev types.Revalidation) (*types.Memory, error) {
+	now := time.Now().UTC()
+	result, err := m.memories.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: rev.ID}, {Key: "is_valid", Value: false}},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "is_valid", Value: true},
+				{Key: "restored", Value: restoreDoc{At: now, ByName: rev.ByName, ByEmail: rev.ByEmail}},
+			}},
+			{Key: "$unset", Value: bson.D{
+				{Key: "superseded_by", Value: ""},
+				{Key: "invalidated", Value: ""},
+			}},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.ModifiedCount > 0 {
+		// A passed expiry would have the next sweep invalidate the memory again
+		_, err = m.memories.UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: rev.ID}, {Key: "expires_at", Value: bson.D{{Key: "$lte", Value: now}}}},
+			bson.D{{Key: "$unset", Value: bson.D{{Key: "expires_at", Value: ""}}}},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m.Get(ctx, rev.ID)
+}
+
+func (m *MongoDB) Merge(ctx context.Context, mg types.Merge, embedding []float32) (*types.Memory, error) {
+	// The ID is taken up front so the transaction doesn't contend on the counter
+	id, err := m.nextID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc memoryDoc
+	err = m.inTransaction(ctx, func(ctx context.Context) error {
+		originatedAt, err := checkMerge(mg, func(id int64) (*mergeSource, error) {
+			var src memoryDoc
+			err := m.memories.FindOne(ctx, bson.D{{Key: "_id", Value: id}},
+				options.FindOne().SetProjection(bson.D{
+					{Key: "repo", Value: 1}, {Key: "is_valid", Value: 1},
+					{Key: "created_at", Value: 1}, {Key: "originated_at", Value: 1},
+				}),
+			).Decode(&src)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			return &mergeSource{Repo: src.Repo, IsValid: src.IsValid, CreatedAt: src.CreatedAt, OriginatedAt: src.OriginatedAt}, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		mem := mg.Memory
+		mem.OriginatedAt = &originatedAt
+		now := time.Now().UTC()
+		doc = newMemoryDoc(id, mem, embedding, now)
+		if _, err := m.memories.InsertOne(ctx, doc); err != nil {
+			return fmt.Errorf("failed to insert memory: %w", err)
+		}
+
+		// Only sources still valid are taken, so without a transaction a
+		// source invalidated since the check shows up as a short count
+		result, err := m.memories.UpdateMany(ctx,
+			bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: mg.Sources}}}, {Key: "is_valid", Value: true}},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "is_valid", Value: false},
+				{Key: "superseded_by", Value: id},
+				{Key: "invalidated", Value: invalidationDoc{
+					At:      now,
+					ByName:  mg.ByName,
+					ByEmail: mg.ByEmail,
+					Reason:  mg.Reason,
+				}},
+			}}},
+		)
+		if err == nil && result.ModifiedCount != int64(len(mg.Sources)) {
+			err = fmt.Errorf("%w: a source memory was invalidated during the merge", types.ErrInvalidSupersede)
+		}
+		if err != nil && !m.transactions {
+			m.undoMerge(ctx, id)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	merged := docToMemory(doc)
+	return &merged, nil
+}
+
+// undoMerge restores the sources a merge into id invalidated and removes
+// the merged memory, for a merge that failed part way without a transaction
+func (m *MongoDB) undoMerge(ctx context.Context, id int64) {
+	_, err := m.memories.UpdateMany(ctx,
+		bson.D{{Key: "superseded_by", Value: id}},
+		bson.D{
+			{Key: "$set", Value: bson.D{{Key: "is_valid", Value: true}}},
+			{Key: "$unset", Value: bson.D{{Key: "superseded_by", Value: ""}, {Key: "invalidated", Value: ""}}},
+		},
+	)
+	if err != nil {
+		log.Printf("Failed to restore the sources of merged memory %d: %v", id, err)
+	}
+	if _, err := m.memories.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}}); err != nil {
+		log.Printf("Failed to remove merged memory %d: %v", id, err)
+	}
+}
+
+func (m *MongoDB) Update(ctx context.Context, upd types.MemoryUpdate, embedding []float32) (*types.Memory, error) {
+	now := time.Now()
+	set := bson.D{
+		{Key: "type", Value: string(upd.Type)},
+		{Key: "area", Value: upd.Area},
+		{Key: "content", Value: upd.Content},
+		{Key: "rationale", Value: upd.Rationale},
+		{Key: "expires_at", Value: upd.ExpiresAt},
+		{Key: "review_by", Value: upd.ReviewBy},
+		{Key: "version", Value: upd.ExpectedVersion + 1},
+		{Key: "updated_at", Value: now},
+	}
+	if embedding != nil {
+		set = append(set,
+			bson.E{Key: "embedding", Value: embedding},
+			bson.E{Key: "embedding_model", Value: upd.EmbeddingModel},
+		)
+	}
+
+	// The version filter makes the write conditional, so concurrent editors
+	// cannot both replace the same version
+	versionFilter := bson.E{Key: "version", Value: upd.ExpectedVersion}
+	if upd.ExpectedVersion == 1 {
+		versionFilter = bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "version", Value: 1}},
+			bson.D{{Key: "version", Value: bson.D{{Key: "$exists", Value: false}}}},
+		}}
+	}
+	filter := bson.D{{Key: "_id", Value: upd.ID}, versionFilter}
+
+	// The revision is written before the memory moves on, and removed if the
+	// update doesn't happen, so no version is left without its history
+	var before memoryDoc
+	err := m.memories.FindOne(ctx, filter).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, m.versionConflict(ctx, upd)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memory: %w", err)
+	}
+
+	rev := revisionDoc{
+		MemoryID:  upd.ID,
+		Version:   upd.ExpectedVersion,
+		Type:      before.Type,
+		Area:      before.Area,
+		Content:   before.Content,
+		Rationale: before.Rationale,
+		RevisedAt: now,
+	}
+	rev.RevisedBy.Name = upd.EditorName
+	rev.RevisedBy.Email = upd.EditorEmail
+
+	// A concurrent editor of the same version has already claimed its
+	// revision; the unique index turns that into a conflict
+	inserted, err := m.revisions.InsertOne(ctx, rev)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, m.versionConflict(ctx, upd)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record revision: %w", err)
+	}
+
+	result, err := m.memories.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: set}})
+	if err == nil && result.MatchedCount == 0 {
+		err = m.versionConflict(ctx, upd)
+	} else if err != nil {
+		err = fmt.Errorf("failed to update memory: %w", err)
+	}
+	if err != nil {
+		if _, delErr := m.revisions.DeleteOne(ctx, bson.D{{Key: "_id", Value: inserted.InsertedID}}); delErr != nil {
+			log.Printf("Failed to remove revision of memory %d after a failed update: %v", upd.ID, delErr)
+		}
+		return nil, err
+	}
+
+	return m.Get(ctx, upd.ID)
+}
+
+// versionConflict returns the error for an update whose expected version no
+// longer matches, or types.ErrNotFound if the memory is gone
+func (m *MongoDB) versionConflict(ctx context.Context, upd types.MemoryUpdate) error {
+	current, err := m.Get(ctx, upd.ID)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: memory %d is at version %d, expected %d", types.ErrVersionConflict, upd.ID, current.Version, upd.ExpectedVersion)
+}
+
+func (m *MongoDB) History(ctx context.Context, id int64) ([]types.Revision, error) {
+	if _, err := m.Get(ctx, id); err != nil {
+		return nil, err
+	}
+
+	cursor, err := m.revisions.Find(ctx,
+		bson.D{{Key: "memory_id", Value: id}},
+		options.Find().SetSort(bson.D{{Key: "version", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var revisions []types.Revision
+	for cursor.Next(ctx) {
+		var doc revisionDoc
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, types.Revision{
+			MemoryID:       doc.MemoryID,
+			Version:        doc.Version,
+			Type:           types.MemoryType(doc.Type),
+			Area:           doc.Area,
+			Content:        doc.Content,
+			Rationale:      doc.Rationale,
+			RevisedAt:      doc.RevisedAt,
+			RevisedByName:  doc.RevisedBy.Name,
+			RevisedByEmail: doc.RevisedBy.Email,
+		})
+	}
+
+	return revisions, cursor.Err()
+}
+
+func (m *MongoDB) Tags(ctx context.Context, repo string) ([]types.TagCount, error) {
+	match := bson.D{{Key: "is_valid", Value: true}}
+	if repo != "" {
+		match = append(match, bson.E{Key: "repo", Value: repo})
+	}
+
+	cursor, err := m.memories.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$tags"},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var tags []types.TagCount
+	for cursor.Next(ctx) {
+		var doc struct {
+			Tag   string `bson:"_id"`
+			Count int    `bson:"count"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		tags = append(tags, types.TagCount{Tag: doc.Tag, Count: doc.Count})
+	}
+	return tags, cursor.Err()
+}
+
+func (m *MongoDB) Catalog(ctx context.Context, field types.CatalogField, repo string) ([]types.CatalogEntry, error) {
+	sf, err := catalogField(field)
+	if err != nil {
+		return nil, err
+	}
+
+	match := bson.D{{Key: "is_valid", Value: true}}
+	if repo != "" {
+		match = append(match, bson.E{Key: "repo", Value: repo})
+	}
+
+	cursor, err := m.memories.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: mongoStatsKeys[sf]},
+			{Key: "name", Value: bson.D{{Key: "$max", Value: "$author.name"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "last", Value: bson.D{{Key: "$max", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$updated_at", "$created_at"}}}}}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []types.CatalogEntry
+	for cursor.Next(ctx) {
+		var doc struct {
+			Value string    `bson:"_id"`
+			Name  string    `bson:"name"`
+			Count int       `bson:"count"`
+			Last  time.Time `bson:"last"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		entries = append(entries, types.CatalogEntry{Value: doc.Value, Name: doc.Name, Count: doc.Count, LastActivity: doc.Last})
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return finishCatalog(field, entries), nil
+}
+
+// mongoStatsKeys are the expressions Stats groups by
+var mongoStatsKeys = map[statsField]interface{}{
+	statsRepo: "$repo",
+	statsArea: "$area",
+	statsType: "$type",
+	statsAuthor: bson.D{{Key: "$cond", Value: bson.A{
+		bson.D{{Key: "$gt", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$author.email", ""}}}, ""}}},
+		"$author.email",
+		bson.D{{Key: "$ifNull", Value: bson.A{"$author.name", ""}}},
+	}}},
+}
+
+func (m *MongoDB) Stats(ctx context.Context, opts types.StatsOpts) (*types.Stats, error) {
+	match := bson.D{}
+	if opts.Repo != "" {
+		match = append(match, bson.E{Key: "repo", Value: opts.Repo})
+	}
+
+	group := func(field statsField) ([]types.StatCount, error) {
+		cursor, err := m.memories.Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: match}},
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: mongoStatsKeys[field]},
+				{Key: "valid", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{"$is_valid", 1, 0}}}}}},
+				{Key: "invalid", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{"$is_valid", 0, 1}}}}}},
+			}}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer cursor.Close(ctx)
+
+		var counts []types.StatCount
+		for cursor.Next(ctx) {
+			var doc struct {
+				Value   string `bson:"_id"`
+				Valid   int    `bson:"valid"`
+				Invalid int    `bson:"invalid"`
+			}
+			if err := cursor.Decode(&doc); err != nil {
+				return nil, err
+			}
+			counts = append(counts, types.StatCount{Value: doc.Value, Valid: doc.Valid, Invalid: doc.Invalid})
+		}
+		return counts, cursor.Err()
+	}
+
+	days := func() ([]dayCount, error) {
+		cursor, err := m.memories.Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: match}},
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: bson.D{{Key: "$dateToString", Value: bson.D{
+					{Key: "format", Value: "%Y-%m-%d"},
+					{Key: "date", Value: "$created_at"},
+				}}}},
+				{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			}}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer cursor.Close(ctx)
+
+		var out []dayCount
+		for cursor.Next(ctx) {
+			var doc struct {
+				Day   string `bson:"_id"`
+				Count int    `bson:"count"`
+			}
+			if err := cursor.Decode(&doc); err != nil {
+				return nil, err
+			}
+			day, err := time.Parse(time.DateOnly, doc.Day)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, dayCount{Day: day, Count: doc.Count})
+		}
+		return out, cursor.Err()
+	}
+
+	return collectStats(opts, group, days)
+}
+
+func (m *MongoDB) Expire(ctx context.Context, now time.Time) (int64, error) {
+	result, err := m.memories.UpdateMany(ctx,
+		bson.D{
+			{Key: "is_valid", Value: true},
+			{Key: "expires_at", Value: bson.D{{Key: "$lte", Value: now}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{
 			{Key: "is_valid", Value: false},
-			{Key: "superseded_by", Value: *supersededBy},
-		}}}
+			{Key: "invalidated", Value: invalidationDoc{At: now, Reason: types.ExpiredReason}},
+		}}},
+	)
+	if err != nil {
+		return 0, err
 	}
+	return result.ModifiedCount, nil
+}
 
-	result, err := m.memories.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update)
+func (m *MongoDB) DueForReview(ctx context.Context, repo string, now time.Time, limit int) ([]types.Memory, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	filter := bson.D{
+		{Key: "is_valid", Value: true},
+		{Key: "review_by", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	if repo != "" {
+		filter = append(filter, bson.E{Key: "repo", Value: repo})
+	}
+
+	cursor, err := m.memories.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "review_by", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit)))
 	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	return m.cursorToMemories(ctx, cursor)
+}
+
+func (m *MongoDB) RecordRetrievals(ctx context.Context, counts map[int64]int, at time.Time) error {
+	if err := markUsageTracked(ctx, m, at); err != nil {
 		return err
 	}
+	if len(counts) == 0 {
+		return nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(counts))
+	for id, n := range counts {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{Key: "_id", Value: id}}).
+			SetUpdate(bson.D{
+				{Key: "$inc", Value: bson.D{{Key: "retrieval_count", Value: n}}},
+				{Key: "$max", Value: bson.D{{Key: "last_retrieved_at", Value: at}}},
+			}))
+	}
+	_, err := m.memories.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (m *MongoDB) Unretrieved(ctx context.Context, repo string, before time.Time, limit int) ([]types.Memory, error) {
+	// A memory created before tracking started may have been used while no
+	// one was counting, so its age only counts from the start of tracking
+	if tracked, err := usageTrackedBefore(ctx, m, before); err != nil || !tracked {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 10
+	}
 
-	if result.MatchedCount == 0 {
-		return fmt.Errorf("memory with id %d not found", id)
+	// retrieval_count is omitted until the first retrieval
+	filter := bson.D{
+		{Key: "is_valid", Value: true},
+		{Key: "retrieval_count", Value: bson.D{{Key: "$in", Value: bson.A{nil, 0}}}},
+		{Key: "created_at", Value: bson.D{{Key: "$lt", Value: before}}},
+	}
+	if repo != "" {
+		filter = append(filter, bson.E{Key: "repo", Value: repo})
 	}
 
-	return nil
+	cursor, err := m.memories.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	return m.cursorToMemories(ctx, cursor)
+}
+
+func (m *MongoDB) Lineage(ctx context.Context, id int64) ([]types.Memory, error) {
+	mem, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[int64]bool{id: true}
+	memories := []types.Memory{*mem}
+
+	// Successors form a chain; a dangling or already seen link ends it
+	for next := mem.SupersededBy; next != nil && !seen[*next]; {
+		succ, err := m.Get(ctx, *next)
+		if errors.Is(err, types.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		seen[succ.ID] = true
+		memories = append(memories, *succ)
+		next = succ.SupersededBy
+	}
+
+	// Predecessors form a tree, fetched a level at a time
+	for frontier := []int64{id}; len(frontier) > 0; {
+		cursor, err := m.memories.Find(ctx, bson.D{{Key: "superseded_by", Value: bson.D{{Key: "$in", Value: frontier}}}})
+		if err != nil {
+			return nil, err
+		}
+		level, err := m.cursorToMemories(ctx, cursor)
+		cursor.Close(ctx)
+		if err != nil {
+			return nil, err
+		}
+		frontier = nil
+		for _, pred := range level {
+			if !seen[pred.ID] {
+				seen[pred.ID] = true
+				memories = append(memories, pred)
+				frontier = append(frontier, pred.ID)
+			}
+		}
+	}
+
+	sort.Slice(memories, func(i, j int) bool { return memories[i].ID < memories[j].ID })
+	return memories, nil
 }
 
 func (m *MongoDB) cursorToMemoriesWithScore(ctx context.Context, cursor *mongo.Cursor) ([]types.Memory, error) {
@@ -274,20 +1152,9 @@ func (m *MongoDB) cursorToMemoriesWithScore(ctx context.Context, cursor *mongo.C
 			return nil, err
 		}
 
-		memories = append(memories, types.Memory{
-			ID:              doc.ID,
-			Type:            types.MemoryType(doc.Type),
-			Area:            doc.Area,
-			Content:         doc.Content,
-			Rationale:       doc.Rationale,
-			IsValid:         doc.IsValid,
-			SupersededBy:    doc.SupersededBy,
-			CreatedAt:       doc.CreatedAt,
-			AuthorName:      doc.Author.Name,
-			AuthorEmail:     doc.Author.Email,
-			Repo:            doc.Repo,
-			SimilarityScore: doc.SimilarityScore,
-		})
+		mem := docToMemory(doc)
+		mem.SimilarityScore = doc.SimilarityScore
+		memories = append(memories, mem)
 	}
 
 	return memories, cursor.Err()
@@ -301,20 +1168,130 @@ func (m *MongoDB) cursorToMemories(ctx context.Context, cursor *mongo.Cursor) ([
 			return nil, err
 		}
 
-		memories = append(memories, types.Memory{
-			ID:           doc.ID,
-			Type:         types.MemoryType(doc.Type),
-			Area:         doc.Area,
-			Content:      doc.Content,
-			Rationale:    doc.Rationale,
-			IsValid:      doc.IsValid,
-			SupersededBy: doc.SupersededBy,
-			CreatedAt:    doc.CreatedAt,
-			AuthorName:   doc.Author.Name,
-			AuthorEmail:  doc.Author.Email,
-			Repo:         doc.Repo,
-		})
+		memories = append(memories, docToMemory(doc))
 	}
 
 	return memories, cursor.Err()
 }
+
+// docToMemory converts a stored document to a Memory (without similarity score)
+func docToMemory(doc memoryDoc) types.Memory {
+	version := doc.Version
+	if version == 0 {
+		version = 1
+	}
+
+	mem := types.Memory{
+		ID:              doc.ID,
+		Type:            types.MemoryType(doc.Type),
+		Area:            doc.Area,
+		Content:         doc.Content,
+		Rationale:       doc.Rationale,
+		IsValid:         doc.IsValid,
+		SupersededBy:    doc.SupersededBy,
+		CreatedAt:       doc.CreatedAt,
+		Version:         version,
+		UpdatedAt:       doc.UpdatedAt,
+		EmbeddingModel:  doc.EmbeddingModel,
+		AuthorName:      doc.Author.Name,
+		AuthorEmail:     doc.Author.Email,
+		Repo:            doc.Repo,
+		Tags:            doc.Tags,
+		ExpiresAt:       doc.ExpiresAt,
+		ReviewBy:        doc.ReviewBy,
+		OriginatedAt:    doc.OriginatedAt,
+		RetrievalCount:  doc.RetrievalCount,
+		LastRetrievedAt: doc.LastRetrievedAt,
+	}
+	if inv := doc.Invalidated; inv != nil {
+		at := inv.At
+		mem.InvalidatedAt = &at
+		mem.InvalidatedByName = inv.ByName
+		mem.InvalidatedByEmail = inv.ByEmail
+		mem.InvalidationReason = inv.Reason
+	}
+	if rst := doc.Restored; rst != nil {
+		at := rst.At
+		mem.RestoredAt = &at
+		mem.RestoredByName = rst.ByName
+		mem.RestoredByEmail = rst.ByEmail
+	}
+	return mem
+}
+
+// mongoMemoryFilter returns the filter elements for a memory filter
+func mongoMemoryFilter(f types.MemoryFilter) bson.D {
+	var filter bson.D
+	if f.Repo != "" {
+		filter = append(filter, bson.E{Key: "repo", Value: f.Repo})
+	}
+	if f.Area != "" {
+		filter = append(filter, bson.E{Key: "area", Value: f.Area})
+	}
+	if f.Type != "" {
+		filter = append(filter, bson.E{Key: "type", Value: string(f.Type)})
+	}
+	if f.Author != "" {
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "author.name", Value: f.Author}},
+			bson.D{{Key: "author.email", Value: f.Author}},
+		}})
+	}
+	if f.CreatedBefore != nil {
+		filter = append(filter, bson.E{Key: "created_at", Value: bson.D{{Key: "$lt", Value: *f.CreatedBefore}}})
+	}
+	return filter
+}
+
+// mongoTagFilter returns the filter elements for a tag filter. All is an
+// $and of equality matches rather than $all, which Atlas vector search
+// filters don't support.
+func mongoTagFilter(f types.TagFilter) bson.D {
+	var filter bson.D
+	if len(f.Any) > 0 {
+		filter = append(filter, bson.E{Key: "tags", Value: bson.D{{Key: "$in", Value: f.Any}}})
+	}
+	if len(f.All) > 0 {
+		all := make(bson.A, len(f.All))
+		for i, tag := range f.All {
+			all[i] = bson.D{{Key: "tags", Value: tag}}
+		}
+		filter = append(filter, bson.E{Key: "$and", Value: all})
+	}
+	return filter
+}
+
+func (m *MongoDB) getMetadata(ctx context.Context, key string) (string, error) {
+	var doc struct {
+		Value string `bson:"value"`
+	}
+	err := m.metadata.FindOne(ctx, bson.D{{Key: "_id", Value: key}}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", nil
+	}
+	return doc.Value, err
+}
+
+func (m *MongoDB) setMetadata(ctx context.Context, key, value string) error {
+	_, err := m.metadata.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: key}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "value", Value: value}}}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// vectorDimension samples one stored embedding; collections have no declared size
+func (m *MongoDB) vectorDimension(ctx context.Context) (int, error) {
+	var doc struct {
+		Embedding []float32 `bson:"embedding"`
+	}
+	err := m.memories.FindOne(ctx,
+		bson.D{{Key: "embedding.0", Value: bson.D{{Key: "$exists", Value: true}}}},
+		options.FindOne().SetProjection(bson.D{{Key: "embedding", Value: 1}}),
+	).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return len(doc.Embedding), err
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (m *MongoDB) ExportMemories(ctx context.Context, afterID int64, limit int) ([]ExportedMemory, error) {
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := m.memories.Find(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: afterID}}}}, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var memories []ExportedMemory
+	for cursor.Next(ctx) {
+		var doc memoryDoc
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		memories = append(memories, ExportedMemory{Memory: docToMemory(doc), Embedding: doc.Embedding})
+	}
+	return memories, cursor.Err()
+}
+
+// ImportMemory relies on the unique imported_from index rather than a
+// transaction: a repeated import loses the insert race and returns the winner
+func (m *MongoDB) ImportMemory(ctx context.Context, source string, mem ExportedMemory) (int64, error) {
+	if id, err := m.importedID(ctx, source, mem.ID); err != nil || id != 0 {
+		return id, err
+	}
+
+	id, err := m.nextID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	doc := memoryDoc{
+		ID:              id,
+		Type:            string(mem.Type),
+		Area:            mem.Area,
+		Content:         mem.Content,
+		Rationale:       mem.Rationale,
+		IsValid:         mem.IsValid,
+		CreatedAt:       mem.CreatedAt,
+		Version:         mem.Version,
+		UpdatedAt:       mem.UpdatedAt,
+		EmbeddingModel:  mem.EmbeddingModel,
+		Repo:            mem.Repo,
+		Tags:            uniqueTags(mem.Tags),
+		ExpiresAt:       mem.ExpiresAt,
+		ReviewBy:        mem.ReviewBy,
+		OriginatedAt:    mem.OriginatedAt,
+		RetrievalCount:  mem.RetrievalCount,
+		LastRetrievedAt: mem.LastRetrievedAt,
+		Embedding:       mem.Embedding,
+		ImportedFrom:    &importRef{Source: source, ID: mem.ID},
+	}
+	doc.Author.Name = mem.AuthorName
+	doc.Author.Email = mem.AuthorEmail
+	if mem.InvalidatedAt != nil {
+		doc.Invalidated = &invalidationDoc{
+			At:      *mem.InvalidatedAt,
+			ByName:  mem.InvalidatedByName,
+			ByEmail: mem.InvalidatedByEmail,
+			Reason:  mem.InvalidationReason,
+		}
+	}
+	if mem.RestoredAt != nil {
+		doc.Restored = &restoreDoc{
+			At:      *mem.RestoredAt,
+			ByName:  mem.RestoredByName,
+			ByEmail: mem.RestoredByEmail,
+		}
+	}
+
+	_, err = m.memories.InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return m.importedID(ctx, source, mem.ID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert memory: %w", err)
+	}
+	return id, nil
+}
+
+// importedID returns the local ID of a memory imported from source, or 0
+func (m *MongoDB) importedID(ctx context.Context, source string, sourceID int64) (int64, error) {
+	var doc struct {
+		ID int64 `bson:"_id"`
+	}
+	err := m.memories.FindOne(ctx,
+		bson.D{{Key: "imported_from.source", Value: source}, {Key: "imported_from.id", Value: sourceID}},
+		options.FindOne().SetProjection(bson.D{{Key: "_id", Value: 1}}),
+	).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return doc.ID, err
+}
+
+func (m *MongoDB) ImportedIDs(ctx context.Context, source string) (map[int64]int64, error) {
+	cursor, err := m.memories.Find(ctx,
+		bson.D{{Key: "imported_from.source", Value: source}},
+		options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}, {Key: "imported_from", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	ids := make(map[int64]int64)
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID           int64     `bson:"_id"`
+			ImportedFrom importRef `bson:"imported_from"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		ids[doc.ImportedFrom.ID] = doc.ID
+	}
+	return ids, cursor.Err()
+}
+
+func (m *MongoDB) SetSupersededBy(ctx context.Context, id, supersededBy int64) error {
+	_, err := m.memories.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "superseded_by", Value: supersededBy}}}},
+	)
+	return err
+}
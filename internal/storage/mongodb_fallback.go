@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"math"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// DefaultMongoFallbackCandidates is how many memories the in-process search
+// fallback scores when no cap is configured
+const DefaultMongoFallbackCandidates = 2000
+
+// Fallback counters, published under "mongodb_search_fallback" in expvar:
+// "searches" counts searches served in-process, "cap_hits" those where more
+// memories matched the filters than the candidate cap allowed scoring
+var (
+	mongoFallbackStats    = expvar.NewMap("mongodb_search_fallback")
+	mongoFallbackSearches = new(expvar.Int)
+	mongoFallbackCapHits  = new(expvar.Int)
+)
+
+func init() {
+	mongoFallbackStats.Set("searches", mongoFallbackSearches)
+	mongoFallbackStats.Set("cap_hits", mongoFallbackCapHits)
+}
+
+// vectorFallback ranks the newest matching memories by cosine similarity in
+// Go, for deployments without Atlas Vector Search. Only the newest
+// fallbackCandidates memories are considered; older ones can't be found.
+func (m *MongoDB) vectorFallback(ctx context.Context, embedding []float32, filter bson.D, limit int) ([]types.Memory, error) {
+	mongoFallbackSearches.Add(1)
+
+	candidates := m.fallbackCandidates
+	if candidates <= 0 {
+		candidates = DefaultMongoFallbackCandidates
+	}
+
+	// One extra document tells us whether the cap cut anything off
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(int64(candidates + 1))
+
+	cursor, err := m.memories.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []memoryDoc
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	if len(docs) > candidates {
+		mongoFallbackCapHits.Add(1)
+		m.warnCapHit.Do(func() {
+			log.Printf("WARNING: in-process vector search scored only the newest %d matching memories; raise --mongodb-fallback-candidates or create the Atlas Vector Search index (further cap hits are counted in the mongodb_search_fallback expvar)", candidates)
+		})
+		docs = docs[:candidates]
+	}
+
+	return rankByCosine(docs, embedding, limit), nil
+}
+
+// rankByCosine scores docs against query and returns the best limit of them.
+// Documents whose vector doesn't match the query's dimension are skipped.
+func rankByCosine(docs []memoryDoc, query []float32, limit int) []types.Memory {
+	memories := make([]types.Memory, 0, len(docs))
+	for _, doc := range docs {
+		if len(doc.Embedding) != len(query) {
+			continue
+		}
+		mem := docToMemory(doc)
+		mem.SimilarityScore = cosineSimilarity(query, doc.Embedding)
+		memories = append(memories, mem)
+	}
+
+	sort.SliceStable(memories, func(i, j int) bool {
+		return memories[i].SimilarityScore > memories[j].SimilarityScore
+	})
+	if len(memories) > limit {
+		memories = memories[:limit]
+	}
+	return memories
+}
+
+// cosineSimilarity returns 1 - cosine distance, matching the SQL backends'
+// scores. A zero vector has no direction and scores 0.
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
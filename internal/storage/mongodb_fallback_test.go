@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float64
+	}{
+		{"identical", []float32{1, 2, 3}, []float32{1, 2, 3}, 1},
+		{"scaled", []float32{1, 0}, []float32{5, 0}, 1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 1}, 0},
+		{"opposite", []float32{1, 0}, []float32{-1, 0}, -1},
+		{"zero vector", []float32{0, 0}, []float32{1, 0}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cosineSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("cosineSimilarity(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankByCosine(t *testing.T) {
+	docs := []memoryDoc{
+		{ID: 1, Content: "far", Embedding: []float32{0, 1}},
+		{ID: 2, Content: "exact", Embedding: []float32{1, 0}},
+		{ID: 3, Content: "close", Embedding: []float32{0.9, 0.1}},
+		{ID: 4, Content: "other model", Embedding: []float32{1, 0, 0}},
+	}
+
+	results := rankByCosine(docs, []float32{1, 0}, 2)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].ID != 2 || results[1].ID != 3 {
+		t.Errorf("expected IDs [2 3] by similarity, got [%d %d]", results[0].ID, results[1].ID)
+	}
+	if results[0].SimilarityScore < 0.999 || results[1].SimilarityScore >= results[0].SimilarityScore {
+		t.Errorf("expected descending scores starting at 1, got %v, %v",
+			results[0].SimilarityScore, results[1].SimilarityScore)
+	}
+
+	// A vector of the wrong dimension is never returned
+	for _, m := range rankByCosine(docs, []float32{1, 0}, 10) {
+		if m.ID == 4 {
+			t.Error("expected memory with mismatched dimension to be skipped")
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoMigration is a numbered schema change. MongoDB has no transactional
+// DDL, so every up func must be idempotent: a migration that fails (or races
+// another replica) is simply re-run on the next start.
+type mongoMigration struct {
+	Migration
+	up func(ctx context.Context, m *MongoDB) error
+}
+
+// mongoMigrations lists every schema change in order. Append new migrations;
+// never edit or renumber one that has shipped.
+var mongoMigrations = []mongoMigration{
+	{Migration{1, "create memory indexes"}, mongoCreateIndexes},
+	{Migration{2, "add memory revisions index"}, mongoAddRevisions},
+	{Migration{3, "create keyword search index"}, mongoCreateTextIndex},
+	{Migration{4, "track imported memories"}, mongoCreateImportIndex},
+	{Migration{5, "index memory tags"}, mongoCreateTagIndex},
+	{Migration{6, "index expiry and review dates"}, mongoCreateExpiryIndexes},
+}
+
+// migrationDoc records an applied migration in schema_migrations
+type migrationDoc struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+func mongoCreateIndexes(ctx context.Context, m *MongoDB) error {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "type", Value: 1}}},
+		{Keys: bson.D{{Key: "area", Value: 1}}},
+		{Keys: bson.D{{Key: "is_valid", Value: 1}}},
+		{Keys: bson.D{{Key: "repo", Value: 1}}},
+		{Keys: bson.D{{Key: "author.email", Value: 1}}},
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+	}
+	_, err := m.memories.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+func mongoAddRevisions(ctx context.Context, m *MongoDB) error {
+	_, err := m.revisions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "memory_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// mongoCreateTextIndex creates the text index KeywordSearch queries. A
+// collection can have only one, so it covers every searchable field.
+func mongoCreateTextIndex(ctx context.Context, m *MongoDB) error {
+	_, err := m.memories.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "area", Value: "text"},
+			{Key: "content", Value: "text"},
+			{Key: "rationale", Value: "text"},
+		},
+		Options: options.Index().
+			SetName("memories_text").
+			SetWeights(bson.D{{Key: "area", Value: 3}, {Key: "content", Value: 2}, {Key: "rationale", Value: 1}}),
+	})
+	return err
+}
+
+// mongoCreateImportIndex makes each source memory importable only once
+func mongoCreateImportIndex(ctx context.Context, m *MongoDB) error {
+	_, err := m.memories.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "imported_from.source", Value: 1}, {Key: "imported_from.id", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.D{{Key: "imported_from", Value: bson.D{{Key: "$exists", Value: true}}}}),
+	})
+	return err
+}
+
+// mongoCreateTagIndex indexes the tags array (a multikey index) for tag filters
+func mongoCreateTagIndex(ctx context.Context, m *MongoDB) error {
+	_, err := m.memories.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "tags", Value: 1}},
+	})
+	return err
+}
+
+// mongoCreateExpiryIndexes indexes the dates the sweeper and review list
+// query. They are sparse: most memories have neither.
+func mongoCreateExpiryIndexes(ctx context.Context, m *MongoDB) error {
+	_, err := m.memories.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "review_by", Value: 1}}, Options: options.Index().SetSparse(true)},
+	})
+	return err
+}
+
+// Migrate applies pending migrations, recording each once it succeeds
+func (m *MongoDB) Migrate(ctx context.Context) error {
+	status, err := m.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkSchemaVersion(status); err != nil {
+		return err
+	}
+
+	pending := make(map[int]bool, len(status.Pending))
+	for _, mig := range status.Pending {
+		pending[mig.Version] = true
+	}
+
+	for _, mig := range mongoMigrations {
+		if !pending[mig.Version] {
+			continue
+		}
+		if err := mig.up(ctx, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", mig.Version, mig.Description, err)
+		}
+		_, err := m.migrations.InsertOne(ctx, migrationDoc{
+			Version:     mig.Version,
+			Description: mig.Description,
+			AppliedAt:   time.Now(),
+		})
+		// A concurrent replica recording the same version first is fine
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("failed to record migration %d: %w", mig.Version, err)
+		}
+	}
+	return nil
+}
+
+// MigrationStatus reports applied and pending migrations
+func (m *MongoDB) MigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	known := make([]Migration, len(mongoMigrations))
+	for i, mig := range mongoMigrations {
+		known[i] = mig.Migration
+	}
+
+	cursor, err := m.migrations.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var applied []AppliedMigration
+	for cursor.Next(ctx) {
+		var doc migrationDoc
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		applied = append(applied, AppliedMigration{
+			Version:     doc.Version,
+			Description: doc.Description,
+			AppliedAt:   doc.AppliedAt,
+		})
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return buildMigrationStatus(known, applied), nil
+}
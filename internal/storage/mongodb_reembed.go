@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// Staged vectors are kept on each document under "reembed" as
+// {version, embedding} until CommitReembed moves them into "embedding".
+
+// mongoStagingPending matches documents with no staged vector for their current version
+var mongoStagingPending = bson.D{{Key: "$or", Value: bson.A{
+	bson.D{{Key: "reembed", Value: bson.D{{Key: "$exists", Value: false}}}},
+	bson.D{{Key: "$expr", Value: bson.D{{Key: "$ne", Value: bson.A{
+		"$reembed.version",
+		bson.D{{Key: "$ifNull", Value: bson.A{"$version", 1}}},
+	}}}}},
+}}}
+
+// checkReembedSupported refuses a re-embed on a standalone mongod, whose
+// CommitReembed would otherwise fail only after every memory was embedded
+func (m *MongoDB) checkReembedSupported() error {
+	if !m.transactions {
+		return fmt.Errorf("%w: MongoDB swaps vectors in a transaction, which needs a replica set (a single-node one will do)", ErrReembedUnsupported)
+	}
+	return nil
+}
+
+func (m *MongoDB) PrepareReembed(ctx context.Context, target EmbeddingSpec) error {
+	if err := m.checkReembedSupported(); err != nil {
+		return err
+	}
+	current, err := readReembedTarget(ctx, m)
+	if err != nil {
+		return err
+	}
+	if current == target {
+		return nil
+	}
+
+	_, err = m.memories.UpdateMany(ctx,
+		bson.D{{Key: "reembed", Value: bson.D{{Key: "$exists", Value: true}}}},
+		bson.D{{Key: "$unset", Value: bson.D{{Key: "reembed", Value: ""}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to clear staged vectors: %w", err)
+	}
+	if err := m.setMetadata(ctx, metaReembedModel, target.Model); err != nil {
+		return err
+	}
+	return m.setMetadata(ctx, metaReembedDim, strconv.Itoa(target.Dimension))
+}
+
+func (m *MongoDB) PendingReembed(ctx context.Context, limit int) ([]types.Memory, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.D{{Key: "embedding", Value: 0}, {Key: "reembed", Value: 0}})
+
+	cursor, err := m.memories.Find(ctx, mongoStagingPending, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	return m.cursorToMemories(ctx, cursor)
+}
+
+func (m *MongoDB) StageEmbedding(ctx context.Context, id int64, version int, embedding []float32) error {
+	_, err := m.memories.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "reembed", Value: bson.D{
+			{Key: "version", Value: version},
+			{Key: "embedding", Value: embedding},
+		}}}}},
+	)
+	return err
+}
+
+func (m *MongoDB) ReembedProgress(ctx context.Context, target EmbeddingSpec) (int, int, error) {
+	if err := m.checkReembedSupported(); err != nil {
+		return 0, 0, err
+	}
+	total, err := m.memories.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	current, err := readReembedTarget(ctx, m)
+	if err != nil {
+		return 0, 0, err
+	}
+	if current != target {
+		return 0, int(total), nil
+	}
+
+	pending, err := m.memories.CountDocuments(ctx, mongoStagingPending)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(total - pending), int(total), nil
+}
+
+// CommitReembed moves staged vectors into place inside a transaction, which
+// requires a replica set (as Atlas Vector Search does); on a standalone mongod
+// every Reembedder method returns ErrReembedUnsupported. If the dimension
+// changed, the Atlas "embedding_index" must be recreated with the new
+// numDimensions before search works again.
+func (m *MongoDB) CommitReembed(ctx context.Context) error {
+	if err := m.checkReembedSupported(); err != nil {
+		return err
+	}
+	target, err := readReembedTarget(ctx, m)
+	if err != nil {
+		return err
+	}
+	if target.Model == "" {
+		return fmt.Errorf("no re-embed in progress")
+	}
+
+	session, err := m.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		pending, err := m.memories.CountDocuments(sc, mongoStagingPending)
+		if err != nil {
+			return nil, err
+		}
+		if pending > 0 {
+			return nil, fmt.Errorf("%w: %d memories still need a new vector", ErrReembedIncomplete, pending)
+		}
+
+		_, err = m.memories.UpdateMany(sc, bson.D{}, mongo.Pipeline{
+			{{Key: "$set", Value: bson.D{
+				{Key: "embedding", Value: "$reembed.embedding"},
+				{Key: "embedding_model", Value: target.Model},
+			}}},
+			{{Key: "$unset", Value: "reembed"}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to swap embeddings: %w", err)
+		}
+
+		if err := m.setMetadata(sc, metaEmbeddingModel, target.Model); err != nil {
+			return nil, err
+		}
+		if err := m.setMetadata(sc, metaEmbeddingDim, strconv.Itoa(target.Dimension)); err != nil {
+			return nil, err
+		}
+		_, err = m.metadata.DeleteMany(sc, bson.D{{Key: "_id", Value: bson.D{
+			{Key: "$in", Value: bson.A{metaReembedModel, metaReembedDim}},
+		}}})
+		return nil, err
+	})
+	return err
+}
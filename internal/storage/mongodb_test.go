@@ -2,6 +2,7 @@ package storage_test
 
 import (
 	"context"
+	"errors"
 	"os"
 	"testing"
 
@@ -32,6 +33,10 @@ func cleanupMongoDB(t *testing.T, uri, database string) {
 	if err != nil {
 		t.Fatalf("failed to cleanup counters: %v", err)
 	}
+	_, err = db.Collection("memory_revisions").DeleteMany(ctx, bson.D{})
+	if err != nil {
+		t.Fatalf("failed to cleanup revisions: %v", err)
+	}
 }
 
 func TestMongoDBStorage_Add(t *testing.T) {
@@ -113,6 +118,52 @@ func TestMongoDBStorage_Search(t *testing.T) {
 	}
 }
 
+// Without an Atlas index, Search ranks candidates in-process and still scores them
+func TestMongoDBStorage_SearchRanksBySimilarity(t *testing.T) {
+	uri := os.Getenv("TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGODB_URI not set, skipping MongoDB tests")
+	}
+	cleanupMongoDB(t, uri, "engram_test")
+
+	ctx := context.Background()
+	store, err := storage.NewMongoDB(ctx, uri, "engram_test")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	for i, content := range []string{"unrelated", "target", "nearby"} {
+		embedding := make([]float32, 768)
+		switch content {
+		case "unrelated":
+			embedding[1] = 1
+		case "target":
+			embedding[0] = 1
+		case "nearby":
+			embedding[0], embedding[1] = 0.8, 0.2
+		}
+		mem := types.Memory{Type: types.TypeLearning, Area: "api", Content: content, Repo: "testorg/testrepo"}
+		if _, err := store.Add(ctx, mem, embedding); err != nil {
+			t.Fatalf("Add %d failed: %v", i, err)
+		}
+	}
+
+	query := make([]float32, 768)
+	query[0] = 1
+	results, err := store.Search(ctx, query, types.SearchOpts{Limit: 2})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Content != "target" || results[1].Content != "nearby" {
+		t.Fatalf("expected [target nearby], got %+v", results)
+	}
+	if results[0].SimilarityScore <= results[1].SimilarityScore || results[1].SimilarityScore <= 0 {
+		t.Errorf("expected descending positive scores, got %v, %v",
+			results[0].SimilarityScore, results[1].SimilarityScore)
+	}
+}
+
 func TestMongoDBStorage_List(t *testing.T) {
 	uri := os.Getenv("TEST_MONGODB_URI")
 	if uri == "" {
@@ -151,3 +202,28 @@ func TestMongoDBStorage_List(t *testing.T) {
 		t.Error("expected at least 1 result")
 	}
 }
+
+func TestMongoDBStorage_ReembedRefusedStandalone(t *testing.T) {
+	uri := os.Getenv("TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGODB_URI not set, skipping MongoDB tests")
+	}
+	cleanupMongoDB(t, uri, "engram_test")
+
+	ctx := context.Background()
+	store, err := storage.NewMongoDB(ctx, uri, "engram_test")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+	storage.DisableMongoTransactions(store)
+
+	// Both the dry run and the real run ask for progress before staging
+	target := storage.EmbeddingSpec{Model: "all-minilm", Dimension: 384}
+	if _, _, err := store.ReembedProgress(ctx, target); !errors.Is(err, storage.ErrReembedUnsupported) {
+		t.Errorf("ReembedProgress: expected ErrReembedUnsupported, got %v", err)
+	}
+	if err := store.PrepareReembed(ctx, target); !errors.Is(err, storage.ErrReembedUnsupported) {
+		t.Errorf("PrepareReembed: expected ErrReembedUnsupported, got %v", err)
+	}
+}
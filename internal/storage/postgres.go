@@ -2,9 +2,12 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pgvector/pgvector-go"
@@ -15,10 +18,24 @@ import (
 // Postgres implements Storage using PostgreSQL with pgvector
 type Postgres struct {
 	pool *pgxpool.Pool
+	dim  int // embedding dimension used when creating the vector table
 }
 
-// NewPostgres creates a new Postgres storage
+// NewPostgres creates a new Postgres storage, applying any pending migrations
 func NewPostgres(ctx context.Context, dsn string) (*Postgres, error) {
+	p, err := openPostgres(ctx, dsn, DefaultEmbeddingDim)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Migrate(ctx); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	return p, nil
+}
+
+// openPostgres connects without touching the schema
+func openPostgres(ctx context.Context, dsn string, dim int) (*Postgres, error) {
 	pool, err := pgxpool.New(ctx, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
@@ -30,49 +47,7 @@ func NewPostgres(ctx context.Context, dsn string) (*Postgres, error) {
 		return nil, fmt.Errorf("failed to ping postgres: %w", err)
 	}
 
-	p := &Postgres{pool: pool}
-	if err := p.initSchema(ctx); err != nil {
-		pool.Close()
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
-	}
-
-	return p, nil
-}
-
-func (p *Postgres) initSchema(ctx context.Context) error {
-	schema := `
-		CREATE EXTENSION IF NOT EXISTS vector;
-
-		CREATE TABLE IF NOT EXISTS memories (
-			id SERIAL PRIMARY KEY,
-			type TEXT NOT NULL CHECK(type IN ('decision', 'learning', 'pattern')),
-			area TEXT NOT NULL,
-			content TEXT NOT NULL,
-			rationale TEXT,
-			is_valid BOOLEAN NOT NULL DEFAULT TRUE,
-			superseded_by INTEGER REFERENCES memories(id),
-			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-			author_name TEXT NOT NULL DEFAULT '',
-			author_email TEXT NOT NULL DEFAULT '',
-			repo TEXT NOT NULL DEFAULT ''
-		);
-
-		CREATE TABLE IF NOT EXISTS memory_embeddings (
-			memory_id INTEGER PRIMARY KEY REFERENCES memories(id) ON DELETE CASCADE,
-			embedding vector(768)
-		);
-
-		CREATE INDEX IF NOT EXISTS idx_memories_type ON memories(type);
-		CREATE INDEX IF NOT EXISTS idx_memories_area ON memories(area);
-		CREATE INDEX IF NOT EXISTS idx_memories_is_valid ON memories(is_valid);
-		CREATE INDEX IF NOT EXISTS idx_memories_repo ON memories(repo);
-		CREATE INDEX IF NOT EXISTS idx_memories_author ON memories(author_email);
-
-		CREATE INDEX IF NOT EXISTS idx_embeddings_vector
-		ON memory_embeddings USING hnsw (embedding vector_cosine_ops);
-	`
-	_, err := p.pool.Exec(ctx, schema)
-	return err
+	return &Postgres{pool: pool, dim: dim}, nil
 }
 
 func (p *Postgres) Close() error {
@@ -87,44 +62,142 @@ func (p *Postgres) Add(ctx context.Context, mem types.Memory, embedding []float3
 	}
 	defer tx.Rollback(ctx)
 
-	var id int64
-	var createdAt time.Time
-	err = tx.QueryRow(ctx,
-		`INSERT INTO memories (type, area, content, rationale, author_name, author_email, repo)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)
-		 RETURNING id, created_at`,
-		mem.Type, mem.Area, mem.Content, mem.Rationale,
-		mem.AuthorName, mem.AuthorEmail, mem.Repo,
-	).Scan(&id, &createdAt)
+	added, err := postgresInsertMemory(ctx, tx, mem, embedding)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// postgresBatchRows caps the rows in one multi-row INSERT, keeping it well
+// under Postgres's limit on bound parameters
+const postgresBatchRows = 1000
+
+func (p *Postgres) AddBatch(ctx context.Context, mems []types.Memory, embeddings [][]float32) ([]types.Memory, error) {
+	if len(embeddings) != len(mems) {
+		return nil, fmt.Errorf("got %d embeddings for %d memories", len(embeddings), len(mems))
+	}
+	added := make([]types.Memory, 0, len(mems))
+	if len(mems) == 0 {
+		return added, nil
+	}
+
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		for start := 0; start < len(mems); start += postgresBatchRows {
+			end := min(start+postgresBatchRows, len(mems))
+			chunk, err := postgresInsertChunk(ctx, tx, mems[start:end], embeddings[start:end])
+			if err != nil {
+				return err
+			}
+			added = append(added, chunk...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// postgresInsertChunk inserts up to postgresBatchRows memories with one
+// statement per table. IDs are drawn from the sequence first, since
+// RETURNING doesn't promise to follow the VALUES order.
+func postgresInsertChunk(ctx context.Context, tx pgx.Tx, mems []types.Memory, embeddings [][]float32) ([]types.Memory, error) {
+	rows, err := tx.Query(ctx,
+		`SELECT nextval(pg_get_serial_sequence('memories', 'id')) FROM generate_series(1, $1)`, len(mems))
+	if err != nil {
+		return nil, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate memory IDs: %w", err)
+	}
+
+	const columns = 12
+	values := make([]string, len(mems))
+	args := make([]interface{}, 0, len(mems)*columns)
+	for i, mem := range mems {
+		placeholders := make([]string, columns)
+		for c := range placeholders {
+			placeholders[c] = fmt.Sprintf("$%d", len(args)+c+1)
+		}
+		values[i] = "(" + strings.Join(placeholders, ", ") + ")"
+		args = append(args, ids[i], mem.Type, mem.Area, mem.Content, mem.Rationale,
+			mem.AuthorName, mem.AuthorEmail, mem.Repo, mem.EmbeddingModel, mem.ExpiresAt, mem.ReviewBy, mem.OriginatedAt)
+	}
+	rows, err = tx.Query(ctx,
+		`INSERT INTO memories (id, type, area, content, rationale, author_name, author_email, repo, embedding_model, expires_at, review_by, originated_at)
+		 VALUES `+strings.Join(values, ", ")+`
+		 RETURNING id, created_at`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert memory: %w", err)
+	}
+	createdAt := make(map[int64]time.Time, len(mems))
+	var (
+		id int64
+		at time.Time
+	)
+	_, err = pgx.ForEachRow(rows, []any{&id, &at}, func() error {
+		createdAt[id] = at
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert memory: %w", err)
 	}
 
-	vec := pgvector.NewVector(embedding)
+	args = args[:0]
+	for i, embedding := range embeddings {
+		values[i] = fmt.Sprintf("($%d, $%d)", 2*i+1, 2*i+2)
+		args = append(args, ids[i], pgvector.NewVector(embedding))
+	}
 	_, err = tx.Exec(ctx,
-		`INSERT INTO memory_embeddings (memory_id, embedding) VALUES ($1, $2)`,
-		id, vec,
-	)
+		`INSERT INTO memory_embeddings (memory_id, embedding) VALUES `+strings.Join(values, ", "), args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert embedding: %w", err)
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return nil, err
+	var tagIDs []int64
+	var tagNames []string
+	added := make([]types.Memory, len(mems))
+	for i, mem := range mems {
+		tags := uniqueTags(mem.Tags)
+		for _, tag := range tags {
+			tagIDs = append(tagIDs, ids[i])
+			tagNames = append(tagNames, tag)
+		}
+		added[i] = types.Memory{
+			ID:             ids[i],
+			Type:           mem.Type,
+			Area:           mem.Area,
+			Content:        mem.Content,
+			Rationale:      mem.Rationale,
+			IsValid:        true,
+			CreatedAt:      createdAt[ids[i]],
+			Version:        1,
+			EmbeddingModel: mem.EmbeddingModel,
+			AuthorName:     mem.AuthorName,
+			AuthorEmail:    mem.AuthorEmail,
+			Repo:           mem.Repo,
+			Tags:           tags,
+			ExpiresAt:      copyTime(mem.ExpiresAt),
+			ReviewBy:       copyTime(mem.ReviewBy),
+			OriginatedAt:   copyTime(mem.OriginatedAt),
+		}
 	}
-
-	return &types.Memory{
-		ID:          id,
-		Type:        mem.Type,
-		Area:        mem.Area,
-		Content:     mem.Content,
-		Rationale:   mem.Rationale,
-		IsValid:     true,
-		CreatedAt:   createdAt,
-		AuthorName:  mem.AuthorName,
-		AuthorEmail: mem.AuthorEmail,
-		Repo:        mem.Repo,
-	}, nil
+	if len(tagIDs) > 0 {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO memory_tags (memory_id, tag) SELECT unnest($1::int[]), unnest($2::text[])`,
+			tagIDs, tagNames,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert tags: %w", err)
+		}
+	}
+	return added, nil
 }
 
 func (p *Postgres) Search(ctx context.Context, embedding []float32, opts types.SearchOpts) ([]types.Memory, error) {
@@ -136,8 +209,7 @@ func (p *Postgres) Search(ctx context.Context, embedding []float32, opts types.S
 	vec := pgvector.NewVector(embedding)
 
 	query := `
-		SELECT m.id, m.type, m.area, m.content, m.rationale, m.is_valid,
-		       m.superseded_by, m.created_at, m.author_name, m.author_email, m.repo,
+		SELECT ` + memoryColumns + `,
 		       (e.embedding <=> $1) AS distance
 		FROM memories m
 		JOIN memory_embeddings e ON m.id = e.memory_id
@@ -161,6 +233,10 @@ func (p *Postgres) Search(ctx context.Context, embedding []float32, opts types.S
 		args = append(args, opts.Repo)
 		argNum++
 	}
+	tagClause, tagArgs := postgresTagFilter(opts.Tags, argNum)
+	query += tagClause
+	args = append(args, tagArgs...)
+	argNum += len(tagArgs)
 
 	query += fmt.Sprintf(" ORDER BY distance LIMIT $%d", argNum)
 	args = append(args, limit)
@@ -168,6 +244,72 @@ func (p *Postgres) Search(ctx context.Context, embedding []float32, opts types.S
 	return p.queryMemoriesWithScore(ctx, query, args...)
 }
 
+func (p *Postgres) KeywordSearch(ctx context.Context, query string, opts types.SearchOpts) ([]types.Memory, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 5
+	}
+
+	terms := keywordTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	q := `
+		SELECT ` + memoryColumns + `,
+		       ts_rank_cd(m.search_vector, q) AS rank
+		FROM memories m, websearch_to_tsquery('english', $1) q
+		WHERE m.search_vector @@ q AND m.is_valid = TRUE
+	`
+	args := []interface{}{anyPhraseQuery(terms, "or")}
+	argNum := 2
+
+	if opts.Type != "" {
+		q += fmt.Sprintf(" AND m.type = $%d", argNum)
+		args = append(args, opts.Type)
+		argNum++
+	}
+	if opts.Area != "" {
+		q += fmt.Sprintf(" AND m.area = $%d", argNum)
+		args = append(args, opts.Area)
+		argNum++
+	}
+	if opts.Repo != "" {
+		q += fmt.Sprintf(" AND m.repo = $%d", argNum)
+		args = append(args, opts.Repo)
+		argNum++
+	}
+	tagClause, tagArgs := postgresTagFilter(opts.Tags, argNum)
+	q += tagClause
+	args = append(args, tagArgs...)
+	argNum += len(tagArgs)
+
+	q += fmt.Sprintf(" ORDER BY rank DESC LIMIT $%d", argNum)
+	args = append(args, limit)
+
+	rows, err := p.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memories []types.Memory
+	for rows.Next() {
+		var rank float64
+		m, err := scanPostgresMemory(rows, &rank)
+		if err != nil {
+			return nil, err
+		}
+		m.SimilarityScore = rank
+		memories = append(memories, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return memories, p.loadTags(ctx, memories)
+}
+
 func (p *Postgres) List(ctx context.Context, opts types.ListOpts) ([]types.Memory, error) {
 	limit := opts.Limit
 	if limit <= 0 {
@@ -175,100 +317,595 @@ func (p *Postgres) List(ctx context.Context, opts types.ListOpts) ([]types.Memor
 	}
 
 	query := `
-		SELECT id, type, area, content, rationale, is_valid,
-		       superseded_by, created_at, author_name, author_email, repo
-		FROM memories
+		SELECT ` + memoryColumns + `
+		FROM memories m
 		WHERE 1=1
 	`
 	args := []interface{}{}
 	argNum := 1
 
 	if !opts.IncludeInvalid {
-		query += " AND is_valid = TRUE"
+		query += " AND m.is_valid = TRUE"
 	}
 	if opts.Type != "" {
-		query += fmt.Sprintf(" AND type = $%d", argNum)
+		query += fmt.Sprintf(" AND m.type = $%d", argNum)
 		args = append(args, opts.Type)
 		argNum++
 	}
 	if opts.Area != "" {
-		query += fmt.Sprintf(" AND area = $%d", argNum)
+		query += fmt.Sprintf(" AND m.area = $%d", argNum)
 		args = append(args, opts.Area)
 		argNum++
 	}
 	if opts.Repo != "" {
-		query += fmt.Sprintf(" AND repo = $%d", argNum)
+		query += fmt.Sprintf(" AND m.repo = $%d", argNum)
 		args = append(args, opts.Repo)
 		argNum++
 	}
+	tagClause, tagArgs := postgresTagFilter(opts.Tags, argNum)
+	query += tagClause
+	args = append(args, tagArgs...)
+	argNum += len(tagArgs)
 
-	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", argNum)
-	args = append(args, limit)
+	if opts.After != nil {
+		query += fmt.Sprintf(" AND (m.created_at, m.id) < ($%d, $%d)", argNum, argNum+1)
+		args = append(args, opts.After.CreatedAt, opts.After.ID)
+		argNum += 2
+	}
+
+	query += fmt.Sprintf(" ORDER BY m.created_at DESC, m.id DESC LIMIT $%d OFFSET $%d", argNum, argNum+1)
+	args = append(args, limit, max(opts.Offset, 0))
 
 	return p.queryMemories(ctx, query, args...)
 }
 
-func (p *Postgres) Invalidate(ctx context.Context, id int64, supersededBy *int64) error {
-	var result pgconn.CommandTag
-	var err error
+func (p *Postgres) Get(ctx context.Context, id int64) (*types.Memory, error) {
+	query := `
+		SELECT ` + memoryColumns + `
+		FROM memories m
+		WHERE m.id = $1
+	`
+	memories, err := p.queryMemories(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(memories) == 0 {
+		return nil, types.ErrNotFound
+	}
+	return &memories[0], nil
+}
 
-	if supersededBy != nil {
-		result, err = p.pool.Exec(ctx,
-			`UPDATE memories SET is_valid = FALSE, superseded_by = $1 WHERE id = $2`,
-			*supersededBy, id,
-		)
-	} else {
-		result, err = p.pool.Exec(ctx,
-			`UPDATE memories SET is_valid = FALSE WHERE id = $1`,
-			id,
-		)
+func (p *Postgres) Invalidate(ctx context.Context, inv types.Invalidation) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback(ctx)
+
+	// Locking each link stops a concurrent Invalidate from closing a loop
+	// through the chain being checked
+	err = checkSupersede(inv, func(id int64) (*supersedeLink, error) {
+		var link supersedeLink
+		err := tx.QueryRow(ctx, `SELECT repo, superseded_by FROM memories WHERE id = $1 FOR UPDATE`, id).
+			Scan(&link.Repo, &link.SupersededBy)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &link, nil
+	})
 	if err != nil {
 		return err
 	}
 
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("memory with id %d not found", id)
+	_, err = tx.Exec(ctx,
+		`UPDATE memories
+		 SET is_valid = FALSE, invalidated_at = NOW(),
+		     invalidated_by_name = $1, invalidated_by_email = $2, invalidation_reason = $3,
+		     superseded_by = $4
+		 WHERE id = $5`,
+		inv.ByName, inv.ByEmail, inv.Reason, inv.SupersededBy, inv.ID,
+	)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return tx.Commit(ctx)
 }
 
-func (p *Postgres) queryMemoriesWithScore(ctx context.Context, query string, args ...interface{}) ([]types.Memory, error) {
-	rows, err := p.pool.Query(ctx, query, args...)
+func (p *Postgres) InvalidateMatching(ctx context.Context, bi types.BulkInvalidation) ([]int64, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the matches stops a concurrent Invalidate or Update changing
+	// them between the check and the write
+	clause, args := postgresMemoryFilter(bi.Filter, 1)
+	rows, err := tx.Query(ctx, `SELECT m.id FROM memories m WHERE m.is_valid = TRUE`+clause+` ORDER BY m.id FOR UPDATE`, args...)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkBulkSupersede(bi, ids, func(id int64) (*supersedeLink, error) {
+		var link supersedeLink
+		err := tx.QueryRow(ctx, `SELECT repo, superseded_by FROM memories WHERE id = $1 FOR UPDATE`, id).
+			Scan(&link.Repo, &link.SupersededBy)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &link, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if bi.DryRun || len(ids) == 0 {
+		return ids, nil
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE memories
+		 SET is_valid = FALSE, invalidated_at = NOW(),
+		     invalidated_by_name = $1, invalidated_by_email = $2, invalidation_reason = $3,
+		     superseded_by = $4
+		 WHERE id = ANY($5)`,
+		bi.ByName, bi.ByEmail, bi.Reason, bi.SupersededBy, ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (p *Postgres) Revalidate(ctx context.Context, rev types.Revalidation) (*types.Memory, error) {
+	// A passed expiry would have the next sweep invalidate the memory again
+	_, err := p.pool.Exec(ctx, `
+		UPDATE memories
+		SET is_valid = TRUE, superseded_by = NULL,
+		    invalidated_at = NULL, invalidated_by_name = '', invalidated_by_email = '', invalidation_reason = '',
+		    expires_at = CASE WHEN expires_at <= NOW() THEN NULL ELSE expires_at END,
+		    restored_at = NOW(), restored_by_name = $1, restored_by_email = $2
+		WHERE id = $3 AND is_valid = FALSE
+	`, rev.ByName, rev.ByEmail, rev.ID)
+	if err != nil {
+		return nil, err
+	}
+	return p.Get(ctx, rev.ID)
+}
+
+func (p *Postgres) Merge(ctx context.Context, m types.Merge, embedding []float32) (*types.Memory, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the sources stops a concurrent merge or invalidation claiming them
+	originatedAt, err := checkMerge(m, func(id int64) (*mergeSource, error) {
+		var src mergeSource
+		err := tx.QueryRow(ctx,
+			`SELECT repo, is_valid, created_at, originated_at FROM memories WHERE id = $1 FOR UPDATE`, id,
+		).Scan(&src.Repo, &src.IsValid, &src.CreatedAt, &src.OriginatedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &src, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mem := m.Memory
+	mem.OriginatedAt = &originatedAt
+	merged, err := postgresInsertMemory(ctx, tx, mem, embedding)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE memories
+		 SET is_valid = FALSE, superseded_by = $1, invalidated_at = NOW(),
+		     invalidated_by_name = $2, invalidated_by_email = $3, invalidation_reason = $4
+		 WHERE id = ANY($5)`,
+		merged.ID, m.ByName, m.ByEmail, m.Reason, m.Sources,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+func (p *Postgres) Update(ctx context.Context, upd types.MemoryUpdate, embedding []float32) (*types.Memory, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		memType   string
+		area      string
+		content   string
+		rationale *string
+		version   int
+	)
+	err = tx.QueryRow(ctx,
+		`SELECT type, area, content, rationale, version FROM memories WHERE id = $1 FOR UPDATE`,
+		upd.ID,
+	).Scan(&memType, &area, &content, &rationale, &version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, types.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if version != upd.ExpectedVersion {
+		return nil, fmt.Errorf("%w: memory %d is at version %d, expected %d", types.ErrVersionConflict, upd.ID, version, upd.ExpectedVersion)
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO memory_revisions (memory_id, version, type, area, content, rationale, revised_by_name, revised_by_email)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		upd.ID, version, memType, area, content, rationale, upd.EditorName, upd.EditorEmail,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record revision: %w", err)
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE memories
+		 SET type = $1, area = $2, content = $3, rationale = $4, expires_at = $5, review_by = $6,
+		     version = version + 1, updated_at = NOW()
+		 WHERE id = $7`,
+		upd.Type, upd.Area, upd.Content, upd.Rationale, upd.ExpiresAt, upd.ReviewBy, upd.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update memory: %w", err)
+	}
+
+	if embedding != nil {
+		_, err = tx.Exec(ctx,
+			`UPDATE memory_embeddings SET embedding = $1 WHERE memory_id = $2`,
+			pgvector.NewVector(embedding), upd.ID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to replace embedding: %w", err)
+		}
+		_, err = tx.Exec(ctx,
+			`UPDATE memories SET embedding_model = $1 WHERE id = $2`,
+			upd.EmbeddingModel, upd.ID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record embedding model: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return p.Get(ctx, upd.ID)
+}
+
+func (p *Postgres) History(ctx context.Context, id int64) ([]types.Revision, error) {
+	if _, err := p.Get(ctx, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT memory_id, version, type, area, content, rationale,
+		       revised_at, revised_by_name, revised_by_email
+		FROM memory_revisions
+		WHERE memory_id = $1
+		ORDER BY version
+	`, id)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var memories []types.Memory
+	var revisions []types.Revision
 	for rows.Next() {
-		var m types.Memory
+		var r types.Revision
 		var memType string
-		var supersededBy *int64
 		var rationale *string
-		var distance float64
 
 		err := rows.Scan(
-			&m.ID, &memType, &m.Area, &m.Content, &rationale, &m.IsValid,
-			&supersededBy, &m.CreatedAt, &m.AuthorName, &m.AuthorEmail, &m.Repo,
-			&distance,
+			&r.MemoryID, &r.Version, &memType, &r.Area, &r.Content, &rationale,
+			&r.RevisedAt, &r.RevisedByName, &r.RevisedByEmail,
 		)
 		if err != nil {
 			return nil, err
 		}
 
-		m.Type = types.MemoryType(memType)
+		r.Type = types.MemoryType(memType)
 		if rationale != nil {
-			m.Rationale = *rationale
+			r.Rationale = *rationale
+		}
+
+		revisions = append(revisions, r)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (p *Postgres) Tags(ctx context.Context, repo string) ([]types.TagCount, error) {
+	query := `
+		SELECT t.tag, COUNT(*) AS uses
+		FROM memory_tags t
+		JOIN memories m ON m.id = t.memory_id
+		WHERE m.is_valid = TRUE
+	`
+	args := []interface{}{}
+	if repo != "" {
+		query += " AND m.repo = $1"
+		args = append(args, repo)
+	}
+	query += " GROUP BY t.tag ORDER BY uses DESC, t.tag"
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []types.TagCount
+	for rows.Next() {
+		var tc types.TagCount
+		if err := rows.Scan(&tc.Tag, &tc.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tc)
+	}
+	return tags, rows.Err()
+}
+
+func (p *Postgres) Catalog(ctx context.Context, field types.CatalogField, repo string) ([]types.CatalogEntry, error) {
+	sf, err := catalogField(field)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + postgresStatsColumns[sf] + `, MAX(m.author_name), COUNT(*),
+		       MAX(COALESCE(m.updated_at, m.created_at))
+		FROM memories m
+		WHERE m.is_valid = TRUE
+	`
+	args := []interface{}{}
+	if repo != "" {
+		query += " AND m.repo = $1"
+		args = append(args, repo)
+	}
+	query += " GROUP BY 1"
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (types.CatalogEntry, error) {
+		var e types.CatalogEntry
+		err := row.Scan(&e.Value, &e.Name, &e.Count, &e.LastActivity)
+		return e, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return finishCatalog(field, entries), nil
+}
+
+// postgresStatsColumns are the expressions Stats groups by
+var postgresStatsColumns = map[statsField]string{
+	statsRepo:   "m.repo",
+	statsArea:   "m.area",
+	statsType:   "m.type",
+	statsAuthor: "CASE WHEN m.author_email <> '' THEN m.author_email ELSE m.author_name END",
+}
+
+func (p *Postgres) Stats(ctx context.Context, opts types.StatsOpts) (*types.Stats, error) {
+	where := ""
+	args := []interface{}{}
+	if opts.Repo != "" {
+		where = " WHERE m.repo = $1"
+		args = append(args, opts.Repo)
+	}
+
+	group := func(field statsField) ([]types.StatCount, error) {
+		rows, err := p.pool.Query(ctx, `
+			SELECT `+postgresStatsColumns[field]+`,
+			       COUNT(*) FILTER (WHERE m.is_valid),
+			       COUNT(*) FILTER (WHERE NOT m.is_valid)
+			FROM memories m`+where+`
+			GROUP BY 1`, args...)
+		if err != nil {
+			return nil, err
 		}
-		m.SupersededBy = supersededBy
+		return pgx.CollectRows(rows, func(row pgx.CollectableRow) (types.StatCount, error) {
+			var c types.StatCount
+			err := row.Scan(&c.Value, &c.Valid, &c.Invalid)
+			return c, err
+		})
+	}
+
+	days := func() ([]dayCount, error) {
+		rows, err := p.pool.Query(ctx,
+			`SELECT (m.created_at AT TIME ZONE 'UTC')::date, COUNT(*) FROM memories m`+where+` GROUP BY 1`, args...)
+		if err != nil {
+			return nil, err
+		}
+		return pgx.CollectRows(rows, func(row pgx.CollectableRow) (dayCount, error) {
+			var dc dayCount
+			err := row.Scan(&dc.Day, &dc.Count)
+			return dc, err
+		})
+	}
+
+	return collectStats(opts, group, days)
+}
+
+func (p *Postgres) Expire(ctx context.Context, now time.Time) (int64, error) {
+	result, err := p.pool.Exec(ctx,
+		`UPDATE memories
+		 SET is_valid = FALSE, invalidated_at = $1, invalidated_by_name = '', invalidated_by_email = '',
+		     invalidation_reason = $2
+		 WHERE is_valid = TRUE AND expires_at <= $1`,
+		now, types.ExpiredReason)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+func (p *Postgres) DueForReview(ctx context.Context, repo string, now time.Time, limit int) ([]types.Memory, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query := `
+		SELECT ` + memoryColumns + `
+		FROM memories m
+		WHERE m.is_valid = TRUE AND m.review_by <= $1
+	`
+	args := []interface{}{now}
+	if repo != "" {
+		query += " AND m.repo = $2"
+		args = append(args, repo)
+	}
+	query += fmt.Sprintf(" ORDER BY m.review_by, m.id LIMIT $%d", len(args)+1)
+	args = append(args, limit)
+
+	return p.queryMemories(ctx, query, args...)
+}
+
+func (p *Postgres) RecordRetrievals(ctx context.Context, counts map[int64]int, at time.Time) error {
+	if err := markUsageTracked(ctx, p, at); err != nil {
+		return err
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(counts))
+	ns := make([]int32, 0, len(counts))
+	for id, n := range counts {
+		ids = append(ids, id)
+		ns = append(ns, int32(n))
+	}
+
+	// GREATEST ignores NULLs, so a first retrieval sets the time
+	_, err := p.pool.Exec(ctx, `
+		UPDATE memories m
+		SET retrieval_count = m.retrieval_count + r.n,
+		    last_retrieved_at = GREATEST(m.last_retrieved_at, $3)
+		FROM unnest($1::bigint[], $2::int[]) AS r(id, n)
+		WHERE m.id = r.id
+	`, ids, ns, at)
+	return err
+}
+
+func (p *Postgres) Unretrieved(ctx context.Context, repo string, before time.Time, limit int) ([]types.Memory, error) {
+	// A memory created before tracking started may have been used while no
+	// one was counting, so its age only counts from the start of tracking
+	if tracked, err := usageTrackedBefore(ctx, p, before); err != nil || !tracked {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query := `
+		SELECT ` + memoryColumns + `
+		FROM memories m
+		WHERE m.is_valid = TRUE AND m.retrieval_count = 0 AND m.created_at < $1
+	`
+	args := []interface{}{before}
+	if repo != "" {
+		query += " AND m.repo = $2"
+		args = append(args, repo)
+	}
+	query += fmt.Sprintf(" ORDER BY m.created_at, m.id LIMIT $%d", len(args)+1)
+	args = append(args, limit)
+
+	return p.queryMemories(ctx, query, args...)
+}
+
+func (p *Postgres) Lineage(ctx context.Context, id int64) ([]types.Memory, error) {
+	// UNION drops rows already seen, which stops the recursion at cycles
+	query := `
+		WITH RECURSIVE
+		successors(id) AS (
+			SELECT superseded_by FROM memories WHERE id = $1 AND superseded_by IS NOT NULL
+			UNION
+			SELECT m.superseded_by FROM memories m JOIN successors s ON m.id = s.id
+			WHERE m.superseded_by IS NOT NULL
+		),
+		predecessors(id) AS (
+			SELECT id FROM memories WHERE superseded_by = $1
+			UNION
+			SELECT m.id FROM memories m JOIN predecessors p ON m.superseded_by = p.id
+		)
+		SELECT ` + memoryColumns + `
+		FROM memories m
+		WHERE m.id = $1
+		   OR m.id IN (SELECT id FROM successors)
+		   OR m.id IN (SELECT id FROM predecessors)
+		ORDER BY m.id
+	`
+	memories, err := p.queryMemories(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if !containsMemory(memories, id) {
+		return nil, types.ErrNotFound
+	}
+	return memories, nil
+}
+
+func (p *Postgres) queryMemoriesWithScore(ctx context.Context, query string, args ...interface{}) ([]types.Memory, error) {
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memories []types.Memory
+	for rows.Next() {
+		var distance float64
+		m, err := scanPostgresMemory(rows, &distance)
+		if err != nil {
+			return nil, err
+		}
+
 		m.SimilarityScore = 1 - distance
 
 		memories = append(memories, m)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return memories, rows.Err()
+	return memories, p.loadTags(ctx, memories)
 }
 
 func (p *Postgres) queryMemories(ctx context.Context, query string, args ...interface{}) ([]types.Memory, error) {
@@ -280,27 +917,243 @@ func (p *Postgres) queryMemories(ctx context.Context, query string, args ...inte
 
 	var memories []types.Memory
 	for rows.Next() {
-		var m types.Memory
-		var memType string
-		var supersededBy *int64
-		var rationale *string
-
-		err := rows.Scan(
-			&m.ID, &memType, &m.Area, &m.Content, &rationale, &m.IsValid,
-			&supersededBy, &m.CreatedAt, &m.AuthorName, &m.AuthorEmail, &m.Repo,
-		)
+		m, err := scanPostgresMemory(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		m.Type = types.MemoryType(memType)
-		if rationale != nil {
-			m.Rationale = *rationale
+		memories = append(memories, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return memories, p.loadTags(ctx, memories)
+}
+
+// loadTags fills in the tags of memories
+func (p *Postgres) loadTags(ctx context.Context, memories []types.Memory) error {
+	ids := make([]int64, len(memories))
+	for i, m := range memories {
+		ids[i] = m.ID
+	}
+	tags, err := p.tagsOf(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range memories {
+		memories[i].Tags = tags[memories[i].ID]
+	}
+	return nil
+}
+
+// tagsOf returns the sorted tags of each of ids that has any
+func (p *Postgres) tagsOf(ctx context.Context, ids []int64) (map[int64][]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	rows, err := p.pool.Query(ctx,
+		`SELECT memory_id, tag FROM memory_tags WHERE memory_id = ANY($1) ORDER BY tag`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[int64][]string)
+	for rows.Next() {
+		var id int64
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return nil, err
 		}
-		m.SupersededBy = supersededBy
+		tags[id] = append(tags[id], tag)
+	}
+	return tags, rows.Err()
+}
 
-		memories = append(memories, m)
+// postgresInsertMemory adds mem as a new valid memory with its embedding and
+// tags inside tx, and returns it as stored
+func postgresInsertMemory(ctx context.Context, tx pgx.Tx, mem types.Memory, embedding []float32) (*types.Memory, error) {
+	var id int64
+	var createdAt time.Time
+	err := tx.QueryRow(ctx,
+		`INSERT INTO memories (type, area, content, rationale, author_name, author_email, repo, embedding_model, expires_at, review_by, originated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 RETURNING id, created_at`,
+		mem.Type, mem.Area, mem.Content, mem.Rationale,
+		mem.AuthorName, mem.AuthorEmail, mem.Repo, mem.EmbeddingModel, mem.ExpiresAt, mem.ReviewBy, mem.OriginatedAt,
+	).Scan(&id, &createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert memory: %w", err)
+	}
+
+	vec := pgvector.NewVector(embedding)
+	_, err = tx.Exec(ctx,
+		`INSERT INTO memory_embeddings (memory_id, embedding) VALUES ($1, $2)`,
+		id, vec,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert embedding: %w", err)
+	}
+
+	tags := uniqueTags(mem.Tags)
+	if err := postgresInsertTags(ctx, tx, id, tags); err != nil {
+		return nil, err
+	}
+
+	return &types.Memory{
+		ID:             id,
+		Type:           mem.Type,
+		Area:           mem.Area,
+		Content:        mem.Content,
+		Rationale:      mem.Rationale,
+		IsValid:        true,
+		CreatedAt:      createdAt,
+		Version:        1,
+		EmbeddingModel: mem.EmbeddingModel,
+		AuthorName:     mem.AuthorName,
+		AuthorEmail:    mem.AuthorEmail,
+		Repo:           mem.Repo,
+		Tags:           tags,
+		ExpiresAt:      copyTime(mem.ExpiresAt),
+		ReviewBy:       copyTime(mem.ReviewBy),
+		OriginatedAt:   copyTime(mem.OriginatedAt),
+	}, nil
+}
+
+// postgresInsertTags records tags, already made unique, against a memory
+func postgresInsertTags(ctx context.Context, db pgExecer, id int64, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	_, err := db.Exec(ctx,
+		`INSERT INTO memory_tags (memory_id, tag) SELECT $1, unnest($2::text[])`, id, tags)
+	if err != nil {
+		return fmt.Errorf("failed to insert tags: %w", err)
 	}
+	return nil
+}
 
-	return memories, rows.Err()
+// postgresTagFilter returns the conditions on m for a tag filter, numbering
+// placeholders from argNum, with their arguments. All matches through a
+// count of distinct hits per memory.
+func postgresTagFilter(f types.TagFilter, argNum int) (string, []interface{}) {
+	var clause string
+	var args []interface{}
+	if len(f.Any) > 0 {
+		clause += fmt.Sprintf(" AND m.id IN (SELECT memory_id FROM memory_tags WHERE tag = ANY($%d))", argNum)
+		args = append(args, f.Any)
+	}
+	if all := uniqueTags(f.All); len(all) > 0 {
+		n := argNum + len(args)
+		clause += fmt.Sprintf(" AND m.id IN (SELECT memory_id FROM memory_tags WHERE tag = ANY($%d) GROUP BY memory_id HAVING COUNT(*) = $%d)", n, n+1)
+		args = append(args, all, len(all))
+	}
+	return clause, args
+}
+
+// postgresMemoryFilter returns the conditions on m for a memory filter,
+// numbering placeholders from argNum, with their arguments
+func postgresMemoryFilter(f types.MemoryFilter, argNum int) (string, []interface{}) {
+	var clause string
+	var args []interface{}
+	if f.Repo != "" {
+		args = append(args, f.Repo)
+		clause += fmt.Sprintf(" AND m.repo = $%d", argNum+len(args)-1)
+	}
+	if f.Area != "" {
+		args = append(args, f.Area)
+		clause += fmt.Sprintf(" AND m.area = $%d", argNum+len(args)-1)
+	}
+	if f.Type != "" {
+		args = append(args, f.Type)
+		clause += fmt.Sprintf(" AND m.type = $%d", argNum+len(args)-1)
+	}
+	if f.Author != "" {
+		args = append(args, f.Author)
+		n := argNum + len(args) - 1
+		clause += fmt.Sprintf(" AND (m.author_name = $%d OR m.author_email = $%d)", n, n)
+	}
+	if f.CreatedBefore != nil {
+		args = append(args, *f.CreatedBefore)
+		clause += fmt.Sprintf(" AND m.created_at < $%d", argNum+len(args)-1)
+	}
+	return clause, args
+}
+
+// scanPostgresMemory scans the memoryColumns of the current row, followed by
+// any extra destinations selected after them
+func scanPostgresMemory(rows pgx.Rows, extra ...interface{}) (types.Memory, error) {
+	var m types.Memory
+	var memType string
+	var rationale *string
+
+	dest := []interface{}{
+		&m.ID, &memType, &m.Area, &m.Content, &rationale, &m.IsValid,
+		&m.SupersededBy, &m.CreatedAt, &m.AuthorName, &m.AuthorEmail, &m.Repo,
+		&m.Version, &m.UpdatedAt, &m.EmbeddingModel, &m.ExpiresAt, &m.ReviewBy,
+		&m.InvalidatedAt, &m.InvalidatedByName, &m.InvalidatedByEmail, &m.InvalidationReason,
+		&m.RestoredAt, &m.RestoredByName, &m.RestoredByEmail, &m.OriginatedAt,
+		&m.RetrievalCount, &m.LastRetrievedAt,
+	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return types.Memory{}, err
+	}
+
+	m.Type = types.MemoryType(memType)
+	if rationale != nil {
+		m.Rationale = *rationale
+	}
+
+	return m, nil
+}
+
+func (p *Postgres) getMetadata(ctx context.Context, key string) (string, error) {
+	var value string
+	err := p.pool.QueryRow(ctx, `SELECT value FROM metadata WHERE key = $1`, key).Scan(&value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+func (p *Postgres) setMetadata(ctx context.Context, key, value string) error {
+	return postgresSetMetadata(ctx, p.pool, key, value)
+}
+
+// pgExecer is satisfied by both the pool and a transaction
+type pgExecer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func postgresSetMetadata(ctx context.Context, db pgExecer, key, value string) error {
+	_, err := db.Exec(ctx,
+		`INSERT INTO metadata (key, value) VALUES ($1, $2)
+		 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value`,
+		key, value,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return nil
+}
+
+func (p *Postgres) hasTable(ctx context.Context, name string) (bool, error) {
+	var exists bool
+	err := p.pool.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists)
+	return exists, err
+}
+
+// vectorDimension reads the typmod of the pgvector column, which is its dimension
+func (p *Postgres) vectorDimension(ctx context.Context) (int, error) {
+	var dim int
+	err := p.pool.QueryRow(ctx,
+		`SELECT atttypmod FROM pg_attribute
+		 WHERE attrelid = 'memory_embeddings'::regclass AND attname = 'embedding'`,
+	).Scan(&dim)
+	if errors.Is(err, pgx.ErrNoRows) || dim < 0 {
+		return 0, nil
+	}
+	return dim, err
 }
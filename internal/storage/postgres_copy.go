@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pgvector/pgvector-go"
+)
+
+func (p *Postgres) ExportMemories(ctx context.Context, afterID int64, limit int) ([]ExportedMemory, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT `+memoryColumns+`, e.embedding
+		FROM memories m
+		LEFT JOIN memory_embeddings e ON e.memory_id = m.id
+		WHERE m.id > $1
+		ORDER BY m.id
+		LIMIT $2
+	`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memories []ExportedMemory
+	for rows.Next() {
+		var embedding *pgvector.Vector
+		m, err := scanPostgresMemory(rows, &embedding)
+		if err != nil {
+			return nil, err
+		}
+
+		exported := ExportedMemory{Memory: m}
+		if embedding != nil {
+			exported.Embedding = embedding.Slice()
+		}
+		memories = append(memories, exported)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(memories))
+	for i, m := range memories {
+		ids[i] = m.ID
+	}
+	tags, err := p.tagsOf(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range memories {
+		memories[i].Tags = tags[memories[i].ID]
+	}
+	return memories, nil
+}
+
+func (p *Postgres) ImportMemory(ctx context.Context, source string, mem ExportedMemory) (int64, error) {
+	var id int64
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx,
+			`SELECT memory_id FROM memory_imports WHERE source = $1 AND source_id = $2`, source, mem.ID,
+		).Scan(&id)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		err = tx.QueryRow(ctx,
+			`INSERT INTO memories (type, area, content, rationale, is_valid, created_at, author_name, author_email, repo, version, updated_at, embedding_model, expires_at, review_by,
+			                       invalidated_at, invalidated_by_name, invalidated_by_email, invalidation_reason,
+			                       restored_at, restored_by_name, restored_by_email, originated_at,
+			                       retrieval_count, last_retrieved_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)
+			 RETURNING id`,
+			mem.Type, mem.Area, mem.Content, mem.Rationale, mem.IsValid, mem.CreatedAt,
+			mem.AuthorName, mem.AuthorEmail, mem.Repo, mem.Version, mem.UpdatedAt, mem.EmbeddingModel,
+			mem.ExpiresAt, mem.ReviewBy,
+			mem.InvalidatedAt, mem.InvalidatedByName, mem.InvalidatedByEmail, mem.InvalidationReason,
+			mem.RestoredAt, mem.RestoredByName, mem.RestoredByEmail, mem.OriginatedAt,
+			mem.RetrievalCount, mem.LastRetrievedAt,
+		).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to insert memory: %w", err)
+		}
+
+		if mem.Embedding != nil {
+			_, err = tx.Exec(ctx,
+				`INSERT INTO memory_embeddings (memory_id, embedding) VALUES ($1, $2)`,
+				id, pgvector.NewVector(mem.Embedding),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to insert embedding: %w", err)
+			}
+		}
+
+		if err := postgresInsertTags(ctx, tx, id, uniqueTags(mem.Tags)); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx,
+			`INSERT INTO memory_imports (source, source_id, memory_id) VALUES ($1, $2, $3)`,
+			source, mem.ID, id,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record import: %w", err)
+		}
+		return nil
+	})
+	return id, err
+}
+
+func (p *Postgres) ImportedIDs(ctx context.Context, source string) (map[int64]int64, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT source_id, memory_id FROM memory_imports WHERE source = $1`, source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int64]int64)
+	for rows.Next() {
+		var sourceID, id int64
+		if err := rows.Scan(&sourceID, &id); err != nil {
+			return nil, err
+		}
+		ids[sourceID] = id
+	}
+	return ids, rows.Err()
+}
+
+func (p *Postgres) SetSupersededBy(ctx context.Context, id, supersededBy int64) error {
+	_, err := p.pool.Exec(ctx,
+		`UPDATE memories SET superseded_by = $1 WHERE id = $2`, supersededBy, id)
+	return err
+}
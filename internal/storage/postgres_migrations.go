@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// postgresMigrationLock is the advisory lock key held while migrating, so
+// several ec-api replicas starting at once apply each migration exactly once
+const postgresMigrationLock = 0x6563_6d69_6772 // "ecmigr"
+
+// postgresMigration is a numbered schema change applied inside a transaction
+type postgresMigration struct {
+	Migration
+	up func(ctx context.Context, p *Postgres, tx pgx.Tx) error
+}
+
+// postgresMigrations lists every schema change in order. Append new
+// migrations; never edit or renumber one that has shipped. Migrations 1 and 2
+// predate schema_migrations and stay idempotent so unversioned databases adopt them.
+var postgresMigrations = []postgresMigration{
+	{Migration{1, "create memories and embeddings"}, postgresCreateMemories},
+	{Migration{2, "add memory versions and revisions"}, postgresAddRevisions},
+	{Migration{3, "create metadata table"}, postgresCreateMetadata},
+	{Migration{4, "track embedding model per memory"}, postgresAddEmbeddingModel},
+	{Migration{5, "create keyword search index"}, postgresCreateKeywordIndex},
+	{Migration{6, "track imported memories"}, postgresCreateImports},
+	{Migration{7, "create memory tags"}, postgresCreateTags},
+	{Migration{8, "add expiry and review dates"}, postgresAddExpiry},
+	{Migration{9, "record who invalidated memories"}, postgresAddInvalidation},
+	{Migration{10, "record who restored memories"}, postgresAddRestore},
+	{Migration{11, "record when merged memories originated"}, postgresAddOriginatedAt},
+	{Migration{12, "track memory retrievals"}, postgresAddRetrievals},
+}
+
+func postgresCreateMemories(ctx context.Context, p *Postgres, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, fmt.Sprintf(`
+		CREATE EXTENSION IF NOT EXISTS vector;
+
+		CREATE TABLE IF NOT EXISTS memories (
+			id SERIAL PRIMARY KEY,
+			type TEXT NOT NULL CHECK(type IN ('decision', 'learning', 'pattern')),
+			area TEXT NOT NULL,
+			content TEXT NOT NULL,
+			rationale TEXT,
+			is_valid BOOLEAN NOT NULL DEFAULT TRUE,
+			superseded_by INTEGER REFERENCES memories(id),
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			author_name TEXT NOT NULL DEFAULT '',
+			author_email TEXT NOT NULL DEFAULT '',
+			repo TEXT NOT NULL DEFAULT ''
+		);
+
+		CREATE TABLE IF NOT EXISTS memory_embeddings (
+			memory_id INTEGER PRIMARY KEY REFERENCES memories(id) ON DELETE CASCADE,
+			embedding vector(%d)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_memories_type ON memories(type);
+		CREATE INDEX IF NOT EXISTS idx_memories_area ON memories(area);
+		CREATE INDEX IF NOT EXISTS idx_memories_is_valid ON memories(is_valid);
+		CREATE INDEX IF NOT EXISTS idx_memories_repo ON memories(repo);
+		CREATE INDEX IF NOT EXISTS idx_memories_author ON memories(author_email);
+
+		CREATE INDEX IF NOT EXISTS idx_embeddings_vector
+		ON memory_embeddings USING hnsw (embedding vector_cosine_ops);
+	`, p.dim))
+	return err
+}
+
+func postgresAddRevisions(ctx context.Context, p *Postgres, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+		ALTER TABLE memories
+			ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1,
+			ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+
+		CREATE TABLE IF NOT EXISTS memory_revisions (
+			id SERIAL PRIMARY KEY,
+			memory_id INTEGER NOT NULL REFERENCES memories(id) ON DELETE CASCADE,
+			version INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			area TEXT NOT NULL,
+			content TEXT NOT NULL,
+			rationale TEXT,
+			revised_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			revised_by_name TEXT NOT NULL DEFAULT '',
+			revised_by_email TEXT NOT NULL DEFAULT '',
+			UNIQUE(memory_id, version)
+		);
+	`)
+	return err
+}
+
+func postgresCreateMetadata(ctx context.Context, p *Postgres, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+		CREATE TABLE metadata (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		)
+	`)
+	return err
+}
+
+func postgresAddEmbeddingModel(ctx context.Context, p *Postgres, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+		ALTER TABLE memories ADD COLUMN embedding_model TEXT NOT NULL DEFAULT '';
+
+		UPDATE memories
+		SET embedding_model = COALESCE((SELECT value FROM metadata WHERE key = 'embedding_model'), '');
+	`)
+	return err
+}
+
+// postgresCreateKeywordIndex adds a generated tsvector for KeywordSearch,
+// weighting area over content over rationale
+func postgresCreateKeywordIndex(ctx context.Context, p *Postgres, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+		ALTER TABLE memories ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('english', area), 'A') ||
+			setweight(to_tsvector('english', content), 'B') ||
+			setweight(to_tsvector('english', COALESCE(rationale, '')), 'C')
+		) STORED;
+
+		CREATE INDEX idx_memories_search ON memories USING GIN (search_vector);
+	`)
+	return err
+}
+
+func postgresCreateImports(ctx context.Context, p *Postgres, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+		CREATE TABLE memory_imports (
+			source TEXT NOT NULL,
+			source_id BIGINT NOT NULL,
+			memory_id INTEGER NOT NULL REFERENCES memories(id) ON DELETE CASCADE,
+			PRIMARY KEY (source, source_id)
+		)
+	`)
+	return err
+}
+
+func postgresCreateTags(ctx context.Context, p *Postgres, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+		CREATE TABLE memory_tags (
+			memory_id INTEGER NOT NULL REFERENCES memories(id) ON DELETE CASCADE,
+			tag TEXT NOT NULL,
+			PRIMARY KEY (memory_id, tag)
+		);
+
+		CREATE INDEX idx_memory_tags_tag ON memory_tags(tag);
+	`)
+	return err
+}
+
+func postgresAddExpiry(ctx context.Context, p *Postgres, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+		ALTER TABLE memories ADD COLUMN expires_at TIMESTAMPTZ;
+		ALTER TABLE memories ADD COLUMN review_by TIMESTAMPTZ;
+
+		CREATE INDEX idx_memories_expires_at ON memories(expires_at) WHERE expires_at IS NOT NULL;
+		CREATE INDEX idx_memories_review_by ON memories(review_by) WHERE review_by IS NOT NULL;
+	`)
+	return err
+}
+
+func postgresAddInvalidation(ctx context.Context, p *Postgres, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+		ALTER TABLE memories ADD COLUMN invalidated_at TIMESTAMPTZ;
+		ALTER TABLE memories ADD COLUMN invalidated_by_name TEXT NOT NULL DEFAULT '';
+		ALTER TABLE memories ADD COLUMN invalidated_by_email TEXT NOT NULL DEFAULT '';
+		ALTER TABLE memories ADD COLUMN invalidation_reason TEXT NOT NULL DEFAULT '';
+	`)
+	return err
+}
+
+func postgresAddRestore(ctx context.Context, p *Postgres, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+		ALTER TABLE memories ADD COLUMN restored_at TIMESTAMPTZ;
+		ALTER TABLE memories ADD COLUMN restored_by_name TEXT NOT NULL DEFAULT '';
+		ALTER TABLE memories ADD COLUMN restored_by_email TEXT NOT NULL DEFAULT '';
+	`)
+	return err
+}
+
+func postgresAddOriginatedAt(ctx context.Context, p *Postgres, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `ALTER TABLE memories ADD COLUMN originated_at TIMESTAMPTZ`)
+	return err
+}
+
+func postgresAddRetrievals(ctx context.Context, p *Postgres, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+		ALTER TABLE memories ADD COLUMN retrieval_count INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE memories ADD COLUMN last_retrieved_at TIMESTAMPTZ;
+	`)
+	return err
+}
+
+// Migrate applies pending migrations while holding a session advisory lock
+func (p *Postgres) Migrate(ctx context.Context) error {
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, postgresMigrationLock); err != nil {
+		return fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, postgresMigrationLock)
+
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	// Read status after taking the lock so a replica that just finished is seen
+	status, err := p.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkSchemaVersion(status); err != nil {
+		return err
+	}
+
+	pending := make(map[int]bool, len(status.Pending))
+	for _, m := range status.Pending {
+		pending[m.Version] = true
+	}
+
+	for _, m := range postgresMigrations {
+		if !pending[m.Version] {
+			continue
+		}
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if err := m.up(ctx, p, tx); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx,
+				`INSERT INTO schema_migrations (version, description) VALUES ($1, $2)`,
+				m.Version, m.Description,
+			)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+	}
+	return nil
+}
+
+// MigrationStatus reports applied and pending migrations
+func (p *Postgres) MigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	known := make([]Migration, len(postgresMigrations))
+	for i, m := range postgresMigrations {
+		known[i] = m.Migration
+	}
+
+	hasTable, err := p.hasTable(ctx, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if !hasTable {
+		return buildMigrationStatus(known, nil), nil
+	}
+
+	rows, err := p.pool.Query(ctx,
+		`SELECT version, description, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var a AppliedMigration
+		if err := rows.Scan(&a.Version, &a.Description, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return buildMigrationStatus(known, applied), nil
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pgvector/pgvector-go"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+const postgresStagingPending = `
+	FROM memories m
+	LEFT JOIN memory_embeddings_next n ON n.memory_id = m.id
+	WHERE n.memory_id IS NULL OR n.version != m.version`
+
+func (p *Postgres) PrepareReembed(ctx context.Context, target EmbeddingSpec) error {
+	current, err := readReembedTarget(ctx, p)
+	if err != nil {
+		return err
+	}
+	exists, err := p.hasTable(ctx, "memory_embeddings_next")
+	if err != nil {
+		return err
+	}
+	if exists && current == target {
+		return nil
+	}
+
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, fmt.Sprintf(`
+			DROP TABLE IF EXISTS memory_embeddings_next;
+			CREATE TABLE memory_embeddings_next (
+				memory_id INTEGER PRIMARY KEY REFERENCES memories(id) ON DELETE CASCADE,
+				version INTEGER NOT NULL,
+				embedding vector(%d) NOT NULL
+			);
+		`, target.Dimension))
+		if err != nil {
+			return fmt.Errorf("failed to create staging table: %w", err)
+		}
+		if err := postgresSetMetadata(ctx, tx, metaReembedModel, target.Model); err != nil {
+			return err
+		}
+		return postgresSetMetadata(ctx, tx, metaReembedDim, strconv.Itoa(target.Dimension))
+	})
+}
+
+func (p *Postgres) PendingReembed(ctx context.Context, limit int) ([]types.Memory, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+memoryColumns+postgresStagingPending+` ORDER BY m.id LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memories []types.Memory
+	for rows.Next() {
+		m, err := scanPostgresMemory(rows)
+		if err != nil {
+			return nil, err
+		}
+		memories = append(memories, m)
+	}
+	return memories, rows.Err()
+}
+
+func (p *Postgres) StageEmbedding(ctx context.Context, id int64, version int, embedding []float32) error {
+	_, err := p.pool.Exec(ctx,
+		`INSERT INTO memory_embeddings_next (memory_id, version, embedding) VALUES ($1, $2, $3)
+		 ON CONFLICT (memory_id) DO UPDATE SET version = EXCLUDED.version, embedding = EXCLUDED.embedding`,
+		id, version, pgvector.NewVector(embedding),
+	)
+	return err
+}
+
+func (p *Postgres) ReembedProgress(ctx context.Context, target EmbeddingSpec) (int, int, error) {
+	var total int
+	if err := p.pool.QueryRow(ctx, `SELECT COUNT(*) FROM memories`).Scan(&total); err != nil {
+		return 0, 0, err
+	}
+
+	current, err := readReembedTarget(ctx, p)
+	if err != nil {
+		return 0, 0, err
+	}
+	exists, err := p.hasTable(ctx, "memory_embeddings_next")
+	if err != nil {
+		return 0, 0, err
+	}
+	if !exists || current != target {
+		return 0, total, nil
+	}
+
+	var pending int
+	if err := p.pool.QueryRow(ctx, `SELECT COUNT(*)`+postgresStagingPending).Scan(&pending); err != nil {
+		return 0, 0, err
+	}
+	return total - pending, total, nil
+}
+
+// CommitReembed rebuilds memory_embeddings from staging in one transaction.
+// Writers are blocked for its duration so no memory can slip in unstaged.
+func (p *Postgres) CommitReembed(ctx context.Context) error {
+	target, err := readReembedTarget(ctx, p)
+	if err != nil {
+		return err
+	}
+	if target.Model == "" {
+		return fmt.Errorf("no re-embed in progress")
+	}
+
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `LOCK TABLE memories IN SHARE MODE`); err != nil {
+			return err
+		}
+
+		var pending int
+		if err := tx.QueryRow(ctx, `SELECT COUNT(*)`+postgresStagingPending).Scan(&pending); err != nil {
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("%w: %d memories still need a new vector", ErrReembedIncomplete, pending)
+		}
+
+		_, err := tx.Exec(ctx, fmt.Sprintf(`
+			DROP TABLE memory_embeddings;
+			CREATE TABLE memory_embeddings (
+				memory_id INTEGER PRIMARY KEY REFERENCES memories(id) ON DELETE CASCADE,
+				embedding vector(%d)
+			);
+			INSERT INTO memory_embeddings (memory_id, embedding)
+			SELECT memory_id, embedding FROM memory_embeddings_next;
+			CREATE INDEX idx_embeddings_vector
+			ON memory_embeddings USING hnsw (embedding vector_cosine_ops);
+			DROP TABLE memory_embeddings_next;
+		`, target.Dimension))
+		if err != nil {
+			return fmt.Errorf("failed to swap embeddings: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE memories SET embedding_model = $1`, target.Model); err != nil {
+			return fmt.Errorf("failed to record embedding model: %w", err)
+		}
+		if err := postgresSetMetadata(ctx, tx, metaEmbeddingModel, target.Model); err != nil {
+			return err
+		}
+		if err := postgresSetMetadata(ctx, tx, metaEmbeddingDim, strconv.Itoa(target.Dimension)); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM metadata WHERE key IN ($1, $2)`, metaReembedModel, metaReembedDim)
+		return err
+	})
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// ErrReembedIncomplete is returned by CommitReembed while some memories have
+// no staged vector (or were edited after theirs was staged)
+var ErrReembedIncomplete = errors.New("re-embed incomplete")
+
+// ErrReembedUnsupported is returned before anything is staged when the
+// deployment can't swap vectors in atomically at the end
+var ErrReembedUnsupported = errors.New("re-embed unsupported")
+
+// Metadata keys for an in-progress re-embed
+const (
+	metaReembedModel = "reembed_model"
+	metaReembedDim   = "reembed_dim"
+)
+
+// Reembedder is implemented by backends that can rebuild every vector with a
+// new embedding model. New vectors are staged beside the live ones, so search
+// keeps working until CommitReembed swaps them in, and staging survives
+// restarts so an interrupted run resumes where it stopped.
+type Reembedder interface {
+	// PrepareReembed creates staging for vectors of the target spec. Staging
+	// left by an earlier run for the same target is kept; any other is discarded.
+	PrepareReembed(ctx context.Context, target EmbeddingSpec) error
+	// PendingReembed returns up to limit memories, valid or not, that have no
+	// staged vector for their current version, in ID order
+	PendingReembed(ctx context.Context, limit int) ([]types.Memory, error)
+	// StageEmbedding stores the new vector for a memory at the given version
+	StageEmbedding(ctx context.Context, id int64, version int, embedding []float32) error
+	// ReembedProgress counts memories already staged for target, and all
+	// memories. It, like PrepareReembed, returns ErrReembedUnsupported if
+	// CommitReembed could never succeed, so a dry run is refused too.
+	ReembedProgress(ctx context.Context, target EmbeddingSpec) (staged, total int, err error)
+	// CommitReembed atomically replaces the live vectors with the staged ones
+	// and records the target as the database's embedding spec
+	CommitReembed(ctx context.Context) error
+}
+
+// StoredEmbeddingSpec returns the embedding model and dimension recorded for
+// the database, or a zero spec if none has been recorded yet
+func StoredEmbeddingSpec(ctx context.Context, store Storage) (EmbeddingSpec, error) {
+	ms, ok := store.(metadataStore)
+	if !ok {
+		return EmbeddingSpec{}, fmt.Errorf("storage driver does not record embedding metadata")
+	}
+	return readEmbeddingSpec(ctx, ms)
+}
+
+// readReembedTarget returns the spec staging was prepared for, or a zero spec
+func readReembedTarget(ctx context.Context, store metadataStore) (EmbeddingSpec, error) {
+	return readSpec(ctx, store, metaReembedModel, metaReembedDim)
+}
@@ -7,6 +7,9 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
@@ -18,10 +21,24 @@ import (
 // SQLite implements Storage using SQLite with sqlite-vec
 type SQLite struct {
 	conn *sql.DB
+	dim  int // embedding dimension used when creating the vector table
 }
 
-// NewSQLite creates a new SQLite storage
+// NewSQLite creates a new SQLite storage, applying any pending migrations
 func NewSQLite(path string) (*SQLite, error) {
+	s, err := openSQLite(path, DefaultEmbeddingDim)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Migrate(context.Background()); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	return s, nil
+}
+
+// openSQLite opens the database without touching the schema
+func openSQLite(path string, dim int) (*SQLite, error) {
 	sqlite_vec.Auto()
 
 	// Pragmas go in the DSN, not a one-time Exec: busy_timeout is per-connection,
@@ -35,43 +52,7 @@ func NewSQLite(path string) (*SQLite, error) {
 	// database/sql pools connections even in one process; one writer serializes them.
 	conn.SetMaxOpenConns(1)
 
-	s := &SQLite{conn: conn}
-	if err := s.initSchema(); err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
-	}
-
-	return s, nil
-}
-
-func (s *SQLite) initSchema() error {
-	schema := `
-		CREATE TABLE IF NOT EXISTS memories (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			type TEXT NOT NULL CHECK(type IN ('decision', 'learning', 'pattern')),
-			area TEXT NOT NULL,
-			content TEXT NOT NULL,
-			rationale TEXT,
-			is_valid BOOLEAN NOT NULL DEFAULT TRUE,
-			superseded_by INTEGER REFERENCES memories(id),
-			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			author_name TEXT NOT NULL DEFAULT '',
-			author_email TEXT NOT NULL DEFAULT '',
-			repo TEXT NOT NULL DEFAULT ''
-		);
-
-		CREATE INDEX IF NOT EXISTS idx_memories_type ON memories(type);
-		CREATE INDEX IF NOT EXISTS idx_memories_area ON memories(area);
-		CREATE INDEX IF NOT EXISTS idx_memories_is_valid ON memories(is_valid);
-		CREATE INDEX IF NOT EXISTS idx_memories_repo ON memories(repo);
-
-		CREATE VIRTUAL TABLE IF NOT EXISTS memory_embeddings USING vec0(
-			memory_id INTEGER PRIMARY KEY,
-			embedding FLOAT[768]
-		);
-	`
-	_, err := s.conn.Exec(schema)
-	return err
+	return &SQLite{conn: conn, dim: dim}, nil
 }
 
 func (s *SQLite) Close() error {
@@ -85,48 +66,40 @@ func (s *SQLite) Add(ctx context.Context, mem types.Memory, embedding []float32)
 	}
 	defer tx.Rollback()
 
-	result, err := tx.ExecContext(ctx,
-		`INSERT INTO memories (type, area, content, rationale, author_name, author_email, repo) VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		mem.Type, mem.Area, mem.Content, mem.Rationale, mem.AuthorName, mem.AuthorEmail, mem.Repo,
-	)
+	added, err := sqliteInsertMemory(ctx, tx, mem, embedding)
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert memory: %w", err)
+		return nil, err
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
+	return added, nil
+}
 
-	embeddingJSON, err := json.Marshal(embedding)
+func (s *SQLite) AddBatch(ctx context.Context, mems []types.Memory, embeddings [][]float32) ([]types.Memory, error) {
+	if len(embeddings) != len(mems) {
+		return nil, fmt.Errorf("got %d embeddings for %d memories", len(embeddings), len(mems))
+	}
+	if len(mems) == 0 {
+		return []types.Memory{}, nil
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal embedding: %w", err)
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	_, err = tx.ExecContext(ctx,
-		`INSERT INTO memory_embeddings (memory_id, embedding) VALUES (?, ?)`,
-		id, string(embeddingJSON),
-	)
+	added, err := sqliteInsertMemories(ctx, tx, mems, embeddings)
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert embedding: %w", err)
+		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
-
-	return &types.Memory{
-		ID:          id,
-		Type:        mem.Type,
-		Area:        mem.Area,
-		Content:     mem.Content,
-		Rationale:   mem.Rationale,
-		IsValid:     true,
-		CreatedAt:   time.Now(),
-		AuthorName:  mem.AuthorName,
-		AuthorEmail: mem.AuthorEmail,
-		Repo:        mem.Repo,
-	}, nil
+	return added, nil
 }
 
 func (s *SQLite) Search(ctx context.Context, embedding []float32, opts types.SearchOpts) ([]types.Memory, error) {
@@ -141,8 +114,7 @@ func (s *SQLite) Search(ctx context.Context, embedding []float32, opts types.Sea
 	}
 
 	query := `
-		SELECT m.id, m.type, m.area, m.content, m.rationale, m.is_valid,
-		       m.superseded_by, m.created_at, m.author_name, m.author_email, m.repo,
+		SELECT ` + memoryColumns + `,
 		       vec_distance_cosine(e.embedding, ?) AS distance
 		FROM memories m
 		JOIN memory_embeddings e ON m.id = e.memory_id
@@ -162,6 +134,9 @@ func (s *SQLite) Search(ctx context.Context, embedding []float32, opts types.Sea
 		query += " AND m.repo = ?"
 		args = append(args, opts.Repo)
 	}
+	tagClause, tagArgs := sqliteTagFilter(opts.Tags)
+	query += tagClause
+	args = append(args, tagArgs...)
 
 	query += `
 		ORDER BY distance
@@ -179,102 +154,665 @@ func (s *SQLite) List(ctx context.Context, opts types.ListOpts) ([]types.Memory,
 	}
 
 	query := `
-		SELECT id, type, area, content, rationale, is_valid,
-		       superseded_by, created_at, author_name, author_email, repo
-		FROM memories
+		SELECT ` + memoryColumns + `
+		FROM memories m
 		WHERE 1=1
 	`
 	args := []interface{}{}
 
 	if !opts.IncludeInvalid {
-		query += " AND is_valid = TRUE"
+		query += " AND m.is_valid = TRUE"
 	}
 	if opts.Type != "" {
-		query += " AND type = ?"
+		query += " AND m.type = ?"
 		args = append(args, opts.Type)
 	}
 	if opts.Area != "" {
-		query += " AND area = ?"
+		query += " AND m.area = ?"
 		args = append(args, opts.Area)
 	}
 	if opts.Repo != "" {
-		query += " AND repo = ?"
+		query += " AND m.repo = ?"
 		args = append(args, opts.Repo)
 	}
+	tagClause, tagArgs := sqliteTagFilter(opts.Tags)
+	query += tagClause
+	args = append(args, tagArgs...)
+	if opts.After != nil {
+		at := opts.After.CreatedAt.UTC().Format(sqliteTimeFormat)
+		query += " AND (m.created_at < ? OR (m.created_at = ? AND m.id < ?))"
+		args = append(args, at, at, opts.After.ID)
+	}
 
-	query += " ORDER BY created_at DESC LIMIT ?"
-	args = append(args, limit)
+	// created_at has one-second resolution, so the ID keeps ties in insert order
+	query += " ORDER BY m.created_at DESC, m.id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, max(opts.Offset, 0))
 
 	return s.queryMemories(ctx, query, args...)
 }
 
-func (s *SQLite) Invalidate(ctx context.Context, id int64, supersededBy *int64) error {
-	query := `UPDATE memories SET is_valid = FALSE`
-	args := []interface{}{}
-
-	if supersededBy != nil {
-		query += ", superseded_by = ?"
-		args = append(args, *supersededBy)
+func (s *SQLite) Get(ctx context.Context, id int64) (*types.Memory, error) {
+	query := `
+		SELECT ` + memoryColumns + `
+		FROM memories m
+		WHERE m.id = ?
+	`
+	memories, err := s.queryMemories(ctx, query, id)
+	if err != nil {
+		return nil, err
 	}
+	if len(memories) == 0 {
+		return nil, types.ErrNotFound
+	}
+	return &memories[0], nil
+}
 
-	query += " WHERE id = ?"
-	args = append(args, id)
+func (s *SQLite) Invalidate(ctx context.Context, inv types.Invalidation) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	result, err := s.conn.ExecContext(ctx, query, args...)
+	err = checkSupersede(inv, func(id int64) (*supersedeLink, error) {
+		var (
+			link         supersedeLink
+			supersededBy sql.NullInt64
+		)
+		err := tx.QueryRowContext(ctx, `SELECT repo, superseded_by FROM memories WHERE id = ?`, id).
+			Scan(&link.Repo, &supersededBy)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if supersededBy.Valid {
+			link.SupersededBy = &supersededBy.Int64
+		}
+		return &link, nil
+	})
 	if err != nil {
 		return err
 	}
 
-	rows, err := result.RowsAffected()
+	// Invalidating again replaces the link too, so a memory re-invalidated
+	// without a successor no longer points at the old one
+	_, err = tx.ExecContext(ctx,
+		`UPDATE memories
+		 SET is_valid = FALSE, invalidated_at = CURRENT_TIMESTAMP,
+		     invalidated_by_name = ?, invalidated_by_email = ?, invalidation_reason = ?,
+		     superseded_by = ?
+		 WHERE id = ?`,
+		inv.ByName, inv.ByEmail, inv.Reason, inv.SupersededBy, inv.ID,
+	)
 	if err != nil {
 		return err
 	}
 
-	if rows == 0 {
-		return fmt.Errorf("memory with id %d not found", id)
+	return tx.Commit()
+}
+
+func (s *SQLite) InvalidateMatching(ctx context.Context, bi types.BulkInvalidation) ([]int64, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	return nil
+	clause, args := sqliteMemoryFilter(bi.Filter)
+	rows, err := tx.QueryContext(ctx, `SELECT m.id FROM memories m WHERE m.is_valid = TRUE`+clause+` ORDER BY m.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = checkBulkSupersede(bi, ids, func(id int64) (*supersedeLink, error) {
+		var (
+			link         supersedeLink
+			supersededBy sql.NullInt64
+		)
+		err := tx.QueryRowContext(ctx, `SELECT repo, superseded_by FROM memories WHERE id = ?`, id).
+			Scan(&link.Repo, &supersededBy)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if supersededBy.Valid {
+			link.SupersededBy = &supersededBy.Int64
+		}
+		return &link, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if bi.DryRun || len(ids) == 0 {
+		return ids, nil
+	}
+
+	query := `UPDATE memories
+		SET is_valid = FALSE, invalidated_at = CURRENT_TIMESTAMP,
+		    invalidated_by_name = ?, invalidated_by_email = ?, invalidation_reason = ?,
+		    superseded_by = ?
+		WHERE id IN (`
+	args = []interface{}{bi.ByName, bi.ByEmail, bi.Reason, bi.SupersededBy}
+	query += sqlitePlaceholders(len(ids)) + ")"
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ids, nil
 }
 
-func (s *SQLite) queryMemoriesWithScore(ctx context.Context, query string, args ...interface{}) ([]types.Memory, error) {
-	rows, err := s.conn.QueryContext(ctx, query, args...)
+func (s *SQLite) Revalidate(ctx context.Context, rev types.Revalidation) (*types.Memory, error) {
+	// A passed expiry would have the next sweep invalidate the memory again
+	_, err := s.conn.ExecContext(ctx, `
+		UPDATE memories
+		SET is_valid = TRUE, superseded_by = NULL,
+		    invalidated_at = NULL, invalidated_by_name = '', invalidated_by_email = '', invalidation_reason = '',
+		    expires_at = CASE WHEN expires_at <= CURRENT_TIMESTAMP THEN NULL ELSE expires_at END,
+		    restored_at = CURRENT_TIMESTAMP, restored_by_name = ?, restored_by_email = ?
+		WHERE id = ? AND is_valid = FALSE
+	`, rev.ByName, rev.ByEmail, rev.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, rev.ID)
+}
+
+func (s *SQLite) Merge(ctx context.Context, m types.Merge, embedding []float32) (*types.Memory, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	originatedAt, err := checkMerge(m, func(id int64) (*mergeSource, error) {
+		var (
+			src          mergeSource
+			originatedAt sql.NullTime
+		)
+		err := tx.QueryRowContext(ctx,
+			`SELECT repo, is_valid, created_at, originated_at FROM memories WHERE id = ?`, id,
+		).Scan(&src.Repo, &src.IsValid, &src.CreatedAt, &originatedAt)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if originatedAt.Valid {
+			src.OriginatedAt = &originatedAt.Time
+		}
+		return &src, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mem := m.Memory
+	mem.OriginatedAt = &originatedAt
+	merged, err := sqliteInsertMemory(ctx, tx, mem, embedding)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{merged.ID, m.ByName, m.ByEmail, m.Reason}
+	for _, id := range m.Sources {
+		args = append(args, id)
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE memories
+		SET is_valid = FALSE, superseded_by = ?, invalidated_at = CURRENT_TIMESTAMP,
+		    invalidated_by_name = ?, invalidated_by_email = ?, invalidation_reason = ?
+		WHERE id IN (`+sqlitePlaceholders(len(m.Sources))+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+func (s *SQLite) Update(ctx context.Context, upd types.MemoryUpdate, embedding []float32) (*types.Memory, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		memType   string
+		area      string
+		content   string
+		rationale sql.NullString
+		version   int
+	)
+	err = tx.QueryRowContext(ctx,
+		`SELECT type, area, content, rationale, version FROM memories WHERE id = ?`,
+		upd.ID,
+	).Scan(&memType, &area, &content, &rationale, &version)
+	if err == sql.ErrNoRows {
+		return nil, types.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if version != upd.ExpectedVersion {
+		return nil, fmt.Errorf("%w: memory %d is at version %d, expected %d", types.ErrVersionConflict, upd.ID, version, upd.ExpectedVersion)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO memory_revisions (memory_id, version, type, area, content, rationale, revised_by_name, revised_by_email)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		upd.ID, version, memType, area, content, rationale, upd.EditorName, upd.EditorEmail,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record revision: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE memories
+		 SET type = ?, area = ?, content = ?, rationale = ?, expires_at = ?, review_by = ?,
+		     version = version + 1, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ?`,
+		upd.Type, upd.Area, upd.Content, upd.Rationale, sqliteNullTime(upd.ExpiresAt), sqliteNullTime(upd.ReviewBy), upd.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update memory: %w", err)
+	}
+
+	if embedding != nil {
+		embeddingJSON, err := json.Marshal(embedding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal embedding: %w", err)
+		}
+		// vec0 tables are replaced rather than updated in place
+		if _, err := tx.ExecContext(ctx, `DELETE FROM memory_embeddings WHERE memory_id = ?`, upd.ID); err != nil {
+			return nil, fmt.Errorf("failed to replace embedding: %w", err)
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO memory_embeddings (memory_id, embedding) VALUES (?, ?)`,
+			upd.ID, string(embeddingJSON),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to replace embedding: %w", err)
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE memories SET embedding_model = ? WHERE id = ?`,
+			upd.EmbeddingModel, upd.ID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record embedding model: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.Get(ctx, upd.ID)
+}
+
+func (s *SQLite) History(ctx context.Context, id int64) ([]types.Revision, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.conn.QueryContext(ctx, `
+		SELECT memory_id, version, type, area, content, rationale,
+		       revised_at, revised_by_name, revised_by_email
+		FROM memory_revisions
+		WHERE memory_id = ?
+		ORDER BY version
+	`, id)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var memories []types.Memory
+	var revisions []types.Revision
 	for rows.Next() {
-		var m types.Memory
+		var r types.Revision
 		var memType string
-		var supersededBy sql.NullInt64
 		var rationale sql.NullString
-		var distance float64
 
 		err := rows.Scan(
-			&m.ID, &memType, &m.Area, &m.Content, &rationale, &m.IsValid,
-			&supersededBy, &m.CreatedAt, &m.AuthorName, &m.AuthorEmail, &m.Repo,
-			&distance,
+			&r.MemoryID, &r.Version, &memType, &r.Area, &r.Content, &rationale,
+			&r.RevisedAt, &r.RevisedByName, &r.RevisedByEmail,
 		)
 		if err != nil {
 			return nil, err
 		}
 
-		m.Type = types.MemoryType(memType)
-		if rationale.Valid {
-			m.Rationale = rationale.String
+		r.Type = types.MemoryType(memType)
+		r.Rationale = rationale.String
+
+		revisions = append(revisions, r)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (s *SQLite) Tags(ctx context.Context, repo string) ([]types.TagCount, error) {
+	query := `
+		SELECT t.tag, COUNT(*) AS uses
+		FROM memory_tags t
+		JOIN memories m ON m.id = t.memory_id
+		WHERE m.is_valid = TRUE
+	`
+	args := []interface{}{}
+	if repo != "" {
+		query += " AND m.repo = ?"
+		args = append(args, repo)
+	}
+	query += " GROUP BY t.tag ORDER BY uses DESC, t.tag"
+
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []types.TagCount
+	for rows.Next() {
+		var tc types.TagCount
+		if err := rows.Scan(&tc.Tag, &tc.Count); err != nil {
+			return nil, err
 		}
-		if supersededBy.Valid {
-			m.SupersededBy = &supersededBy.Int64
+		tags = append(tags, tc)
+	}
+	return tags, rows.Err()
+}
+
+func (s *SQLite) Catalog(ctx context.Context, field types.CatalogField, repo string) ([]types.CatalogEntry, error) {
+	sf, err := catalogField(field)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + sqliteStatsColumns[sf] + `, MAX(m.author_name), COUNT(*),
+		       strftime('%Y-%m-%d %H:%M:%S', MAX(COALESCE(m.updated_at, m.created_at)))
+		FROM memories m
+		WHERE m.is_valid = TRUE
+	`
+	args := []interface{}{}
+	if repo != "" {
+		query += " AND m.repo = ?"
+		args = append(args, repo)
+	}
+	query += " GROUP BY 1"
+
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []types.CatalogEntry
+	for rows.Next() {
+		var (
+			e    types.CatalogEntry
+			last string
+		)
+		if err := rows.Scan(&e.Value, &e.Name, &e.Count, &last); err != nil {
+			return nil, err
 		}
+		if e.LastActivity, err = time.Parse(sqliteTimeFormat, last); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return finishCatalog(field, entries), nil
+}
+
+// sqliteStatsColumns are the expressions Stats groups by
+var sqliteStatsColumns = map[statsField]string{
+	statsRepo:   "m.repo",
+	statsArea:   "m.area",
+	statsType:   "m.type",
+	statsAuthor: "CASE WHEN m.author_email != '' THEN m.author_email ELSE m.author_name END",
+}
+
+func (s *SQLite) Stats(ctx context.Context, opts types.StatsOpts) (*types.Stats, error) {
+	where := ""
+	args := []interface{}{}
+	if opts.Repo != "" {
+		where = " WHERE m.repo = ?"
+		args = append(args, opts.Repo)
+	}
+
+	group := func(field statsField) ([]types.StatCount, error) {
+		rows, err := s.conn.QueryContext(ctx, `
+			SELECT `+sqliteStatsColumns[field]+`,
+			       SUM(CASE WHEN m.is_valid THEN 1 ELSE 0 END),
+			       SUM(CASE WHEN m.is_valid THEN 0 ELSE 1 END)
+			FROM memories m`+where+`
+			GROUP BY 1`, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var counts []types.StatCount
+		for rows.Next() {
+			var c types.StatCount
+			if err := rows.Scan(&c.Value, &c.Valid, &c.Invalid); err != nil {
+				return nil, err
+			}
+			counts = append(counts, c)
+		}
+		return counts, rows.Err()
+	}
+
+	days := func() ([]dayCount, error) {
+		rows, err := s.conn.QueryContext(ctx, `SELECT date(m.created_at), COUNT(*) FROM memories m`+where+` GROUP BY 1`, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var out []dayCount
+		for rows.Next() {
+			var (
+				day string
+				dc  dayCount
+			)
+			if err := rows.Scan(&day, &dc.Count); err != nil {
+				return nil, err
+			}
+			if dc.Day, err = time.Parse(time.DateOnly, day); err != nil {
+				return nil, err
+			}
+			out = append(out, dc)
+		}
+		return out, rows.Err()
+	}
+
+	return collectStats(opts, group, days)
+}
+
+func (s *SQLite) Expire(ctx context.Context, now time.Time) (int64, error) {
+	at := now.UTC().Format(sqliteTimeFormat)
+	result, err := s.conn.ExecContext(ctx,
+		`UPDATE memories
+		 SET is_valid = FALSE, invalidated_at = ?, invalidated_by_name = '', invalidated_by_email = '',
+		     invalidation_reason = ?
+		 WHERE is_valid = TRUE AND expires_at <= ?`,
+		at, types.ExpiredReason, at,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *SQLite) DueForReview(ctx context.Context, repo string, now time.Time, limit int) ([]types.Memory, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query := `
+		SELECT ` + memoryColumns + `
+		FROM memories m
+		WHERE m.is_valid = TRUE AND m.review_by <= ?
+	`
+	args := []interface{}{now.UTC().Format(sqliteTimeFormat)}
+	if repo != "" {
+		query += " AND m.repo = ?"
+		args = append(args, repo)
+	}
+	query += " ORDER BY m.review_by, m.id LIMIT ?"
+	args = append(args, limit)
+
+	return s.queryMemories(ctx, query, args...)
+}
+
+func (s *SQLite) RecordRetrievals(ctx context.Context, counts map[int64]int, at time.Time) error {
+	if err := markUsageTracked(ctx, s, at); err != nil {
+		return err
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		UPDATE memories
+		SET retrieval_count = retrieval_count + ?1,
+		    last_retrieved_at = CASE
+		        WHEN last_retrieved_at IS NULL OR last_retrieved_at < ?2 THEN ?2
+		        ELSE last_retrieved_at END
+		WHERE id = ?3
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	when := at.UTC().Format(sqliteTimeFormat)
+	for id, n := range counts {
+		if _, err := stmt.ExecContext(ctx, n, when, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) Unretrieved(ctx context.Context, repo string, before time.Time, limit int) ([]types.Memory, error) {
+	// A memory created before tracking started may have been used while no
+	// one was counting, so its age only counts from the start of tracking
+	if tracked, err := usageTrackedBefore(ctx, s, before); err != nil || !tracked {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query := `
+		SELECT ` + memoryColumns + `
+		FROM memories m
+		WHERE m.is_valid = TRUE AND m.retrieval_count = 0 AND m.created_at < ?
+	`
+	args := []interface{}{before.UTC().Format(sqliteTimeFormat)}
+	if repo != "" {
+		query += " AND m.repo = ?"
+		args = append(args, repo)
+	}
+	query += " ORDER BY m.created_at, m.id LIMIT ?"
+	args = append(args, limit)
+
+	return s.queryMemories(ctx, query, args...)
+}
+
+func (s *SQLite) Lineage(ctx context.Context, id int64) ([]types.Memory, error) {
+	// UNION drops rows already seen, which stops the recursion at cycles
+	query := `
+		WITH RECURSIVE
+		successors(id) AS (
+			SELECT superseded_by FROM memories WHERE id = ?1 AND superseded_by IS NOT NULL
+			UNION
+			SELECT m.superseded_by FROM memories m JOIN successors s ON m.id = s.id
+			WHERE m.superseded_by IS NOT NULL
+		),
+		predecessors(id) AS (
+			SELECT id FROM memories WHERE superseded_by = ?1
+			UNION
+			SELECT m.id FROM memories m JOIN predecessors p ON m.superseded_by = p.id
+		)
+		SELECT ` + memoryColumns + `
+		FROM memories m
+		WHERE m.id = ?1
+		   OR m.id IN (SELECT id FROM successors)
+		   OR m.id IN (SELECT id FROM predecessors)
+		ORDER BY m.id
+	`
+	memories, err := s.queryMemories(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if !containsMemory(memories, id) {
+		return nil, types.ErrNotFound
+	}
+	return memories, nil
+}
+
+func (s *SQLite) queryMemoriesWithScore(ctx context.Context, query string, args ...interface{}) ([]types.Memory, error) {
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memories []types.Memory
+	for rows.Next() {
+		var distance float64
+		m, err := scanSQLiteMemory(rows, &distance)
+		if err != nil {
+			return nil, err
+		}
+
 		// Convert cosine distance to similarity: 1 - distance
 		m.SimilarityScore = 1 - distance
 
 		memories = append(memories, m)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return memories, rows.Err()
+	return memories, s.loadTags(ctx, memories)
 }
 
 func (s *SQLite) queryMemories(ctx context.Context, query string, args ...interface{}) ([]types.Memory, error) {
@@ -286,29 +824,340 @@ func (s *SQLite) queryMemories(ctx context.Context, query string, args ...interf
 
 	var memories []types.Memory
 	for rows.Next() {
-		var m types.Memory
-		var memType string
-		var supersededBy sql.NullInt64
-		var rationale sql.NullString
+		m, err := scanSQLiteMemory(rows)
+		if err != nil {
+			return nil, err
+		}
 
-		err := rows.Scan(
-			&m.ID, &memType, &m.Area, &m.Content, &rationale, &m.IsValid,
-			&supersededBy, &m.CreatedAt, &m.AuthorName, &m.AuthorEmail, &m.Repo,
+		memories = append(memories, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return memories, s.loadTags(ctx, memories)
+}
+
+// loadTags fills in the tags of memories. The caller's rows must be done:
+// the pool has a single connection.
+func (s *SQLite) loadTags(ctx context.Context, memories []types.Memory) error {
+	ids := make([]int64, len(memories))
+	for i, m := range memories {
+		ids[i] = m.ID
+	}
+	tags, err := s.tagsOf(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range memories {
+		memories[i].Tags = tags[memories[i].ID]
+	}
+	return nil
+}
+
+// tagsOf returns the sorted tags of each of ids that has any
+func (s *SQLite) tagsOf(ctx context.Context, ids []int64) (map[int64][]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := s.conn.QueryContext(ctx,
+		`SELECT memory_id, tag FROM memory_tags WHERE memory_id IN (`+sqlitePlaceholders(len(args))+`) ORDER BY tag`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[int64][]string)
+	for rows.Next() {
+		var id int64
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return nil, err
+		}
+		tags[id] = append(tags[id], tag)
+	}
+	return tags, rows.Err()
+}
+
+// sqliteInsertMemory adds mem as a new valid memory with its embedding and
+// tags inside tx, and returns it as stored
+func sqliteInsertMemory(ctx context.Context, tx *sql.Tx, mem types.Memory, embedding []float32) (*types.Memory, error) {
+	added, err := sqliteInsertMemories(ctx, tx, []types.Memory{mem}, [][]float32{embedding})
+	if err != nil {
+		return nil, err
+	}
+	return &added[0], nil
+}
+
+// sqliteBatchRows caps the rows in one multi-row INSERT, keeping it well
+// under SQLite's limit on bound parameters
+const sqliteBatchRows = 500
+
+// sqliteInsertMemories adds mems as new valid memories inside tx, with
+// embeddings[i] as mems[i]'s vector, and returns them as stored, in order
+func sqliteInsertMemories(ctx context.Context, tx *sql.Tx, mems []types.Memory, embeddings [][]float32) ([]types.Memory, error) {
+	added := make([]types.Memory, 0, len(mems))
+	for start := 0; start < len(mems); start += sqliteBatchRows {
+		end := min(start+sqliteBatchRows, len(mems))
+		chunk, err := sqliteInsertChunk(ctx, tx, mems[start:end], embeddings[start:end])
+		if err != nil {
+			return nil, err
+		}
+		added = append(added, chunk...)
+	}
+	return added, nil
+}
+
+// sqliteInsertChunk inserts up to sqliteBatchRows memories with one statement
+// per table
+func sqliteInsertChunk(ctx context.Context, tx *sql.Tx, mems []types.Memory, embeddings [][]float32) ([]types.Memory, error) {
+	const columns = 11
+	args := make([]interface{}, 0, len(mems)*columns)
+	rows := make([]string, len(mems))
+	for i, mem := range mems {
+		rows[i] = "(" + sqlitePlaceholders(columns) + ")"
+		args = append(args,
+			mem.Type, mem.Area, mem.Content, mem.Rationale, mem.AuthorName, mem.AuthorEmail, mem.Repo, mem.EmbeddingModel,
+			sqliteNullTime(mem.ExpiresAt), sqliteNullTime(mem.ReviewBy), sqliteNullTime(mem.OriginatedAt),
 		)
+	}
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO memories (type, area, content, rationale, author_name, author_email, repo, embedding_model, expires_at, review_by, originated_at)
+		 VALUES `+strings.Join(rows, ", "), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert memory: %w", err)
+	}
+
+	// AUTOINCREMENT hands the rows of one statement consecutive IDs, and the
+	// open write transaction keeps anyone else from taking one in between
+	last, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	first := last - int64(len(mems)) + 1
+
+	args = args[:0]
+	for i, embedding := range embeddings {
+		embeddingJSON, err := json.Marshal(embedding)
 		if err != nil {
+			return nil, fmt.Errorf("failed to marshal embedding: %w", err)
+		}
+		rows[i] = "(?, ?)"
+		args = append(args, first+int64(i), string(embeddingJSON))
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO memory_embeddings (memory_id, embedding) VALUES `+strings.Join(rows, ", "), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert embedding: %w", err)
+	}
+
+	now := time.Now()
+	added := make([]types.Memory, len(mems))
+	for i, mem := range mems {
+		id := first + int64(i)
+		tags := uniqueTags(mem.Tags)
+		if err := sqliteInsertTags(ctx, tx, id, tags); err != nil {
 			return nil, err
 		}
+		added[i] = types.Memory{
+			ID:             id,
+			Type:           mem.Type,
+			Area:           mem.Area,
+			Content:        mem.Content,
+			Rationale:      mem.Rationale,
+			IsValid:        true,
+			CreatedAt:      now,
+			Version:        1,
+			EmbeddingModel: mem.EmbeddingModel,
+			AuthorName:     mem.AuthorName,
+			AuthorEmail:    mem.AuthorEmail,
+			Repo:           mem.Repo,
+			Tags:           tags,
+			ExpiresAt:      copyTime(mem.ExpiresAt),
+			ReviewBy:       copyTime(mem.ReviewBy),
+			OriginatedAt:   copyTime(mem.OriginatedAt),
+		}
+	}
+	return added, nil
+}
 
-		m.Type = types.MemoryType(memType)
-		if rationale.Valid {
-			m.Rationale = rationale.String
+// sqliteInsertTags records tags, already made unique, against a memory
+func sqliteInsertTags(ctx context.Context, tx *sql.Tx, id int64, tags []string) error {
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO memory_tags (memory_id, tag) VALUES (?, ?)`, id, tag); err != nil {
+			return fmt.Errorf("failed to insert tag: %w", err)
 		}
-		if supersededBy.Valid {
-			m.SupersededBy = &supersededBy.Int64
+	}
+	return nil
+}
+
+// sqliteTagFilter returns the conditions on m for a tag filter, with their
+// arguments. All matches through a count of distinct hits per memory.
+func sqliteTagFilter(f types.TagFilter) (string, []interface{}) {
+	var clause string
+	var args []interface{}
+	if len(f.Any) > 0 {
+		clause += " AND m.id IN (SELECT memory_id FROM memory_tags WHERE tag IN (" + sqlitePlaceholders(len(f.Any)) + "))"
+		for _, tag := range f.Any {
+			args = append(args, tag)
 		}
+	}
+	if all := uniqueTags(f.All); len(all) > 0 {
+		clause += " AND m.id IN (SELECT memory_id FROM memory_tags WHERE tag IN (" + sqlitePlaceholders(len(all)) +
+			") GROUP BY memory_id HAVING COUNT(*) = ?)"
+		for _, tag := range all {
+			args = append(args, tag)
+		}
+		args = append(args, len(all))
+	}
+	return clause, args
+}
 
-		memories = append(memories, m)
+// sqliteMemoryFilter returns the conditions on m for a memory filter, with
+// their arguments
+func sqliteMemoryFilter(f types.MemoryFilter) (string, []interface{}) {
+	var clause string
+	var args []interface{}
+	if f.Repo != "" {
+		clause += " AND m.repo = ?"
+		args = append(args, f.Repo)
+	}
+	if f.Area != "" {
+		clause += " AND m.area = ?"
+		args = append(args, f.Area)
+	}
+	if f.Type != "" {
+		clause += " AND m.type = ?"
+		args = append(args, f.Type)
+	}
+	if f.Author != "" {
+		clause += " AND (m.author_name = ? OR m.author_email = ?)"
+		args = append(args, f.Author, f.Author)
+	}
+	if f.CreatedBefore != nil {
+		clause += " AND m.created_at < ?"
+		args = append(args, sqliteNullTime(f.CreatedBefore))
+	}
+	return clause, args
+}
+
+// sqliteNullTime formats t as CURRENT_TIMESTAMP does, or returns nil for NULL
+func sqliteNullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// sqlitePlaceholders returns n comma-separated ? placeholders
+func sqlitePlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// scanSQLiteMemory scans the memoryColumns of the current row, followed by
+// any extra destinations selected after them
+func scanSQLiteMemory(rows *sql.Rows, extra ...interface{}) (types.Memory, error) {
+	var m types.Memory
+	var memType string
+	var supersededBy sql.NullInt64
+	var rationale sql.NullString
+	var updatedAt, expiresAt, reviewBy, invalidatedAt, restoredAt, originatedAt, lastRetrievedAt sql.NullTime
+
+	dest := []interface{}{
+		&m.ID, &memType, &m.Area, &m.Content, &rationale, &m.IsValid,
+		&supersededBy, &m.CreatedAt, &m.AuthorName, &m.AuthorEmail, &m.Repo,
+		&m.Version, &updatedAt, &m.EmbeddingModel, &expiresAt, &reviewBy,
+		&invalidatedAt, &m.InvalidatedByName, &m.InvalidatedByEmail, &m.InvalidationReason,
+		&restoredAt, &m.RestoredByName, &m.RestoredByEmail, &originatedAt,
+		&m.RetrievalCount, &lastRetrievedAt,
 	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return types.Memory{}, err
+	}
+
+	m.Type = types.MemoryType(memType)
+	if rationale.Valid {
+		m.Rationale = rationale.String
+	}
+	if supersededBy.Valid {
+		m.SupersededBy = &supersededBy.Int64
+	}
+	if updatedAt.Valid {
+		m.UpdatedAt = &updatedAt.Time
+	}
+	if expiresAt.Valid {
+		m.ExpiresAt = &expiresAt.Time
+	}
+	if reviewBy.Valid {
+		m.ReviewBy = &reviewBy.Time
+	}
+	if invalidatedAt.Valid {
+		m.InvalidatedAt = &invalidatedAt.Time
+	}
+	if restoredAt.Valid {
+		m.RestoredAt = &restoredAt.Time
+	}
+	if originatedAt.Valid {
+		m.OriginatedAt = &originatedAt.Time
+	}
+	if lastRetrievedAt.Valid {
+		m.LastRetrievedAt = &lastRetrievedAt.Time
+	}
+
+	return m, nil
+}
+
+func (s *SQLite) hasTable(ctx context.Context, name string) (bool, error) {
+	var exists bool
+	err := s.conn.QueryRowContext(ctx,
+		`SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = ?`, name,
+	).Scan(&exists)
+	return exists, err
+}
+
+func (s *SQLite) getMetadata(ctx context.Context, key string) (string, error) {
+	var value string
+	err := s.conn.QueryRowContext(ctx, `SELECT value FROM metadata WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+func (s *SQLite) setMetadata(ctx context.Context, key, value string) error {
+	_, err := s.conn.ExecContext(ctx,
+		`INSERT INTO metadata (key, value) VALUES (?, ?)
+		 ON CONFLICT(key) DO UPDATE SET value = excluded.value`,
+		key, value,
+	)
+	return err
+}
+
+// vectorDimensionPattern extracts N from the vec0 column declaration FLOAT[N]
+var vectorDimensionPattern = regexp.MustCompile(`(?i)FLOAT\[(\d+)\]`)
 
-	return memories, rows.Err()
+func (s *SQLite) vectorDimension(ctx context.Context) (int, error) {
+	var ddl string
+	err := s.conn.QueryRowContext(ctx,
+		`SELECT sql FROM sqlite_master WHERE name = 'memory_embeddings'`,
+	).Scan(&ddl)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	match := vectorDimensionPattern.FindStringSubmatch(ddl)
+	if match == nil {
+		return 0, nil
+	}
+	return strconv.Atoi(match[1])
 }
@@ -0,0 +1,154 @@
+//go:build cgo
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// sqliteTimeFormat matches what CURRENT_TIMESTAMP writes, so imported and
+// native timestamps sort together
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+func (s *SQLite) ExportMemories(ctx context.Context, afterID int64, limit int) ([]ExportedMemory, error) {
+	rows, err := s.conn.QueryContext(ctx, `
+		SELECT `+memoryColumns+`, vec_to_json(e.embedding)
+		FROM memories m
+		LEFT JOIN memory_embeddings e ON e.memory_id = m.id
+		WHERE m.id > ?
+		ORDER BY m.id
+		LIMIT ?
+	`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memories []ExportedMemory
+	for rows.Next() {
+		var embeddingJSON sql.NullString
+		m, err := scanSQLiteMemory(rows, &embeddingJSON)
+		if err != nil {
+			return nil, err
+		}
+
+		exported := ExportedMemory{Memory: m}
+		if embeddingJSON.Valid {
+			if err := json.Unmarshal([]byte(embeddingJSON.String), &exported.Embedding); err != nil {
+				return nil, fmt.Errorf("failed to decode embedding of memory %d: %w", m.ID, err)
+			}
+		}
+		memories = append(memories, exported)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(memories))
+	for i, m := range memories {
+		ids[i] = m.ID
+	}
+	tags, err := s.tagsOf(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range memories {
+		memories[i].Tags = tags[memories[i].ID]
+	}
+	return memories, nil
+}
+
+func (s *SQLite) ImportMemory(ctx context.Context, source string, mem ExportedMemory) (int64, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		`SELECT memory_id FROM memory_imports WHERE source = ? AND source_id = ?`, source, mem.ID,
+	).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO memories (type, area, content, rationale, is_valid, created_at, author_name, author_email, repo, version, updated_at, embedding_model, expires_at, review_by,
+		                       invalidated_at, invalidated_by_name, invalidated_by_email, invalidation_reason,
+		                       restored_at, restored_by_name, restored_by_email, originated_at,
+		                       retrieval_count, last_retrieved_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		mem.Type, mem.Area, mem.Content, mem.Rationale, mem.IsValid, mem.CreatedAt.UTC().Format(sqliteTimeFormat),
+		mem.AuthorName, mem.AuthorEmail, mem.Repo, mem.Version, sqliteNullTime(mem.UpdatedAt), mem.EmbeddingModel,
+		sqliteNullTime(mem.ExpiresAt), sqliteNullTime(mem.ReviewBy),
+		sqliteNullTime(mem.InvalidatedAt), mem.InvalidatedByName, mem.InvalidatedByEmail, mem.InvalidationReason,
+		sqliteNullTime(mem.RestoredAt), mem.RestoredByName, mem.RestoredByEmail, sqliteNullTime(mem.OriginatedAt),
+		mem.RetrievalCount, sqliteNullTime(mem.LastRetrievedAt),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert memory: %w", err)
+	}
+	if id, err = result.LastInsertId(); err != nil {
+		return 0, err
+	}
+
+	if mem.Embedding != nil {
+		embeddingJSON, err := json.Marshal(mem.Embedding)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal embedding: %w", err)
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO memory_embeddings (memory_id, embedding) VALUES (?, ?)`,
+			id, string(embeddingJSON),
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert embedding: %w", err)
+		}
+	}
+
+	if err := sqliteInsertTags(ctx, tx, id, uniqueTags(mem.Tags)); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO memory_imports (source, source_id, memory_id) VALUES (?, ?, ?)`,
+		source, mem.ID, id,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record import: %w", err)
+	}
+
+	return id, tx.Commit()
+}
+
+func (s *SQLite) ImportedIDs(ctx context.Context, source string) (map[int64]int64, error) {
+	rows, err := s.conn.QueryContext(ctx,
+		`SELECT source_id, memory_id FROM memory_imports WHERE source = ?`, source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int64]int64)
+	for rows.Next() {
+		var sourceID, id int64
+		if err := rows.Scan(&sourceID, &id); err != nil {
+			return nil, err
+		}
+		ids[sourceID] = id
+	}
+	return ids, rows.Err()
+}
+
+func (s *SQLite) SetSupersededBy(ctx context.Context, id, supersededBy int64) error {
+	_, err := s.conn.ExecContext(ctx,
+		`UPDATE memories SET superseded_by = ? WHERE id = ?`, supersededBy, id)
+	return err
+}
@@ -0,0 +1,197 @@
+//go:build cgo
+
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+func TestCopy_SQLiteToSQLite(t *testing.T) {
+	ctx := context.Background()
+	open := func(name string) storage.Storage {
+		t.Helper()
+		store, err := storage.New(ctx, storage.Config{
+			Driver:         "sqlite",
+			SQLitePath:     filepath.Join(t.TempDir(), name),
+			EmbeddingModel: "nomic-embed-text",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	}
+	src, dst := open("src.db"), open("dst.db")
+
+	vector := func(hot int) []float32 {
+		v := make([]float32, 768)
+		v[hot] = 1
+		return v
+	}
+	reviewBy := time.Date(2030, 3, 1, 0, 0, 0, 0, time.UTC)
+	add := func(store storage.Storage, content string, hot int) *types.Memory {
+		t.Helper()
+		mem, err := store.Add(ctx, types.Memory{
+			Type: types.TypeDecision, Area: "db", Content: content,
+			AuthorName: "Ada", AuthorEmail: "ada@example.com", Repo: "org/repo",
+			Tags: []string{"db", "infra"}, ReviewBy: &reviewBy,
+		}, vector(hot))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mem
+	}
+
+	// The destination already has a memory, so copied IDs must shift
+	add(dst, "unrelated", 9)
+
+	oldDecision := add(src, "Use MySQL", 1)
+	dropped := add(src, "Shard by tenant", 2)
+	newDecision := add(src, "Use Postgres", 3)
+	if err := src.Invalidate(ctx, types.Invalidation{ID: oldDecision.ID, SupersededBy: &newDecision.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Invalidate(ctx, types.Invalidation{ID: dropped.ID, Reason: "single tenant for now", ByName: "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := storage.Copy(ctx, src, dst, storage.CopyOptions{BatchSize: 2})
+	if err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if result.Total != 3 || result.Copied != 3 || result.Resumed != 0 || result.Linked != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	// The copy of the newest decision is still found by its original vector
+	found, err := dst.Search(ctx, vector(3), types.SearchOpts{Limit: 1})
+	if err != nil || len(found) != 1 {
+		t.Fatalf("expected a search hit, got %v (%v)", found, err)
+	}
+	copied := found[0]
+	if copied.Content != "Use Postgres" || copied.ID == newDecision.ID {
+		t.Fatalf("expected a remapped copy of %+v, got %+v", newDecision, copied)
+	}
+	if copied.AuthorEmail != "ada@example.com" || copied.Repo != "org/repo" || len(copied.Tags) != 2 {
+		t.Errorf("expected author, repo and tags to be kept, got %+v", copied)
+	}
+	original, err := src.Get(ctx, newDecision.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !copied.CreatedAt.Equal(original.CreatedAt) {
+		t.Errorf("expected created_at to be kept, got %v want %v", copied.CreatedAt, original.CreatedAt)
+	}
+	if copied.ReviewBy == nil || !copied.ReviewBy.Equal(reviewBy) {
+		t.Errorf("expected review_by to be kept, got %v want %v", copied.ReviewBy, reviewBy)
+	}
+
+	// The superseded memory points at the copy, not the source ID
+	list, err := dst.List(ctx, types.ListOpts{Limit: 10, IncludeInvalid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var linked int
+	for _, mem := range list {
+		if mem.Content == "Use MySQL" {
+			if mem.IsValid || mem.SupersededBy == nil || *mem.SupersededBy != copied.ID {
+				t.Errorf("expected invalid memory superseded by %d, got %+v", copied.ID, mem)
+			}
+			linked++
+		}
+		if mem.Content == "Shard by tenant" && (mem.InvalidatedAt == nil || mem.InvalidationReason != "single tenant for now" || mem.InvalidatedByName != "Ada") {
+			t.Errorf("expected the invalidation details to be kept, got %+v", mem)
+		}
+	}
+	if linked != 1 {
+		t.Fatalf("expected one copy of the superseded memory, got %d", linked)
+	}
+
+	// A second run only copies what's new
+	add(src, "Use pgbouncer", 4)
+	result, err = storage.Copy(ctx, src, dst, storage.CopyOptions{})
+	if err != nil {
+		t.Fatalf("resumed Copy failed: %v", err)
+	}
+	if result.Total != 4 || result.Copied != 1 || result.Resumed != 3 {
+		t.Fatalf("unexpected resumed result: %+v", result)
+	}
+
+	if _, err := storage.Copy(ctx, src, src, storage.CopyOptions{}); err == nil {
+		t.Error("expected copying a database onto itself to fail")
+	}
+}
+
+func TestCopy_VerifyCatchesDrift(t *testing.T) {
+	ctx := context.Background()
+	open := func(name string) storage.Storage {
+		t.Helper()
+		store, err := storage.New(ctx, storage.Config{
+			Driver:         "sqlite",
+			SQLitePath:     filepath.Join(t.TempDir(), name),
+			EmbeddingModel: "nomic-embed-text",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	}
+	src, dst := open("src.db"), open("dst.db")
+
+	mem, err := src.Add(ctx, types.Memory{
+		Type: types.TypeDecision, Area: "db", Content: "Use Postgres",
+		AuthorName: "Ada", AuthorEmail: "ada@example.com", Repo: "org/repo",
+		EmbeddingModel: "nomic-embed-text",
+	}, make([]float32, 768))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Copy(ctx, src, dst, storage.CopyOptions{}); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	source, err := storage.DatabaseID(ctx, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := dst.(storage.Importer).ImportedIDs(ctx, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := src.Get(ctx, mem.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		drift   func(m *types.Memory)
+		wantErr bool
+	}{
+		{"unchanged", func(m *types.Memory) {}, false},
+		{"created_at within a second", func(m *types.Memory) { m.CreatedAt = m.CreatedAt.Add(500 * time.Millisecond) }, false},
+		{"author name", func(m *types.Memory) { m.AuthorName = "Bob" }, true},
+		{"author email", func(m *types.Memory) { m.AuthorEmail = "bob@example.com" }, true},
+		{"repo", func(m *types.Memory) { m.Repo = "org/other" }, true},
+		{"created_at", func(m *types.Memory) { m.CreatedAt = m.CreatedAt.Add(-time.Hour) }, true},
+		{"embedding model", func(m *types.Memory) { m.EmbeddingModel = "mxbai-embed-large" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := *original
+			tt.drift(&want)
+			err := storage.VerifyCopy(ctx, dst, source, []types.Memory{want}, imported)
+			if tt.wantErr && !errors.Is(err, storage.ErrCopyMismatch) || !tt.wantErr && err != nil {
+				t.Errorf("wantErr %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"path/filepath"
 	"testing"
 
@@ -46,10 +47,10 @@ func TestSQLite_BusyTimeoutSet(t *testing.T) {
 	}
 }
 
-// TestSQLite_InvalidateDanglingSupersededBy documents that invalidating with a
-// superseded_by that does not exist must succeed (foreign_keys stays OFF — it
-// was never part of the storage hardening decision and turned a previously-OK
-// Invalidate into a constraint error).
+// TestSQLite_InvalidateDanglingSupersededBy documents that a superseded_by
+// that does not exist is rejected by Invalidate's own check, not by a
+// constraint: foreign_keys stays OFF, as it was never part of the storage
+// hardening decision.
 func TestSQLite_InvalidateDanglingSupersededBy(t *testing.T) {
 	db := filepath.Join(t.TempDir(), "m.db")
 	s, err := NewSQLite(db)
@@ -65,7 +66,44 @@ func TestSQLite_InvalidateDanglingSupersededBy(t *testing.T) {
 		t.Fatal(err)
 	}
 	nonexistent := int64(999999)
-	if err := s.Invalidate(context.Background(), mem.ID, &nonexistent); err != nil {
-		t.Fatalf("Invalidate with dangling superseded_by should succeed, got: %v", err)
+	err = s.Invalidate(context.Background(), types.Invalidation{ID: mem.ID, SupersededBy: &nonexistent})
+	if !errors.Is(err, types.ErrInvalidSupersede) {
+		t.Fatalf("Invalidate with dangling superseded_by should fail with ErrInvalidSupersede, got: %v", err)
+	}
+}
+
+// TestSQLite_LineageOfLegacyLinks checks Lineage still ends on the loops and
+// dangling successors Invalidate now rejects, which older databases and
+// imports may hold
+func TestSQLite_LineageOfLegacyLinks(t *testing.T) {
+	s, err := NewSQLite(filepath.Join(t.TempDir(), "m.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	var ids []int64
+	for _, content := range []string{"use tabs", "use spaces", "use Postgres"} {
+		mem, err := s.Add(ctx, types.Memory{Type: "decision", Area: "style", Content: content}, make([]float32, 768))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, mem.ID)
+	}
+	p, q, lone := ids[0], ids[1], ids[2]
+	for _, link := range [][2]int64{{p, q}, {q, p}, {lone, lone + 1000}} {
+		if _, err := s.conn.Exec(`UPDATE memories SET is_valid = FALSE, superseded_by = ? WHERE id = ?`, link[1], link[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lineage, err := s.Lineage(ctx, p)
+	if err != nil || len(lineage) != 2 {
+		t.Errorf("expected the loop's two memories, got %d (err %v)", len(lineage), err)
+	}
+	lineage, err = s.Lineage(ctx, lone)
+	if err != nil || len(lineage) != 1 || lineage[0].ID != lone {
+		t.Errorf("expected a dangling successor skipped, got %+v (err %v)", lineage, err)
 	}
 }
@@ -0,0 +1,146 @@
+//go:build cgo
+
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+func (s *SQLite) KeywordSearch(ctx context.Context, query string, opts types.SearchOpts) ([]types.Memory, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 5
+	}
+
+	terms := keywordTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	fts5, err := s.keywordIndexIsFTS5(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rank := "matchinfo(memories_fts, 'pcnx')"
+	if fts5 {
+		rank = "bm25(memories_fts)"
+	}
+
+	q := `
+		SELECT ` + memoryColumns + `, ` + rank + ` AS rank
+		FROM memories_fts
+		JOIN memories m ON m.id = memories_fts.rowid
+		WHERE memories_fts MATCH ? AND m.is_valid = TRUE
+	`
+	args := []interface{}{anyPhraseQuery(terms, "OR")}
+
+	if opts.Type != "" {
+		q += " AND m.type = ?"
+		args = append(args, opts.Type)
+	}
+	if opts.Area != "" {
+		q += " AND m.area = ?"
+		args = append(args, opts.Area)
+	}
+	if opts.Repo != "" {
+		q += " AND m.repo = ?"
+		args = append(args, opts.Repo)
+	}
+	tagClause, tagArgs := sqliteTagFilter(opts.Tags)
+	q += tagClause
+	args = append(args, tagArgs...)
+
+	// FTS4 has no ranking function, so its matches are scored and cut in Go
+	if fts5 {
+		q += " ORDER BY rank LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := s.conn.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memories []types.Memory
+	for rows.Next() {
+		var bm25 float64
+		var matchinfo []byte
+		var dest interface{} = &matchinfo
+		if fts5 {
+			dest = &bm25
+		}
+
+		m, err := scanSQLiteMemory(rows, dest)
+		if err != nil {
+			return nil, err
+		}
+
+		// bm25() is lower-is-better
+		if fts5 {
+			m.SimilarityScore = -bm25
+		} else {
+			m.SimilarityScore = fts4Score(matchinfo)
+		}
+		memories = append(memories, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !fts5 {
+		sort.SliceStable(memories, func(i, j int) bool {
+			return memories[i].SimilarityScore > memories[j].SimilarityScore
+		})
+		if len(memories) > limit {
+			memories = memories[:limit]
+		}
+	}
+	return memories, s.loadTags(ctx, memories)
+}
+
+// keywordIndexIsFTS5 reports which module the keyword index was created
+// with, which depends on the build that ran the migration
+func (s *SQLite) keywordIndexIsFTS5(ctx context.Context) (bool, error) {
+	var fts5 bool
+	err := s.conn.QueryRowContext(ctx,
+		`SELECT sql LIKE '%fts5%' FROM sqlite_master WHERE name = 'memories_fts'`,
+	).Scan(&fts5)
+	if err != nil {
+		return false, fmt.Errorf("keyword index unavailable (run migrations): %w", err)
+	}
+	return fts5, nil
+}
+
+// fts4Score computes a TF-IDF score from matchinfo(..., 'pcnx'): phrase and
+// column counts, the row count, then per phrase and column the hits in this
+// row, the hits in all rows and the rows with any hit
+func fts4Score(matchinfo []byte) float64 {
+	if len(matchinfo) < 12 {
+		return 0
+	}
+	value := func(i int) float64 {
+		return float64(binary.NativeEndian.Uint32(matchinfo[i*4:]))
+	}
+
+	phrases, columns, rows := int(value(0)), int(value(1)), value(2)
+	if len(matchinfo) < (3+3*phrases*columns)*4 {
+		return 0
+	}
+
+	var score float64
+	for i := 0; i < phrases*columns; i++ {
+		hits, docs := value(3+3*i), value(3+3*i+2)
+		if hits > 0 && docs > 0 {
+			score += hits * math.Log(1+rows/docs)
+		}
+	}
+	return score
+}
@@ -0,0 +1,351 @@
+//go:build cgo
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// sqliteMigration is a numbered schema change applied inside a transaction
+type sqliteMigration struct {
+	Migration
+	up func(ctx context.Context, s *SQLite, tx *sql.Tx) error
+}
+
+// sqliteMigrations lists every schema change in order. Append new migrations;
+// never edit or renumber one that has shipped. Migrations 1 and 2 predate
+// schema_migrations and stay idempotent so unversioned databases adopt them.
+var sqliteMigrations = []sqliteMigration{
+	{Migration{1, "create memories and embeddings"}, sqliteCreateMemories},
+	{Migration{2, "add memory versions and revisions"}, sqliteAddRevisions},
+	{Migration{3, "create metadata table"}, sqliteCreateMetadata},
+	{Migration{4, "track embedding model per memory"}, sqliteAddEmbeddingModel},
+	{Migration{5, "create keyword search index"}, sqliteCreateKeywordIndex},
+	{Migration{6, "track imported memories"}, sqliteCreateImports},
+	{Migration{7, "create memory tags"}, sqliteCreateTags},
+	{Migration{8, "add expiry and review dates"}, sqliteAddExpiry},
+	{Migration{9, "record who invalidated memories"}, sqliteAddInvalidation},
+	{Migration{10, "record who restored memories"}, sqliteAddRestore},
+	{Migration{11, "record when merged memories originated"}, sqliteAddOriginatedAt},
+	{Migration{12, "track memory retrievals"}, sqliteAddRetrievals},
+}
+
+func sqliteCreateMemories(ctx context.Context, s *SQLite, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS memories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			type TEXT NOT NULL CHECK(type IN ('decision', 'learning', 'pattern')),
+			area TEXT NOT NULL,
+			content TEXT NOT NULL,
+			rationale TEXT,
+			is_valid BOOLEAN NOT NULL DEFAULT TRUE,
+			superseded_by INTEGER REFERENCES memories(id),
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			author_name TEXT NOT NULL DEFAULT '',
+			author_email TEXT NOT NULL DEFAULT '',
+			repo TEXT NOT NULL DEFAULT ''
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_memories_type ON memories(type);
+		CREATE INDEX IF NOT EXISTS idx_memories_area ON memories(area);
+		CREATE INDEX IF NOT EXISTS idx_memories_is_valid ON memories(is_valid);
+		CREATE INDEX IF NOT EXISTS idx_memories_repo ON memories(repo);
+
+		CREATE VIRTUAL TABLE IF NOT EXISTS memory_embeddings USING vec0(
+			memory_id INTEGER PRIMARY KEY,
+			embedding FLOAT[%d]
+		);
+	`, s.dim))
+	return err
+}
+
+func sqliteAddRevisions(ctx context.Context, s *SQLite, tx *sql.Tx) error {
+	if err := sqliteAddColumnIfMissing(ctx, tx, "memories", "version", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+	if err := sqliteAddColumnIfMissing(ctx, tx, "memories", "updated_at", "TIMESTAMP"); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS memory_revisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			memory_id INTEGER NOT NULL REFERENCES memories(id),
+			version INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			area TEXT NOT NULL,
+			content TEXT NOT NULL,
+			rationale TEXT,
+			revised_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			revised_by_name TEXT NOT NULL DEFAULT '',
+			revised_by_email TEXT NOT NULL DEFAULT '',
+			UNIQUE(memory_id, version)
+		);
+	`)
+	return err
+}
+
+func sqliteCreateMetadata(ctx context.Context, s *SQLite, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		CREATE TABLE metadata (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		)
+	`)
+	return err
+}
+
+func sqliteAddEmbeddingModel(ctx context.Context, s *SQLite, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		ALTER TABLE memories ADD COLUMN embedding_model TEXT NOT NULL DEFAULT '';
+
+		UPDATE memories
+		SET embedding_model = COALESCE((SELECT value FROM metadata WHERE key = 'embedding_model'), '');
+	`)
+	return err
+}
+
+// sqliteCreateKeywordIndex indexes memory text for KeywordSearch. FTS5 needs
+// the sqlite_fts5 build tag; builds without it get FTS4, ranked in Go.
+// Triggers keep the index in step with memories.
+func sqliteCreateKeywordIndex(ctx context.Context, s *SQLite, tx *sql.Tx) error {
+	var fts5 bool
+	if err := tx.QueryRowContext(ctx, `SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5); err != nil {
+		return err
+	}
+
+	schema := `
+		CREATE VIRTUAL TABLE memories_fts USING fts4(content="memories", area, content, rationale);
+
+		CREATE TRIGGER memories_fts_insert AFTER INSERT ON memories BEGIN
+			INSERT INTO memories_fts(docid, area, content, rationale)
+			VALUES (new.id, new.area, new.content, new.rationale);
+		END;
+		CREATE TRIGGER memories_fts_before_update BEFORE UPDATE OF area, content, rationale ON memories BEGIN
+			DELETE FROM memories_fts WHERE docid = old.id;
+		END;
+		CREATE TRIGGER memories_fts_after_update AFTER UPDATE OF area, content, rationale ON memories BEGIN
+			INSERT INTO memories_fts(docid, area, content, rationale)
+			VALUES (new.id, new.area, new.content, new.rationale);
+		END;
+		CREATE TRIGGER memories_fts_delete BEFORE DELETE ON memories BEGIN
+			DELETE FROM memories_fts WHERE docid = old.id;
+		END;
+	`
+	if fts5 {
+		schema = `
+			CREATE VIRTUAL TABLE memories_fts USING fts5(area, content, rationale, content='memories', content_rowid='id');
+
+			CREATE TRIGGER memories_fts_insert AFTER INSERT ON memories BEGIN
+				INSERT INTO memories_fts(rowid, area, content, rationale)
+				VALUES (new.id, new.area, new.content, new.rationale);
+			END;
+			CREATE TRIGGER memories_fts_update AFTER UPDATE OF area, content, rationale ON memories BEGIN
+				INSERT INTO memories_fts(memories_fts, rowid, area, content, rationale)
+				VALUES ('delete', old.id, old.area, old.content, old.rationale);
+				INSERT INTO memories_fts(rowid, area, content, rationale)
+				VALUES (new.id, new.area, new.content, new.rationale);
+			END;
+			CREATE TRIGGER memories_fts_delete AFTER DELETE ON memories BEGIN
+				INSERT INTO memories_fts(memories_fts, rowid, area, content, rationale)
+				VALUES ('delete', old.id, old.area, old.content, old.rationale);
+			END;
+		`
+	}
+
+	if _, err := tx.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+	// Index memories written before this migration
+	_, err := tx.ExecContext(ctx, `INSERT INTO memories_fts(memories_fts) VALUES ('rebuild')`)
+	return err
+}
+
+func sqliteCreateImports(ctx context.Context, s *SQLite, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		CREATE TABLE memory_imports (
+			source TEXT NOT NULL,
+			source_id INTEGER NOT NULL,
+			memory_id INTEGER NOT NULL REFERENCES memories(id),
+			PRIMARY KEY (source, source_id)
+		)
+	`)
+	return err
+}
+
+func sqliteCreateTags(ctx context.Context, s *SQLite, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		CREATE TABLE memory_tags (
+			memory_id INTEGER NOT NULL REFERENCES memories(id),
+			tag TEXT NOT NULL,
+			PRIMARY KEY (memory_id, tag)
+		);
+
+		CREATE INDEX idx_memory_tags_tag ON memory_tags(tag);
+	`)
+	return err
+}
+
+func sqliteAddExpiry(ctx context.Context, s *SQLite, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		ALTER TABLE memories ADD COLUMN expires_at TIMESTAMP;
+		ALTER TABLE memories ADD COLUMN review_by TIMESTAMP;
+
+		CREATE INDEX idx_memories_expires_at ON memories(expires_at) WHERE expires_at IS NOT NULL;
+		CREATE INDEX idx_memories_review_by ON memories(review_by) WHERE review_by IS NOT NULL;
+	`)
+	return err
+}
+
+func sqliteAddInvalidation(ctx context.Context, s *SQLite, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		ALTER TABLE memories ADD COLUMN invalidated_at TIMESTAMP;
+		ALTER TABLE memories ADD COLUMN invalidated_by_name TEXT NOT NULL DEFAULT '';
+		ALTER TABLE memories ADD COLUMN invalidated_by_email TEXT NOT NULL DEFAULT '';
+		ALTER TABLE memories ADD COLUMN invalidation_reason TEXT NOT NULL DEFAULT '';
+	`)
+	return err
+}
+
+func sqliteAddRestore(ctx context.Context, s *SQLite, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		ALTER TABLE memories ADD COLUMN restored_at TIMESTAMP;
+		ALTER TABLE memories ADD COLUMN restored_by_name TEXT NOT NULL DEFAULT '';
+		ALTER TABLE memories ADD COLUMN restored_by_email TEXT NOT NULL DEFAULT '';
+	`)
+	return err
+}
+
+func sqliteAddOriginatedAt(ctx context.Context, s *SQLite, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE memories ADD COLUMN originated_at TIMESTAMP`)
+	return err
+}
+
+func sqliteAddRetrievals(ctx context.Context, s *SQLite, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		ALTER TABLE memories ADD COLUMN retrieval_count INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE memories ADD COLUMN last_retrieved_at TIMESTAMP;
+	`)
+	return err
+}
+
+// sqliteAddColumnIfMissing runs ALTER TABLE ADD COLUMN unless the column
+// already exists (SQLite has no ADD COLUMN IF NOT EXISTS)
+func sqliteAddColumnIfMissing(ctx context.Context, tx *sql.Tx, table, column, definition string) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?`,
+		table, column,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// Migrate applies pending migrations. Each migration claims its version row
+// first, so a second process racing on the same file skips it instead of
+// applying it twice.
+func (s *SQLite) Migrate(ctx context.Context) error {
+	_, err := s.conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	status, err := s.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkSchemaVersion(status); err != nil {
+		return err
+	}
+
+	pending := make(map[int]bool, len(status.Pending))
+	for _, m := range status.Pending {
+		pending[m.Version] = true
+	}
+
+	for _, m := range sqliteMigrations {
+		if !pending[m.Version] {
+			continue
+		}
+		if err := s.applyMigration(ctx, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLite) applyMigration(ctx context.Context, m sqliteMigration) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT OR IGNORE INTO schema_migrations (version, description) VALUES (?, ?)`,
+		m.Version, m.Description,
+	)
+	if err != nil {
+		return err
+	}
+	if claimed, _ := result.RowsAffected(); claimed == 0 {
+		return nil
+	}
+
+	if err := m.up(ctx, s, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrationStatus reports applied and pending migrations
+func (s *SQLite) MigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	known := make([]Migration, len(sqliteMigrations))
+	for i, m := range sqliteMigrations {
+		known[i] = m.Migration
+	}
+
+	hasTable, err := s.hasTable(ctx, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if !hasTable {
+		return buildMigrationStatus(known, nil), nil
+	}
+
+	rows, err := s.conn.QueryContext(ctx,
+		`SELECT version, description, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var a AppliedMigration
+		if err := rows.Scan(&a.Version, &a.Description, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return buildMigrationStatus(known, applied), nil
+}
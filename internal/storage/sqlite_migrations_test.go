@@ -0,0 +1,181 @@
+//go:build cgo
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLite_MigrateFreshDatabase(t *testing.T) {
+	s, err := NewSQLite(filepath.Join(t.TempDir(), "m.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	status, err := s.MigrationStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.UpToDate() || len(status.Pending) != 0 {
+		t.Fatalf("expected fresh database to be up to date, got %+v", status)
+	}
+	if status.Current != len(sqliteMigrations) || len(status.Applied) != len(sqliteMigrations) {
+		t.Fatalf("expected all %d migrations recorded, got current=%d applied=%d",
+			len(sqliteMigrations), status.Current, len(status.Applied))
+	}
+
+	// Re-running is a no-op
+	if err := s.Migrate(context.Background()); err != nil {
+		t.Fatalf("second Migrate failed: %v", err)
+	}
+}
+
+// TestSQLite_MigrateUnversionedDatabase opens a database created before
+// schema_migrations existed and checks its data survives adoption.
+func TestSQLite_MigrateUnversionedDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "m.db")
+	legacy, err := openSQLite(path, DefaultEmbeddingDim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = legacy.conn.Exec(`
+		CREATE TABLE memories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			type TEXT NOT NULL CHECK(type IN ('decision', 'learning', 'pattern')),
+			area TEXT NOT NULL,
+			content TEXT NOT NULL,
+			rationale TEXT,
+			is_valid BOOLEAN NOT NULL DEFAULT TRUE,
+			superseded_by INTEGER REFERENCES memories(id),
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			author_name TEXT NOT NULL DEFAULT '',
+			author_email TEXT NOT NULL DEFAULT '',
+			repo TEXT NOT NULL DEFAULT ''
+		);
+		CREATE VIRTUAL TABLE memory_embeddings USING vec0(
+			memory_id INTEGER PRIMARY KEY,
+			embedding FLOAT[768]
+		);
+		INSERT INTO memories (type, area, content) VALUES ('decision', 'auth', 'Use JWT');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+
+	s, err := NewSQLite(path)
+	if err != nil {
+		t.Fatalf("failed to migrate unversioned database: %v", err)
+	}
+	defer s.Close()
+
+	mem, err := s.Get(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Get failed after migration: %v", err)
+	}
+	if mem.Content != "Use JWT" || mem.Version != 1 {
+		t.Fatalf("expected legacy row at version 1, got %+v", mem)
+	}
+}
+
+// TestSQLite_UpgradedDatabaseStaleAfterTracking checks memories from before
+// retrieval counts existed aren't reported unretrieved until tracking has
+// been on for the whole window.
+func TestSQLite_UpgradedDatabaseStaleAfterTracking(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "m.db")
+	legacy, err := openSQLite(path, DefaultEmbeddingDim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = legacy.conn.Exec(`
+		CREATE TABLE memories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			type TEXT NOT NULL CHECK(type IN ('decision', 'learning', 'pattern')),
+			area TEXT NOT NULL,
+			content TEXT NOT NULL,
+			rationale TEXT,
+			is_valid BOOLEAN NOT NULL DEFAULT TRUE,
+			superseded_by INTEGER REFERENCES memories(id),
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			author_name TEXT NOT NULL DEFAULT '',
+			author_email TEXT NOT NULL DEFAULT '',
+			repo TEXT NOT NULL DEFAULT ''
+		);
+		CREATE VIRTUAL TABLE memory_embeddings USING vec0(
+			memory_id INTEGER PRIMARY KEY,
+			embedding FLOAT[768]
+		);
+		INSERT INTO memories (type, area, content, created_at)
+		VALUES ('decision', 'auth', 'Use JWT', datetime('now', '-90 days'));
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+
+	s, err := NewSQLite(path)
+	if err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+	monthAgo := now.AddDate(0, 0, -30)
+	if stale, err := s.Unretrieved(ctx, "", monthAgo, 10); err != nil || len(stale) != 0 {
+		t.Fatalf("expected nothing stale before tracking, got %v (err %v)", stale, err)
+	}
+
+	// Tracking starts now, so a month later is the first time the memory counts
+	if err := s.RecordRetrievals(ctx, nil, now); err != nil {
+		t.Fatalf("RecordRetrievals failed: %v", err)
+	}
+	if stale, err := s.Unretrieved(ctx, "", monthAgo, 10); err != nil || len(stale) != 0 {
+		t.Errorf("expected nothing stale on the day tracking started, got %v (err %v)", stale, err)
+	}
+	if stale, err := s.Unretrieved(ctx, "", now.Add(time.Minute), 10); err != nil || len(stale) != 1 {
+		t.Errorf("expected the memory stale once tracked for the window, got %v (err %v)", stale, err)
+	}
+}
+
+func TestSQLite_RefusesNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "m.db")
+	s, err := NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.conn.Exec(`INSERT INTO schema_migrations (version, description) VALUES (999, 'from the future')`); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	ctx := context.Background()
+	for _, noMigrate := range []bool{false, true} {
+		_, err := New(ctx, Config{Driver: "sqlite", SQLitePath: path, NoMigrate: noMigrate})
+		if !errors.Is(err, ErrSchemaTooNew) {
+			t.Errorf("NoMigrate=%v: expected ErrSchemaTooNew, got %v", noMigrate, err)
+		}
+	}
+}
+
+func TestSQLite_NoMigrateLeavesSchemaAlone(t *testing.T) {
+	ctx := context.Background()
+	store, err := New(ctx, Config{Driver: "sqlite", SQLitePath: filepath.Join(t.TempDir(), "m.db"), NoMigrate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	status, err := store.(Migrator).MigrationStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Current != 0 || len(status.Pending) != len(sqliteMigrations) {
+		t.Fatalf("expected every migration pending, got %+v", status)
+	}
+}
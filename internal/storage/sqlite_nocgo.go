@@ -5,6 +5,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/MereWhiplash/engram-cogitator/internal/types"
 )
@@ -19,22 +20,146 @@ func NewSQLite(path string) (*SQLite, error) {
 	return nil, errNoCGO
 }
 
+func openSQLite(path string, dim int) (*SQLite, error) {
+	return nil, errNoCGO
+}
+
+func (s *SQLite) Migrate(ctx context.Context) error {
+	return errNoCGO
+}
+
+func (s *SQLite) MigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	return nil, errNoCGO
+}
+
 func (s *SQLite) Add(ctx context.Context, mem types.Memory, embedding []float32) (*types.Memory, error) {
 	return nil, errNoCGO
 }
 
+func (s *SQLite) AddBatch(ctx context.Context, mems []types.Memory, embeddings [][]float32) ([]types.Memory, error) {
+	return nil, errNoCGO
+}
+
 func (s *SQLite) Search(ctx context.Context, embedding []float32, opts types.SearchOpts) ([]types.Memory, error) {
 	return nil, errNoCGO
 }
 
+func (s *SQLite) KeywordSearch(ctx context.Context, query string, opts types.SearchOpts) ([]types.Memory, error) {
+	return nil, errNoCGO
+}
+
 func (s *SQLite) List(ctx context.Context, opts types.ListOpts) ([]types.Memory, error) {
 	return nil, errNoCGO
 }
 
-func (s *SQLite) Invalidate(ctx context.Context, id int64, supersededBy *int64) error {
+func (s *SQLite) Get(ctx context.Context, id int64) (*types.Memory, error) {
+	return nil, errNoCGO
+}
+
+func (s *SQLite) Invalidate(ctx context.Context, inv types.Invalidation) error {
+	return errNoCGO
+}
+
+func (s *SQLite) Merge(ctx context.Context, m types.Merge, embedding []float32) (*types.Memory, error) {
+	return nil, errNoCGO
+}
+
+func (s *SQLite) Update(ctx context.Context, upd types.MemoryUpdate, embedding []float32) (*types.Memory, error) {
+	return nil, errNoCGO
+}
+
+func (s *SQLite) History(ctx context.Context, id int64) ([]types.Revision, error) {
+	return nil, errNoCGO
+}
+
+func (s *SQLite) Tags(ctx context.Context, repo string) ([]types.TagCount, error) {
+	return nil, errNoCGO
+}
+
+func (s *SQLite) Catalog(ctx context.Context, field types.CatalogField, repo string) ([]types.CatalogEntry, error) {
+	return nil, errNoCGO
+}
+
+func (s *SQLite) Stats(ctx context.Context, opts types.StatsOpts) (*types.Stats, error) {
+	return nil, errNoCGO
+}
+
+func (s *SQLite) Expire(ctx context.Context, now time.Time) (int64, error) {
+	return 0, errNoCGO
+}
+
+func (s *SQLite) DueForReview(ctx context.Context, repo string, now time.Time, limit int) ([]types.Memory, error) {
+	return nil, errNoCGO
+}
+
+func (s *SQLite) RecordRetrievals(ctx context.Context, counts map[int64]int, at time.Time) error {
 	return errNoCGO
 }
 
+func (s *SQLite) Unretrieved(ctx context.Context, repo string, before time.Time, limit int) ([]types.Memory, error) {
+	return nil, errNoCGO
+}
+
+func (s *SQLite) Lineage(ctx context.Context, id int64) ([]types.Memory, error) {
+	return nil, errNoCGO
+}
+
+func (s *SQLite) InvalidateMatching(ctx context.Context, bi types.BulkInvalidation) ([]int64, error) {
+	return nil, errNoCGO
+}
+
+func (s *SQLite) Revalidate(ctx context.Context, rev types.Revalidation) (*types.Memory, error) {
+	return nil, errNoCGO
+}
+
 func (s *SQLite) Close() error {
 	return nil
 }
+
+func (s *SQLite) getMetadata(ctx context.Context, key string) (string, error) {
+	return "", errNoCGO
+}
+
+func (s *SQLite) setMetadata(ctx context.Context, key, value string) error {
+	return errNoCGO
+}
+
+func (s *SQLite) vectorDimension(ctx context.Context) (int, error) {
+	return 0, errNoCGO
+}
+
+func (s *SQLite) PrepareReembed(ctx context.Context, target EmbeddingSpec) error {
+	return errNoCGO
+}
+
+func (s *SQLite) PendingReembed(ctx context.Context, limit int) ([]types.Memory, error) {
+	return nil, errNoCGO
+}
+
+func (s *SQLite) StageEmbedding(ctx context.Context, id int64, version int, embedding []float32) error {
+	return errNoCGO
+}
+
+func (s *SQLite) ReembedProgress(ctx context.Context, target EmbeddingSpec) (int, int, error) {
+	return 0, 0, errNoCGO
+}
+
+func (s *SQLite) CommitReembed(ctx context.Context) error {
+	return errNoCGO
+}
+
+func (s *SQLite) ExportMemories(ctx context.Context, afterID int64, limit int) ([]ExportedMemory, error) {
+	return nil, errNoCGO
+}
+
+func (s *SQLite) ImportMemory(ctx context.Context, source string, mem ExportedMemory) (int64, error) {
+	return 0, errNoCGO
+}
+
+func (s *SQLite) ImportedIDs(ctx context.Context, source string) (map[int64]int64, error) {
+	return nil, errNoCGO
+}
+
+func (s *SQLite) SetSupersededBy(ctx context.Context, id, supersededBy int64) error {
+	return errNoCGO
+}
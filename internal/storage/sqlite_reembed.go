@@ -0,0 +1,188 @@
+//go:build cgo
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// Staged vectors live in a plain table as JSON: vec0 fixes its dimension at
+// creation, and the live table is only rebuilt at commit time.
+const sqliteStagingPending = `
+	FROM memories m
+	LEFT JOIN memory_embeddings_next n ON n.memory_id = m.id
+	WHERE n.memory_id IS NULL OR n.version != m.version`
+
+func (s *SQLite) PrepareReembed(ctx context.Context, target EmbeddingSpec) error {
+	current, err := readReembedTarget(ctx, s)
+	if err != nil {
+		return err
+	}
+	exists, err := s.hasTable(ctx, "memory_embeddings_next")
+	if err != nil {
+		return err
+	}
+	if exists && current == target {
+		return nil
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		DROP TABLE IF EXISTS memory_embeddings_next;
+		CREATE TABLE memory_embeddings_next (
+			memory_id INTEGER PRIMARY KEY REFERENCES memories(id),
+			version INTEGER NOT NULL,
+			embedding TEXT NOT NULL
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create staging table: %w", err)
+	}
+	if err := sqliteSetMetadata(ctx, tx, metaReembedModel, target.Model); err != nil {
+		return err
+	}
+	if err := sqliteSetMetadata(ctx, tx, metaReembedDim, strconv.Itoa(target.Dimension)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) PendingReembed(ctx context.Context, limit int) ([]types.Memory, error) {
+	rows, err := s.conn.QueryContext(ctx,
+		`SELECT `+memoryColumns+sqliteStagingPending+` ORDER BY m.id LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memories []types.Memory
+	for rows.Next() {
+		m, err := scanSQLiteMemory(rows)
+		if err != nil {
+			return nil, err
+		}
+		memories = append(memories, m)
+	}
+	return memories, rows.Err()
+}
+
+func (s *SQLite) StageEmbedding(ctx context.Context, id int64, version int, embedding []float32) error {
+	embeddingJSON, err := json.Marshal(embedding)
+	if err != nil {
+		return fmt.Errorf("failed to marshal embedding: %w", err)
+	}
+	_, err = s.conn.ExecContext(ctx,
+		`INSERT INTO memory_embeddings_next (memory_id, version, embedding) VALUES (?, ?, ?)
+		 ON CONFLICT(memory_id) DO UPDATE SET version = excluded.version, embedding = excluded.embedding`,
+		id, version, string(embeddingJSON),
+	)
+	return err
+}
+
+func (s *SQLite) ReembedProgress(ctx context.Context, target EmbeddingSpec) (int, int, error) {
+	var total int
+	if err := s.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM memories`).Scan(&total); err != nil {
+		return 0, 0, err
+	}
+
+	current, err := readReembedTarget(ctx, s)
+	if err != nil {
+		return 0, 0, err
+	}
+	exists, err := s.hasTable(ctx, "memory_embeddings_next")
+	if err != nil {
+		return 0, 0, err
+	}
+	if !exists || current != target {
+		return 0, total, nil
+	}
+
+	var pending int
+	if err := s.conn.QueryRowContext(ctx, `SELECT COUNT(*)`+sqliteStagingPending).Scan(&pending); err != nil {
+		return 0, 0, err
+	}
+	return total - pending, total, nil
+}
+
+func (s *SQLite) CommitReembed(ctx context.Context) error {
+	target, err := readReembedTarget(ctx, s)
+	if err != nil {
+		return err
+	}
+	if target.Model == "" {
+		return fmt.Errorf("no re-embed in progress")
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var pending int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*)`+sqliteStagingPending).Scan(&pending); err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d memories still need a new vector", ErrReembedIncomplete, pending)
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+		DROP TABLE memory_embeddings;
+		CREATE VIRTUAL TABLE memory_embeddings USING vec0(
+			memory_id INTEGER PRIMARY KEY,
+			embedding FLOAT[%d]
+		);
+		INSERT INTO memory_embeddings (memory_id, embedding)
+		SELECT memory_id, embedding FROM memory_embeddings_next;
+		DROP TABLE memory_embeddings_next;
+	`, target.Dimension))
+	if err != nil {
+		return fmt.Errorf("failed to swap embeddings: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE memories SET embedding_model = ?`, target.Model); err != nil {
+		return fmt.Errorf("failed to record embedding model: %w", err)
+	}
+	if err := sqliteSetMetadata(ctx, tx, metaEmbeddingModel, target.Model); err != nil {
+		return err
+	}
+	if err := sqliteSetMetadata(ctx, tx, metaEmbeddingDim, strconv.Itoa(target.Dimension)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM metadata WHERE key IN (?, ?)`, metaReembedModel, metaReembedDim,
+	); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.dim = target.Dimension
+	return nil
+}
+
+// sqliteSetMetadata upserts a metadata key inside a transaction
+func sqliteSetMetadata(ctx context.Context, tx *sql.Tx, key, value string) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO metadata (key, value) VALUES (?, ?)
+		 ON CONFLICT(key) DO UPDATE SET value = excluded.value`,
+		key, value,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return nil
+}
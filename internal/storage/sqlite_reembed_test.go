@@ -0,0 +1,124 @@
+//go:build cgo
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+func TestSQLite_Reembed(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "m.db")
+	store, err := New(ctx, Config{Driver: "sqlite", SQLitePath: path, EmbeddingModel: "nomic-embed-text", EmbeddingDim: 768})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := store.(*SQLite)
+
+	oldVector := make([]float32, 768)
+	oldVector[0] = 1
+
+	var ids []int64
+	for _, content := range []string{"one", "two", "three"} {
+		mem, err := s.Add(ctx, types.Memory{Type: types.TypeLearning, Area: "a", Content: content, EmbeddingModel: "nomic-embed-text"}, oldVector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, mem.ID)
+	}
+	if err := s.Invalidate(ctx, types.Invalidation{ID: ids[2]}); err != nil {
+		t.Fatal(err)
+	}
+
+	target := EmbeddingSpec{Model: "mxbai-embed-large", Dimension: 1024}
+	vector := func(hot int) []float32 {
+		v := make([]float32, 1024)
+		v[hot] = 1
+		return v
+	}
+
+	if err := s.PrepareReembed(ctx, target); err != nil {
+		t.Fatalf("PrepareReembed failed: %v", err)
+	}
+	pending, err := s.PendingReembed(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].ID != ids[0] {
+		t.Fatalf("expected first 2 memories pending in ID order, got %+v", pending)
+	}
+	for i, mem := range pending {
+		if err := s.StageEmbedding(ctx, mem.ID, mem.Version, vector(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.CommitReembed(ctx); !errors.Is(err, ErrReembedIncomplete) {
+		t.Fatalf("expected ErrReembedIncomplete with one memory unstaged, got %v", err)
+	}
+
+	// Preparing again for the same target resumes instead of starting over
+	if err := s.PrepareReembed(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+	staged, total, err := s.ReembedProgress(ctx, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if staged != 2 || total != 3 {
+		t.Fatalf("expected 2/3 staged after resume, got %d/%d", staged, total)
+	}
+
+	// Editing a memory after it was staged makes it pending again
+	if _, err := s.Update(ctx, types.MemoryUpdate{ID: ids[0], Type: types.TypeLearning, Area: "a", Content: "one, edited", ExpectedVersion: 1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	pending, err = s.PendingReembed(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].ID != ids[0] || pending[1].ID != ids[2] {
+		t.Fatalf("expected edited and unstaged memories pending, got %+v", pending)
+	}
+	for _, mem := range pending {
+		if err := s.StageEmbedding(ctx, mem.ID, mem.Version, vector(100+int(mem.ID))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Search keeps using the old vectors until commit
+	if _, err := s.Search(ctx, oldVector, types.SearchOpts{Limit: 1}); err != nil {
+		t.Fatalf("search during staging failed: %v", err)
+	}
+
+	if err := s.CommitReembed(ctx); err != nil {
+		t.Fatalf("CommitReembed failed: %v", err)
+	}
+
+	results, err := s.Search(ctx, vector(1), types.SearchOpts{Limit: 1})
+	if err != nil {
+		t.Fatalf("search with new dimension failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != ids[1] {
+		t.Fatalf("expected memory %d to match its new vector, got %+v", ids[1], results)
+	}
+	if results[0].EmbeddingModel != target.Model {
+		t.Errorf("expected memory to record %q, got %q", target.Model, results[0].EmbeddingModel)
+	}
+	s.Close()
+
+	// The database now belongs to the new model
+	if _, err := New(ctx, Config{Driver: "sqlite", SQLitePath: path, EmbeddingModel: "nomic-embed-text", EmbeddingDim: 768}); !errors.Is(err, ErrEmbeddingMismatch) {
+		t.Errorf("expected old model to be rejected after re-embed, got %v", err)
+	}
+	store, err = New(ctx, Config{Driver: "sqlite", SQLitePath: path, EmbeddingModel: target.Model, EmbeddingDim: target.Dimension})
+	if err != nil {
+		t.Fatalf("expected new model to open, got %v", err)
+	}
+	store.Close()
+}
@@ -4,6 +4,7 @@ package storage_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"testing"
@@ -93,6 +94,84 @@ func TestSQLiteStorage_Search(t *testing.T) {
 	}
 }
 
+func TestSQLiteStorage_KeywordSearch(t *testing.T) {
+	f, err := os.CreateTemp("", "test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	store, err := storage.NewSQLite(f.Name())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	embedding := make([]float32, 768)
+	embedding[0] = 1
+
+	add := func(memType types.MemoryType, area, content string) *types.Memory {
+		t.Helper()
+		mem, err := store.Add(ctx, types.Memory{Type: memType, Area: area, Content: content}, embedding)
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		return mem
+	}
+	envVar := add(types.TypeLearning, "config", "DATABASE_URL must include sslmode")
+	errCode := add(types.TypeLearning, "api", "ERR_RATE_LIMITED means retry after the reset header")
+	add(types.TypeDecision, "config", "Config comes from the environment")
+
+	results, err := store.KeywordSearch(ctx, "DATABASE_URL", types.SearchOpts{Limit: 5})
+	if err != nil {
+		t.Fatalf("KeywordSearch failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != envVar.ID {
+		t.Fatalf("expected only memory %d, got %+v", envVar.ID, results)
+	}
+
+	// Any term matches; memories matching more terms rank first
+	results, err = store.KeywordSearch(ctx, "ERR_RATE_LIMITED retry sslmode", types.SearchOpts{Limit: 5})
+	if err != nil {
+		t.Fatalf("KeywordSearch failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != errCode.ID {
+		t.Fatalf("expected memory %d first of 2, got %+v", errCode.ID, results)
+	}
+
+	// Filters apply as in Search
+	results, err = store.KeywordSearch(ctx, "config environment DATABASE_URL", types.SearchOpts{Limit: 5, Type: types.TypeDecision})
+	if err != nil {
+		t.Fatalf("KeywordSearch failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Type != types.TypeDecision {
+		t.Fatalf("expected 1 decision, got %+v", results)
+	}
+
+	// The index follows edits and invalidation
+	_, err = store.Update(ctx, types.MemoryUpdate{
+		ID: envVar.ID, Type: envVar.Type, Area: envVar.Area, Content: "POSTGRES_DSN must include sslmode", ExpectedVersion: 1,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if results, _ := store.KeywordSearch(ctx, "DATABASE_URL", types.SearchOpts{}); len(results) != 0 {
+		t.Errorf("expected edited-out term to stop matching, got %+v", results)
+	}
+	if results, _ := store.KeywordSearch(ctx, "POSTGRES_DSN", types.SearchOpts{}); len(results) != 1 {
+		t.Errorf("expected edited-in term to match, got %+v", results)
+	}
+
+	if err := store.Invalidate(ctx, types.Invalidation{ID: errCode.ID}); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	if results, _ := store.KeywordSearch(ctx, "ERR_RATE_LIMITED", types.SearchOpts{}); len(results) != 0 {
+		t.Errorf("expected invalidated memory to be excluded, got %+v", results)
+	}
+}
+
 func TestSQLiteStorage_List(t *testing.T) {
 	f, err := os.CreateTemp("", "test-*.db")
 	if err != nil {
@@ -161,7 +240,7 @@ func TestSQLiteStorage_Invalidate(t *testing.T) {
 		t.Fatalf("Add failed: %v", err)
 	}
 
-	err = store.Invalidate(ctx, added.ID, nil)
+	err = store.Invalidate(ctx, types.Invalidation{ID: added.ID})
 	if err != nil {
 		t.Fatalf("Invalidate failed: %v", err)
 	}
@@ -177,6 +256,193 @@ func TestSQLiteStorage_Invalidate(t *testing.T) {
 	}
 }
 
+func TestSQLiteStorage_Get(t *testing.T) {
+	f, err := os.CreateTemp("", "test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	store, err := storage.NewSQLite(f.Name())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	mem := types.Memory{
+		Type:      types.TypeDecision,
+		Area:      "auth",
+		Content:   "Use JWT tokens",
+		Rationale: "Stateless auth",
+		Repo:      "org/repo",
+	}
+	added, err := store.Add(ctx, mem, make([]float32, 768))
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	got, err := store.Get(ctx, added.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Content != "Use JWT tokens" || got.Rationale != "Stateless auth" || got.Repo != "org/repo" {
+		t.Errorf("unexpected memory: %+v", got)
+	}
+
+	// Invalidated memories are still returned
+	if err := store.Invalidate(ctx, types.Invalidation{ID: added.ID}); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	got, err = store.Get(ctx, added.ID)
+	if err != nil {
+		t.Fatalf("Get after invalidate failed: %v", err)
+	}
+	if got.IsValid {
+		t.Error("expected IsValid to be false after invalidation")
+	}
+
+	if _, err := store.Get(ctx, added.ID+100); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("expected types.ErrNotFound for missing ID, got %v", err)
+	}
+}
+
+func TestSQLiteStorage_Update(t *testing.T) {
+	f, err := os.CreateTemp("", "test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	store, err := storage.NewSQLite(f.Name())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	oldEmbedding := make([]float32, 768)
+	oldEmbedding[0] = 1
+	added, err := store.Add(ctx, types.Memory{
+		Type:    types.TypeLearning,
+		Area:    "db",
+		Content: "Pool maxes at 10",
+	}, oldEmbedding)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if added.Version != 1 {
+		t.Fatalf("expected new memory at version 1, got %d", added.Version)
+	}
+
+	newEmbedding := make([]float32, 768)
+	newEmbedding[1] = 1
+	updated, err := store.Update(ctx, types.MemoryUpdate{
+		ID:              added.ID,
+		Type:            types.TypeLearning,
+		Area:            "db",
+		Content:         "Pool maxes at 20",
+		Rationale:       "Instance was resized",
+		ExpectedVersion: 1,
+		EditorName:      "Bob",
+		EditorEmail:     "bob@example.com",
+	}, newEmbedding)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if updated.ID != added.ID {
+		t.Errorf("expected ID to be kept, got %d", updated.ID)
+	}
+	if updated.Version != 2 {
+		t.Errorf("expected version 2, got %d", updated.Version)
+	}
+	if updated.Content != "Pool maxes at 20" || updated.Rationale != "Instance was resized" {
+		t.Errorf("unexpected content after update: %+v", updated)
+	}
+	if updated.UpdatedAt == nil {
+		t.Error("expected UpdatedAt to be set")
+	}
+
+	// The new embedding replaced the old one
+	results, err := store.Search(ctx, newEmbedding, types.SearchOpts{Limit: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].SimilarityScore < 0.99 {
+		t.Errorf("expected search on the new embedding to match, got %+v", results)
+	}
+
+	history, err := store.History(ctx, added.ID)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("expected 1 revision, got %d", len(history))
+	}
+	if history[0].Version != 1 || history[0].Content != "Pool maxes at 10" {
+		t.Errorf("expected revision to hold version 1, got %+v", history[0])
+	}
+	if history[0].RevisedByEmail != "bob@example.com" {
+		t.Errorf("expected revision to record the editor, got %q", history[0].RevisedByEmail)
+	}
+}
+
+func TestSQLiteStorage_Update_VersionConflict(t *testing.T) {
+	f, err := os.CreateTemp("", "test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	store, err := storage.NewSQLite(f.Name())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	added, err := store.Add(ctx, types.Memory{
+		Type:    types.TypeDecision,
+		Area:    "auth",
+		Content: "Use JWT",
+	}, make([]float32, 768))
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	upd := types.MemoryUpdate{
+		ID:              added.ID,
+		Type:            types.TypeDecision,
+		Area:            "auth",
+		Content:         "Use PASETO",
+		ExpectedVersion: 1,
+	}
+	if _, err := store.Update(ctx, upd, nil); err != nil {
+		t.Fatalf("first Update failed: %v", err)
+	}
+
+	// A second writer still holding version 1 must be rejected
+	upd.Content = "Use sessions"
+	if _, err := store.Update(ctx, upd, nil); !errors.Is(err, types.ErrVersionConflict) {
+		t.Errorf("expected types.ErrVersionConflict, got %v", err)
+	}
+
+	upd.ID = added.ID + 100
+	if _, err := store.Update(ctx, upd, nil); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("expected types.ErrNotFound for missing ID, got %v", err)
+	}
+	if _, err := store.History(ctx, added.ID+100); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("expected types.ErrNotFound from History for missing ID, got %v", err)
+	}
+}
+
 func TestSQLiteStorage_Add_InvalidType(t *testing.T) {
 	f, err := os.CreateTemp("", "test-*.db")
 	if err != nil {
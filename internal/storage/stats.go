@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// statsField is a field Stats groups memories by
+type statsField int
+
+const (
+	statsRepo statsField = iota
+	statsArea
+	statsType
+	statsAuthor
+)
+
+// dayCount is the number of memories created on one UTC day
+type dayCount struct {
+	Day   time.Time
+	Count int
+}
+
+// collectStats builds Stats from what backends read: group counts the valid
+// and invalid memories per value of a field, in any order, and days counts
+// the memories created per day
+func collectStats(opts types.StatsOpts, group func(statsField) ([]types.StatCount, error), days func() ([]dayCount, error)) (*types.Stats, error) {
+	stats := &types.Stats{Period: opts.Period.OrDefault()}
+	for _, g := range []struct {
+		field statsField
+		dest  *[]types.StatCount
+	}{
+		{statsRepo, &stats.ByRepo},
+		{statsArea, &stats.ByArea},
+		{statsType, &stats.ByType},
+		{statsAuthor, &stats.ByAuthor},
+	} {
+		counts, err := group(g.field)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(counts, func(i, j int) bool {
+			a, b := counts[i].Valid+counts[i].Invalid, counts[j].Valid+counts[j].Invalid
+			if a != b {
+				return a > b
+			}
+			return counts[i].Value < counts[j].Value
+		})
+		if counts == nil {
+			counts = []types.StatCount{}
+		}
+		*g.dest = counts
+	}
+	for _, c := range stats.ByType {
+		stats.Valid += c.Valid
+		stats.Invalid += c.Invalid
+	}
+	stats.Total = stats.Valid + stats.Invalid
+
+	daily, err := days()
+	if err != nil {
+		return nil, err
+	}
+	stats.Growth = growthBuckets(daily, stats.Period)
+	return stats, nil
+}
+
+// growthBuckets rolls daily counts up into one bucket per period, from the
+// earliest day's period to the latest's
+func growthBuckets(days []dayCount, period types.StatsPeriod) []types.GrowthBucket {
+	buckets := []types.GrowthBucket{}
+	if len(days) == 0 {
+		return buckets
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Day.Before(days[j].Day) })
+
+	added := make(map[time.Time]int)
+	for _, d := range days {
+		added[period.Start(d.Day)] += d.Count
+	}
+	total := 0
+	last := period.Start(days[len(days)-1].Day)
+	for start := period.Start(days[0].Day); !start.After(last); start = period.Next(start) {
+		total += added[start]
+		buckets = append(buckets, types.GrowthBucket{Start: start, Added: added[start], Total: total})
+	}
+	return buckets
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+func TestGrowthBuckets(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	// 2025-03-03 is a Monday
+	days := []dayCount{
+		{day("2025-03-20"), 1},
+		{day("2025-01-31"), 2},
+		{day("2025-03-03"), 3},
+		{day("2025-03-02"), 4},
+	}
+
+	tests := []struct {
+		period types.StatsPeriod
+		want   string
+	}{
+		{types.PeriodMonth, "2025-01-01 +2=2, 2025-02-01 +0=2, 2025-03-01 +8=10"},
+		{types.PeriodWeek, "2025-01-27 +2=2, 2025-02-03 +0=2, 2025-02-10 +0=2, 2025-02-17 +0=2, 2025-02-24 +4=6, 2025-03-03 +3=9, 2025-03-10 +0=9, 2025-03-17 +1=10"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.period), func(t *testing.T) {
+			var got string
+			for i, b := range growthBuckets(days, tt.period) {
+				if i > 0 {
+					got += ", "
+				}
+				got += fmt.Sprintf("%s +%d=%d", b.Start.Format(time.DateOnly), b.Added, b.Total)
+			}
+			if got != tt.want {
+				t.Errorf("got %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+
+	if got := growthBuckets(nil, types.PeriodDay); got == nil || len(got) != 0 {
+		t.Errorf("expected no buckets for no memories, got %v", got)
+	}
+}
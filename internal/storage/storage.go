@@ -2,15 +2,95 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/MereWhiplash/engram-cogitator/internal/types"
 )
 
-// Storage defines the interface for memory persistence
+// Storage defines the interface for memory persistence. The storagetest
+// package checks implementations against the behaviour documented here.
 type Storage interface {
 	Add(ctx context.Context, mem types.Memory, embedding []float32) (*types.Memory, error)
+	// AddBatch adds mems in one transaction, with embeddings[i] as mems[i]'s
+	// vector, and returns them in order. Either all are added or none are.
+	AddBatch(ctx context.Context, mems []types.Memory, embeddings [][]float32) ([]types.Memory, error)
+	// Search finds the valid memories nearest to embedding, best first, scored
+	// by cosine similarity (1 same direction, 0 unrelated, -1 opposite)
 	Search(ctx context.Context, embedding []float32, opts types.SearchOpts) ([]types.Memory, error)
+	// KeywordSearch finds valid memories whose text matches any of the query's
+	// terms, best match first. Scores are positive and backend-specific, so
+	// they only order results.
+	KeywordSearch(ctx context.Context, query string, opts types.SearchOpts) ([]types.Memory, error)
+	// List returns memories newest first, ties broken by descending ID, after
+	// skipping opts.Offset of them
 	List(ctx context.Context, opts types.ListOpts) ([]types.Memory, error)
-	Invalidate(ctx context.Context, id int64, supersededBy *int64) error
+	// Get returns types.ErrNotFound if no memory has id
+	Get(ctx context.Context, id int64) (*types.Memory, error)
+	// Invalidate marks a memory invalid, recording when, by whom and why, and
+	// optionally its successor. Invalidating an invalid memory again replaces
+	// those details. It returns types.ErrNotFound if no memory has inv.ID, and
+	// types.ErrInvalidSupersede if the successor is missing, is the memory
+	// itself, would close a superseded_by loop, or with inv.SameRepo is in
+	// another repo; the check and the write share one transaction.
+	Invalidate(ctx context.Context, inv types.Invalidation) error
+	// InvalidateMatching invalidates every valid memory matching bi.Filter as
+	// Invalidate would, in one transaction, and returns their IDs in
+	// ascending order. A zero filter matches every valid memory. The successor
+	// is checked against each memory as in Invalidate, so one that matches
+	// the filter itself is rejected with types.ErrInvalidSupersede. With
+	// bi.DryRun the IDs are returned and the successor checked, but nothing
+	// changes.
+	InvalidateMatching(ctx context.Context, bi types.BulkInvalidation) ([]int64, error)
+	// Revalidate makes an invalidated memory valid again and returns it. It
+	// clears the successor, the invalidation details and an expiry that has
+	// already passed, and records when and by whom the memory was restored.
+	// Revalidating a valid memory changes nothing. It returns
+	// types.ErrNotFound if no memory has rev.ID.
+	Revalidate(ctx context.Context, rev types.Revalidation) (*types.Memory, error)
+	// Merge adds m.Memory as the consolidation of m.Sources and invalidates
+	// every source as superseded by it, recording the reason and who merged
+	// them, all in one transaction. The new memory's OriginatedAt is the
+	// earliest CreatedAt (or OriginatedAt) among the sources. It returns
+	// types.ErrInvalidSupersede, changing nothing, if a source is missing or
+	// already invalid, or with m.SameRepo is in another repo.
+	Merge(ctx context.Context, m types.Merge, embedding []float32) (*types.Memory, error)
+	// Update edits a memory in place, recording the replaced version as a revision.
+	// A nil embedding keeps the stored vector.
+	Update(ctx context.Context, upd types.MemoryUpdate, embedding []float32) (*types.Memory, error)
+	History(ctx context.Context, id int64) ([]types.Revision, error)
+	// Tags counts the valid memories carrying each tag, most used first, ties
+	// in tag order. A non-empty repo counts only that repo's memories.
+	Tags(ctx context.Context, repo string) ([]types.TagCount, error)
+	// Catalog lists the values field takes across valid memories, in repo
+	// if it is non-empty, with how many memories have each and when one was
+	// last added or updated. Entries are ordered most used first, ties by
+	// value, and memories without a value are left out. Authors are keyed
+	// by email, or by name for memories without one.
+	Catalog(ctx context.Context, field types.CatalogField, repo string) ([]types.CatalogEntry, error)
+	// Stats counts the memories, valid and invalid, grouped by repo, area,
+	// type and author, and how many were added per opts.Period. A non-empty
+	// opts.Repo counts only that repo's memories.
+	Stats(ctx context.Context, opts types.StatsOpts) (*types.Stats, error)
+	// Expire invalidates the valid memories whose ExpiresAt is at or before
+	// now, with reason types.ExpiredReason, and returns how many it invalidated
+	Expire(ctx context.Context, now time.Time) (int64, error)
+	// DueForReview returns up to limit valid memories whose ReviewBy is at or
+	// before now, most overdue first. A non-empty repo restricts them to that repo.
+	DueForReview(ctx context.Context, repo string, now time.Time, limit int) ([]types.Memory, error)
+	// RecordRetrievals adds counts[id] to each memory's RetrievalCount and
+	// moves its LastRetrievedAt forward to at. IDs with no memory are ignored.
+	// The earliest at it is called with, even with no counts, is recorded as
+	// the start of usage tracking.
+	RecordRetrievals(ctx context.Context, counts map[int64]int, at time.Time) error
+	// Unretrieved returns up to limit valid memories created before before
+	// that have never been retrieved, oldest first. A non-empty repo
+	// restricts them to that repo. Until usage tracking started before
+	// before, no retrievals prove nothing, so it returns none.
+	Unretrieved(ctx context.Context, repo string, before time.Time, limit int) ([]types.Memory, error)
+	// Lineage returns the memory with id, every memory it was superseded by,
+	// directly or transitively, and every memory it transitively superseded,
+	// in ID order. Superseded-by cycles end the walk rather than looping.
+	// It returns types.ErrNotFound if no memory has id.
+	Lineage(ctx context.Context, id int64) ([]types.Memory, error)
 	Close() error
 }
@@ -0,0 +1,1334 @@
+// Package storagetest is a conformance suite for storage.Storage
+// implementations. Every backend runs the same checks, so behaviour the
+// service and API rely on can't drift between them.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// Opener returns an empty store with storage.DefaultEmbeddingDim vectors. It
+// is called once per subtest and should close the store via t.Cleanup.
+type Opener func(t *testing.T) storage.Storage
+
+// scoreTolerance absorbs float32 rounding in backends' distance functions
+const scoreTolerance = 1e-3
+
+// Run runs the conformance suite against stores from open
+func Run(t *testing.T, open Opener) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store storage.Storage)
+	}{
+		{"AddAndGet", testAddAndGet},
+		{"AddBatch", testAddBatch},
+		{"ListFilters", testListFilters},
+		{"ListPagination", testListPagination},
+		{"ListCursor", testListCursor},
+		{"Invalidate", testInvalidate},
+		{"InvalidateMatching", testInvalidateMatching},
+		{"Supersede", testSupersede},
+		{"SupersedeValidation", testSupersedeValidation},
+		{"Revalidate", testRevalidate},
+		{"Merge", testMerge},
+		{"MergeValidation", testMergeValidation},
+		{"SearchRanking", testSearchRanking},
+		{"SearchFilters", testSearchFilters},
+		{"KeywordSearch", testKeywordSearch},
+		{"UpdateAndHistory", testUpdateAndHistory},
+		{"Tags", testTags},
+		{"Catalog", testCatalog},
+		{"Stats", testStats},
+		{"ExpiryAndReview", testExpiryAndReview},
+		{"Retrievals", testRetrievals},
+		{"Lineage", testLineage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+// vec returns a storage-sized vector starting with the given components
+func vec(components ...float32) []float32 {
+	v := make([]float32, storage.DefaultEmbeddingDim)
+	copy(v, components)
+	return v
+}
+
+func add(t *testing.T, store storage.Storage, mem types.Memory, embedding []float32) *types.Memory {
+	t.Helper()
+	if mem.Type == "" {
+		mem.Type = types.TypeDecision
+	}
+	if mem.Area == "" {
+		mem.Area = "general"
+	}
+	if embedding == nil {
+		embedding = vec(1)
+	}
+	added, err := store.Add(context.Background(), mem, embedding)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	return added
+}
+
+func ids(memories []types.Memory) []int64 {
+	out := make([]int64, len(memories))
+	for i, m := range memories {
+		out[i] = m.ID
+	}
+	return out
+}
+
+func sameIDs(got []types.Memory, want ...int64) bool {
+	return fmt.Sprint(ids(got)) == fmt.Sprint(want)
+}
+
+func testAddAndGet(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	added := add(t, store, types.Memory{
+		Type:           types.TypeLearning,
+		Area:           "auth",
+		Content:        "Tokens expire after an hour",
+		Rationale:      "Found in production",
+		AuthorName:     "Ana",
+		AuthorEmail:    "ana@example.com",
+		Repo:           "org/repo",
+		EmbeddingModel: "test-embed",
+	}, nil)
+	if added.ID == 0 || !added.IsValid || added.Version != 1 {
+		t.Fatalf("expected a valid memory at version 1 with an ID, got %+v", added)
+	}
+
+	got, err := store.Get(ctx, added.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	want := *added
+	switch {
+	case got.Type != want.Type || got.Area != want.Area || got.Content != want.Content || got.Rationale != want.Rationale:
+		t.Errorf("text differs: got %+v, want %+v", got, want)
+	case got.AuthorName != want.AuthorName || got.AuthorEmail != want.AuthorEmail || got.Repo != want.Repo:
+		t.Errorf("team fields differ: got %+v, want %+v", got, want)
+	case got.EmbeddingModel != want.EmbeddingModel:
+		t.Errorf("embedding model: got %q, want %q", got.EmbeddingModel, want.EmbeddingModel)
+	case !got.IsValid || got.SupersededBy != nil || got.Version != 1 || got.UpdatedAt != nil:
+		t.Errorf("expected a fresh valid memory, got %+v", got)
+	case got.CreatedAt.IsZero():
+		t.Error("expected created_at to be set")
+	}
+
+	if other := add(t, store, types.Memory{Content: "second"}, nil); other.ID == added.ID {
+		t.Errorf("expected distinct IDs, both got %d", added.ID)
+	}
+
+	if _, err := store.Get(ctx, added.ID+1000); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Get of a missing memory: expected ErrNotFound, got %v", err)
+	}
+}
+
+func testAddBatch(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	origin := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	mems := []types.Memory{
+		{Type: types.TypeDecision, Area: "auth", Content: "use sessions", Repo: "org/one", Tags: []string{"auth", "web"}},
+		{Type: types.TypeLearning, Area: "db", Content: "index foreign keys", AuthorName: "Ada", OriginatedAt: &origin},
+		{Type: types.TypePattern, Area: "api", Content: "wrap errors"},
+	}
+	added, err := store.AddBatch(ctx, mems, [][]float32{vec(1, 0), vec(0, 1), vec(1, 1)})
+	if err != nil {
+		t.Fatalf("AddBatch failed: %v", err)
+	}
+	if len(added) != len(mems) {
+		t.Fatalf("expected %d memories, got %d", len(mems), len(added))
+	}
+	seen := make(map[int64]bool)
+	for i, mem := range added {
+		if mem.ID == 0 || seen[mem.ID] || mem.Content != mems[i].Content || !mem.IsValid || mem.CreatedAt.IsZero() {
+			t.Errorf("memory %d: expected a new valid %q, got %+v", i, mems[i].Content, mem)
+		}
+		seen[mem.ID] = true
+
+		got, err := store.Get(ctx, mem.ID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if got.Content != mems[i].Content || got.Area != mems[i].Area || got.Type != mems[i].Type {
+			t.Errorf("memory %d: expected the stored memory to match, got %+v", i, got)
+		}
+	}
+	if got, _ := store.Get(ctx, added[0].ID); got == nil || fmt.Sprint(got.Tags) != "[auth web]" || got.Repo != "org/one" {
+		t.Errorf("expected tags and repo stored, got %+v", got)
+	}
+	if got, _ := store.Get(ctx, added[1].ID); got == nil || got.AuthorName != "Ada" || got.OriginatedAt == nil || !got.OriginatedAt.Equal(origin) {
+		t.Errorf("expected author and originated_at stored, got %+v", got)
+	}
+	if found, _ := store.Search(ctx, vec(0, 1), types.SearchOpts{Limit: 1}); !sameIDs(found, added[1].ID) {
+		t.Errorf("expected search to find the batch's embeddings, got %v", ids(found))
+	}
+
+	empty, err := store.AddBatch(ctx, nil, nil)
+	if err != nil || len(empty) != 0 {
+		t.Errorf("expected an empty batch to add nothing, got %v, %v", empty, err)
+	}
+	if _, err := store.AddBatch(ctx, mems, [][]float32{vec(1)}); err == nil {
+		t.Error("expected an error when embeddings and memories differ in number")
+	}
+	if all, _ := store.List(ctx, types.ListOpts{IncludeInvalid: true}); len(all) != len(mems) {
+		t.Errorf("expected only the first batch stored, got %v", ids(all))
+	}
+}
+
+func testListFilters(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	a := add(t, store, types.Memory{Type: types.TypeDecision, Area: "auth", Content: "a", Repo: "org/one"}, nil)
+	b := add(t, store, types.Memory{Type: types.TypeLearning, Area: "auth", Content: "b", Repo: "org/two"}, nil)
+	c := add(t, store, types.Memory{Type: types.TypeDecision, Area: "db", Content: "c", Repo: "org/one"}, nil)
+	d := add(t, store, types.Memory{Type: types.TypeDecision, Area: "auth", Content: "d", Repo: "org/one"}, nil)
+	if err := store.Invalidate(ctx, types.Invalidation{ID: d.ID}); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts types.ListOpts
+		want []int64
+	}{
+		{"all valid", types.ListOpts{}, []int64{c.ID, b.ID, a.ID}},
+		{"type", types.ListOpts{Type: types.TypeDecision}, []int64{c.ID, a.ID}},
+		{"area", types.ListOpts{Area: "auth"}, []int64{b.ID, a.ID}},
+		{"repo", types.ListOpts{Repo: "org/one"}, []int64{c.ID, a.ID}},
+		{"combined", types.ListOpts{Type: types.TypeDecision, Area: "auth", Repo: "org/one"}, []int64{a.ID}},
+		{"include invalid", types.ListOpts{Area: "auth", IncludeInvalid: true}, []int64{d.ID, b.ID, a.ID}},
+		{"no match", types.ListOpts{Area: "nowhere"}, nil},
+	}
+	for _, tt := range tests {
+		got, err := store.List(ctx, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: List failed: %v", tt.name, err)
+		}
+		if !sameIDs(got, tt.want...) {
+			t.Errorf("%s: got %v, want %v", tt.name, ids(got), tt.want)
+		}
+	}
+}
+
+func testListPagination(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	// Memories are added faster than some backends' timestamp resolution, so
+	// ties must fall back to the ID to keep newest first
+	var want []int64
+	for i := 0; i < 5; i++ {
+		m := add(t, store, types.Memory{Content: fmt.Sprintf("memory %d", i)}, nil)
+		want = append([]int64{m.ID}, want...)
+	}
+
+	all, err := store.List(ctx, types.ListOpts{Limit: 10})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if !sameIDs(all, want...) {
+		t.Fatalf("expected newest first %v, got %v", want, ids(all))
+	}
+
+	if got, _ := store.List(ctx, types.ListOpts{}); len(got) != 5 {
+		t.Errorf("expected the default limit to cover 5 memories, got %d", len(got))
+	}
+
+	var paged []types.Memory
+	for offset := 0; offset < 6; offset += 2 {
+		page, err := store.List(ctx, types.ListOpts{Limit: 2, Offset: offset})
+		if err != nil {
+			t.Fatalf("List at offset %d failed: %v", offset, err)
+		}
+		paged = append(paged, page...)
+	}
+	if !sameIDs(paged, want...) {
+		t.Errorf("pages of 2 should concatenate to %v, got %v", want, ids(paged))
+	}
+
+	past, err := store.List(ctx, types.ListOpts{Limit: 2, Offset: 5})
+	if err != nil {
+		t.Fatalf("List past the end failed: %v", err)
+	}
+	if len(past) != 0 {
+		t.Errorf("expected nothing past the end, got %v", ids(past))
+	}
+}
+
+func testListCursor(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	var want []int64
+	for i := 0; i < 5; i++ {
+		m := add(t, store, types.Memory{Area: "paged", Content: fmt.Sprintf("memory %d", i)}, nil)
+		want = append([]int64{m.ID}, want...)
+	}
+
+	var walked []types.Memory
+	var after *types.ListCursor
+	for page := 0; page < 4; page++ {
+		got, err := store.List(ctx, types.ListOpts{Limit: 2, Area: "paged", After: after})
+		if err != nil {
+			t.Fatalf("List page %d failed: %v", page, err)
+		}
+		if len(got) == 0 {
+			break
+		}
+		walked = append(walked, got...)
+
+		// Memories added mid-walk are newer than the cursor and don't shift it
+		add(t, store, types.Memory{Area: "paged", Content: fmt.Sprintf("added during page %d", page)}, nil)
+
+		// The cursor must survive a round trip through its token
+		next, err := types.ParseListCursor(types.CursorAfter(got[len(got)-1]).Encode())
+		if err != nil {
+			t.Fatalf("cursor did not round-trip: %v", err)
+		}
+		after = next
+	}
+	if !sameIDs(walked, want...) {
+		t.Errorf("walking with a cursor: expected %v, got %v", want, ids(walked))
+	}
+}
+
+func testInvalidate(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	keep := add(t, store, types.Memory{Content: "keep the retry budget"}, vec(1, 0))
+	drop := add(t, store, types.Memory{Content: "drop the retry budget"}, vec(1, 0))
+
+	// Timestamps may be stored to the second
+	before := time.Now().Add(-time.Second)
+	err := store.Invalidate(ctx, types.Invalidation{
+		ID: drop.ID, Reason: "retries moved to the gateway", ByName: "Ada", ByEmail: "ada@example.com",
+	})
+	if err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	after := time.Now().Add(time.Second)
+
+	got, err := store.Get(ctx, drop.ID)
+	if err != nil {
+		t.Fatalf("Get of an invalidated memory failed: %v", err)
+	}
+	if got.IsValid || got.SupersededBy != nil {
+		t.Errorf("expected invalid without a successor, got %+v", got)
+	}
+	if got.InvalidatedAt == nil || got.InvalidatedAt.Before(before) || got.InvalidatedAt.After(after) {
+		t.Errorf("expected invalidated_at between %v and %v, got %v", before, after, got.InvalidatedAt)
+	}
+	if got.InvalidationReason != "retries moved to the gateway" || got.InvalidatedByName != "Ada" || got.InvalidatedByEmail != "ada@example.com" {
+		t.Errorf("expected the reason and invalidator recorded, got %q by %q <%q>",
+			got.InvalidationReason, got.InvalidatedByName, got.InvalidatedByEmail)
+	}
+	if kept, _ := store.Get(ctx, keep.ID); kept == nil || kept.InvalidatedAt != nil || kept.InvalidationReason != "" {
+		t.Errorf("expected a valid memory to have no invalidation details, got %+v", kept)
+	}
+	all, _ := store.List(ctx, types.ListOpts{IncludeInvalid: true})
+	if len(all) != 2 || all[0].ID != drop.ID || all[0].InvalidationReason == "" {
+		t.Errorf("expected List with invalid memories to include the reason, got %+v", all)
+	}
+
+	listed, _ := store.List(ctx, types.ListOpts{})
+	if !sameIDs(listed, keep.ID) {
+		t.Errorf("List: expected only %d, got %v", keep.ID, ids(listed))
+	}
+	found, _ := store.Search(ctx, vec(1, 0), types.SearchOpts{})
+	if !sameIDs(found, keep.ID) {
+		t.Errorf("Search: expected only %d, got %v", keep.ID, ids(found))
+	}
+	matched, _ := store.KeywordSearch(ctx, "retry", types.SearchOpts{})
+	if !sameIDs(matched, keep.ID) {
+		t.Errorf("KeywordSearch: expected only %d, got %v", keep.ID, ids(matched))
+	}
+
+	// Invalidating twice is harmless and replaces the details
+	if err := store.Invalidate(ctx, types.Invalidation{ID: drop.ID, Reason: "duplicate"}); err != nil {
+		t.Errorf("second Invalidate failed: %v", err)
+	}
+	if got, _ := store.Get(ctx, drop.ID); got == nil || got.InvalidationReason != "duplicate" || got.InvalidatedByName != "" {
+		t.Errorf("expected the second invalidation's details, got %+v", got)
+	}
+
+	// The link is replaced as well, so dropping the successor clears it
+	if err := store.Invalidate(ctx, types.Invalidation{ID: drop.ID, SupersededBy: &keep.ID}); err != nil {
+		t.Fatalf("Invalidate with a successor failed: %v", err)
+	}
+	if err := store.Invalidate(ctx, types.Invalidation{ID: drop.ID, Reason: "no replacement"}); err != nil {
+		t.Fatalf("Invalidate without a successor failed: %v", err)
+	}
+	if got, _ := store.Get(ctx, drop.ID); got == nil || got.SupersededBy != nil {
+		t.Errorf("expected re-invalidating without a successor to clear the link, got %+v", got)
+	}
+
+	if err := store.Invalidate(ctx, types.Invalidation{ID: drop.ID + 1000}); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Invalidate of a missing memory: expected ErrNotFound, got %v", err)
+	}
+}
+
+func testInvalidateMatching(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	gqlSchema := add(t, store, types.Memory{Area: "graphql", Content: "schema first", Repo: "org/one", AuthorName: "Ada"}, nil)
+	gqlLearn := add(t, store, types.Memory{Type: types.TypeLearning, Area: "graphql", Content: "batch resolvers", Repo: "org/one", AuthorEmail: "bob@example.com"}, nil)
+	gqlOther := add(t, store, types.Memory{Area: "graphql", Content: "persisted queries", Repo: "org/two", AuthorName: "Ada"}, nil)
+	rest := add(t, store, types.Memory{Area: "rest", Content: "use REST", Repo: "org/one", AuthorName: "Ada"}, nil)
+	gone := add(t, store, types.Memory{Area: "graphql", Content: "old note", Repo: "org/one"}, nil)
+	if err := store.Invalidate(ctx, types.Invalidation{ID: gone.ID}); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name   string
+		filter types.MemoryFilter
+		want   []int64
+	}{
+		{"area", types.MemoryFilter{Area: "graphql"}, []int64{gqlSchema.ID, gqlLearn.ID, gqlOther.ID}},
+		{"repo and area", types.MemoryFilter{Repo: "org/one", Area: "graphql"}, []int64{gqlSchema.ID, gqlLearn.ID}},
+		{"type", types.MemoryFilter{Area: "graphql", Type: types.TypeLearning}, []int64{gqlLearn.ID}},
+		{"author name", types.MemoryFilter{Author: "Ada"}, []int64{gqlSchema.ID, gqlOther.ID, rest.ID}},
+		{"author email", types.MemoryFilter{Author: "bob@example.com"}, []int64{gqlLearn.ID}},
+		{"created before now", types.MemoryFilter{Repo: "org/two", CreatedBefore: &future}, []int64{gqlOther.ID}},
+		{"created before all", types.MemoryFilter{Area: "graphql", CreatedBefore: &past}, []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.InvalidateMatching(ctx, types.BulkInvalidation{Filter: tt.filter, DryRun: true})
+			if err != nil {
+				t.Fatalf("InvalidateMatching failed: %v", err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+	if all, _ := store.List(ctx, types.ListOpts{}); len(all) != 4 {
+		t.Fatalf("expected a dry run to invalidate nothing, got %v valid", ids(all))
+	}
+
+	// A successor matching the filter would supersede itself
+	if _, err := store.InvalidateMatching(ctx, types.BulkInvalidation{
+		Filter: types.MemoryFilter{Area: "graphql"}, SupersededBy: &gqlSchema.ID,
+	}); !errors.Is(err, types.ErrInvalidSupersede) {
+		t.Errorf("expected ErrInvalidSupersede for a successor among the matches, got %v", err)
+	}
+	missing := rest.ID + 1000
+	if _, err := store.InvalidateMatching(ctx, types.BulkInvalidation{
+		Filter: types.MemoryFilter{Area: "nowhere"}, SupersededBy: &missing, DryRun: true,
+	}); !errors.Is(err, types.ErrInvalidSupersede) {
+		t.Errorf("expected ErrInvalidSupersede for a missing successor, got %v", err)
+	}
+	if _, err := store.InvalidateMatching(ctx, types.BulkInvalidation{
+		Filter: types.MemoryFilter{Area: "graphql"}, SupersededBy: &rest.ID, SameRepo: true,
+	}); !errors.Is(err, types.ErrInvalidSupersede) {
+		t.Errorf("expected ErrInvalidSupersede for a successor in another repo, got %v", err)
+	}
+	if all, _ := store.List(ctx, types.ListOpts{}); len(all) != 4 {
+		t.Fatalf("expected a rejected run to invalidate nothing, got %v valid", ids(all))
+	}
+
+	got, err := store.InvalidateMatching(ctx, types.BulkInvalidation{
+		Filter:       types.MemoryFilter{Area: "graphql"},
+		SupersededBy: &rest.ID,
+		Reason:       "moved to REST",
+		ByName:       "Ada",
+		ByEmail:      "ada@example.com",
+	})
+	if err != nil {
+		t.Fatalf("InvalidateMatching failed: %v", err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint([]int64{gqlSchema.ID, gqlLearn.ID, gqlOther.ID}) {
+		t.Errorf("expected the graphql memories, got %v", got)
+	}
+	for _, id := range got {
+		mem, _ := store.Get(ctx, id)
+		if mem == nil || mem.IsValid || mem.SupersededBy == nil || *mem.SupersededBy != rest.ID {
+			t.Errorf("expected memory %d superseded by %d, got %+v", id, rest.ID, mem)
+			continue
+		}
+		if mem.InvalidatedAt == nil || mem.InvalidationReason != "moved to REST" || mem.InvalidatedByName != "Ada" || mem.InvalidatedByEmail != "ada@example.com" {
+			t.Errorf("expected memory %d's invalidation recorded, got %+v", id, mem)
+		}
+	}
+	if old, _ := store.Get(ctx, gone.ID); old == nil || old.SupersededBy != nil || old.InvalidationReason != "" {
+		t.Errorf("expected an already invalid memory left alone, got %+v", old)
+	}
+	if listed, _ := store.List(ctx, types.ListOpts{}); !sameIDs(listed, rest.ID) {
+		t.Errorf("expected only %d still valid, got %v", rest.ID, ids(listed))
+	}
+
+	// A copied-in memory can be valid and still carry a link; invalidating
+	// it without a successor clears the link, as Invalidate does
+	importer, ok := store.(storage.Importer)
+	if !ok {
+		return
+	}
+	linked := add(t, store, types.Memory{Area: "soap", Content: "wsdl first", Repo: "org/one"}, nil)
+	if err := importer.SetSupersededBy(ctx, linked.ID, rest.ID); err != nil {
+		t.Fatalf("SetSupersededBy failed: %v", err)
+	}
+	if _, err := store.InvalidateMatching(ctx, types.BulkInvalidation{Filter: types.MemoryFilter{Area: "soap"}}); err != nil {
+		t.Fatalf("InvalidateMatching failed: %v", err)
+	}
+	if mem, _ := store.Get(ctx, linked.ID); mem == nil || mem.IsValid || mem.SupersededBy != nil {
+		t.Errorf("expected memory %d invalidated with its link cleared, got %+v", linked.ID, mem)
+	}
+}
+
+func testSupersede(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	old := add(t, store, types.Memory{Content: "use sessions"}, nil)
+	replacement := add(t, store, types.Memory{Content: "use JWT"}, nil)
+
+	if err := store.Invalidate(ctx, types.Invalidation{ID: old.ID, SupersededBy: &replacement.ID}); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+
+	got, err := store.Get(ctx, old.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.IsValid || got.SupersededBy == nil || *got.SupersededBy != replacement.ID {
+		t.Errorf("expected invalid and superseded by %d, got %+v", replacement.ID, got)
+	}
+
+	got, err = store.Get(ctx, replacement.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !got.IsValid || got.SupersededBy != nil {
+		t.Errorf("expected the replacement untouched, got %+v", got)
+	}
+}
+
+func testSupersedeValidation(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	a := add(t, store, types.Memory{Content: "use sessions", Repo: "org/one"}, nil)
+	b := add(t, store, types.Memory{Content: "use JWT", Repo: "org/one"}, nil)
+	c := add(t, store, types.Memory{Content: "use JWT with rotation", Repo: "org/one"}, nil)
+	other := add(t, store, types.Memory{Content: "use OAuth", Repo: "org/two"}, nil)
+	missing := other.ID + 1000
+
+	for _, link := range [][2]int64{{a.ID, b.ID}, {b.ID, c.ID}} {
+		if err := store.Invalidate(ctx, types.Invalidation{ID: link[0], SupersededBy: &link[1]}); err != nil {
+			t.Fatalf("Invalidate %d by %d failed: %v", link[0], link[1], err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		inv  types.Invalidation
+	}{
+		{"itself", types.Invalidation{ID: c.ID, SupersededBy: &c.ID}},
+		{"missing successor", types.Invalidation{ID: c.ID, SupersededBy: &missing}},
+		{"two-memory loop", types.Invalidation{ID: b.ID, SupersededBy: &a.ID}},
+		{"longer loop", types.Invalidation{ID: c.ID, SupersededBy: &a.ID}},
+		{"other repo", types.Invalidation{ID: c.ID, SupersededBy: &other.ID, SameRepo: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.Invalidate(ctx, tt.inv); !errors.Is(err, types.ErrInvalidSupersede) {
+				t.Errorf("expected ErrInvalidSupersede, got %v", err)
+			}
+		})
+	}
+
+	// Rejected invalidations change nothing
+	if got, _ := store.Get(ctx, c.ID); got == nil || !got.IsValid || got.SupersededBy != nil {
+		t.Errorf("expected memory %d still valid without a successor, got %+v", c.ID, got)
+	}
+	if got, _ := store.Get(ctx, b.ID); got == nil || got.SupersededBy == nil || *got.SupersededBy != c.ID {
+		t.Errorf("expected memory %d still superseded by %d, got %+v", b.ID, c.ID, got)
+	}
+
+	if err := store.Invalidate(ctx, types.Invalidation{ID: missing, SupersededBy: &c.ID}); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Invalidate of a missing memory: expected ErrNotFound, got %v", err)
+	}
+	if err := store.Invalidate(ctx, types.Invalidation{ID: c.ID, SupersededBy: &other.ID}); err != nil {
+		t.Errorf("a successor from another repo is allowed without SameRepo, got %v", err)
+	}
+}
+
+func testRevalidate(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	old := add(t, store, types.Memory{Content: "use sessions"}, vec(1, 0))
+	replacement := add(t, store, types.Memory{Content: "use JWT"}, vec(0, 1))
+	if err := store.Invalidate(ctx, types.Invalidation{ID: old.ID, SupersededBy: &replacement.ID, Reason: "JWT instead"}); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+
+	before := time.Now().Add(-time.Second)
+	got, err := store.Revalidate(ctx, types.Revalidation{ID: old.ID, ByName: "Ada", ByEmail: "ada@example.com"})
+	if err != nil {
+		t.Fatalf("Revalidate failed: %v", err)
+	}
+	after := time.Now().Add(time.Second)
+
+	if !got.IsValid || got.SupersededBy != nil {
+		t.Errorf("expected valid without a successor, got %+v", got)
+	}
+	if got.InvalidatedAt != nil || got.InvalidationReason != "" || got.InvalidatedByName != "" {
+		t.Errorf("expected the invalidation details cleared, got %+v", got)
+	}
+	if got.RestoredAt == nil || got.RestoredAt.Before(before) || got.RestoredAt.After(after) {
+		t.Errorf("expected restored_at between %v and %v, got %v", before, after, got.RestoredAt)
+	}
+	if got.RestoredByName != "Ada" || got.RestoredByEmail != "ada@example.com" {
+		t.Errorf("expected the restorer recorded, got %q <%q>", got.RestoredByName, got.RestoredByEmail)
+	}
+	if fetched, _ := store.Get(ctx, old.ID); fetched == nil || !fetched.IsValid || fetched.RestoredByName != "Ada" {
+		t.Errorf("expected Get to return the restored memory, got %+v", fetched)
+	}
+	found, _ := store.Search(ctx, vec(1, 0), types.SearchOpts{})
+	if len(found) == 0 || found[0].ID != old.ID {
+		t.Errorf("expected the restored memory searchable, got %v", ids(found))
+	}
+
+	// Revalidating a valid memory changes nothing
+	again, err := store.Revalidate(ctx, types.Revalidation{ID: old.ID, ByName: "Grace"})
+	if err != nil {
+		t.Fatalf("second Revalidate failed: %v", err)
+	}
+	if again.RestoredByName != "Ada" {
+		t.Errorf("expected the first restore kept, got %q", again.RestoredByName)
+	}
+
+	// A passed expiry is cleared, or the next sweep would invalidate it again
+	past := time.Now().Add(-time.Hour)
+	stale := add(t, store, types.Memory{Content: "pool maxes at 20", ExpiresAt: &past}, nil)
+	if _, err := store.Expire(ctx, time.Now()); err != nil {
+		t.Fatalf("Expire failed: %v", err)
+	}
+	restored, err := store.Revalidate(ctx, types.Revalidation{ID: stale.ID})
+	if err != nil {
+		t.Fatalf("Revalidate of an expired memory failed: %v", err)
+	}
+	if !restored.IsValid || restored.ExpiresAt != nil {
+		t.Errorf("expected valid with no expiry, got %+v", restored)
+	}
+
+	if _, err := store.Revalidate(ctx, types.Revalidation{ID: old.ID + 1000}); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Revalidate of a missing memory: expected ErrNotFound, got %v", err)
+	}
+}
+
+func testMerge(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	origin := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	a := add(t, store, types.Memory{Content: "use sessions", Repo: "org/one", OriginatedAt: &origin}, vec(1, 0))
+	b := add(t, store, types.Memory{Content: "use cookies", Repo: "org/one"}, vec(1, 0.1))
+
+	merged, err := store.Merge(ctx, types.Merge{
+		Memory:   types.Memory{Type: types.TypeDecision, Area: "auth", Content: "use server sessions in cookies", Repo: "org/one", Tags: []string{"auth"}},
+		Sources:  []int64{a.ID, b.ID},
+		SameRepo: true,
+		Reason:   "duplicates",
+		ByName:   "Ada",
+		ByEmail:  "ada@example.com",
+	}, vec(1, 0.05))
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if merged.ID == a.ID || merged.ID == b.ID || !merged.IsValid || merged.Content != "use server sessions in cookies" {
+		t.Errorf("expected a new valid memory, got %+v", merged)
+	}
+	if merged.OriginatedAt == nil || !merged.OriginatedAt.Equal(origin) {
+		t.Errorf("expected originated_at %v, got %v", origin, merged.OriginatedAt)
+	}
+
+	got, err := store.Get(ctx, merged.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.OriginatedAt == nil || !got.OriginatedAt.Equal(origin) || fmt.Sprint(got.Tags) != "[auth]" {
+		t.Errorf("expected the stored memory to keep originated_at and tags, got %+v", got)
+	}
+	for _, id := range []int64{a.ID, b.ID} {
+		src, _ := store.Get(ctx, id)
+		if src == nil || src.IsValid || src.SupersededBy == nil || *src.SupersededBy != merged.ID {
+			t.Errorf("expected memory %d superseded by %d, got %+v", id, merged.ID, src)
+			continue
+		}
+		if src.InvalidatedAt == nil || src.InvalidationReason != "duplicates" || src.InvalidatedByName != "Ada" || src.InvalidatedByEmail != "ada@example.com" {
+			t.Errorf("expected memory %d's invalidation recorded, got %+v", id, src)
+		}
+	}
+	if found, _ := store.Search(ctx, vec(1, 0), types.SearchOpts{}); !sameIDs(found, merged.ID) {
+		t.Errorf("expected search to find only the merged memory, got %v", ids(found))
+	}
+	if lineage, _ := store.Lineage(ctx, merged.ID); !sameIDs(lineage, a.ID, b.ID, merged.ID) {
+		t.Errorf("expected the sources in the merged memory's lineage, got %v", ids(lineage))
+	}
+
+	// Without an earlier origin, the oldest source's created_at is kept
+	c := add(t, store, types.Memory{Content: "use JWT"}, nil)
+	d := add(t, store, types.Memory{Content: "use tokens"}, nil)
+	first, _ := store.Get(ctx, c.ID)
+	again, err := store.Merge(ctx, types.Merge{
+		Memory:  types.Memory{Type: types.TypeDecision, Area: "auth", Content: "use JWT tokens"},
+		Sources: []int64{d.ID, c.ID},
+	}, vec(1))
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if again.OriginatedAt == nil || !again.OriginatedAt.Equal(first.CreatedAt) {
+		t.Errorf("expected originated_at %v, got %v", first.CreatedAt, again.OriginatedAt)
+	}
+}
+
+func testMergeValidation(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	a := add(t, store, types.Memory{Content: "use sessions", Repo: "org/one"}, nil)
+	b := add(t, store, types.Memory{Content: "use cookies", Repo: "org/one"}, nil)
+	gone := add(t, store, types.Memory{Content: "use basic auth", Repo: "org/one"}, nil)
+	other := add(t, store, types.Memory{Content: "use OAuth", Repo: "org/two"}, nil)
+	missing := other.ID + 1000
+	if err := store.Invalidate(ctx, types.Invalidation{ID: gone.ID}); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+
+	merge := func(sameRepo bool, sources ...int64) error {
+		_, err := store.Merge(ctx, types.Merge{
+			Memory:   types.Memory{Type: types.TypeDecision, Area: "auth", Content: "merged", Repo: "org/one"},
+			Sources:  sources,
+			SameRepo: sameRepo,
+		}, vec(1))
+		return err
+	}
+
+	tests := []struct {
+		name     string
+		sameRepo bool
+		sources  []int64
+		want     error
+	}{
+		{"missing source", false, []int64{a.ID, missing}, types.ErrInvalidSupersede},
+		{"invalid source", false, []int64{a.ID, gone.ID}, types.ErrInvalidSupersede},
+		{"other repo", true, []int64{a.ID, other.ID}, types.ErrInvalidSupersede},
+		{"repeated source", false, []int64{a.ID, b.ID, a.ID}, types.ErrValidation},
+		{"no sources", false, nil, types.ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := merge(tt.sameRepo, tt.sources...); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	// Rejected merges add nothing and leave the sources untouched
+	all, err := store.List(ctx, types.ListOpts{IncludeInvalid: true, Limit: 100})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if !sameIDs(all, other.ID, gone.ID, b.ID, a.ID) {
+		t.Errorf("expected only the original memories, got %v", ids(all))
+	}
+	if got, _ := store.Get(ctx, a.ID); got == nil || !got.IsValid || got.SupersededBy != nil {
+		t.Errorf("expected memory %d still valid, got %+v", a.ID, got)
+	}
+
+	if err := merge(false, a.ID, other.ID); err != nil {
+		t.Errorf("sources from another repo are allowed without SameRepo, got %v", err)
+	}
+}
+
+func testSearchRanking(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	exact := add(t, store, types.Memory{Content: "exact"}, vec(1, 0, 0))
+	near := add(t, store, types.Memory{Content: "near"}, vec(0.9, 0.1, 0))
+	orthogonal := add(t, store, types.Memory{Content: "orthogonal"}, vec(0, 1, 0))
+	opposite := add(t, store, types.Memory{Content: "opposite"}, vec(-1, 0, 0))
+
+	got, err := store.Search(ctx, vec(1, 0, 0), types.SearchOpts{Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if !sameIDs(got, exact.ID, near.ID, orthogonal.ID, opposite.ID) {
+		t.Fatalf("expected nearest first, got %v", ids(got))
+	}
+
+	// Scores are cosine similarity: 1 for the same direction, 0 at right
+	// angles, -1 opposite
+	want := []float64{1, 0.9 / math.Sqrt(0.82), 0, -1}
+	for i, m := range got {
+		if math.Abs(m.SimilarityScore-want[i]) > scoreTolerance {
+			t.Errorf("memory %d (%s): score %v, want %v", m.ID, m.Content, m.SimilarityScore, want[i])
+		}
+	}
+
+	limited, err := store.Search(ctx, vec(1, 0, 0), types.SearchOpts{Limit: 2})
+	if err != nil {
+		t.Fatalf("Search with limit failed: %v", err)
+	}
+	if !sameIDs(limited, exact.ID, near.ID) {
+		t.Errorf("expected the best 2, got %v", ids(limited))
+	}
+}
+
+func testSearchFilters(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	a := add(t, store, types.Memory{Type: types.TypeDecision, Area: "auth", Content: "a", Repo: "org/one"}, vec(1, 0))
+	b := add(t, store, types.Memory{Type: types.TypeLearning, Area: "auth", Content: "b", Repo: "org/two"}, vec(1, 0.1))
+	c := add(t, store, types.Memory{Type: types.TypeDecision, Area: "db", Content: "c", Repo: "org/one"}, vec(1, 0.2))
+
+	tests := []struct {
+		name string
+		opts types.SearchOpts
+		want []int64
+	}{
+		{"type", types.SearchOpts{Type: types.TypeDecision}, []int64{a.ID, c.ID}},
+		{"area", types.SearchOpts{Area: "auth"}, []int64{a.ID, b.ID}},
+		{"repo", types.SearchOpts{Repo: "org/two"}, []int64{b.ID}},
+		{"combined", types.SearchOpts{Type: types.TypeDecision, Repo: "org/one", Area: "db"}, []int64{c.ID}},
+		{"no match", types.SearchOpts{Area: "nowhere"}, nil},
+	}
+	for _, tt := range tests {
+		got, err := store.Search(ctx, vec(1, 0), tt.opts)
+		if err != nil {
+			t.Fatalf("%s: Search failed: %v", tt.name, err)
+		}
+		if !sameIDs(got, tt.want...) {
+			t.Errorf("%s: got %v, want %v", tt.name, ids(got), tt.want)
+		}
+	}
+}
+
+func testKeywordSearch(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	both := add(t, store, types.Memory{Area: "network", Content: "Proxy drops idle sockets", Rationale: "set a proxy keepalive"}, nil)
+	one := add(t, store, types.Memory{Area: "network", Content: "Sockets need a keepalive"}, nil)
+	add(t, store, types.Memory{Area: "ui", Content: "Buttons use the brand colour"}, nil)
+	other := add(t, store, types.Memory{Area: "ops", Content: "Proxy config lives in the ops repo", Repo: "org/ops"}, nil)
+
+	got, err := store.KeywordSearch(ctx, "proxy", types.SearchOpts{Limit: 10})
+	if err != nil {
+		t.Fatalf("KeywordSearch failed: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != both.ID {
+		t.Errorf("expected %d (two hits) then %d, got %v", both.ID, other.ID, ids(got))
+	}
+
+	// Any term may match; scores are positive and best first
+	got, err = store.KeywordSearch(ctx, "keepalive brand", types.SearchOpts{Limit: 10})
+	if err != nil {
+		t.Fatalf("KeywordSearch failed: %v", err)
+	}
+	if len(got) != 3 {
+		t.Errorf("expected 3 memories matching either term, got %v", ids(got))
+	}
+	for i, m := range got {
+		if m.SimilarityScore <= 0 {
+			t.Errorf("memory %d: expected a positive score, got %v", m.ID, m.SimilarityScore)
+		}
+		if i > 0 && m.SimilarityScore > got[i-1].SimilarityScore {
+			t.Errorf("scores not descending: %v", got)
+		}
+	}
+
+	got, err = store.KeywordSearch(ctx, "keepalive", types.SearchOpts{Area: "network", Limit: 1})
+	if err != nil {
+		t.Fatalf("KeywordSearch failed: %v", err)
+	}
+	if len(got) != 1 || (got[0].ID != both.ID && got[0].ID != one.ID) {
+		t.Errorf("expected one network memory, got %v", ids(got))
+	}
+
+	got, err = store.KeywordSearch(ctx, "proxy", types.SearchOpts{Repo: "org/ops"})
+	if err != nil {
+		t.Fatalf("KeywordSearch failed: %v", err)
+	}
+	if !sameIDs(got, other.ID) {
+		t.Errorf("expected only %d for the repo filter, got %v", other.ID, ids(got))
+	}
+
+	if got, err := store.KeywordSearch(ctx, "  ", types.SearchOpts{}); err != nil || len(got) != 0 {
+		t.Errorf("expected nothing for a blank query, got %v (err %v)", ids(got), err)
+	}
+	if got, err := store.KeywordSearch(ctx, "zeppelin", types.SearchOpts{}); err != nil || len(got) != 0 {
+		t.Errorf("expected nothing for an unknown word, got %v (err %v)", ids(got), err)
+	}
+}
+
+func testUpdateAndHistory(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	mem := add(t, store, types.Memory{Area: "auth", Content: "use sessions"}, vec(1, 0))
+
+	updated, err := store.Update(ctx, types.MemoryUpdate{
+		ID: mem.ID, Type: types.TypeDecision, Area: "auth", Content: "use JWT",
+		ExpectedVersion: 1, EditorName: "Ana", EditorEmail: "ana@example.com",
+		EmbeddingModel: "test-embed",
+	}, vec(0, 1))
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Version != 2 || updated.Content != "use JWT" || updated.UpdatedAt == nil || updated.EmbeddingModel != "test-embed" {
+		t.Errorf("expected version 2 with the new content and model, got %+v", updated)
+	}
+
+	// The new vector replaces the old one
+	found, err := store.Search(ctx, vec(0, 1), types.SearchOpts{Limit: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(found) != 1 || math.Abs(found[0].SimilarityScore-1) > scoreTolerance {
+		t.Errorf("expected the replaced vector to match exactly, got %+v", found)
+	}
+
+	_, err = store.Update(ctx, types.MemoryUpdate{ID: mem.ID, Type: types.TypeDecision, Area: "auth", Content: "stale", ExpectedVersion: 1}, nil)
+	if !errors.Is(err, types.ErrVersionConflict) {
+		t.Errorf("stale Update: expected ErrVersionConflict, got %v", err)
+	}
+
+	history, err := store.History(ctx, mem.ID)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("expected 1 revision, got %d", len(history))
+	}
+	rev := history[0]
+	if rev.MemoryID != mem.ID || rev.Version != 1 || rev.Content != "use sessions" || rev.RevisedByName != "Ana" || rev.RevisedByEmail != "ana@example.com" {
+		t.Errorf("expected the replaced version, got %+v", rev)
+	}
+
+	if _, err := store.Update(ctx, types.MemoryUpdate{ID: mem.ID + 1000, Content: "x", ExpectedVersion: 1}, nil); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Update of a missing memory: expected ErrNotFound, got %v", err)
+	}
+	if _, err := store.History(ctx, mem.ID+1000); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("History of a missing memory: expected ErrNotFound, got %v", err)
+	}
+}
+
+func testTags(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	pg := add(t, store, types.Memory{Content: "Postgres gotcha", Tags: []string{"payments", "db", "db"}}, vec(1, 0))
+	pay := add(t, store, types.Memory{Content: "Refunds settle nightly", Tags: []string{"payments"}, Repo: "org/pay"}, vec(1, 0.1))
+	idx := add(t, store, types.Memory{Content: "Index foreign keys", Tags: []string{"db"}}, vec(1, 0.2))
+	plain := add(t, store, types.Memory{Content: "No tags here"}, vec(1, 0.3))
+	gone := add(t, store, types.Memory{Content: "Old payments db note", Tags: []string{"db", "legacy", "payments"}}, vec(1, 0.4))
+	if err := store.Invalidate(ctx, types.Invalidation{ID: gone.ID}); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+
+	if fmt.Sprint(pg.Tags) != "[db payments]" {
+		t.Errorf("Add: expected tags [db payments], got %v", pg.Tags)
+	}
+	got, err := store.Get(ctx, pg.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if fmt.Sprint(got.Tags) != "[db payments]" {
+		t.Errorf("Get: expected tags [db payments], got %v", got.Tags)
+	}
+	if got, _ := store.Get(ctx, plain.ID); len(got.Tags) != 0 {
+		t.Errorf("expected no tags on an untagged memory, got %v", got.Tags)
+	}
+
+	filters := []struct {
+		name string
+		tags types.TagFilter
+		want []int64
+	}{
+		{"any", types.TagFilter{Any: []string{"db", "legacy"}}, []int64{idx.ID, pg.ID}},
+		{"all", types.TagFilter{All: []string{"db", "payments"}}, []int64{pg.ID}},
+		{"all repeated", types.TagFilter{All: []string{"db", "db"}}, []int64{idx.ID, pg.ID}},
+		{"any and all", types.TagFilter{Any: []string{"db"}, All: []string{"payments"}}, []int64{pg.ID}},
+		{"unknown", types.TagFilter{Any: []string{"frontend"}}, nil},
+	}
+	for _, tt := range filters {
+		listed, err := store.List(ctx, types.ListOpts{Tags: tt.tags})
+		if err != nil {
+			t.Fatalf("%s: List failed: %v", tt.name, err)
+		}
+		if !sameIDs(listed, tt.want...) {
+			t.Errorf("%s: List got %v, want %v", tt.name, ids(listed), tt.want)
+		}
+		if len(tt.want) > 0 && len(listed) > 0 && len(listed[0].Tags) == 0 {
+			t.Errorf("%s: List returned memories without their tags", tt.name)
+		}
+
+		found, err := store.Search(ctx, vec(1, 0), types.SearchOpts{Tags: tt.tags})
+		if err != nil {
+			t.Fatalf("%s: Search failed: %v", tt.name, err)
+		}
+		want := append([]int64(nil), tt.want...)
+		for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+			want[i], want[j] = want[j], want[i]
+		}
+		if !sameIDs(found, want...) {
+			t.Errorf("%s: Search got %v, want %v", tt.name, ids(found), want)
+		}
+	}
+
+	found, err := store.KeywordSearch(ctx, "payments refunds postgres", types.SearchOpts{Tags: types.TagFilter{All: []string{"payments"}}})
+	if err != nil {
+		t.Fatalf("KeywordSearch failed: %v", err)
+	}
+	if len(found) != 2 {
+		t.Errorf("KeywordSearch: expected the 2 valid payments memories, got %v", ids(found))
+	}
+	for _, m := range found {
+		if m.ID != pg.ID && m.ID != pay.ID {
+			t.Errorf("KeywordSearch: unexpected memory %d for the tag filter", m.ID)
+		}
+	}
+
+	counts, err := store.Tags(ctx, "")
+	if err != nil {
+		t.Fatalf("Tags failed: %v", err)
+	}
+	if want := "[{db 2} {payments 2}]"; fmt.Sprint(counts) != want {
+		t.Errorf("Tags: expected %s (invalid memories excluded), got %v", want, counts)
+	}
+	counts, err = store.Tags(ctx, "org/pay")
+	if err != nil {
+		t.Fatalf("Tags failed: %v", err)
+	}
+	if want := "[{payments 1}]"; fmt.Sprint(counts) != want {
+		t.Errorf("Tags for a repo: expected %s, got %v", want, counts)
+	}
+}
+
+func testCatalog(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	empty, err := store.Catalog(ctx, types.CatalogArea, "")
+	if err != nil {
+		t.Fatalf("Catalog failed: %v", err)
+	}
+	if len(empty) != 0 {
+		t.Errorf("expected no areas, got %+v", empty)
+	}
+	if _, err := store.Catalog(ctx, "colour", ""); err == nil {
+		t.Error("expected an unknown field to be rejected")
+	}
+
+	start := time.Now().UTC().Truncate(time.Second)
+	add(t, store, types.Memory{Area: "auth", Content: "a", Repo: "org/one", AuthorName: "Ada", AuthorEmail: "ada@example.com"}, nil)
+	add(t, store, types.Memory{Area: "db", Content: "b", Repo: "org/one", AuthorName: "Bob"}, nil)
+	add(t, store, types.Memory{Area: "db", Content: "c", Repo: "org/two", AuthorName: "Ada", AuthorEmail: "ada@example.com"}, nil)
+	add(t, store, types.Memory{Area: "cache", Content: "d", Repo: "org/two"}, nil)
+	gone := add(t, store, types.Memory{Area: "legacy", Content: "e", Repo: "org/three"}, nil)
+	if err := store.Invalidate(ctx, types.Invalidation{ID: gone.ID}); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+
+	summary := func(entries []types.CatalogEntry) string {
+		var parts []string
+		for _, e := range entries {
+			parts = append(parts, fmt.Sprintf("%s/%s:%d", e.Value, e.Name, e.Count))
+		}
+		return strings.Join(parts, " ")
+	}
+	tests := []struct {
+		field types.CatalogField
+		repo  string
+		want  string
+	}{
+		// Invalid memories and empty values are left out
+		{types.CatalogRepo, "", "org/one/:2 org/two/:2"},
+		{types.CatalogArea, "", "db/:2 auth/:1 cache/:1"},
+		{types.CatalogArea, "org/two", "cache/:1 db/:1"},
+		{types.CatalogAuthor, "", "ada@example.com/Ada:2 Bob/Bob:1"},
+	}
+	for _, tt := range tests {
+		entries, err := store.Catalog(ctx, tt.field, tt.repo)
+		if err != nil {
+			t.Fatalf("Catalog(%s, %q) failed: %v", tt.field, tt.repo, err)
+		}
+		if got := summary(entries); got != tt.want {
+			t.Errorf("Catalog(%s, %q): expected %s, got %s", tt.field, tt.repo, tt.want, got)
+		}
+		for _, e := range entries {
+			if e.LastActivity.Before(start) || e.LastActivity.After(time.Now().Add(time.Second)) {
+				t.Errorf("%s: expected last activity around now, got %v", e.Value, e.LastActivity)
+			}
+		}
+	}
+}
+
+func testStats(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	empty, err := store.Stats(ctx, types.StatsOpts{})
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if empty.Total != 0 || len(empty.ByRepo) != 0 || len(empty.Growth) != 0 || empty.Period != types.PeriodMonth {
+		t.Errorf("expected empty month stats, got %+v", empty)
+	}
+
+	add(t, store, types.Memory{Area: "auth", Content: "a", Repo: "org/one", AuthorName: "Ada", AuthorEmail: "ada@example.com"}, nil)
+	add(t, store, types.Memory{Area: "auth", Content: "b", Repo: "org/one", AuthorName: "Ada", AuthorEmail: "ada@example.com"}, nil)
+	add(t, store, types.Memory{Type: types.TypeLearning, Area: "db", Content: "c", Repo: "org/one", AuthorName: "Bob"}, nil)
+	gone := add(t, store, types.Memory{Type: types.TypePattern, Area: "db", Content: "d", Repo: "org/two"}, nil)
+	if err := store.Invalidate(ctx, types.Invalidation{ID: gone.ID}); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+
+	stats, err := store.Stats(ctx, types.StatsOpts{Period: types.PeriodDay})
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Total != 4 || stats.Valid != 3 || stats.Invalid != 1 {
+		t.Errorf("expected 4 memories, 3 valid, got %+v", stats)
+	}
+	groups := []struct {
+		name string
+		got  []types.StatCount
+		want string
+	}{
+		{"repo", stats.ByRepo, "[{org/one 3 0} {org/two 0 1}]"},
+		{"area", stats.ByArea, "[{auth 2 0} {db 1 1}]"},
+		{"type", stats.ByType, "[{decision 2 0} {learning 1 0} {pattern 0 1}]"},
+		{"author", stats.ByAuthor, "[{ada@example.com 2 0} { 0 1} {Bob 1 0}]"},
+	}
+	for _, g := range groups {
+		if fmt.Sprint(g.got) != g.want {
+			t.Errorf("by %s: expected %s, got %v", g.name, g.want, g.got)
+		}
+	}
+	today := types.PeriodDay.Start(time.Now())
+	if len(stats.Growth) != 1 || !stats.Growth[0].Start.Equal(today) || stats.Growth[0].Added != 4 || stats.Growth[0].Total != 4 {
+		t.Errorf("expected today's bucket with 4 added, got %+v", stats.Growth)
+	}
+
+	one, err := store.Stats(ctx, types.StatsOpts{Repo: "org/one"})
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if one.Total != 3 || fmt.Sprint(one.ByRepo) != "[{org/one 3 0}]" || len(one.Growth) != 1 || one.Growth[0].Total != 3 {
+		t.Errorf("expected only org/one's 3 memories, got %+v", one)
+	}
+}
+
+func testExpiryAndReview(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	// Whole seconds, which every backend stores exactly
+	now := time.Now().UTC().Truncate(time.Second)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	expired := add(t, store, types.Memory{Content: "Staging runs Postgres 14", ExpiresAt: at(-time.Hour)}, nil)
+	expiresNow := add(t, store, types.Memory{Content: "Freeze ends today", ExpiresAt: at(0)}, nil)
+	later := add(t, store, types.Memory{Content: "Beta flag on until spring", ExpiresAt: at(24 * time.Hour)}, nil)
+	overdue := add(t, store, types.Memory{Content: "Check the pool size", ReviewBy: at(-48 * time.Hour)}, nil)
+	due := add(t, store, types.Memory{Content: "Recheck retry limits", ReviewBy: at(-time.Hour), Repo: "org/pay"}, nil)
+	notYet := add(t, store, types.Memory{Content: "Revisit caching", ReviewBy: at(time.Hour)}, nil)
+	gone := add(t, store, types.Memory{Content: "Old review", ReviewBy: at(-72 * time.Hour)}, nil)
+	if err := store.Invalidate(ctx, types.Invalidation{ID: gone.ID}); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+
+	got, err := store.Get(ctx, later.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.ExpiresAt == nil || !got.ExpiresAt.Equal(*later.ExpiresAt) || got.ReviewBy != nil {
+		t.Errorf("expected expires_at %v and no review_by, got %v and %v", *later.ExpiresAt, got.ExpiresAt, got.ReviewBy)
+	}
+
+	n, err := store.Expire(ctx, now)
+	if err != nil {
+		t.Fatalf("Expire failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 memories expired, got %d", n)
+	}
+	for _, id := range []int64{expired.ID, expiresNow.ID} {
+		got, _ := store.Get(ctx, id)
+		if got == nil || got.IsValid || got.InvalidationReason != types.ExpiredReason {
+			t.Errorf("expected memory %d invalidated by expiry, got %+v", id, got)
+		} else if got.InvalidatedAt == nil || !got.InvalidatedAt.Equal(now) {
+			t.Errorf("expected memory %d invalidated at %v, got %v", id, now, got.InvalidatedAt)
+		}
+	}
+	if got, _ := store.Get(ctx, later.ID); got == nil || !got.IsValid {
+		t.Error("expected a memory expiring tomorrow to stay valid")
+	}
+	if n, _ := store.Expire(ctx, now); n != 0 {
+		t.Errorf("expected a second sweep to expire nothing, got %d", n)
+	}
+
+	reviews, err := store.DueForReview(ctx, "", now, 10)
+	if err != nil {
+		t.Fatalf("DueForReview failed: %v", err)
+	}
+	if !sameIDs(reviews, overdue.ID, due.ID) {
+		t.Errorf("expected the most overdue valid memory first %v, got %v", []int64{overdue.ID, due.ID}, ids(reviews))
+	}
+	if reviews, _ := store.DueForReview(ctx, "org/pay", now, 10); !sameIDs(reviews, due.ID) {
+		t.Errorf("expected only repo org/pay's memory, got %v", ids(reviews))
+	}
+	if reviews, _ := store.DueForReview(ctx, "", now, 1); !sameIDs(reviews, overdue.ID) {
+		t.Errorf("expected limit 1 to keep the most overdue, got %v", ids(reviews))
+	}
+
+	// Reviewing pushes the date out; clearing removes it
+	updated, err := store.Update(ctx, types.MemoryUpdate{
+		ID: overdue.ID, Type: overdue.Type, Area: overdue.Area, Content: overdue.Content,
+		ExpectedVersion: 1, ReviewBy: at(30 * 24 * time.Hour),
+	}, nil)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.ReviewBy == nil || !updated.ReviewBy.Equal(now.Add(30*24*time.Hour)) {
+		t.Errorf("expected review_by moved 30 days out, got %v", updated.ReviewBy)
+	}
+	updated, err = store.Update(ctx, types.MemoryUpdate{
+		ID: notYet.ID, Type: notYet.Type, Area: notYet.Area, Content: notYet.Content, ExpectedVersion: 1,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.ReviewBy != nil {
+		t.Errorf("expected review_by cleared, got %v", updated.ReviewBy)
+	}
+	if reviews, _ := store.DueForReview(ctx, "", now.Add(2*time.Hour), 10); !sameIDs(reviews, due.ID) {
+		t.Errorf("expected only the unreviewed memory due, got %v", ids(reviews))
+	}
+}
+
+func testRetrievals(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	used := add(t, store, types.Memory{Content: "Used", Repo: "org/api"}, nil)
+	unused := add(t, store, types.Memory{Content: "Unused", Repo: "org/api"}, nil)
+	other := add(t, store, types.Memory{Content: "Other repo", Repo: "org/pay"}, nil)
+	gone := add(t, store, types.Memory{Content: "Invalidated", Repo: "org/api"}, nil)
+	if err := store.Invalidate(ctx, types.Invalidation{ID: gone.ID}); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+
+	if got, _ := store.Get(ctx, used.ID); got == nil || got.RetrievalCount != 0 || got.LastRetrievedAt != nil {
+		t.Fatalf("expected a new memory to have no retrievals, got %+v", got)
+	}
+
+	// No retrievals prove nothing until tracking started before the cutoff
+	if stale, _ := store.Unretrieved(ctx, "", now.Add(time.Hour), 10); len(stale) != 0 {
+		t.Errorf("expected nothing unretrieved before any were recorded, got %v", ids(stale))
+	}
+	if err := store.RecordRetrievals(ctx, nil, now.Add(2*time.Hour)); err != nil {
+		t.Fatalf("RecordRetrievals failed: %v", err)
+	}
+	if stale, _ := store.Unretrieved(ctx, "", now.Add(time.Hour), 10); len(stale) != 0 {
+		t.Errorf("expected memories older than tracking left out, got %v", ids(stale))
+	}
+
+	if err := store.RecordRetrievals(ctx, map[int64]int{used.ID: 2, 9999: 1}, now); err != nil {
+		t.Fatalf("RecordRetrievals failed: %v", err)
+	}
+	// An older batch adds to the count but leaves the time alone
+	if err := store.RecordRetrievals(ctx, map[int64]int{used.ID: 1}, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("RecordRetrievals failed: %v", err)
+	}
+	if err := store.RecordRetrievals(ctx, nil, now); err != nil {
+		t.Fatalf("RecordRetrievals with no counts failed: %v", err)
+	}
+
+	got, err := store.Get(ctx, used.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.RetrievalCount != 3 {
+		t.Errorf("expected 3 retrievals, got %d", got.RetrievalCount)
+	}
+	if got.LastRetrievedAt == nil || !got.LastRetrievedAt.Equal(now) {
+		t.Errorf("expected last retrieved at %v, got %v", now, got.LastRetrievedAt)
+	}
+
+	later := now.Add(time.Hour)
+	stale, err := store.Unretrieved(ctx, "", later, 10)
+	if err != nil {
+		t.Fatalf("Unretrieved failed: %v", err)
+	}
+	if !sameIDs(stale, unused.ID, other.ID) {
+		t.Errorf("expected the valid never-retrieved memories %v, got %v", []int64{unused.ID, other.ID}, ids(stale))
+	}
+	if stale, _ := store.Unretrieved(ctx, "org/pay", later, 10); !sameIDs(stale, other.ID) {
+		t.Errorf("expected only repo org/pay's memory, got %v", ids(stale))
+	}
+	if stale, _ := store.Unretrieved(ctx, "", later, 1); !sameIDs(stale, unused.ID) {
+		t.Errorf("expected limit 1 to keep the oldest, got %v", ids(stale))
+	}
+	if stale, _ := store.Unretrieved(ctx, "", now.Add(-time.Hour), 10); len(stale) != 0 {
+		t.Errorf("expected memories created after the cutoff to be left out, got %v", ids(stale))
+	}
+}
+
+func testLineage(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	supersede := func(old, by int64) {
+		t.Helper()
+		if err := store.Invalidate(ctx, types.Invalidation{ID: old, SupersededBy: &by}); err != nil {
+			t.Fatalf("Invalidate failed: %v", err)
+		}
+	}
+
+	// a and d were merged into b, which c replaced; s was replaced by c
+	// separately, so it is c's predecessor but not b's
+	a := add(t, store, types.Memory{Content: "use sessions"}, nil)
+	b := add(t, store, types.Memory{Content: "use JWT"}, nil)
+	c := add(t, store, types.Memory{Content: "use JWT with rotation"}, nil)
+	d := add(t, store, types.Memory{Content: "use cookies"}, nil)
+	s := add(t, store, types.Memory{Content: "rotate keys yearly"}, nil)
+	unrelated := add(t, store, types.Memory{Content: "use Postgres"}, nil)
+	supersede(a.ID, b.ID)
+	supersede(d.ID, b.ID)
+	supersede(b.ID, c.ID)
+	supersede(s.ID, c.ID)
+
+	lineage, err := store.Lineage(ctx, b.ID)
+	if err != nil {
+		t.Fatalf("Lineage failed: %v", err)
+	}
+	if !sameIDs(lineage, a.ID, b.ID, c.ID, d.ID) {
+		t.Errorf("expected b's lineage %v, got %v", []int64{a.ID, b.ID, c.ID, d.ID}, ids(lineage))
+	}
+	if lineage, _ := store.Lineage(ctx, c.ID); !sameIDs(lineage, a.ID, b.ID, c.ID, d.ID, s.ID) {
+		t.Errorf("expected every predecessor in c's lineage, got %v", ids(lineage))
+	}
+	if lineage, _ := store.Lineage(ctx, unrelated.ID); !sameIDs(lineage, unrelated.ID) {
+		t.Errorf("expected a lone memory, got %v", ids(lineage))
+	}
+
+	missing := unrelated.ID + 1000
+	if _, err := store.Lineage(ctx, missing); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Lineage of a missing memory: expected ErrNotFound, got %v", err)
+	}
+}
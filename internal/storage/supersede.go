@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// supersedeLink is what checkSupersede reads about a memory
+type supersedeLink struct {
+	Repo         string
+	SupersededBy *int64
+}
+
+// checkSupersede validates inv.SupersededBy as the replacement for inv.ID.
+// The replacement must exist, must not be inv.ID itself or already lead back
+// to it through superseded_by links, and with inv.SameRepo must be in the
+// same repo. Backends call it inside the transaction that invalidates, with
+// lookup returning nil for a missing memory.
+func checkSupersede(inv types.Invalidation, lookup func(id int64) (*supersedeLink, error)) error {
+	mem, err := lookup(inv.ID)
+	if err != nil {
+		return err
+	}
+	if mem == nil {
+		return types.ErrNotFound
+	}
+	if inv.SupersededBy == nil {
+		return nil
+	}
+
+	target := *inv.SupersededBy
+	if target == inv.ID {
+		return fmt.Errorf("%w: memory %d cannot supersede itself", types.ErrInvalidSupersede, inv.ID)
+	}
+	succ, err := lookup(target)
+	if err != nil {
+		return err
+	}
+	if succ == nil {
+		return fmt.Errorf("%w: memory %d does not exist", types.ErrInvalidSupersede, target)
+	}
+	if inv.SameRepo && succ.Repo != mem.Repo {
+		return fmt.Errorf("%w: memory %d is in repo %q, not %q", types.ErrInvalidSupersede, target, succ.Repo, mem.Repo)
+	}
+
+	// A loop elsewhere in the chain is not ours to reject, so stop at one
+	seen := map[int64]bool{target: true}
+	for next := succ.SupersededBy; next != nil && !seen[*next]; {
+		if *next == inv.ID {
+			return fmt.Errorf("%w: memory %d already leads back to memory %d through superseded_by",
+				types.ErrInvalidSupersede, target, inv.ID)
+		}
+		seen[*next] = true
+		link, err := lookup(*next)
+		if err != nil {
+			return err
+		}
+		if link == nil {
+			break
+		}
+		next = link.SupersededBy
+	}
+	return nil
+}
+
+// checkBulkSupersede validates bi.SupersededBy as the replacement for each
+// of ids, as checkSupersede does. The replacement must exist even when
+// nothing matched.
+func checkBulkSupersede(bi types.BulkInvalidation, ids []int64, lookup func(id int64) (*supersedeLink, error)) error {
+	if bi.SupersededBy == nil {
+		return nil
+	}
+	succ, err := lookup(*bi.SupersededBy)
+	if err != nil {
+		return err
+	}
+	if succ == nil {
+		return fmt.Errorf("%w: memory %d does not exist", types.ErrInvalidSupersede, *bi.SupersededBy)
+	}
+	for _, id := range ids {
+		inv := types.Invalidation{ID: id, SupersededBy: bi.SupersededBy, SameRepo: bi.SameRepo}
+		if err := checkSupersede(inv, lookup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
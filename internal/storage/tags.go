@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/types"
+)
+
+// uniqueTags returns tags sorted with duplicates removed, the form every
+// backend stores them in. Callers normalize case and spelling beforehand.
+func uniqueTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	out := append([]string(nil), tags...)
+	sort.Strings(out)
+	n := 1
+	for _, tag := range out[1:] {
+		if tag != out[n-1] {
+			out[n] = tag
+			n++
+		}
+	}
+	return out[:n]
+}
+
+// sortTagCounts orders counts the way Storage.Tags returns them
+func sortTagCounts(counts map[string]int) []types.TagCount {
+	tags := make([]types.TagCount, 0, len(counts))
+	for tag, n := range counts {
+		tags = append(tags, types.TagCount{Tag: tag, Count: n})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags
+}
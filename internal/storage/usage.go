@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// metaUsageTrackingSince names the metadata key holding when retrievals
+// were first counted. Memories older than that may have been used before
+// anyone was counting, so Unretrieved measures their age from it instead.
+const metaUsageTrackingSince = "usage_tracking_since"
+
+// markUsageTracked records at as the start of usage tracking unless an
+// earlier start is already recorded
+func markUsageTracked(ctx context.Context, store metadataStore, at time.Time) error {
+	since, err := usageTrackedSince(ctx, store)
+	if err != nil {
+		return err
+	}
+	if !since.IsZero() && !at.Before(since) {
+		return nil
+	}
+	if err := store.setMetadata(ctx, metaUsageTrackingSince, at.UTC().Format(time.RFC3339Nano)); err != nil {
+		return fmt.Errorf("failed to record %s: %w", metaUsageTrackingSince, err)
+	}
+	return nil
+}
+
+// usageTrackedSince returns when usage tracking started, or the zero time
+// if no retrievals have been recorded yet
+func usageTrackedSince(ctx context.Context, store metadataStore) (time.Time, error) {
+	value, err := store.getMetadata(ctx, metaUsageTrackingSince)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read %s: %w", metaUsageTrackingSince, err)
+	}
+	if value == "" {
+		return time.Time{}, nil
+	}
+	since, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: %w", metaUsageTrackingSince, value, err)
+	}
+	return since, nil
+}
+
+// usageTrackedBefore reports whether usage tracking started before before,
+// which Unretrieved needs for a memory's lack of retrievals to mean anything
+func usageTrackedBefore(ctx context.Context, store metadataStore, before time.Time) (bool, error) {
+	since, err := usageTrackedSince(ctx, store)
+	if err != nil {
+		return false, err
+	}
+	return !since.IsZero() && since.Before(before), nil
+}
@@ -0,0 +1,394 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/MereWhiplash/engram-cogitator/internal/apitypes"
+	"github.com/MereWhiplash/engram-cogitator/internal/mcptypes"
+	"github.com/MereWhiplash/engram-cogitator/internal/service"
+	"github.com/MereWhiplash/engram-cogitator/internal/storage"
+	"github.com/MereWhiplash/engram-cogitator/internal/tools"
+)
+
+// wordEmbedder hashes words into a small bag-of-words vector, so texts
+// sharing words are similar and semantic search has something to rank
+type wordEmbedder struct{}
+
+func (e *wordEmbedder) EmbedForStorage(text string) ([]float32, error) {
+	vec := make([]float32, 64)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		h := fnv.New32a()
+		h.Write([]byte(strings.Trim(word, ".,:")))
+		vec[h.Sum32()%64]++
+	}
+	return vec, nil
+}
+
+func (e *wordEmbedder) EmbedForSearch(query string) ([]float32, error) {
+	return e.EmbedForStorage(query)
+}
+
+func (e *wordEmbedder) Model() string {
+	return "word-embed"
+}
+
+// connectIntegrationClient serves the EC tools over an in-memory transport,
+// backed by the real service and the in-memory store (no CGO needed)
+func connectIntegrationClient(t *testing.T, repo string) *mcp.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+
+	store, err := storage.New(ctx, storage.Config{
+		Driver:         "memory",
+		EmbeddingModel: "word-embed",
+		EmbeddingDim:   64,
+	})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "0.0.1"}, nil)
+	svc := service.New(store, &wordEmbedder{})
+	svc.TrackUsage()
+	tools.RegisterWithRepo(server, svc, repo)
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatalf("server connect failed: %v", err)
+	}
+	t.Cleanup(func() { serverSession.Close() })
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "0.0.1"}, nil)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client connect failed: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+// callTool calls a tool and decodes its structured output into out,
+// failing the test if the call errors
+func callTool(t *testing.T, session *mcp.ClientSession, name string, args, out interface{}) {
+	t.Helper()
+
+	res, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: name, Arguments: args})
+	if err != nil {
+		t.Fatalf("%s failed: %v", name, err)
+	}
+	if res.IsError {
+		t.Fatalf("%s returned an error: %+v", name, res.Content)
+	}
+	if out == nil {
+		return
+	}
+	raw, err := json.Marshal(res.StructuredContent)
+	if err != nil {
+		t.Fatalf("%s: failed to encode output: %v", name, err)
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		t.Fatalf("%s: failed to decode output: %v", name, err)
+	}
+}
+
+func TestIntegration_Tools(t *testing.T) {
+	session := connectIntegrationClient(t, "testorg/testrepo")
+
+	var jwt, sessions mcptypes.AddOutput
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "decision", Area: "auth", Content: "Use JWT tokens for service auth"}, &jwt)
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "decision", Area: "auth", Content: "Use server sessions for the admin UI"}, &sessions)
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "learning", Area: "db", Content: "Connection pool starves under load"}, nil)
+
+	if jwt.Memory == nil || jwt.Memory.Repo != "testorg/testrepo" {
+		t.Fatalf("expected memory tagged with the session repo, got %+v", jwt.Memory)
+	}
+
+	for _, mode := range []string{"semantic", "keyword", "hybrid"} {
+		var found mcptypes.SearchOutput
+		callTool(t, session, "ec_search", mcptypes.SearchInput{Query: "JWT tokens", Mode: mode}, &found)
+		if len(found.Memories) == 0 || found.Memories[0].ID != jwt.Memory.ID {
+			t.Errorf("%s search: expected memory %d first, got %+v", mode, jwt.Memory.ID, found.Memories)
+		}
+	}
+
+	var updated mcptypes.UpdateOutput
+	callTool(t, session, "ec_update", mcptypes.UpdateInput{ID: jwt.Memory.ID, Rationale: "Stateless", ExpectedVersion: 1}, &updated)
+	if updated.Memory == nil || updated.Memory.Version != 2 || updated.Memory.Rationale != "Stateless" {
+		t.Errorf("expected version 2 with the new rationale, got %+v", updated.Memory)
+	}
+
+	callTool(t, session, "ec_invalidate", mcptypes.InvalidateInput{ID: sessions.Memory.ID, SupersededBy: jwt.Memory.ID, Reason: "JWT covers the admin UI too"}, nil)
+
+	var listed mcptypes.ListOutput
+	callTool(t, session, "ec_list", mcptypes.ListInput{Area: "auth"}, &listed)
+	if len(listed.Memories) != 1 || listed.Memories[0].ID != jwt.Memory.ID {
+		t.Errorf("expected only the valid auth memory listed, got %+v", listed.Memories)
+	}
+
+	var got mcptypes.GetOutput
+	callTool(t, session, "ec_get", mcptypes.GetInput{ID: sessions.Memory.ID}, &got)
+	if got.Memory == nil || got.Memory.IsValid || got.Memory.SupersededBy == nil || *got.Memory.SupersededBy != jwt.Memory.ID {
+		t.Errorf("expected memory superseded by %d, got %+v", jwt.Memory.ID, got.Memory)
+	}
+	if got.Memory != nil && (got.Memory.InvalidatedAt == nil || got.Memory.InvalidationReason != "JWT covers the admin UI too") {
+		t.Errorf("expected when and why it was invalidated, got %+v", got.Memory)
+	}
+
+	res, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: "ec_get", Arguments: mcptypes.GetInput{ID: 99999}})
+	if err != nil {
+		t.Fatalf("ec_get failed: %v", err)
+	}
+	if !res.IsError {
+		t.Error("expected an error result for a missing memory")
+	}
+}
+
+func TestIntegration_Tags(t *testing.T) {
+	session := connectIntegrationClient(t, "testorg/testrepo")
+
+	var pool mcptypes.AddOutput
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "learning", Area: "db", Content: "Connection pool starves under load", Tags: []string{"postgres", "infra"}}, &pool)
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "decision", Area: "deploy", Content: "Deploy with blue green releases", Tags: []string{"infra"}}, nil)
+
+	var listed mcptypes.ListOutput
+	callTool(t, session, "ec_list", mcptypes.ListInput{TagsAll: []string{"postgres"}}, &listed)
+	if len(listed.Memories) != 1 || listed.Memories[0].ID != pool.Memory.ID {
+		t.Errorf("expected only the postgres memory listed, got %+v", listed.Memories)
+	}
+
+	var tags mcptypes.TagsOutput
+	callTool(t, session, "ec_tags", mcptypes.TagsInput{}, &tags)
+	if len(tags.Tags) != 2 || tags.Tags[0].Tag != "infra" || tags.Tags[0].Count != 2 {
+		t.Errorf("expected infra used twice first, got %+v", tags.Tags)
+	}
+}
+
+func TestIntegration_Review(t *testing.T) {
+	session := connectIntegrationClient(t, "testorg/testrepo")
+
+	var pool mcptypes.AddOutput
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "learning", Area: "db", Content: "Pool maxes at 20", ReviewBy: "2020-01-01"}, &pool)
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "learning", Area: "db", Content: "Index foreign keys", ReviewBy: "2099-01-01"}, nil)
+
+	var due mcptypes.ReviewOutput
+	callTool(t, session, "ec_review_due", mcptypes.ReviewInput{}, &due)
+	if len(due.Memories) != 1 || due.Memories[0].ID != pool.Memory.ID {
+		t.Fatalf("expected only the overdue memory, got %+v", due.Memories)
+	}
+
+	var updated mcptypes.UpdateOutput
+	callTool(t, session, "ec_update", mcptypes.UpdateInput{ID: pool.Memory.ID, ReviewBy: "none"}, &updated)
+	if updated.Memory == nil || updated.Memory.ReviewBy != nil {
+		t.Errorf("expected review_by removed, got %+v", updated.Memory)
+	}
+
+	callTool(t, session, "ec_review_due", mcptypes.ReviewInput{}, &due)
+	if len(due.Memories) != 0 {
+		t.Errorf("expected nothing due, got %+v", due.Memories)
+	}
+}
+
+func TestIntegration_Stale(t *testing.T) {
+	session := connectIntegrationClient(t, "testorg/testrepo")
+
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "learning", Area: "db", Content: "Pool maxes at 20"}, nil)
+
+	// A memory added just now is too new to be stale
+	var stale mcptypes.StaleOutput
+	callTool(t, session, "ec_stale", mcptypes.StaleInput{MinAgeDays: 1}, &stale)
+	if stale.Memories == nil || len(stale.Memories) != 0 {
+		t.Errorf("expected no stale candidates, got %+v", stale.Memories)
+	}
+
+	res, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: "ec_stale", Arguments: mcptypes.StaleInput{MinAgeDays: -1}})
+	if err != nil {
+		t.Fatalf("ec_stale failed: %v", err)
+	}
+	if !res.IsError {
+		t.Error("expected an error result for a negative age")
+	}
+}
+
+func TestIntegration_Lineage(t *testing.T) {
+	session := connectIntegrationClient(t, "testorg/testrepo")
+
+	var sessions, jwt mcptypes.AddOutput
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "decision", Area: "auth", Content: "Use server sessions"}, &sessions)
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "decision", Area: "auth", Content: "Use JWT tokens"}, &jwt)
+	callTool(t, session, "ec_invalidate", mcptypes.InvalidateInput{ID: sessions.Memory.ID, SupersededBy: jwt.Memory.ID}, nil)
+
+	var out mcptypes.LineageOutput
+	callTool(t, session, "ec_lineage", mcptypes.LineageInput{ID: sessions.Memory.ID}, &out)
+	if out.Lineage == nil || len(out.Lineage.Descendants) != 1 || out.Lineage.Descendants[0].ID != jwt.Memory.ID {
+		t.Fatalf("expected memory %d as the descendant, got %+v", jwt.Memory.ID, out.Lineage)
+	}
+	if out.Lineage.Current == nil || out.Lineage.Current.ID != jwt.Memory.ID {
+		t.Errorf("expected memory %d current, got %+v", jwt.Memory.ID, out.Lineage.Current)
+	}
+
+	callTool(t, session, "ec_lineage", mcptypes.LineageInput{ID: jwt.Memory.ID}, &out)
+	if len(out.Lineage.Ancestors) != 1 || out.Lineage.Ancestors[0].ID != sessions.Memory.ID {
+		t.Errorf("expected memory %d as the ancestor, got %+v", sessions.Memory.ID, out.Lineage.Ancestors)
+	}
+}
+
+func TestIntegration_Restore(t *testing.T) {
+	session := connectIntegrationClient(t, "testorg/testrepo")
+
+	var sessions, jwt mcptypes.AddOutput
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "decision", Area: "auth", Content: "Use server sessions"}, &sessions)
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "decision", Area: "auth", Content: "Use JWT tokens"}, &jwt)
+	callTool(t, session, "ec_invalidate", mcptypes.InvalidateInput{ID: sessions.Memory.ID, SupersededBy: jwt.Memory.ID}, nil)
+
+	var restored mcptypes.RestoreOutput
+	callTool(t, session, "ec_restore", mcptypes.RestoreInput{ID: sessions.Memory.ID}, &restored)
+	if restored.Memory == nil || !restored.Memory.IsValid || restored.Memory.SupersededBy != nil || restored.Memory.RestoredAt == nil {
+		t.Fatalf("expected a restored memory with no successor, got %+v", restored.Memory)
+	}
+
+	var listed mcptypes.ListOutput
+	callTool(t, session, "ec_list", mcptypes.ListInput{Area: "auth"}, &listed)
+	if len(listed.Memories) != 2 {
+		t.Errorf("expected both auth memories listed, got %+v", listed.Memories)
+	}
+}
+
+func TestIntegration_InvalidSupersede(t *testing.T) {
+	session := connectIntegrationClient(t, "testorg/testrepo")
+
+	var mem mcptypes.AddOutput
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "decision", Area: "auth", Content: "Use server sessions"}, &mem)
+
+	res, err := session.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "ec_invalidate",
+		Arguments: mcptypes.InvalidateInput{ID: mem.Memory.ID, SupersededBy: mem.Memory.ID},
+	})
+	if err != nil {
+		t.Fatalf("ec_invalidate failed: %v", err)
+	}
+	if !res.IsError {
+		t.Fatal("expected an error result for a memory superseding itself")
+	}
+	if text := res.Content[0].(*mcp.TextContent).Text; !strings.Contains(text, "cannot supersede itself") {
+		t.Errorf("expected the reason in the error, got %q", text)
+	}
+
+	var got mcptypes.GetOutput
+	callTool(t, session, "ec_get", mcptypes.GetInput{ID: mem.Memory.ID}, &got)
+	if got.Memory == nil || !got.Memory.IsValid {
+		t.Errorf("expected the memory still valid, got %+v", got.Memory)
+	}
+}
+
+func TestIntegration_AddBatch(t *testing.T) {
+	session := connectIntegrationClient(t, "testorg/testrepo")
+
+	var added mcptypes.AddBatchOutput
+	callTool(t, session, "ec_add_batch", mcptypes.AddBatchInput{Memories: []mcptypes.AddInput{
+		{Type: "decision", Area: "auth", Content: "Use server sessions", Tags: []string{"security"}},
+		{Type: "fact", Area: "auth", Content: "Not a memory type"},
+		{Type: "learning", Area: "db", Content: "Index foreign keys"},
+	}}, &added)
+	if added.Added != 2 || len(added.Results) != 3 {
+		t.Fatalf("expected 2 of 3 memories added, got %+v", added)
+	}
+	if res := added.Results[1]; res.Memory != nil || res.Code != apitypes.CodeValidation {
+		t.Errorf("expected the invalid type rejected, got %+v", res)
+	}
+	first := added.Results[0].Memory
+	if first == nil || first.Repo != "testorg/testrepo" || len(first.Tags) != 1 {
+		t.Fatalf("expected the first memory in the session repo, got %+v", first)
+	}
+
+	var found mcptypes.SearchOutput
+	callTool(t, session, "ec_search", mcptypes.SearchInput{Query: "server sessions", Limit: 1}, &found)
+	if len(found.Memories) != 1 || found.Memories[0].ID != first.ID {
+		t.Errorf("expected search to find the batch's first memory, got %+v", found.Memories)
+	}
+}
+
+func TestIntegration_Stats(t *testing.T) {
+	session := connectIntegrationClient(t, "testorg/testrepo")
+
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "decision", Area: "auth", Content: "Use server sessions"}, nil)
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "learning", Area: "auth", Content: "Cookies need SameSite"}, nil)
+
+	var out mcptypes.StatsOutput
+	callTool(t, session, "ec_stats", mcptypes.StatsInput{Period: "day"}, &out)
+	stats := out.Stats
+	if stats == nil || stats.Total != 2 || stats.Valid != 2 || stats.Period != "day" {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if len(stats.ByRepo) != 1 || stats.ByRepo[0].Value != "testorg/testrepo" || len(stats.ByType) != 2 {
+		t.Errorf("unexpected groups: %+v", stats)
+	}
+	if len(stats.Growth) != 1 || stats.Growth[0].Added != 2 {
+		t.Errorf("expected today's growth of 2, got %+v", stats.Growth)
+	}
+}
+
+func TestIntegration_Catalog(t *testing.T) {
+	session := connectIntegrationClient(t, "testorg/testrepo")
+
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "decision", Area: "auth", Content: "Use server sessions"}, nil)
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "learning", Area: "auth", Content: "Cookies need SameSite"}, nil)
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "learning", Area: "db", Content: "Index foreign keys"}, nil)
+
+	var areas mcptypes.AreasOutput
+	callTool(t, session, "ec_areas", mcptypes.AreasInput{}, &areas)
+	if len(areas.Areas) != 2 || areas.Areas[0].Value != "auth" || areas.Areas[0].Count != 2 || areas.Areas[1].Value != "db" {
+		t.Errorf("expected auth then db, got %+v", areas.Areas)
+	}
+
+	var repos mcptypes.ReposOutput
+	callTool(t, session, "ec_repos", mcptypes.ReposInput{}, &repos)
+	if len(repos.Repos) != 1 || repos.Repos[0].Value != "testorg/testrepo" || repos.Repos[0].Count != 3 {
+		t.Errorf("unexpected repos: %+v", repos.Repos)
+	}
+
+	var authors mcptypes.AuthorsOutput
+	callTool(t, session, "ec_authors", mcptypes.AuthorsInput{}, &authors)
+	if authors.Authors == nil {
+		t.Error("expected authors to marshal as a list")
+	}
+}
+
+func TestIntegration_Merge(t *testing.T) {
+	session := connectIntegrationClient(t, "testorg/testrepo")
+
+	var sessions, cookies mcptypes.AddOutput
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "decision", Area: "auth", Content: "Use server sessions"}, &sessions)
+	callTool(t, session, "ec_add", mcptypes.AddInput{Type: "decision", Area: "auth", Content: "Keep sessions in cookies"}, &cookies)
+
+	var merged mcptypes.MergeOutput
+	callTool(t, session, "ec_merge", mcptypes.MergeInput{
+		Sources: []int64{sessions.Memory.ID, cookies.Memory.ID},
+		Type:    "decision",
+		Area:    "auth",
+		Content: "Use server sessions kept in cookies",
+		Reason:  "duplicates",
+	}, &merged)
+	if merged.Memory == nil || !merged.Memory.IsValid || merged.Memory.Repo != "testorg/testrepo" || merged.Memory.OriginatedAt == nil {
+		t.Fatalf("expected a valid merged memory in the session repo, got %+v", merged.Memory)
+	}
+
+	var lineage mcptypes.LineageOutput
+	callTool(t, session, "ec_lineage", mcptypes.LineageInput{ID: sessions.Memory.ID}, &lineage)
+	if lineage.Lineage == nil || lineage.Lineage.Current == nil || lineage.Lineage.Current.ID != merged.Memory.ID {
+		t.Errorf("expected memory %d current for the merged source, got %+v", merged.Memory.ID, lineage.Lineage)
+	}
+
+	var listed mcptypes.ListOutput
+	callTool(t, session, "ec_list", mcptypes.ListInput{Area: "auth"}, &listed)
+	if len(listed.Memories) != 1 || listed.Memories[0].ID != merged.Memory.ID {
+		t.Errorf("expected only the merged memory listed, got %+v", listed.Memories)
+	}
+}
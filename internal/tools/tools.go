@@ -2,10 +2,10 @@ package tools
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
+	"github.com/MereWhiplash/engram-cogitator/internal/apitypes"
 	"github.com/MereWhiplash/engram-cogitator/internal/mcptypes"
 	"github.com/MereWhiplash/engram-cogitator/internal/service"
 	"github.com/MereWhiplash/engram-cogitator/internal/types"
@@ -29,40 +29,105 @@ func RegisterWithRepo(server *mcp.Server, svc *service.Service, repo string) {
 	h := &Handler{svc: svc, repo: repo}
 
 	mcp.AddTool(server, mcptypes.AddTool, h.Add)
+	mcp.AddTool(server, mcptypes.AddBatchTool, h.AddBatch)
 	mcp.AddTool(server, mcptypes.SearchTool, h.Search)
+	mcp.AddTool(server, mcptypes.GetTool, h.Get)
+	mcp.AddTool(server, mcptypes.UpdateTool, h.Update)
 	mcp.AddTool(server, mcptypes.InvalidateTool, h.Invalidate)
+	mcp.AddTool(server, mcptypes.RestoreTool, h.Restore)
+	mcp.AddTool(server, mcptypes.MergeTool, h.Merge)
+	mcp.AddTool(server, mcptypes.LineageTool, h.Lineage)
 	mcp.AddTool(server, mcptypes.ListTool, h.List)
+	mcp.AddTool(server, mcptypes.TagsTool, h.Tags)
+	mcp.AddTool(server, mcptypes.ReposTool, h.Repos)
+	mcp.AddTool(server, mcptypes.AreasTool, h.Areas)
+	mcp.AddTool(server, mcptypes.AuthorsTool, h.Authors)
+	mcp.AddTool(server, mcptypes.StatsTool, h.Stats)
+	mcp.AddTool(server, mcptypes.ReviewTool, h.Review)
+	mcp.AddTool(server, mcptypes.StaleTool, h.Stale)
 }
 
 func (h *Handler) Add(ctx context.Context, req *mcp.CallToolRequest, input mcptypes.AddInput) (*mcp.CallToolResult, mcptypes.AddOutput, error) {
 	if input.Type == "" || input.Area == "" || input.Content == "" {
 		return mcptypes.ErrorResult("type, area, and content are required"), mcptypes.AddOutput{}, nil
 	}
+	expiresAt, reviewBy, err := input.Dates()
+	if err != nil {
+		return mcptypes.ErrorResult(err.Error()), mcptypes.AddOutput{}, nil
+	}
 
-	var memory *types.Memory
-	var err error
+	memory, err := h.svc.AddWithContext(ctx, service.AddParams{
+		Type:      input.Type,
+		Area:      input.Area,
+		Content:   input.Content,
+		Rationale: input.Rationale,
+		Repo:      h.repo,
+		Tags:      input.Tags,
+		ExpiresAt: expiresAt,
+		ReviewBy:  reviewBy,
+	})
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "store memory", err)), mcptypes.AddOutput{}, nil
+	}
 
-	if h.repo != "" {
-		memory, err = h.svc.AddWithContext(ctx, service.AddParams{
-			Type:      input.Type,
-			Area:      input.Area,
-			Content:   input.Content,
-			Rationale: input.Rationale,
+	result, fmtErr := mcptypes.MemoryAddedResult(memory)
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.AddOutput{}, nil
+	}
+	return result, mcptypes.AddOutput{Memory: memory}, nil
+}
+
+func (h *Handler) AddBatch(ctx context.Context, req *mcp.CallToolRequest, input mcptypes.AddBatchInput) (*mcp.CallToolResult, mcptypes.AddBatchOutput, error) {
+	if len(input.Memories) == 0 {
+		return mcptypes.ErrorResult("memories are required"), mcptypes.EmptyAddBatchOutput(), nil
+	}
+
+	out := mcptypes.AddBatchOutput{Results: make([]apitypes.BatchAddResult, len(input.Memories))}
+	var (
+		params []service.AddParams
+		index  []int // index[i] is the memory params[i] came from
+	)
+	for i, m := range input.Memories {
+		if m.Type == "" || m.Area == "" || m.Content == "" {
+			out.Results[i] = apitypes.BatchAddResult{Error: "type, area, and content are required", Code: apitypes.CodeValidation}
+			continue
+		}
+		expiresAt, reviewBy, err := m.Dates()
+		if err != nil {
+			out.Results[i] = apitypes.BatchAddResult{Error: err.Error(), Code: apitypes.CodeValidation}
+			continue
+		}
+		params = append(params, service.AddParams{
+			Type:      m.Type,
+			Area:      m.Area,
+			Content:   m.Content,
+			Rationale: m.Rationale,
 			Repo:      h.repo,
+			Tags:      m.Tags,
+			ExpiresAt: expiresAt,
+			ReviewBy:  reviewBy,
 		})
-	} else {
-		memory, err = h.svc.Add(ctx, types.MemoryType(input.Type), input.Area, input.Content, input.Rationale)
+		index = append(index, i)
 	}
 
+	added, err := h.svc.AddBatch(ctx, params)
 	if err != nil {
-		return mcptypes.ErrorResult(fmt.Sprintf("failed to store memory: %v", err)), mcptypes.AddOutput{}, nil
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "store memories", err)), mcptypes.EmptyAddBatchOutput(), nil
+	}
+	for i, res := range added {
+		if res.Err != nil {
+			out.Results[index[i]] = apitypes.BatchAddResult{Error: res.Err.Error(), Code: apitypes.ErrorCode(res.Err)}
+			continue
+		}
+		out.Results[index[i]].Memory = res.Memory
+		out.Added++
 	}
 
-	result, fmtErr := mcptypes.MemoryAddedResult(memory)
+	result, fmtErr := mcptypes.BatchAddedResult(out)
 	if fmtErr != nil {
-		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.AddOutput{}, nil
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.EmptyAddBatchOutput(), nil
 	}
-	return result, mcptypes.AddOutput{Memory: memory}, nil
+	return result, out, nil
 }
 
 func (h *Handler) Search(ctx context.Context, req *mcp.CallToolRequest, input mcptypes.SearchInput) (*mcp.CallToolResult, mcptypes.SearchOutput, error) {
@@ -72,17 +137,9 @@ func (h *Handler) Search(ctx context.Context, req *mcp.CallToolRequest, input mc
 
 	limit := mcptypes.DefaultSearchLimit(input.Limit)
 
-	var memories []types.Memory
-	var err error
-
-	if h.repo != "" {
-		memories, err = h.svc.SearchWithRepo(ctx, input.Query, limit, input.Type, input.Area, h.repo)
-	} else {
-		memories, err = h.svc.Search(ctx, input.Query, limit, types.MemoryType(input.Type), input.Area)
-	}
-
+	memories, err := h.svc.SearchWithRepo(ctx, input.Query, limit, input.Type, input.Area, h.repo, input.Mode, input.TagFilter(), input.Ranking())
 	if err != nil {
-		return mcptypes.ErrorResult(fmt.Sprintf("failed to search: %v", err)), mcptypes.EmptySearchOutput(), nil
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "search", err)), mcptypes.EmptySearchOutput(), nil
 	}
 
 	result, fmtErr := mcptypes.MemoriesResult(memories, "No matching memories found.")
@@ -95,6 +152,57 @@ func (h *Handler) Search(ctx context.Context, req *mcp.CallToolRequest, input mc
 	return result, mcptypes.SearchOutput{Memories: memories}, nil
 }
 
+func (h *Handler) Get(ctx context.Context, req *mcp.CallToolRequest, input mcptypes.GetInput) (*mcp.CallToolResult, mcptypes.GetOutput, error) {
+	if input.ID == 0 {
+		return mcptypes.ErrorResult("id is required"), mcptypes.GetOutput{}, nil
+	}
+
+	memory, err := h.svc.Get(ctx, input.ID)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(input.ID, "get memory", err)), mcptypes.GetOutput{}, nil
+	}
+
+	result, fmtErr := mcptypes.MemoryResult(memory)
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.GetOutput{}, nil
+	}
+	return result, mcptypes.GetOutput{Memory: memory}, nil
+}
+
+func (h *Handler) Update(ctx context.Context, req *mcp.CallToolRequest, input mcptypes.UpdateInput) (*mcp.CallToolResult, mcptypes.UpdateOutput, error) {
+	if input.ID == 0 {
+		return mcptypes.ErrorResult("id is required"), mcptypes.UpdateOutput{}, nil
+	}
+	if !input.HasChanges() {
+		return mcptypes.ErrorResult("at least one of type, area, content, rationale, expires_at, or review_by is required"), mcptypes.UpdateOutput{}, nil
+	}
+	expiresAt, reviewBy, err := input.DateChanges()
+	if err != nil {
+		return mcptypes.ErrorResult(err.Error()), mcptypes.UpdateOutput{}, nil
+	}
+
+	upd := input.Request()
+	memory, err := h.svc.Update(ctx, service.UpdateParams{
+		ID:              input.ID,
+		Type:            upd.Type,
+		Area:            upd.Area,
+		Content:         upd.Content,
+		Rationale:       upd.Rationale,
+		ExpiresAt:       expiresAt,
+		ReviewBy:        reviewBy,
+		ExpectedVersion: upd.ExpectedVersion,
+	})
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(input.ID, "update memory", err)), mcptypes.UpdateOutput{}, nil
+	}
+
+	result, fmtErr := mcptypes.MemoryUpdatedResult(memory)
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.UpdateOutput{}, nil
+	}
+	return result, mcptypes.UpdateOutput{Memory: memory}, nil
+}
+
 func (h *Handler) Invalidate(ctx context.Context, req *mcp.CallToolRequest, input mcptypes.InvalidateInput) (*mcp.CallToolResult, mcptypes.InvalidateOutput, error) {
 	if input.ID == 0 {
 		return mcptypes.ErrorResult("id is required"), mcptypes.InvalidateOutput{}, nil
@@ -105,36 +213,205 @@ func (h *Handler) Invalidate(ctx context.Context, req *mcp.CallToolRequest, inpu
 		supersededBy = &input.SupersededBy
 	}
 
-	if err := h.svc.Invalidate(ctx, input.ID, supersededBy); err != nil {
-		return mcptypes.ErrorResult(fmt.Sprintf("failed to invalidate: %v", err)), mcptypes.InvalidateOutput{}, nil
+	err := h.svc.Invalidate(ctx, types.Invalidation{ID: input.ID, SupersededBy: supersededBy, Reason: input.Reason})
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(input.ID, "invalidate", err)), mcptypes.InvalidateOutput{}, nil
 	}
 
 	msg := mcptypes.InvalidateMsg(input.ID, supersededBy)
 	return mcptypes.TextResult(msg), mcptypes.InvalidateOutput{Message: msg}, nil
 }
 
+func (h *Handler) Restore(ctx context.Context, req *mcp.CallToolRequest, input mcptypes.RestoreInput) (*mcp.CallToolResult, mcptypes.RestoreOutput, error) {
+	if input.ID == 0 {
+		return mcptypes.ErrorResult("id is required"), mcptypes.RestoreOutput{}, nil
+	}
+
+	memory, err := h.svc.Revalidate(ctx, types.Revalidation{ID: input.ID})
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(input.ID, "restore", err)), mcptypes.RestoreOutput{}, nil
+	}
+
+	result, fmtErr := mcptypes.RestoredResult(memory)
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.RestoreOutput{}, nil
+	}
+	return result, mcptypes.RestoreOutput{Memory: memory}, nil
+}
+
 func (h *Handler) List(ctx context.Context, req *mcp.CallToolRequest, input mcptypes.ListInput) (*mcp.CallToolResult, mcptypes.ListOutput, error) {
 	limit := mcptypes.DefaultListLimit(input.Limit)
 
-	var memories []types.Memory
-	var err error
-
-	if h.repo != "" {
-		memories, err = h.svc.ListWithRepo(ctx, limit, input.Type, input.Area, h.repo, input.IncludeInvalid, 0)
-	} else {
-		memories, err = h.svc.List(ctx, limit, types.MemoryType(input.Type), input.Area, input.IncludeInvalid)
+	var after *types.ListCursor
+	if input.Cursor != "" {
+		parsed, err := types.ParseListCursor(input.Cursor)
+		if err != nil {
+			return mcptypes.ErrorResult("invalid cursor; pass next_cursor from a previous ec_list call"), mcptypes.EmptyListOutput(), nil
+		}
+		after = parsed
 	}
 
+	// One extra memory tells us whether another page follows
+	memories, err := h.svc.ListWithRepo(ctx, limit+1, input.Type, input.Area, h.repo, input.IncludeInvalid, 0, after, input.TagFilter())
 	if err != nil {
-		return mcptypes.ErrorResult(fmt.Sprintf("failed to list: %v", err)), mcptypes.EmptyListOutput(), nil
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "list", err)), mcptypes.EmptyListOutput(), nil
 	}
 
-	result, fmtErr := mcptypes.MemoriesResult(memories, "No memories found.")
+	var nextCursor string
+	if len(memories) > limit {
+		memories = memories[:limit]
+		nextCursor = types.CursorAfter(memories[limit-1]).Encode()
+	}
+
+	result, fmtErr := mcptypes.ListResult(memories, nextCursor)
 	if fmtErr != nil {
 		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.EmptyListOutput(), nil
 	}
 	if memories == nil {
 		memories = []types.Memory{}
 	}
-	return result, mcptypes.ListOutput{Memories: memories}, nil
+	return result, mcptypes.ListOutput{Memories: memories, NextCursor: nextCursor}, nil
+}
+
+func (h *Handler) Tags(ctx context.Context, req *mcp.CallToolRequest, input mcptypes.TagsInput) (*mcp.CallToolResult, mcptypes.TagsOutput, error) {
+	tags, err := h.svc.Tags(ctx, h.repo)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "list tags", err)), mcptypes.EmptyTagsOutput(), nil
+	}
+	if tags == nil {
+		tags = []types.TagCount{}
+	}
+	return mcptypes.TagsResult(tags), mcptypes.TagsOutput{Tags: tags}, nil
+}
+
+func (h *Handler) Repos(ctx context.Context, req *mcp.CallToolRequest, _ mcptypes.ReposInput) (*mcp.CallToolResult, mcptypes.ReposOutput, error) {
+	repos, err := h.svc.Catalog(ctx, types.CatalogRepo, "")
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "list repos", err)), mcptypes.EmptyReposOutput(), nil
+	}
+	if repos == nil {
+		repos = []types.CatalogEntry{}
+	}
+	return mcptypes.CatalogResult(repos, "No repos have memories."), mcptypes.ReposOutput{Repos: repos}, nil
+}
+
+func (h *Handler) Areas(ctx context.Context, req *mcp.CallToolRequest, _ mcptypes.AreasInput) (*mcp.CallToolResult, mcptypes.AreasOutput, error) {
+	areas, err := h.svc.Catalog(ctx, types.CatalogArea, h.repo)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "list areas", err)), mcptypes.EmptyAreasOutput(), nil
+	}
+	if areas == nil {
+		areas = []types.CatalogEntry{}
+	}
+	return mcptypes.CatalogResult(areas, "No areas in use."), mcptypes.AreasOutput{Areas: areas}, nil
+}
+
+func (h *Handler) Authors(ctx context.Context, req *mcp.CallToolRequest, _ mcptypes.AuthorsInput) (*mcp.CallToolResult, mcptypes.AuthorsOutput, error) {
+	authors, err := h.svc.Catalog(ctx, types.CatalogAuthor, h.repo)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "list authors", err)), mcptypes.EmptyAuthorsOutput(), nil
+	}
+	if authors == nil {
+		authors = []types.CatalogEntry{}
+	}
+	return mcptypes.CatalogResult(authors, "No authors yet."), mcptypes.AuthorsOutput{Authors: authors}, nil
+}
+
+func (h *Handler) Stats(ctx context.Context, req *mcp.CallToolRequest, input mcptypes.StatsInput) (*mcp.CallToolResult, mcptypes.StatsOutput, error) {
+	stats, err := h.svc.Stats(ctx, h.repo, input.Period)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "count memories", err)), mcptypes.StatsOutput{}, nil
+	}
+
+	result, fmtErr := mcptypes.StatsResult(stats)
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.StatsOutput{}, nil
+	}
+	return result, mcptypes.StatsOutput{Stats: stats}, nil
+}
+
+func (h *Handler) Review(ctx context.Context, req *mcp.CallToolRequest, input mcptypes.ReviewInput) (*mcp.CallToolResult, mcptypes.ReviewOutput, error) {
+	memories, err := h.svc.DueForReview(ctx, h.repo, input.Limit)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "list memories due for review", err)), mcptypes.EmptyReviewOutput(), nil
+	}
+
+	result, fmtErr := mcptypes.MemoriesResult(memories, "No memories are due for review.")
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.EmptyReviewOutput(), nil
+	}
+	if memories == nil {
+		memories = []types.Memory{}
+	}
+	return result, mcptypes.ReviewOutput{Memories: memories}, nil
+}
+
+func (h *Handler) Stale(ctx context.Context, req *mcp.CallToolRequest, input mcptypes.StaleInput) (*mcp.CallToolResult, mcptypes.StaleOutput, error) {
+	memories, err := h.svc.StaleCandidates(ctx, h.repo, input.MinAgeDays, input.Limit)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "list stale memories", err)), mcptypes.EmptyStaleOutput(), nil
+	}
+
+	result, fmtErr := mcptypes.MemoriesResult(memories, "No stale candidates.")
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.EmptyStaleOutput(), nil
+	}
+	if memories == nil {
+		memories = []types.Memory{}
+	}
+	return result, mcptypes.StaleOutput{Memories: memories}, nil
+}
+
+func (h *Handler) Merge(ctx context.Context, req *mcp.CallToolRequest, input mcptypes.MergeInput) (*mcp.CallToolResult, mcptypes.MergeOutput, error) {
+	if len(input.Sources) == 0 {
+		return mcptypes.ErrorResult("sources are required"), mcptypes.MergeOutput{}, nil
+	}
+	if input.Type == "" || input.Area == "" || input.Content == "" {
+		return mcptypes.ErrorResult("type, area, and content are required"), mcptypes.MergeOutput{}, nil
+	}
+	expiresAt, reviewBy, err := input.Dates()
+	if err != nil {
+		return mcptypes.ErrorResult(err.Error()), mcptypes.MergeOutput{}, nil
+	}
+
+	memory, err := h.svc.Merge(ctx, service.MergeParams{
+		AddParams: service.AddParams{
+			Type:      input.Type,
+			Area:      input.Area,
+			Content:   input.Content,
+			Rationale: input.Rationale,
+			Repo:      h.repo,
+			Tags:      input.Tags,
+			ExpiresAt: expiresAt,
+			ReviewBy:  reviewBy,
+		},
+		Sources: input.Sources,
+		Reason:  input.Reason,
+	})
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(0, "merge memories", err)), mcptypes.MergeOutput{}, nil
+	}
+
+	result, fmtErr := mcptypes.MergedResult(memory, input.Sources)
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.MergeOutput{}, nil
+	}
+	return result, mcptypes.MergeOutput{Memory: memory}, nil
+}
+
+func (h *Handler) Lineage(ctx context.Context, req *mcp.CallToolRequest, input mcptypes.LineageInput) (*mcp.CallToolResult, mcptypes.LineageOutput, error) {
+	if input.ID == 0 {
+		return mcptypes.ErrorResult("id is required"), mcptypes.LineageOutput{}, nil
+	}
+
+	lineage, err := h.svc.Lineage(ctx, input.ID)
+	if err != nil {
+		return mcptypes.ErrorResult(mcptypes.ErrorMsg(input.ID, "get memory lineage", err)), mcptypes.LineageOutput{}, nil
+	}
+
+	result, fmtErr := mcptypes.LineageResult(lineage)
+	if fmtErr != nil {
+		return mcptypes.ErrorResult(fmtErr.Error()), mcptypes.LineageOutput{}, nil
+	}
+	return result, mcptypes.LineageOutput{Lineage: lineage}, nil
 }
@@ -2,7 +2,10 @@ package tools_test
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
@@ -23,6 +26,10 @@ func (m *mockEmbedder) EmbedForSearch(query string) ([]float32, error) {
 	return make([]float32, 768), nil
 }
 
+func (m *mockEmbedder) Model() string {
+	return "mock-embed"
+}
+
 // mockStorage implements storage.Storage for testing
 type mockStorage struct {
 	memories []types.Memory
@@ -33,10 +40,23 @@ func (m *mockStorage) Add(ctx context.Context, mem types.Memory, embedding []flo
 	m.nextID++
 	mem.ID = m.nextID
 	mem.IsValid = true
+	mem.Version = 1
 	m.memories = append(m.memories, mem)
 	return &mem, nil
 }
 
+func (m *mockStorage) AddBatch(ctx context.Context, mems []types.Memory, embeddings [][]float32) ([]types.Memory, error) {
+	added := make([]types.Memory, len(mems))
+	for i, mem := range mems {
+		a, err := m.Add(ctx, mem, embeddings[i])
+		if err != nil {
+			return nil, err
+		}
+		added[i] = *a
+	}
+	return added, nil
+}
+
 func (m *mockStorage) Search(ctx context.Context, embedding []float32, opts types.SearchOpts) ([]types.Memory, error) {
 	// Simple filter simulation
 	var results []types.Memory
@@ -58,6 +78,10 @@ func (m *mockStorage) Search(ctx context.Context, embedding []float32, opts type
 	return results, nil
 }
 
+func (m *mockStorage) KeywordSearch(ctx context.Context, query string, opts types.SearchOpts) ([]types.Memory, error) {
+	return nil, nil
+}
+
 func (m *mockStorage) List(ctx context.Context, opts types.ListOpts) ([]types.Memory, error) {
 	var results []types.Memory
 	for _, mem := range m.memories {
@@ -78,17 +102,148 @@ func (m *mockStorage) List(ctx context.Context, opts types.ListOpts) ([]types.Me
 	return results, nil
 }
 
-func (m *mockStorage) Invalidate(ctx context.Context, id int64, supersededBy *int64) error {
+func (m *mockStorage) Get(ctx context.Context, id int64) (*types.Memory, error) {
 	for i := range m.memories {
 		if m.memories[i].ID == id {
+			mem := m.memories[i]
+			return &mem, nil
+		}
+	}
+	return nil, types.ErrNotFound
+}
+
+func (m *mockStorage) Invalidate(ctx context.Context, inv types.Invalidation) error {
+	for i := range m.memories {
+		if m.memories[i].ID == inv.ID {
 			m.memories[i].IsValid = false
-			m.memories[i].SupersededBy = supersededBy
+			m.memories[i].SupersededBy = inv.SupersededBy
+			m.memories[i].InvalidationReason = inv.Reason
 			return nil
 		}
 	}
 	return types.ErrNotFound
 }
 
+func (m *mockStorage) InvalidateMatching(ctx context.Context, bi types.BulkInvalidation) ([]int64, error) {
+	ids := []int64{}
+	for i := range m.memories {
+		if m.memories[i].IsValid && bi.Filter.Matches(m.memories[i]) {
+			ids = append(ids, m.memories[i].ID)
+			if !bi.DryRun {
+				m.memories[i].IsValid = false
+				m.memories[i].SupersededBy = bi.SupersededBy
+				m.memories[i].InvalidationReason = bi.Reason
+			}
+		}
+	}
+	return ids, nil
+}
+
+func (m *mockStorage) Revalidate(ctx context.Context, rev types.Revalidation) (*types.Memory, error) {
+	for i := range m.memories {
+		if m.memories[i].ID == rev.ID {
+			m.memories[i].IsValid = true
+			m.memories[i].SupersededBy = nil
+			m.memories[i].RestoredByName = rev.ByName
+			m.memories[i].RestoredByEmail = rev.ByEmail
+			mem := m.memories[i]
+			return &mem, nil
+		}
+	}
+	return nil, types.ErrNotFound
+}
+
+func (m *mockStorage) Merge(ctx context.Context, mg types.Merge, embedding []float32) (*types.Memory, error) {
+	merged, err := m.Add(ctx, mg.Memory, embedding)
+	if err != nil {
+		return nil, err
+	}
+	for i := range m.memories {
+		if slices.Contains(mg.Sources, m.memories[i].ID) {
+			m.memories[i].IsValid = false
+			m.memories[i].SupersededBy = &merged.ID
+			m.memories[i].InvalidationReason = mg.Reason
+		}
+	}
+	return merged, nil
+}
+
+func (m *mockStorage) Update(ctx context.Context, upd types.MemoryUpdate, embedding []float32) (*types.Memory, error) {
+	for i := range m.memories {
+		if m.memories[i].ID == upd.ID {
+			if m.memories[i].Version != upd.ExpectedVersion {
+				return nil, types.ErrVersionConflict
+			}
+			m.memories[i].Type = upd.Type
+			m.memories[i].Area = upd.Area
+			m.memories[i].Content = upd.Content
+			m.memories[i].Rationale = upd.Rationale
+			m.memories[i].Version++
+			mem := m.memories[i]
+			return &mem, nil
+		}
+	}
+	return nil, types.ErrNotFound
+}
+
+func (m *mockStorage) History(ctx context.Context, id int64) ([]types.Revision, error) {
+	return nil, nil
+}
+
+func (m *mockStorage) Tags(ctx context.Context, repo string) ([]types.TagCount, error) {
+	return nil, nil
+}
+
+func (m *mockStorage) Catalog(ctx context.Context, field types.CatalogField, repo string) ([]types.CatalogEntry, error) {
+	return nil, nil
+}
+
+func (m *mockStorage) Stats(ctx context.Context, opts types.StatsOpts) (*types.Stats, error) {
+	stats := &types.Stats{Period: opts.Period.OrDefault()}
+	for _, mem := range m.memories {
+		if opts.Repo != "" && mem.Repo != opts.Repo {
+			continue
+		}
+		if mem.IsValid {
+			stats.Valid++
+		} else {
+			stats.Invalid++
+		}
+	}
+	stats.Total = stats.Valid + stats.Invalid
+	return stats, nil
+}
+
+func (m *mockStorage) Expire(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockStorage) DueForReview(ctx context.Context, repo string, now time.Time, limit int) ([]types.Memory, error) {
+	var due []types.Memory
+	for _, mem := range m.memories {
+		if mem.IsValid && mem.ReviewBy != nil && !mem.ReviewBy.After(now) {
+			due = append(due, mem)
+		}
+	}
+	return due, nil
+}
+
+func (m *mockStorage) RecordRetrievals(ctx context.Context, counts map[int64]int, at time.Time) error {
+	return nil
+}
+
+func (m *mockStorage) Unretrieved(ctx context.Context, repo string, before time.Time, limit int) ([]types.Memory, error) {
+	return nil, nil
+}
+
+func (m *mockStorage) Lineage(ctx context.Context, id int64) ([]types.Memory, error) {
+	mem, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return []types.Memory{*mem}, nil
+}
+
 func (m *mockStorage) Close() error {
 	return nil
 }
@@ -166,7 +321,7 @@ func TestSearch_Success(t *testing.T) {
 	_, _ = svc.Add(ctx, types.TypePattern, "api", "REST conventions", "")
 
 	// Search all
-	results, err := svc.Search(ctx, "authentication", 10, "", "")
+	results, err := svc.Search(ctx, "authentication", 10, "", "", "")
 	if err != nil {
 		t.Fatalf("Search failed: %v", err)
 	}
@@ -175,7 +330,7 @@ func TestSearch_Success(t *testing.T) {
 	}
 
 	// Search with type filter
-	results, err = svc.Search(ctx, "auth", 10, types.TypeDecision, "")
+	results, err = svc.Search(ctx, "auth", 10, types.TypeDecision, "", "")
 	if err != nil {
 		t.Fatalf("Search with type filter failed: %v", err)
 	}
@@ -184,7 +339,7 @@ func TestSearch_Success(t *testing.T) {
 	}
 
 	// Search with area filter
-	results, err = svc.Search(ctx, "patterns", 10, "", "api")
+	results, err = svc.Search(ctx, "patterns", 10, "", "api", "")
 	if err != nil {
 		t.Fatalf("Search with area filter failed: %v", err)
 	}
@@ -205,7 +360,7 @@ func TestSearch_WithLimit(t *testing.T) {
 	}
 
 	// Search with limit
-	results, err := svc.Search(ctx, "test", 3, "", "")
+	results, err := svc.Search(ctx, "test", 3, "", "", "")
 	if err != nil {
 		t.Fatalf("Search failed: %v", err)
 	}
@@ -243,6 +398,45 @@ func TestList_Success(t *testing.T) {
 	}
 }
 
+func TestGet_Success(t *testing.T) {
+	store := &mockStorage{}
+	svc := service.New(store, &mockEmbedder{})
+
+	ctx := context.Background()
+
+	mem, _ := svc.Add(ctx, types.TypeDecision, "auth", "Use JWT", "")
+
+	got, err := svc.Get(ctx, mem.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Content != "Use JWT" {
+		t.Errorf("expected content 'Use JWT', got %q", got.Content)
+	}
+
+	// Invalidated memories are still retrievable by ID
+	if err := svc.Invalidate(ctx, types.Invalidation{ID: mem.ID}); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	got, err = svc.Get(ctx, mem.ID)
+	if err != nil {
+		t.Fatalf("Get after invalidate failed: %v", err)
+	}
+	if got.IsValid {
+		t.Error("expected invalidated memory to have IsValid false")
+	}
+}
+
+func TestGet_NotFound(t *testing.T) {
+	store := &mockStorage{}
+	svc := service.New(store, &mockEmbedder{})
+
+	_, err := svc.Get(context.Background(), 42)
+	if !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("expected types.ErrNotFound, got %v", err)
+	}
+}
+
 func TestInvalidate_Success(t *testing.T) {
 	store := &mockStorage{}
 	svc := service.New(store, &mockEmbedder{})
@@ -253,7 +447,7 @@ func TestInvalidate_Success(t *testing.T) {
 	mem, _ := svc.Add(ctx, types.TypeDecision, "auth", "Old decision", "")
 
 	// Invalidate it
-	err := svc.Invalidate(ctx, mem.ID, nil)
+	err := svc.Invalidate(ctx, types.Invalidation{ID: mem.ID})
 	if err != nil {
 		t.Fatalf("Invalidate failed: %v", err)
 	}
@@ -282,7 +476,7 @@ func TestInvalidate_WithSupersededBy(t *testing.T) {
 	newMem, _ := svc.Add(ctx, types.TypeDecision, "auth", "Use JWT", "")
 
 	// Invalidate old, superseded by new
-	err := svc.Invalidate(ctx, oldMem.ID, &newMem.ID)
+	err := svc.Invalidate(ctx, types.Invalidation{ID: oldMem.ID, SupersededBy: &newMem.ID})
 	if err != nil {
 		t.Fatalf("Invalidate with supersededBy failed: %v", err)
 	}
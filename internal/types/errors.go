@@ -0,0 +1,49 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the storage backends and the service. Match them with
+// errors.Is; the API reports each with its own status and error code, and
+// the client turns those back into the same errors.
+var (
+	// ErrNotFound is returned when a memory is not found
+	ErrNotFound = errors.New("memory not found")
+
+	// ErrVersionConflict is returned when an update's expected version does not
+	// match the stored version (another writer got there first)
+	ErrVersionConflict = errors.New("memory version conflict")
+
+	// ErrInvalidSupersede is returned when a memory cannot be superseded by
+	// the given memory
+	ErrInvalidSupersede = errors.New("invalid supersede target")
+
+	// ErrValidation is matched by every ValidationError
+	ErrValidation = errors.New("invalid input")
+
+	// ErrEmbedderUnavailable is returned when the embedding model fails, so
+	// nothing could be embedded or searched by meaning
+	ErrEmbedderUnavailable = errors.New("embedder unavailable")
+)
+
+// ValidationError is an input rejected before reaching storage. It matches
+// ErrValidation with errors.Is while keeping its own message.
+type ValidationError struct {
+	msg string
+}
+
+// Invalidf returns a ValidationError with a formatted message
+func Invalidf(format string, args ...interface{}) error {
+	return &ValidationError{msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *ValidationError) Error() string {
+	return e.msg
+}
+
+// Is reports whether target is ErrValidation
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
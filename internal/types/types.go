@@ -4,14 +4,15 @@
 package types
 
 import (
-	"errors"
+	"encoding/base64"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 )
 
-// ErrNotFound is returned when a memory is not found
-var ErrNotFound = errors.New("memory not found")
-
 // MemoryType represents the type of memory entry
 type MemoryType string
 
@@ -33,7 +34,34 @@ func (t MemoryType) Valid() bool {
 // Validate returns an error if the MemoryType is invalid
 func (t MemoryType) Validate() error {
 	if !t.Valid() {
-		return fmt.Errorf("invalid memory type %q: must be decision, learning, or pattern", t)
+		return Invalidf("invalid memory type %q: must be decision, learning, or pattern", t)
+	}
+	return nil
+}
+
+// SearchMode selects how search queries are matched
+type SearchMode string
+
+const (
+	// SearchHybrid fuses keyword and semantic rankings (the default)
+	SearchHybrid   SearchMode = "hybrid"
+	SearchSemantic SearchMode = "semantic"
+	SearchKeyword  SearchMode = "keyword"
+)
+
+// Valid returns true if the SearchMode is known; empty means the default
+func (m SearchMode) Valid() bool {
+	switch m {
+	case "", SearchHybrid, SearchSemantic, SearchKeyword:
+		return true
+	}
+	return false
+}
+
+// Validate returns an error if the SearchMode is invalid
+func (m SearchMode) Validate() error {
+	if !m.Valid() {
+		return Invalidf("invalid search mode %q: must be semantic, keyword, or hybrid", m)
 	}
 	return nil
 }
@@ -48,12 +76,42 @@ type Memory struct {
 	IsValid      bool       `json:"is_valid"`
 	SupersededBy *int64     `json:"superseded_by,omitempty"`
 	CreatedAt    time.Time  `json:"created_at"`
+	// Version starts at 1 and increments on every in-place update
+	Version   int        `json:"version"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	// EmbeddingModel is the model that produced the stored vector (empty for
+	// memories written before models were tracked)
+	EmbeddingModel string `json:"embedding_model,omitempty"`
 	// Search result fields (only populated by Search, not List/Add)
 	SimilarityScore float64 `json:"similarity_score,omitempty"`
 	// Team mode fields (optional, empty for solo mode)
 	AuthorName  string `json:"author_name,omitempty"`
 	AuthorEmail string `json:"author_email,omitempty"`
 	Repo        string `json:"repo,omitempty"`
+	// Tags label a memory across areas, sorted and without duplicates
+	Tags []string `json:"tags,omitempty"`
+	// ExpiresAt, if set, is when the memory stops being true; the expiry
+	// sweeper invalidates it then
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ReviewBy, if set, is when the memory should next be checked
+	ReviewBy *time.Time `json:"review_by,omitempty"`
+	// Invalidation fields, set when the memory is invalidated
+	InvalidatedAt      *time.Time `json:"invalidated_at,omitempty"`
+	InvalidatedByName  string     `json:"invalidated_by_name,omitempty"`
+	InvalidatedByEmail string     `json:"invalidated_by_email,omitempty"`
+	InvalidationReason string     `json:"invalidation_reason,omitempty"`
+	// Restore fields, set when an invalidated memory is made valid again
+	RestoredAt      *time.Time `json:"restored_at,omitempty"`
+	RestoredByName  string     `json:"restored_by_name,omitempty"`
+	RestoredByEmail string     `json:"restored_by_email,omitempty"`
+	// OriginatedAt is set on a memory created by merging others: the earliest
+	// time any of them was first recorded
+	OriginatedAt *time.Time `json:"originated_at,omitempty"`
+	// Usage fields: how many times the memory has been returned by a search
+	// or get, and when it last was. They are written in batches, so they
+	// can trail the latest reads.
+	RetrievalCount  int        `json:"retrieval_count,omitempty"`
+	LastRetrievedAt *time.Time `json:"last_retrieved_at,omitempty"`
 }
 
 // SearchOpts configures search behavior
@@ -62,14 +120,416 @@ type SearchOpts struct {
 	Type  MemoryType
 	Area  string
 	Repo  string // team mode only
+	Tags  TagFilter
 }
 
 // ListOpts configures list behavior
 type ListOpts struct {
-	Limit          int
-	Offset         int
+	Limit  int
+	Offset int
+	// After resumes a newest-first listing just past this position, so pages
+	// don't shift when memories are added between requests
+	After          *ListCursor
 	Type           MemoryType
 	Area           string
 	Repo           string // team mode only
+	Tags           TagFilter
 	IncludeInvalid bool
 }
+
+// MemoryFilter selects memories for bulk operations. Empty fields match
+// every memory.
+type MemoryFilter struct {
+	Repo string
+	Area string
+	Type MemoryType
+	// Author matches the author's name or email
+	Author string
+	// CreatedBefore matches memories created strictly before it
+	CreatedBefore *time.Time
+}
+
+// IsZero reports whether the filter matches every memory
+func (f MemoryFilter) IsZero() bool {
+	return f.Repo == "" && f.Area == "" && f.Type == "" && f.Author == "" && f.CreatedBefore == nil
+}
+
+// Matches reports whether mem passes the filter
+func (f MemoryFilter) Matches(mem Memory) bool {
+	switch {
+	case f.Repo != "" && mem.Repo != f.Repo,
+		f.Area != "" && mem.Area != f.Area,
+		f.Type != "" && mem.Type != f.Type,
+		f.Author != "" && mem.AuthorName != f.Author && mem.AuthorEmail != f.Author,
+		f.CreatedBefore != nil && !mem.CreatedAt.Before(*f.CreatedBefore):
+		return false
+	}
+	return true
+}
+
+// TagFilter restricts results by tag. A memory matches when it has at least
+// one of Any (if set) and every one of All (if set).
+type TagFilter struct {
+	Any []string
+	All []string
+}
+
+// IsZero reports whether the filter matches every memory
+func (f TagFilter) IsZero() bool {
+	return len(f.Any) == 0 && len(f.All) == 0
+}
+
+// Matches reports whether a memory with tags passes the filter
+func (f TagFilter) Matches(tags []string) bool {
+	has := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		has[t] = true
+	}
+	for _, t := range f.All {
+		if !has[t] {
+			return false
+		}
+	}
+	if len(f.Any) == 0 {
+		return true
+	}
+	for _, t := range f.Any {
+		if has[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize returns the filter with both lists normalized by NormalizeTags
+func (f TagFilter) Normalize() (TagFilter, error) {
+	anyOf, err := NormalizeTags(f.Any)
+	if err != nil {
+		return TagFilter{}, err
+	}
+	allOf, err := NormalizeTags(f.All)
+	if err != nil {
+		return TagFilter{}, err
+	}
+	return TagFilter{Any: anyOf, All: allOf}, nil
+}
+
+// RankingOverride adjusts the server's search ranking policy for one
+// search; nil fields keep the server's settings
+type RankingOverride struct {
+	// RecencyWeight is how much recency counts against similarity, from 0
+	// to 1
+	RecencyWeight *float64
+	// RecencyHalfLifeDays replaces the half-life of every memory type
+	RecencyHalfLifeDays *float64
+	// UsageWeight is how much retrieval counts count, from 0 to 1
+	UsageWeight *float64
+}
+
+// MaxTagLength is the longest tag accepted, in bytes
+const MaxTagLength = 64
+
+// NormalizeTags trims and lowercases tags, drops empty and duplicate ones,
+// and sorts the rest. Tags may not contain whitespace or commas, since list
+// queries take them comma-separated.
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if strings.ContainsFunc(tag, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+			return nil, Invalidf("invalid tag %q: tags cannot contain spaces or commas", tag)
+		}
+		if len(tag) > MaxTagLength {
+			return nil, Invalidf("invalid tag %q: tags are at most %d characters", tag, MaxTagLength)
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// TagCount is a tag and the number of valid memories carrying it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// CatalogField is a memory field whose values Storage.Catalog lists
+type CatalogField string
+
+const (
+	CatalogRepo   CatalogField = "repo"
+	CatalogArea   CatalogField = "area"
+	CatalogAuthor CatalogField = "author"
+)
+
+// CatalogEntry is a value of a CatalogField and the valid memories having it
+type CatalogEntry struct {
+	Value string `json:"value"`
+	// Name is set on author entries, whose Value is the author's email where
+	// there is one
+	Name  string `json:"name,omitempty"`
+	Count int    `json:"count"`
+	// LastActivity is when a memory with the value was last added or updated
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// StatsPeriod is the length of the buckets Stats counts growth in
+type StatsPeriod string
+
+const (
+	PeriodDay  StatsPeriod = "day"
+	PeriodWeek StatsPeriod = "week"
+	// PeriodMonth is the default
+	PeriodMonth StatsPeriod = "month"
+)
+
+// Valid returns true if the StatsPeriod is known; empty means the default
+func (p StatsPeriod) Valid() bool {
+	switch p {
+	case "", PeriodDay, PeriodWeek, PeriodMonth:
+		return true
+	}
+	return false
+}
+
+// Validate returns an error if the StatsPeriod is invalid
+func (p StatsPeriod) Validate() error {
+	if !p.Valid() {
+		return Invalidf("invalid period %q: must be day, week, or month", p)
+	}
+	return nil
+}
+
+// OrDefault returns p, or PeriodMonth if p is empty
+func (p StatsPeriod) OrDefault() StatsPeriod {
+	if p == "" {
+		return PeriodMonth
+	}
+	return p
+}
+
+// Start returns the start of the period containing t, in UTC. Weeks start
+// on Monday.
+func (p StatsPeriod) Start(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch p.OrDefault() {
+	case PeriodDay:
+		return day
+	case PeriodWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Next returns the start of the period after the one starting at start
+func (p StatsPeriod) Next(start time.Time) time.Time {
+	switch p.OrDefault() {
+	case PeriodDay:
+		return start.AddDate(0, 0, 1)
+	case PeriodWeek:
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// StatsOpts configures Storage.Stats
+type StatsOpts struct {
+	Repo   string // restricts the stats to one repo
+	Period StatsPeriod
+}
+
+// Stats summarises the memories in a store, valid and invalid
+type Stats struct {
+	Total   int `json:"total"`
+	Valid   int `json:"valid"`
+	Invalid int `json:"invalid"`
+	// The groups are ordered most memories first, ties by value. Authors are
+	// grouped by email, or by name for memories without one.
+	ByRepo   []StatCount `json:"by_repo"`
+	ByArea   []StatCount `json:"by_area"`
+	ByType   []StatCount `json:"by_type"`
+	ByAuthor []StatCount `json:"by_author"`
+	// Growth has a bucket per Period from the first memory's to the last's,
+	// oldest first, including periods when nothing was added
+	Period StatsPeriod    `json:"period"`
+	Growth []GrowthBucket `json:"growth"`
+}
+
+// StatCount is the number of valid and invalid memories sharing a value
+type StatCount struct {
+	Value   string `json:"value"`
+	Valid   int    `json:"valid"`
+	Invalid int    `json:"invalid"`
+}
+
+// GrowthBucket counts the memories created in the period beginning at
+// Start, and all those created up to its end
+type GrowthBucket struct {
+	Start time.Time `json:"start"`
+	Added int       `json:"added"`
+	Total int       `json:"total"`
+}
+
+// ParseDate parses an RFC 3339 timestamp or a YYYY-MM-DD date, which is
+// taken as midnight UTC
+func ParseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, Invalidf("invalid date %q: use YYYY-MM-DD or RFC 3339", s)
+	}
+	return t.UTC(), nil
+}
+
+// ErrInvalidCursor is returned when a list cursor cannot be decoded
+var ErrInvalidCursor = Invalidf("invalid list cursor")
+
+// ListCursor is a position in List's (created_at, id) descending order
+type ListCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// CursorAfter returns the cursor for the page following mem
+func CursorAfter(mem Memory) ListCursor {
+	return ListCursor{CreatedAt: mem.CreatedAt, ID: mem.ID}
+}
+
+// Encode returns the cursor as an opaque URL-safe token
+func (c ListCursor) Encode() string {
+	raw := fmt.Sprintf("%d:%d", c.CreatedAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseListCursor decodes a token from ListCursor.Encode
+func ParseListCursor(token string) (*ListCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &ListCursor{CreatedAt: time.Unix(0, n).UTC()}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// MemoryUpdate describes an in-place edit applied by Storage.Update.
+// All content fields are written as given; callers merge unchanged values.
+type MemoryUpdate struct {
+	ID        int64
+	Type      MemoryType
+	Area      string
+	Content   string
+	Rationale string
+	// ExpectedVersion must equal the stored version or the update is rejected
+	// with ErrVersionConflict
+	ExpectedVersion int
+	EditorName      string
+	EditorEmail     string
+	// EmbeddingModel produced the replacement vector; ignored when the vector is kept
+	EmbeddingModel string
+	ExpiresAt      *time.Time
+	ReviewBy       *time.Time
+}
+
+// Invalidation describes a memory being marked invalid by Storage.Invalidate
+type Invalidation struct {
+	ID int64
+	// SupersededBy, if set, is recorded as the memory's successor
+	SupersededBy *int64
+	// SameRepo rejects a successor from another repo
+	SameRepo bool
+	Reason   string
+	ByName   string
+	ByEmail  string
+}
+
+// BulkInvalidation describes the memories marked invalid by
+// Storage.InvalidateMatching
+type BulkInvalidation struct {
+	// Filter selects the valid memories to invalidate
+	Filter MemoryFilter
+	// SupersededBy, if set, is recorded as every memory's successor
+	SupersededBy *int64
+	// SameRepo rejects a successor from another repo than any of the memories
+	SameRepo bool
+	Reason   string
+	ByName   string
+	ByEmail  string
+	// DryRun finds the memories and checks the successor without
+	// invalidating anything
+	DryRun bool
+}
+
+// Revalidation describes an invalidated memory being restored by
+// Storage.Revalidate
+type Revalidation struct {
+	ID      int64
+	ByName  string
+	ByEmail string
+}
+
+// Merge describes memories consolidated into a new one by Storage.Merge
+type Merge struct {
+	// Memory is the consolidated memory to add
+	Memory Memory
+	// Sources are the memories it replaces
+	Sources []int64
+	// SameRepo rejects sources from another repo than Memory's
+	SameRepo bool
+	// Reason and ByName/ByEmail are recorded on the invalidated sources
+	Reason  string
+	ByName  string
+	ByEmail string
+}
+
+// ExpiredReason is the invalidation reason Storage.Expire records
+const ExpiredReason = "expired"
+
+// Revision is a previous version of a memory, recorded when it was updated
+type Revision struct {
+	MemoryID  int64      `json:"memory_id"`
+	Version   int        `json:"version"`
+	Type      MemoryType `json:"type"`
+	Area      string     `json:"area"`
+	Content   string     `json:"content"`
+	Rationale string     `json:"rationale,omitempty"`
+	// RevisedAt is when this version was replaced, and by whom
+	RevisedAt      time.Time `json:"revised_at"`
+	RevisedByName  string    `json:"revised_by_name,omitempty"`
+	RevisedByEmail string    `json:"revised_by_email,omitempty"`
+}
+
+// Lineage is a memory's place in its supersession chain
+type Lineage struct {
+	Memory Memory `json:"memory"`
+	// Ancestors are the memories this one superseded, directly or
+	// transitively, oldest first
+	Ancestors []Memory `json:"ancestors"`
+	// Descendants follow superseded_by from the memory, in order
+	Descendants []Memory `json:"descendants"`
+	// Current is the newest valid memory among the memory and its
+	// descendants, or nil if all of them are invalid
+	Current *Memory `json:"current,omitempty"`
+	// Cycle reports that superseded_by links loop back on themselves;
+	// Descendants stops before repeating a memory
+	Cycle bool `json:"cycle,omitempty"`
+}